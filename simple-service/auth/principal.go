@@ -0,0 +1,20 @@
+package auth
+
+import "context"
+
+// Anonymous is the principal of requests that were not authenticated.
+const Anonymous = "anonymous"
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns who the request is made by, it is recorded as the actor of the changes it makes.
+func PrincipalFromContext(ctx context.Context) string {
+	if principal, ok := ctx.Value(principalKey{}).(string); ok && principal != "" {
+		return principal
+	}
+	return Anonymous
+}
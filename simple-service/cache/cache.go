@@ -0,0 +1,17 @@
+// Package cache keeps what repositories read close to the handlers, so that hot reads skip Postgres.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend stores opaque values under string keys until their TTL runs out. LRU keeps them in process,
+// a backend speaking the Redis protocol can share them between instances.
+type Backend interface {
+	// Get returns false for keys that are missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+}
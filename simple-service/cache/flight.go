@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+var errLoadPanicked = errors.New("cache load panicked")
+
+// flight collapses concurrent loads of the same key into one, the callers that joined share its result.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+func (f *flight) do(key string, load func() ([]byte, error)) (value []byte, err error, shared bool) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*call)
+	}
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		<-c.done
+		return c.value, c.err, true
+	}
+	// the callers that joined see errLoadPanicked should load panic
+	c := &call{done: make(chan struct{}), err: errLoadPanicked}
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = load()
+	return c.value, c.err, false
+}
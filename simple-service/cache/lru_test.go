@@ -0,0 +1,78 @@
+package cache_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/cache"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LRU", func() {
+	var (
+		ctx context.Context
+		now time.Time
+		lru *cache.LRU
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		now = time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+		lru = cache.NewLRU(2).WithClock(func() time.Time { return now })
+	})
+
+	get := func(key string) ([]byte, bool) {
+		value, ok, err := lru.Get(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		return value, ok
+	}
+
+	It("returns what was set until it expires", func() {
+		Expect(lru.Set(ctx, "a", []byte("1"), time.Minute)).To(Succeed())
+
+		value, ok := get("a")
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal([]byte("1")))
+
+		now = now.Add(time.Minute)
+		_, ok = get("a")
+		Expect(ok).To(BeFalse())
+		Expect(lru.Len()).To(Equal(0))
+	})
+
+	It("evicts the least recently used entry", func() {
+		Expect(lru.Set(ctx, "a", []byte("1"), time.Minute)).To(Succeed())
+		Expect(lru.Set(ctx, "b", []byte("2"), time.Minute)).To(Succeed())
+		get("a")
+		Expect(lru.Set(ctx, "c", []byte("3"), time.Minute)).To(Succeed())
+
+		_, ok := get("b")
+		Expect(ok).To(BeFalse())
+		_, ok = get("a")
+		Expect(ok).To(BeTrue())
+		_, ok = get("c")
+		Expect(ok).To(BeTrue())
+		Expect(lru.Evictions()).To(Equal(int64(1)))
+	})
+
+	It("replaces entries in place", func() {
+		Expect(lru.Set(ctx, "a", []byte("1"), time.Minute)).To(Succeed())
+		Expect(lru.Set(ctx, "a", []byte("2"), time.Minute)).To(Succeed())
+
+		value, _ := get("a")
+		Expect(value).To(Equal([]byte("2")))
+		Expect(lru.Len()).To(Equal(1))
+	})
+
+	It("deletes keys and prefixes", func() {
+		Expect(lru.Set(ctx, "resource:1", []byte("1"), time.Minute)).To(Succeed())
+		Expect(lru.Set(ctx, "webhook:1", []byte("2"), time.Minute)).To(Succeed())
+
+		Expect(lru.DeletePrefix(ctx, "resource:")).To(Succeed())
+		_, ok := get("resource:1")
+		Expect(ok).To(BeFalse())
+
+		Expect(lru.Delete(ctx, "webhook:1", "unknown")).To(Succeed())
+		Expect(lru.Len()).To(Equal(0))
+	})
+})
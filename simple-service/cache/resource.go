@@ -0,0 +1,339 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	resourcePrefix = "resource:"
+	publicIDPrefix = "resource-id:"
+)
+
+// Resource is a read-through cache in front of a handlers.ResourceRepository. It serves Read, ReadBatch and Resolve
+// from the Backend, remembers for NegativeTTL what was not found and drops the resources it changes.
+// Its own changes are dropped before they are committed, Watch drops them again once they are,
+// together with the changes of other instances.
+type Resource struct {
+	handlers.ResourceRepository
+	Backend     Backend
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	flight flight
+	// generation changes with every invalidation, loads that overlapped one do not store what they read
+	generation                     uint64
+	hits, misses, shared, failures int64
+}
+
+// Stats count the lookups of Read, ReadBatch and Resolve.
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Shared counts the misses that waited for a concurrent load of the same key rather than reading themselves.
+	Shared int64 `json:"shared"`
+	// Errors counts the failed Backend calls, lookups fall back to the repository when the Backend fails.
+	Errors int64 `json:"errors"`
+}
+
+func NewResource(repository handlers.ResourceRepository, backend Backend) *Resource {
+	return &Resource{ResourceRepository: repository, Backend: backend, TTL: time.Minute, NegativeTTL: 5 * time.Second}
+}
+
+// cachedResource keeps the internal IDs the JSON of entities.Resource leaves out.
+type cachedResource struct {
+	entities.Resource
+	ID       int  `json:"internal_id"`
+	ParentID *int `json:"internal_parent_id"`
+}
+
+// Read is not cached within transactions, they may lock the resource or have changed it already.
+func (r *Resource) Read(ctx context.Context, id int) (*entities.Resource, error) {
+	if _, ok := database.TxFromContext(ctx); ok {
+		return r.ResourceRepository.Read(ctx, id)
+	}
+	value, err := r.readThrough(ctx, resourceKey(ctx, id), func() (interface{}, error) {
+		// a replica may not have replayed the change whose notification dropped the entry yet
+		resource, err := r.ResourceRepository.Read(database.WithPrimary(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		return newCachedResource(*resource), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeCachedResource(value)
+}
+
+// batchReader is implemented by repositories.Resource.
+type batchReader interface {
+	ReadBatch(ctx context.Context, ids []int) ([]entities.Resource, error)
+}
+
+// ReadBatch serves the cached resources of ids and reads the others in one batch, the ones that are
+// missing are left out. Unlike those of Read, the misses of concurrent batches are not collapsed.
+func (r *Resource) ReadBatch(ctx context.Context, ids []int) ([]entities.Resource, error) {
+	repository, ok := r.ResourceRepository.(batchReader)
+	if !ok {
+		return r.readEach(ctx, ids)
+	}
+	if _, ok := database.TxFromContext(ctx); ok {
+		return repository.ReadBatch(ctx, ids)
+	}
+	resources := make([]entities.Resource, 0, len(ids))
+	var misses []int
+	for _, id := range ids {
+		value, ok, err := r.Backend.Get(ctx, resourceKey(ctx, id))
+		if err != nil {
+			atomic.AddInt64(&r.failures, 1)
+		}
+		if !ok {
+			atomic.AddInt64(&r.misses, 1)
+			misses = append(misses, id)
+			continue
+		}
+		atomic.AddInt64(&r.hits, 1)
+		if len(value) == 0 {
+			continue
+		}
+		resource, err := decodeCachedResource(value)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, *resource)
+	}
+	if len(misses) == 0 {
+		return resources, nil
+	}
+	generation := atomic.LoadUint64(&r.generation)
+	loaded, err := repository.ReadBatch(database.WithPrimary(ctx), misses)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[int][]byte, len(misses))
+	for _, resource := range loaded {
+		if values[resource.ID], err = json.Marshal(newCachedResource(resource)); err != nil {
+			return nil, err
+		}
+	}
+	resources = append(resources, loaded...)
+	if generation != atomic.LoadUint64(&r.generation) {
+		return resources, nil
+	}
+	for _, id := range misses {
+		value, ttl := values[id], r.TTL
+		if value == nil {
+			value, ttl = []byte{}, r.NegativeTTL
+		}
+		if err = r.Backend.Set(ctx, resourceKey(ctx, id), value, ttl); err != nil {
+			atomic.AddInt64(&r.failures, 1)
+		}
+	}
+	return resources, nil
+}
+
+// readEach reads ids through Read when the repository cannot read them in one batch.
+func (r *Resource) readEach(ctx context.Context, ids []int) ([]entities.Resource, error) {
+	resources := make([]entities.Resource, 0, len(ids))
+	for _, id := range ids {
+		resource, err := r.Read(ctx, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, *resource)
+	}
+	return resources, nil
+}
+
+// Resolve is cached everywhere, as public IDs never change hands.
+func (r *Resource) Resolve(ctx context.Context, publicID string) (int, error) {
+	value, err := r.readThrough(ctx, publicIDPrefix+scope(ctx)+publicID, func() (interface{}, error) {
+		return r.ResourceRepository.Resolve(ctx, publicID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = json.Unmarshal(value, &id)
+	return id, err
+}
+
+func (r *Resource) Create(ctx context.Context, newResource entities.Resource) (*entities.Resource, error) {
+	created, err := r.ResourceRepository.Create(ctx, newResource)
+	if err == nil {
+		// a lookup by its integer ID may have been cached as not found
+		r.invalidate(ctx, created.ID)
+	}
+	return created, err
+}
+
+func (r *Resource) Update(ctx context.Context, id int, resource entities.Resource) error {
+	defer r.invalidate(ctx, id)
+	return r.ResourceRepository.Update(ctx, id, resource)
+}
+
+func (r *Resource) Delete(ctx context.Context, id int) error {
+	defer r.invalidate(ctx, id)
+	return r.ResourceRepository.Delete(ctx, id)
+}
+
+// DeleteTree drops every resource when the policy changes the children too, their IDs are not known here.
+func (r *Resource) DeleteTree(ctx context.Context, id int, policy entities.DeletePolicy) error {
+	if policy == entities.DeleteCascade || policy == entities.DeleteOrphan {
+		defer r.invalidateAll(ctx)
+	} else {
+		defer r.invalidate(ctx, id)
+	}
+	return r.ResourceRepository.DeleteTree(ctx, id, policy)
+}
+
+func (r *Resource) Restore(ctx context.Context, id int) (*entities.Resource, error) {
+	defer r.invalidate(ctx, id)
+	return r.ResourceRepository.Restore(ctx, id)
+}
+
+func (r *Resource) Revert(ctx context.Context, id, revision int) (*entities.Resource, error) {
+	defer r.invalidate(ctx, id)
+	return r.ResourceRepository.Revert(ctx, id, revision)
+}
+
+func (r *Resource) Transition(ctx context.Context, id int, from, to, reason string) (*entities.Resource, error) {
+	defer r.invalidate(ctx, id)
+	return r.ResourceRepository.Transition(ctx, id, from, to, reason)
+}
+
+func (r *Resource) Move(ctx context.Context, id int, parentID *int) (*entities.Resource, error) {
+	defer r.invalidate(ctx, id)
+	return r.ResourceRepository.Move(ctx, id, parentID)
+}
+
+// Watch drops the resources named by events until they are closed or ctx is cancelled.
+// Subscribed to the database.Listener, it sees every committed change, whichever instance made it.
+func (r *Resource) Watch(ctx context.Context, events <-chan database.ChangeEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Op == database.ChangeResync {
+				r.invalidateAll(ctx)
+			} else if tenant, ok := tenancy.FromSchema(event.Schema); ok {
+				r.invalidate(tenancy.WithTenant(ctx, tenant), event.ID)
+			} else {
+				r.invalidate(ctx, event.ID)
+			}
+		}
+	}
+}
+
+func (r *Resource) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadInt64(&r.hits),
+		Misses: atomic.LoadInt64(&r.misses),
+		Shared: atomic.LoadInt64(&r.shared),
+		Errors: atomic.LoadInt64(&r.failures),
+	}
+}
+
+// readThrough returns the value cached under key, or else stores what load returns as JSON.
+// An empty value stands for pgx.ErrNoRows, other errors of load are not cached.
+func (r *Resource) readThrough(ctx context.Context, key string, load func() (interface{}, error)) ([]byte, error) {
+	value, ok, err := r.Backend.Get(ctx, key)
+	if err != nil {
+		atomic.AddInt64(&r.failures, 1)
+	}
+	if ok {
+		atomic.AddInt64(&r.hits, 1)
+	} else {
+		atomic.AddInt64(&r.misses, 1)
+		var shared bool
+		value, err, shared = r.flight.do(key, func() ([]byte, error) {
+			generation := atomic.LoadUint64(&r.generation)
+			loaded, err := load()
+			value, ttl := []byte{}, r.NegativeTTL
+			if err == nil {
+				if value, err = json.Marshal(loaded); err != nil {
+					return nil, err
+				}
+				ttl = r.TTL
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				return nil, err
+			}
+			if generation == atomic.LoadUint64(&r.generation) {
+				if err = r.Backend.Set(ctx, key, value, ttl); err != nil {
+					atomic.AddInt64(&r.failures, 1)
+				}
+			}
+			return value, nil
+		})
+		if shared {
+			atomic.AddInt64(&r.shared, 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(value) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return value, nil
+}
+
+// invalidate drops the resource, failures are only counted as the change it follows has been made.
+func (r *Resource) invalidate(ctx context.Context, id int) {
+	atomic.AddUint64(&r.generation, 1)
+	if err := r.Backend.Delete(ctx, resourceKey(ctx, id)); err != nil {
+		atomic.AddInt64(&r.failures, 1)
+	}
+}
+
+// invalidateAll drops every resource of every tenant, the public IDs they are resolved from stay.
+func (r *Resource) invalidateAll(ctx context.Context) {
+	atomic.AddUint64(&r.generation, 1)
+	if err := r.Backend.DeletePrefix(ctx, resourcePrefix); err != nil {
+		atomic.AddInt64(&r.failures, 1)
+	}
+}
+
+func newCachedResource(resource entities.Resource) cachedResource {
+	return cachedResource{Resource: resource, ID: resource.ID, ParentID: resource.ParentID}
+}
+
+func decodeCachedResource(value []byte) (*entities.Resource, error) {
+	var cached cachedResource
+	if err := json.Unmarshal(value, &cached); err != nil {
+		return nil, err
+	}
+	resource := cached.Resource
+	resource.ID, resource.ParentID = cached.ID, cached.ParentID
+	return &resource, nil
+}
+
+func resourceKey(ctx context.Context, id int) string {
+	return resourcePrefix + scope(ctx) + strconv.Itoa(id)
+}
+
+// scope keeps the entries of tenants apart, as resources only have unique IDs within the schema of their tenant.
+func scope(ctx context.Context) string {
+	if tenant, ok := tenancy.TenantFromContext(ctx); ok {
+		return tenant + "/"
+	}
+	return ""
+}
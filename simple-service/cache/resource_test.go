@@ -0,0 +1,385 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/cache"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	databasemocks "github.com/addme96/simple-go-service/simple-service/database/mocks"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+// failingBackend fails every call, the way an unreachable remote backend does.
+type failingBackend struct{}
+
+var errBackend = errors.New("backend unavailable")
+
+func (failingBackend) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errBackend
+}
+
+func (failingBackend) Set(context.Context, string, []byte, time.Duration) error {
+	return errBackend
+}
+
+func (failingBackend) Delete(context.Context, ...string) error {
+	return errBackend
+}
+
+func (failingBackend) DeletePrefix(context.Context, string) error {
+	return errBackend
+}
+
+// batchRepository adds ReadBatch to the mock, recording the IDs of every batch.
+type batchRepository struct {
+	*mocks.MockResourceRepository
+	resources map[int]entities.Resource
+	batches   [][]int
+}
+
+func (b *batchRepository) ReadBatch(_ context.Context, ids []int) ([]entities.Resource, error) {
+	b.batches = append(b.batches, ids)
+	var found []entities.Resource
+	for _, id := range ids {
+		if resource, ok := b.resources[id]; ok {
+			found = append(found, resource)
+		}
+	}
+	return found, nil
+}
+
+var _ = Describe("Resource", func() {
+	var (
+		ctx      context.Context
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		cached   *cache.Resource
+	)
+	parentID := 7
+	resource := &entities.Resource{ID: 123, PublicID: "00000000-0000-7000-8000-000000000123", Name: "Cached",
+		Attributes: map[string]interface{}{"size": 3.0}, Labels: map[string]string{"env": "prod"}, Status: "active",
+		ParentID: &parentID, ParentPublicID: "00000000-0000-7000-8000-000000000007"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		cached = cache.NewResource(mockRepo, cache.NewLRU(10))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("Read", func() {
+		It("reads the resource once", func() {
+			By("arranging")
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(resource, nil)
+
+			By("acting")
+			first, err := cached.Read(ctx, 123)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := cached.Read(ctx, 123)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).To(Equal(resource))
+			Expect(second).To(Equal(resource))
+			Expect(cached.Stats()).To(Equal(cache.Stats{Hits: 1, Misses: 1}))
+		})
+
+		It("remembers resources that are not found", func() {
+			By("arranging")
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			_, err := cached.Read(ctx, 123)
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			read, err := cached.Read(ctx, 123)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(read).To(BeNil())
+		})
+
+		It("does not remember failures", func() {
+			By("arranging")
+			gomock.InOrder(
+				mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(nil, errors.New("some err")),
+				mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(resource, nil),
+			)
+
+			By("acting")
+			_, err := cached.Read(ctx, 123)
+			Expect(err).To(HaveOccurred())
+			read, err := cached.Read(ctx, 123)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(resource))
+		})
+
+		It("collapses concurrent misses into one read", func() {
+			By("arranging")
+			release := make(chan struct{})
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).DoAndReturn(func(context.Context, int) (*entities.Resource, error) {
+				<-release
+				return resource, nil
+			})
+
+			By("acting")
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					read, err := cached.Read(ctx, 123)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(read).To(Equal(resource))
+				}()
+			}
+			Eventually(func() int64 { return cached.Stats().Misses }).Should(Equal(int64(5)))
+			// the misses are counted just before they join the read
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			By("asserting")
+			Expect(cached.Stats().Shared).To(Equal(int64(4)))
+		})
+
+		It("reads through within transactions", func() {
+			By("arranging")
+			mockPgx := databasemocks.NewMockPgx(mockCtrl)
+			mockConn, _ := pgxmock.NewConn()
+			mockPgx.EXPECT().Connect(ctx, "dbURL").Return(mockConn, nil)
+			mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(2).Return(resource, nil)
+
+			By("acting")
+			err := database.NewTxManager(database.NewDB(mockPgx, "dbURL"), pgx.ReadCommitted).WithinTx(ctx, func(ctx context.Context) error {
+				for i := 0; i < 2; i++ {
+					if _, err := cached.Read(ctx, 123); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cached.Stats()).To(Equal(cache.Stats{}))
+		})
+
+		It("falls back to the repository when the backend fails", func() {
+			By("arranging")
+			cached = cache.NewResource(mockRepo, failingBackend{})
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(2).Return(resource, nil)
+
+			By("acting")
+			for i := 0; i < 2; i++ {
+				read, err := cached.Read(ctx, 123)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(read).To(Equal(resource))
+			}
+
+			By("asserting")
+			Expect(cached.Stats().Errors).To(Equal(int64(4)))
+		})
+	})
+
+	Context("ReadBatch", func() {
+		var repository *batchRepository
+
+		BeforeEach(func() {
+			repository = &batchRepository{MockResourceRepository: mockRepo, resources: map[int]entities.Resource{123: *resource}}
+			cached = cache.NewResource(repository, cache.NewLRU(10))
+		})
+
+		It("reads the misses in one batch and serves the hits from the cache", func() {
+			By("acting")
+			first, err := cached.ReadBatch(ctx, []int{123, 124})
+			Expect(err).NotTo(HaveOccurred())
+			second, err := cached.ReadBatch(ctx, []int{123, 124, 125})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).To(Equal([]entities.Resource{*resource}))
+			Expect(second).To(Equal([]entities.Resource{*resource}))
+			Expect(repository.batches).To(Equal([][]int{{123, 124}, {125}}))
+			Expect(cached.Stats()).To(Equal(cache.Stats{Hits: 2, Misses: 3}))
+		})
+
+		It("shares its entries with Read", func() {
+			By("arranging")
+			mockRepo.EXPECT().Read(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			_, err := cached.ReadBatch(ctx, []int{123})
+			Expect(err).NotTo(HaveOccurred())
+			read, err := cached.Read(ctx, 123)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(resource))
+		})
+
+		It("reads one resource at a time from repositories that cannot batch", func() {
+			By("arranging")
+			cached = cache.NewResource(mockRepo, cache.NewLRU(10))
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(resource, nil)
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 124).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			resources, err := cached.ReadBatch(ctx, []int{123, 124})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(Equal([]entities.Resource{*resource}))
+		})
+	})
+
+	Context("Resolve", func() {
+		It("resolves the public ID once", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(ctx, resource.PublicID).Times(1).Return(123, nil)
+
+			By("acting")
+			_, err := cached.Resolve(ctx, resource.PublicID)
+			Expect(err).NotTo(HaveOccurred())
+			id, err := cached.Resolve(ctx, resource.PublicID)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(123))
+		})
+
+		It("remembers public IDs that are not found for NegativeTTL", func() {
+			By("arranging")
+			cached.NegativeTTL = time.Millisecond
+			mockRepo.EXPECT().Resolve(ctx, resource.PublicID).Times(2).Return(0, pgx.ErrNoRows)
+
+			By("acting")
+			for i := 0; i < 2; i++ {
+				_, err := cached.Resolve(ctx, resource.PublicID)
+				Expect(err).To(Equal(pgx.ErrNoRows))
+			}
+			time.Sleep(2 * time.Millisecond)
+			_, err := cached.Resolve(ctx, resource.PublicID)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(cached.Stats()).To(Equal(cache.Stats{Hits: 1, Misses: 2}))
+		})
+	})
+
+	Context("invalidation", func() {
+		BeforeEach(func() {
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(2).Return(resource, nil)
+			_, err := cached.Read(ctx, 123)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			_, err := cached.Read(ctx, 123)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("drops updated resources", func() {
+			mockRepo.EXPECT().Update(ctx, 123, *resource).Times(1).Return(nil)
+			Expect(cached.Update(ctx, 123, *resource)).To(Succeed())
+		})
+
+		It("drops deleted resources", func() {
+			mockRepo.EXPECT().Delete(ctx, 123).Times(1).Return(nil)
+			Expect(cached.Delete(ctx, 123)).To(Succeed())
+		})
+
+		It("drops resources whose change failed", func() {
+			mockRepo.EXPECT().Transition(ctx, 123, "active", "archived", "").Times(1).Return(nil, errors.New("some err"))
+			_, err := cached.Transition(ctx, 123, "active", "archived", "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("drops moved resources", func() {
+			mockRepo.EXPECT().Move(ctx, 123, nil).Times(1).Return(resource, nil)
+			_, err := cached.Move(ctx, 123, nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("drops every resource when deleting a subtree", func() {
+			mockRepo.EXPECT().DeleteTree(ctx, 7, entities.DeleteCascade).Times(1).Return(nil)
+			Expect(cached.DeleteTree(ctx, 7, entities.DeleteCascade)).To(Succeed())
+		})
+
+		It("drops the resources announced by the listener", func() {
+			events := make(chan database.ChangeEvent, 1)
+			events <- database.ChangeEvent{Op: database.ChangeUpdate, ID: 123}
+			close(events)
+			cached.Watch(ctx, events)
+		})
+
+		It("drops every resource on resync", func() {
+			events := make(chan database.ChangeEvent, 1)
+			events <- database.ChangeEvent{Op: database.ChangeResync}
+			close(events)
+			cached.Watch(ctx, events)
+		})
+	})
+
+	Context("tenants", func() {
+		It("keeps the resources of tenants apart", func() {
+			By("arranging")
+			acme := tenancy.WithTenant(ctx, "acme")
+			other := &entities.Resource{ID: 123, PublicID: "00000000-0000-7000-8000-000000000999", Name: "Other"}
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(resource, nil)
+			mockRepo.EXPECT().Read(database.WithPrimary(acme), 123).Times(1).Return(other, nil)
+
+			By("acting")
+			first, err := cached.Read(ctx, 123)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := cached.Read(acme, 123)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Name).To(Equal("Cached"))
+			Expect(second.Name).To(Equal("Other"))
+		})
+
+		It("drops the resources announced in the schema of a tenant for that tenant only", func() {
+			By("arranging")
+			acme := tenancy.WithTenant(ctx, "acme")
+			mockRepo.EXPECT().Read(database.WithPrimary(ctx), 123).Times(1).Return(resource, nil)
+			mockRepo.EXPECT().Read(database.WithPrimary(acme), 123).Times(2).Return(resource, nil)
+			for _, readCtx := range []context.Context{ctx, acme} {
+				_, err := cached.Read(readCtx, 123)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			events := make(chan database.ChangeEvent, 1)
+			events <- database.ChangeEvent{Op: database.ChangeUpdate, ID: 123, Schema: tenancy.Schema("acme")}
+			close(events)
+
+			By("acting")
+			cached.Watch(ctx, events)
+
+			By("asserting")
+			for _, readCtx := range []context.Context{ctx, acme} {
+				_, err := cached.Read(readCtx, 123)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	})
+})
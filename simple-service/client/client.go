@@ -0,0 +1,145 @@
+// Package client calls the resources API of the service over HTTP.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+)
+
+// Client makes the requests of one caller, it is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	auth       func(request *http.Request) error
+	retry      database.RetryPolicy
+}
+
+type Option func(*Client)
+
+// New returns a client of the service at baseURL, such as https://resources.example.com.
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		auth:       func(*http.Request) error { return nil },
+		retry:      database.DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAuth authenticates every attempt of every request with auth, which is called again on
+// retries so that it can refresh expired credentials.
+func WithAuth(auth func(request *http.Request) error) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithBearerToken identifies the principal the changes are recorded for.
+func WithBearerToken(token string) Option {
+	return WithAuth(func(request *http.Request) error {
+		request.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// WithRetryPolicy replaces database.DefaultRetryPolicy, a policy with no retries turns them off.
+func WithRetryPolicy(policy database.RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// do sends the request until it succeeds, fails for good or runs out of retries, and decodes the
+// response into result unless it is nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return c.retry.Do(ctx, retryable(ctx, method), func() error {
+		return c.attempt(ctx, method, target, payload, result)
+	})
+}
+
+func (c *Client) attempt(ctx context.Context, method, target string, payload []byte, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if err = c.auth(request); err != nil {
+		return err
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	bytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return newError(response, bytes)
+	}
+	if result == nil || len(bytes) == 0 {
+		return nil
+	}
+	return json.Unmarshal(bytes, result)
+}
+
+// retryable retries requests that failed without an effect: responses of the status Transactional
+// rolls back with and gateways respond with when the service is down, and connections that were refused. Requests that may have been served, because
+// their connection broke or a gateway in front of the service gave up on them, are only sent again
+// when sending them twice makes no difference, which POST does not guarantee.
+func retryable(ctx context.Context, method string) func(error) bool {
+	return func(err error) bool {
+		var apiError *Error
+		if errors.As(err, &apiError) {
+			switch apiError.StatusCode {
+			case http.StatusServiceUnavailable:
+				return true
+			case http.StatusBadGateway, http.StatusGatewayTimeout:
+				return method != http.MethodPost
+			}
+			return false
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		var urlError *url.Error
+		if !errors.As(err, &urlError) {
+			return false
+		}
+		return method != http.MethodPost || errors.Is(err, syscall.ECONNREFUSED)
+	}
+}
@@ -0,0 +1,254 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/client"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		respond  func(w http.ResponseWriter, attempt int)
+		server   *httptest.Server
+		c        *client.Client
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests = append(requests, r)
+			attempt := len(requests)
+			mu.Unlock()
+			respond(w, attempt)
+		}))
+		c = client.New(server.URL+"/", client.WithRetryPolicy(database.RetryPolicy{MaxRetries: 2}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("retries unavailable services", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, attempt int) {
+			if attempt < 3 {
+				http.Error(w, "breaker is open", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "a"}`))
+		}
+
+		By("acting")
+		id, err := c.Create(context.Background(), entities.Resource{Name: "a", Status: "ignored"})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal("a"))
+		Expect(requests).To(HaveLen(3))
+		for _, request := range requests {
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+		}
+	})
+
+	It("gives up after the retries of the policy", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, _ int) {
+			w.Header().Set("Retry-After", "2")
+			http.Error(w, "breaker is open", http.StatusServiceUnavailable)
+		}
+
+		By("acting")
+		_, err := c.Get(context.Background(), "a")
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrUnavailable)).To(BeTrue())
+		var apiError *client.Error
+		Expect(errors.As(err, &apiError)).To(BeTrue())
+		Expect(apiError.RetryAfter).To(Equal(2 * time.Second))
+		Expect(apiError.Message).To(Equal("breaker is open"))
+		Expect(requests).To(HaveLen(3))
+	})
+
+	It("does not retry requests the service rejected", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, _ int) {
+			http.Error(w, "resource not found", http.StatusNotFound)
+		}
+
+		By("acting")
+		_, err := c.Get(context.Background(), "a")
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrNotFound)).To(BeTrue())
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("does not retry creations whose connection broke, as they may have been made", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, _ int) {
+			connection, _, err := w.(http.Hijacker).Hijack()
+			Expect(err).NotTo(HaveOccurred())
+			connection.Close()
+		}
+
+		By("acting")
+		_, err := c.Create(context.Background(), entities.Resource{Name: "a"})
+
+		By("asserting")
+		Expect(err).To(HaveOccurred())
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("does not retry creations a gateway gave up on, as they may have been made", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, _ int) {
+			http.Error(w, "upstream timed out", http.StatusGatewayTimeout)
+		}
+
+		By("acting")
+		_, err := c.Create(context.Background(), entities.Resource{Name: "a"})
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrServer)).To(BeTrue())
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("retries reads whose connection broke", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, attempt int) {
+			if attempt == 1 {
+				connection, _, _ := w.(http.Hijacker).Hijack()
+				connection.Close()
+				return
+			}
+			w.Write([]byte(`{"id": "a", "name": "b"}`))
+		}
+
+		By("acting")
+		resource, err := c.Get(context.Background(), "a")
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resource.Name).To(Equal("b"))
+		Expect(requests).To(HaveLen(2))
+	})
+
+	It("decodes the problems of requests that do not match the API description", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, _ int) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "The request does not match the API description.",
+				"errors": [{"in": "body", "pointer": "/name", "reason": "value must be a string"}]}`))
+		}
+
+		By("acting")
+		err := c.Update(context.Background(), "a", entities.Resource{})
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrInvalid)).To(BeTrue())
+		var apiError *client.Error
+		Expect(errors.As(err, &apiError)).To(BeTrue())
+		Expect(apiError.Problems).To(ConsistOf(client.Problem{In: "body", Pointer: "/name",
+			Reason: "value must be a string"}))
+	})
+
+	It("decodes rejected transitions", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, _ int) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"from": "draft", "to": "retired", "error": "not allowed", "allowed": ["active"]}`))
+		}
+
+		By("acting")
+		err := c.Delete(context.Background(), "a", entities.DeleteCascade)
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrConflict)).To(BeTrue())
+		var apiError *client.Error
+		Expect(errors.As(err, &apiError)).To(BeTrue())
+		Expect(apiError.Transition).To(Equal(&entities.TransitionError{From: "draft", To: "retired",
+			Reason: "not allowed", Allowed: []string{"active"}}))
+		Expect(requests[0].URL.Query().Get("children")).To(Equal("cascade"))
+	})
+
+	It("authenticates every attempt", func() {
+		By("arranging")
+		tokens := 0
+		c = client.New(server.URL, client.WithRetryPolicy(database.RetryPolicy{MaxRetries: 1}),
+			client.WithAuth(func(request *http.Request) error {
+				tokens++
+				request.Header.Set("Authorization", "Bearer "+string(rune('0'+tokens)))
+				return nil
+			}))
+		respond = func(w http.ResponseWriter, attempt int) {
+			if attempt == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`[]`))
+		}
+
+		By("acting")
+		_, err := c.List(context.Background(), client.ListOptions{}).All()
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("Bearer 1"))
+		Expect(requests[1].Header.Get("Authorization")).To(Equal("Bearer 2"))
+	})
+
+	It("stops retrying when the context is done", func() {
+		By("arranging")
+		ctx, cancel := context.WithCancel(context.Background())
+		c = client.New(server.URL, client.WithRetryPolicy(database.RetryPolicy{MaxRetries: 5,
+			BaseDelay: time.Hour, MaxDelay: time.Hour}))
+		respond = func(w http.ResponseWriter, _ int) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		By("acting")
+		time.AfterFunc(50*time.Millisecond, cancel)
+		_, err := c.Get(ctx, "a")
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrUnavailable)).To(BeTrue())
+		Expect(requests).To(HaveLen(1))
+	})
+
+	It("fetches the pages of searches as they are reached", func() {
+		By("arranging")
+		respond = func(w http.ResponseWriter, attempt int) {
+			if attempt == 1 {
+				w.Write([]byte(`{"items": [{"id": "a", "name": "a", "rank": 1, "snippet": ""}], "next_cursor": "MQ"}`))
+				return
+			}
+			w.Write([]byte(`{"items": [{"id": "b", "name": "b", "rank": 0.5, "snippet": ""}]}`))
+		}
+
+		By("acting")
+		results, err := c.Search(context.Background(), "a b", 1).All()
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[1].PublicID).To(Equal("b"))
+		Expect(requests[0].URL.Query().Get("limit")).To(Equal("1"))
+		Expect(requests[0].URL.Query().Has("cursor")).To(BeFalse())
+		Expect(requests[1].URL.Query().Get("cursor")).To(Equal("MQ"))
+	})
+})
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+// The errors an *Error unwraps to, by the status of the response.
+var (
+	ErrInvalid     = errors.New("invalid request")
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrUnavailable = errors.New("service unavailable")
+	ErrServer      = errors.New("server error")
+)
+
+// Error is a response with an error status. Most are plain text, requests that do not match the API
+// description come with the problems found in them and rejected transitions with the allowed ones.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Problems are the ways the request, or for a 500 the response, does not match the API description.
+	Problems []Problem
+	// Transition is set when the lifecycle does not allow a transition.
+	Transition *entities.TransitionError
+	// RetryAfter is how long the database breaker of the service stays open.
+	RetryAfter time.Duration
+}
+
+// Problem mirrors openapi.FieldError, which is not imported to keep the router out of clients.
+type Problem struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Pointer string `json:"pointer,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest, e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrInvalid
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return nil
+}
+
+func newError(response *http.Response, body []byte) *Error {
+	apiError := &Error{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(body))}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		apiError.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != "application/json" {
+		return apiError
+	}
+	var validation struct {
+		Message string    `json:"message"`
+		Errors  []Problem `json:"errors"`
+	}
+	if response.StatusCode == http.StatusConflict {
+		var transition entities.TransitionError
+		if json.Unmarshal(body, &transition) == nil {
+			apiError.Message, apiError.Transition = transition.Reason, &transition
+		}
+	} else if json.Unmarshal(body, &validation) == nil {
+		apiError.Message, apiError.Problems = validation.Message, validation.Errors
+	}
+	return apiError
+}
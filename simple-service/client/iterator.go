@@ -0,0 +1,61 @@
+package client
+
+import "context"
+
+// Iterator walks through the items of a listing, fetching its pages as they are reached:
+//
+//	for it := c.Search(ctx, "db", 0); it.Next(); {
+//		use(it.Item())
+//	}
+//	if err := it.Err(); err != nil {
+type Iterator[T any] struct {
+	ctx    context.Context
+	fetch  func(ctx context.Context, cursor string) (items []T, nextCursor string, err error)
+	page   []T
+	cursor string
+	last   bool
+	item   T
+	err    error
+}
+
+// page is the envelope the service returns listings in.
+type page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func newIterator[T any](ctx context.Context,
+	fetch func(ctx context.Context, cursor string) ([]T, string, error)) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, fetch: fetch}
+}
+
+// Next moves to the next item, fetching the next page when the current one is used up. It returns
+// false at the end of the listing or when fetching a page fails, which Err tells apart.
+func (it *Iterator[T]) Next() bool {
+	for len(it.page) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.page, it.cursor, it.err = it.fetch(it.ctx, it.cursor)
+		it.last = it.cursor == ""
+	}
+	it.item, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All collects the remaining items.
+func (it *Iterator[T]) All() ([]T, error) {
+	var all []T
+	for it.Next() {
+		all = append(all, it.Item())
+	}
+	return all, it.Err()
+}
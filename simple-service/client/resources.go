@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+// ListOptions filter the resources List returns.
+type ListOptions struct {
+	IncludeDeleted bool
+	// Selector is a label selector such as env=prod,tier!=db.
+	Selector string
+	// PageSize is how many resources are fetched at a time, 0 fetches them all at once.
+	PageSize int
+}
+
+// Create creates the resource in the initial status of the lifecycle and returns its ID. Only the name,
+// attributes, labels and parent ID of resource are sent.
+func (c *Client) Create(ctx context.Context, resource entities.Resource) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/resources", nil, input(resource), &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func (c *Client) Get(ctx context.Context, id string) (*entities.Resource, error) {
+	var resource entities.Resource
+	if err := c.do(ctx, http.MethodGet, "/resources/"+url.PathEscape(id), nil, nil, &resource); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// List iterates over the live resources, and the soft-deleted ones too if options ask for them.
+func (c *Client) List(ctx context.Context, options ListOptions) *Iterator[entities.Resource] {
+	return newIterator(ctx, func(ctx context.Context, cursor string) ([]entities.Resource, string, error) {
+		query := url.Values{}
+		if options.IncludeDeleted {
+			query.Set("include", "deleted")
+		}
+		if options.Selector != "" {
+			query.Set("selector", options.Selector)
+		}
+		if options.PageSize <= 0 {
+			var resources []entities.Resource
+			err := c.do(ctx, http.MethodGet, "/resources", query, nil, &resources)
+			return resources, "", err
+		}
+		query.Set("limit", strconv.Itoa(options.PageSize))
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		var listing page[entities.Resource]
+		err := c.do(ctx, http.MethodGet, "/resources", query, nil, &listing)
+		return listing.Items, listing.NextCursor, err
+	})
+}
+
+// Search iterates over the live resources matching query, best matches first, fetching pageSize of
+// them at a time. A pageSize of 0 leaves it to the service.
+func (c *Client) Search(ctx context.Context, query string, pageSize int) *Iterator[entities.SearchResult] {
+	return newIterator(ctx, func(ctx context.Context, cursor string) ([]entities.SearchResult, string, error) {
+		values := url.Values{"q": {query}}
+		if pageSize > 0 {
+			values.Set("limit", strconv.Itoa(pageSize))
+		}
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+		var listing page[entities.SearchResult]
+		err := c.do(ctx, http.MethodGet, "/resources/search", values, nil, &listing)
+		return listing.Items, listing.NextCursor, err
+	})
+}
+
+// Update replaces the name, attributes and labels of the resource.
+func (c *Client) Update(ctx context.Context, id string, resource entities.Resource) error {
+	return c.do(ctx, http.MethodPut, "/resources/"+url.PathEscape(id), nil, input(resource), nil)
+}
+
+// Delete soft-deletes the resource, policy decides what happens to its children and defaults to
+// entities.DeleteRestrict when it is empty.
+func (c *Client) Delete(ctx context.Context, id string, policy entities.DeletePolicy) error {
+	query := url.Values{}
+	if policy != "" {
+		query.Set("children", string(policy))
+	}
+	return c.do(ctx, http.MethodDelete, "/resources/"+url.PathEscape(id), query, nil, nil)
+}
+
+// resourceInput is the body of the requests changing resources, which leave out the read-only fields.
+type resourceInput struct {
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+}
+
+func input(resource entities.Resource) resourceInput {
+	return resourceInput{Name: resource.Name, Attributes: resource.Attributes, Labels: resource.Labels,
+		ParentID: resource.ParentPublicID}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+
+	"github.com/addme96/simple-go-service/simple-service/client"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// the client is run against the router with response validation, over resources kept in memory
+var _ = Describe("Client", func() {
+	var (
+		mockCtrl  *gomock.Controller
+		resources map[int]entities.Resource
+		server    *httptest.Server
+		c         *client.Client
+		ctx       = context.Background()
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		resources = make(map[int]entities.Resource)
+		resourceRepo := mocks.NewMockResourceRepository(mockCtrl)
+		read := func(_ context.Context, id int) (*entities.Resource, error) {
+			resource, ok := resources[id]
+			if !ok {
+				return nil, pgx.ErrNoRows
+			}
+			return &resource, nil
+		}
+		live := func() []entities.Resource {
+			all := make([]entities.Resource, 0, len(resources))
+			for _, resource := range resources {
+				all = append(all, resource)
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+			return all
+		}
+		resourceRepo.EXPECT().Create(gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_ context.Context, resource entities.Resource) (*entities.Resource, error) {
+				resource.ID = len(resources) + 1
+				resource.PublicID = fmt.Sprintf("00000000-0000-7000-8000-%012d", resource.ID)
+				resources[resource.ID] = resource
+				return &resource, nil
+			})
+		resourceRepo.EXPECT().Resolve(gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_ context.Context, publicID string) (int, error) {
+				for id, resource := range resources {
+					if resource.PublicID == publicID {
+						return id, nil
+					}
+				}
+				return 0, pgx.ErrNoRows
+			})
+		resourceRepo.EXPECT().Read(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(read)
+		resourceRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_ context.Context, id int, resource entities.Resource) error {
+				current := resources[id]
+				current.Name, current.Attributes, current.Labels = resource.Name, resource.Attributes, resource.Labels
+				resources[id] = current
+				return nil
+			})
+		resourceRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_ context.Context, options entities.ListOptions) ([]entities.Resource, error) {
+				all := live()
+				if options.Offset > len(all) {
+					options.Offset = len(all)
+				}
+				if options.Offset+options.Limit < len(all) {
+					return all[options.Offset : options.Offset+options.Limit], nil
+				}
+				return all[options.Offset:], nil
+			})
+		resourceRepo.EXPECT().Search(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_ context.Context, _ string, limit, offset int) ([]entities.SearchResult, error) {
+				var results []entities.SearchResult
+				for _, resource := range live() {
+					results = append(results, entities.SearchResult{Resource: resource, Rank: 1})
+				}
+				if offset > len(results) {
+					offset = len(results)
+				}
+				if offset+limit < len(results) {
+					return results[offset : offset+limit], nil
+				}
+				return results[offset:], nil
+			})
+		resourceRepo.EXPECT().DeleteTree(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(_ context.Context, id int, _ entities.DeletePolicy) error {
+				delete(resources, id)
+				return nil
+			})
+		auditRepo := mocks.NewMockAuditRepository(mockCtrl)
+		auditRepo.EXPECT().Record(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+		txManager := mocks.NewMockTxRunner(mockCtrl)
+		txManager.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+			DoAndReturn(func(ctx context.Context, _ database.TxOptions, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		router, err := newRouter(routes{resources: handlers.NewResource(resourceRepo), webhooks: handlers.NewWebhook(nil),
+			audit: handlers.NewAudit(auditRepo), graph: http.NotFoundHandler(), txManager: txManager,
+			validateResponses: true})
+		Expect(err).NotTo(HaveOccurred())
+		server = httptest.NewServer(router)
+		c = client.New(server.URL, client.WithBearerToken("token"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		mockCtrl.Finish()
+	})
+
+	It("creates, reads, updates and deletes resources", func() {
+		By("creating")
+		id, err := c.Create(ctx, entities.Resource{Name: "a", Labels: map[string]string{"env": "prod"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("reading")
+		resource, err := c.Get(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resource.PublicID).To(Equal(id))
+		Expect(resource.Name).To(Equal("a"))
+		Expect(resource.Status).To(Equal(entities.StatusDraft))
+
+		By("updating")
+		resource.Name = "b"
+		Expect(c.Update(ctx, id, *resource)).To(Succeed())
+		resource, err = c.Get(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resource.Name).To(Equal("b"))
+		Expect(resource.Labels).To(Equal(map[string]string{"env": "prod"}))
+
+		By("deleting")
+		Expect(c.Delete(ctx, id, entities.DeleteCascade)).To(Succeed())
+		_, err = c.Get(ctx, id)
+		Expect(errors.Is(err, client.ErrNotFound)).To(BeTrue())
+	})
+
+	It("pages through resources", func() {
+		By("arranging")
+		for _, name := range []string{"a", "b", "c"} {
+			_, err := c.Create(ctx, entities.Resource{Name: name})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("acting")
+		listed, err := c.List(ctx, client.ListOptions{IncludeDeleted: true, PageSize: 2}).All()
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(HaveLen(3))
+		Expect(listed[2].Name).To(Equal("c"))
+	})
+
+	It("pages through search results", func() {
+		By("arranging")
+		for _, name := range []string{"a", "b", "c"} {
+			_, err := c.Create(ctx, entities.Resource{Name: name})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("acting")
+		var names []string
+		it := c.Search(ctx, "any", 2)
+		for it.Next() {
+			names = append(names, it.Item().Name)
+		}
+
+		By("asserting")
+		Expect(it.Err()).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"a", "b", "c"}))
+	})
+
+	It("returns the problems of invalid requests", func() {
+		By("arranging")
+		labels := make(map[string]string)
+		for i := 0; i <= entities.MaxLabels; i++ {
+			labels[fmt.Sprint("label", i)] = "a"
+		}
+
+		By("acting")
+		_, err := c.Create(ctx, entities.Resource{Name: "a", Labels: labels})
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrInvalid)).To(BeTrue())
+		var apiError *client.Error
+		Expect(errors.As(err, &apiError)).To(BeTrue())
+		Expect(apiError.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+		Expect(apiError.Problems).To(HaveLen(1))
+		Expect(apiError.Problems[0].Pointer).To(Equal("/labels"))
+		Expect(resources).To(BeEmpty())
+	})
+
+	It("returns the errors of the handlers", func() {
+		By("acting")
+		_, err := c.Create(ctx, entities.Resource{Name: "a", ParentPublicID: "00000000-0000-7000-8000-000000000009"})
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrInvalid)).To(BeTrue())
+		var apiError *client.Error
+		Expect(errors.As(err, &apiError)).To(BeTrue())
+		Expect(apiError.Message).To(Equal(entities.ErrParentMissing.Error()))
+	})
+})
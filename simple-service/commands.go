@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/fixtures"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/cobra"
+)
+
+// newCommand is the command line of the service, it serves the APIs when it is run without a subcommand.
+// Every subcommand reaches the database the environment configures, as the server does, in the schema of
+// the tenant given by --tenant.
+func newCommand() *cobra.Command {
+	var tenant string
+	root := &cobra.Command{
+		Use:          "simple-service",
+		Short:        "Serves and operates the resources API",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if tenant == "" {
+				return nil
+			}
+			if err := tenancy.Validate(tenant); err != nil {
+				return err
+			}
+			cmd.SetContext(tenancy.WithTenant(cmd.Context(), tenant))
+			return nil
+		},
+		RunE: func(*cobra.Command, []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			serve(db)
+			return nil
+		},
+	}
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Migrates the database and serves the REST, gRPC and GraphQL APIs",
+			Args:  cobra.NoArgs,
+			RunE:  root.RunE,
+		},
+		newMigrateCommand(),
+		newSeedCommand(),
+		newImportCommand(),
+		newExportCommand(),
+		&cobra.Command{
+			Use:   "verify-audit",
+			Short: "Verifies the audit chain, exits with 1 when it is broken and 2 when it cannot be read",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				db, err := newDB()
+				if err != nil {
+					return err
+				}
+				os.Exit(verifyAuditLog(cmd.Context(), repositories.NewAudit(db)))
+				return nil
+			},
+		},
+		newAPIKeysCommand(),
+		newTenantsCommand(),
+	)
+	root.PersistentFlags().StringVar(&tenant, "tenant", "", "the tenant whose schema the command works on")
+	return root
+}
+
+func newMigrateCommand() *cobra.Command {
+	migrate := &cobra.Command{Use: "migrate", Short: "Applies, reverts and lists the schema migrations"}
+	var target, steps int
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Applies the migrations up to a version, the latest by default",
+		Long: "Applies the migrations up to a version to the default schema and to the schema of every tenant, " +
+			"or to the schema of the tenant given by --tenant only.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			var total int
+			report := func(tenant string, applied []database.Migration) {
+				for _, migration := range applied {
+					fmt.Fprintf(cmd.OutOrStdout(), "Applied migration %d %s%s\n", migration.Version, migration.Name,
+						ofTenant(tenant))
+				}
+				total += len(applied)
+			}
+			if tenant, ok := tenancy.TenantFromContext(cmd.Context()); ok {
+				var applied []database.Migration
+				applied, err = db.Migrate(cmd.Context(), target)
+				report(tenant, applied)
+			} else {
+				err = migrateAll(cmd.Context(), db, target, report)
+			}
+			if err == nil && total == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "The schema is up to date")
+			}
+			return err
+		},
+	}
+	up.Flags().IntVar(&target, "to", database.LatestMigration(), "the version to migrate to")
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Reverts the last applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			reverted, err := db.Rollback(cmd.Context(), steps)
+			printMigrations(cmd.OutOrStdout(), "Reverted", reverted)
+			return err
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "how many migrations to revert")
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Lists the migrations and when they were applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			states, err := db.MigrationStatus(cmd.Context())
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+			for _, state := range states {
+				appliedAt := "pending"
+				if state.AppliedAt != nil {
+					appliedAt = state.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+			}
+			return w.Flush()
+		},
+	}
+	migrate.AddCommand(up, down, status)
+	return migrate
+}
+
+func printMigrations(w io.Writer, verb string, migrations []database.Migration) {
+	for _, migration := range migrations {
+		fmt.Fprintf(w, "%s migration %d %s\n", verb, migration.Version, migration.Name)
+	}
+}
+
+func newSeedCommand() *cobra.Command {
+	var (
+		files   []string
+		profile string
+		rows    int
+		reset   bool
+	)
+	seed := &cobra.Command{
+		Use:   "seed",
+		Short: "Migrates the database and loads fixture sets into it",
+		Long: "Migrates the database to the latest version and loads the fixture sets of the files and profile, " +
+			"in a single transaction. Resources are upserted by their name below their parent, so seeding " +
+			"again updates them. --reset empties the database first.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var sets []*fixtures.Set
+			for _, file := range files {
+				set, err := fixtures.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				sets = append(sets, set)
+			}
+			if profile != "" {
+				set, err := fixtures.Profile(profile, rows)
+				if err != nil {
+					return err
+				}
+				sets = append(sets, set)
+			}
+			if len(sets) == 0 && !reset {
+				return errors.New("there is nothing to seed, give a --file or a --profile")
+			}
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			if _, err = db.Migrate(cmd.Context(), database.LatestMigration()); err != nil {
+				return err
+			}
+			if reset {
+				if err = db.Reset(cmd.Context()); err != nil {
+					return err
+				}
+			}
+			var result fixtures.Result
+			err = database.NewTxManager(db, pgx.ReadCommitted).WithinTx(cmd.Context(), func(ctx context.Context) (err error) {
+				result, err = fixtures.Load(ctx, repositories.NewResource(db), sets...)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created %d and updated %d resources\n", result.Created, result.Updated)
+			return nil
+		},
+	}
+	seed.Flags().StringArrayVarP(&files, "file", "f", nil, "a YAML or JSON fixture set, may be repeated")
+	seed.Flags().StringVarP(&profile, "profile", "p", "",
+		fmt.Sprintf("a built-in fixture set, %s or %s", fixtures.ProfileDemo, fixtures.ProfileLoadTest))
+	seed.Flags().IntVar(&rows, "rows", 1000, "how many resources the load-test profile generates")
+	seed.Flags().BoolVar(&reset, "reset", false, "empty every table but the migrations before seeding")
+	return seed
+}
+
+func newImportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import FILE",
+		Short: "Creates the resources of an export, - reads it from stdin",
+		Long: "Creates the resources of a file written by export, in a single transaction. Resources get new IDs, " +
+			"the hierarchy of the file is kept and parents outside of it have to exist already.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			return importFile(cmd, db, args[0])
+		},
+	}
+}
+
+// importFile imports the resources of path, or of stdin when it is "-", in one transaction.
+func importFile(cmd *cobra.Command, db *database.DB, path string) error {
+	in := cmd.InOrStdin()
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	var imported int
+	err := database.NewTxManager(db, pgx.ReadCommitted).WithinTx(cmd.Context(), func(ctx context.Context) (err error) {
+		imported, err = importResources(ctx, repositories.NewResource(db), in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Imported %d resources\n", imported)
+	return nil
+}
+
+func newExportCommand() *cobra.Command {
+	var output, selector string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Writes the live resources as a JSON array, which import reads back",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var options entities.ListOptions
+			var err error
+			if options.LabelSelector, err = entities.ParseLabelSelector(selector); err != nil {
+				return err
+			}
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if output != "" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+			exported, err := exportResources(cmd.Context(), repositories.NewResource(db), options, out)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d resources\n", exported)
+			return nil
+		},
+	}
+	export.Flags().StringVarP(&output, "output", "o", "", "the file to write instead of stdout")
+	export.Flags().StringVarP(&selector, "selector", "l", "", "a label selector such as env=prod,tier!=db")
+	return export
+}
+
+// exportResources writes the resources of store matching options as an indented JSON array.
+func exportResources(ctx context.Context, store handlers.Store[entities.Resource], options entities.ListOptions,
+	w io.Writer) (int, error) {
+	resources, err := store.ReadAll(ctx, options)
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return len(resources), encoder.Encode(resources)
+}
+
+// importResources creates the resources of the JSON array read from r, parents before their children.
+// The parent IDs of the array are those of the resources it holds, or of resources stored already.
+func importResources(ctx context.Context, store handlers.Store[entities.Resource], r io.Reader) (int, error) {
+	var resources []entities.Resource
+	if err := json.NewDecoder(r).Decode(&resources); err != nil {
+		return 0, fmt.Errorf("reading resources: %w", err)
+	}
+	inFile := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		if resource.PublicID != "" {
+			inFile[resource.PublicID] = true
+		}
+	}
+	// created maps the IDs of the file to the internal IDs of the resources created for them
+	created := make(map[string]int, len(resources))
+	pending := resources
+	for len(pending) > 0 {
+		var waiting []entities.Resource
+		for _, resource := range pending {
+			parent := resource.ParentPublicID
+			if _, ok := created[parent]; parent != "" && inFile[parent] && !ok {
+				waiting = append(waiting, resource)
+				continue
+			}
+			id, err := importResource(ctx, store, resource, created)
+			if err != nil {
+				return len(created), fmt.Errorf("importing resource %q: %w", resource.Name, err)
+			}
+			if resource.PublicID != "" {
+				created[resource.PublicID] = id
+			}
+		}
+		if len(waiting) == len(pending) {
+			names := make([]string, len(waiting))
+			for i, resource := range waiting {
+				names[i] = strconv.Quote(resource.Name)
+			}
+			return len(created), fmt.Errorf("the parents of resources %s form a cycle", strings.Join(names, ", "))
+		}
+		pending = waiting
+	}
+	return len(resources), nil
+}
+
+func importResource(ctx context.Context, store handlers.Store[entities.Resource], resource entities.Resource,
+	created map[string]int) (int, error) {
+	if err := resource.Validate(); err != nil {
+		return 0, err
+	}
+	if resource.Status == "" {
+		resource.Status = entities.DefaultLifecycle.Initial
+	}
+	resource.ID, resource.PublicID, resource.ParentID, resource.DeletedAt = 0, "", nil, nil
+	if parent := resource.ParentPublicID; parent != "" {
+		parentID, ok := created[parent]
+		if !ok {
+			var err error
+			if parentID, err = store.Resolve(ctx, parent); errors.Is(err, pgx.ErrNoRows) {
+				return 0, entities.ErrParentMissing
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		resource.ParentID = &parentID
+	}
+	stored, err := store.Create(ctx, resource)
+	if err != nil {
+		return 0, err
+	}
+	return stored.ID, nil
+}
+
+func newAPIKeysCommand() *cobra.Command {
+	apiKeys := &cobra.Command{Use: "api-keys", Short: "Creates, lists and revokes the API keys requests authenticate with"}
+	var principal string
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Creates an API key and prints its secret, which cannot be shown again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			key, secret, err := repositories.NewAPIKey(db).Create(cmd.Context(), principal)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Created API key %d for %s\n", key.ID, key.Principal)
+			fmt.Fprintln(cmd.OutOrStdout(), secret)
+			return nil
+		},
+	}
+	create.Flags().StringVar(&principal, "principal", "", "who the requests made with the key are recorded for")
+	create.MarkFlagRequired("principal")
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the API keys, without their secrets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			keys, err := repositories.NewAPIKey(db).ReadAll(cmd.Context())
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tPRINCIPAL\tPREFIX\tCREATED AT\tREVOKED AT")
+			for _, key := range keys {
+				revokedAt := ""
+				if key.RevokedAt != nil {
+					revokedAt = key.RevokedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", key.ID, key.Principal, key.Prefix,
+					key.CreatedAt.Format(time.RFC3339), revokedAt)
+			}
+			return w.Flush()
+		},
+	}
+	revoke := &cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revokes an API key, requests made with it are rejected from then on",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid API key ID %q", args[0])
+			}
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			err = repositories.NewAPIKey(db).Revoke(cmd.Context(), id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("there is no API key %d left to revoke", id)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Revoked API key %d\n", id)
+			return nil
+		},
+	}
+	apiKeys.AddCommand(create, list, revoke)
+	return apiKeys
+}
+
+func newTenantsCommand() *cobra.Command {
+	tenants := &cobra.Command{Use: "tenants", Short: "Creates, lists and deletes the tenants and sets their quotas"}
+	var maxResources int
+	create := &cobra.Command{
+		Use:   "create ID",
+		Short: "Creates a tenant and migrates its schema to the latest version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			repository := repositories.NewTenant(db)
+			tenant, err := repository.Create(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			ctx := tenancy.WithTenant(cmd.Context(), tenant.ID)
+			if _, err = db.Migrate(ctx, database.LatestMigration()); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("max-resources") {
+				if err = repository.SetQuota(ctx, entities.QuotaResources, &maxResources); err != nil {
+					return err
+				}
+			}
+			if err = repository.MarkReady(cmd.Context(), tenant.ID); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created tenant %s in schema %s\n", tenant.ID, tenant.Schema)
+			return nil
+		},
+	}
+	create.Flags().IntVar(&maxResources, "max-resources", 0,
+		"how many live resources the tenant may have, unlimited by default")
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the tenants and their quotas",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			repository := repositories.NewTenant(db)
+			registered, err := repository.ReadAll(cmd.Context())
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSCHEMA\tCREATED AT\tMAX RESOURCES")
+			for _, tenant := range registered {
+				quotas, err := repository.Quotas(tenancy.WithTenant(cmd.Context(), tenant.ID))
+				if err != nil {
+					return err
+				}
+				maximum := "unlimited"
+				if quota, ok := quotas[entities.QuotaResources]; ok {
+					maximum = strconv.Itoa(quota)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tenant.ID, tenant.Schema, tenant.CreatedAt.Format(time.RFC3339), maximum)
+			}
+			return w.Flush()
+		},
+	}
+	deleteTenant := &cobra.Command{
+		Use:   "delete ID",
+		Short: "Deletes a tenant and drops its schema with everything in it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			err = repositories.NewTenant(db).Delete(cmd.Context(), args[0])
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("there is no tenant %s", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted tenant %s\n", args[0])
+			return nil
+		},
+	}
+	quota := &cobra.Command{
+		Use:   "quota ID MAX",
+		Short: "Sets how many live resources a tenant may have, unlimited lifts the quota",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var maximum *int
+			if args[1] != "unlimited" {
+				value, err := strconv.Atoi(args[1])
+				if err != nil || value < 0 {
+					return fmt.Errorf("invalid quota %q, it is a number of resources or unlimited", args[1])
+				}
+				maximum = &value
+			}
+			db, err := newDB()
+			if err != nil {
+				return err
+			}
+			repository := repositories.NewTenant(db)
+			if _, err = repository.Read(cmd.Context(), args[0]); errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("there is no tenant %s", args[0])
+			} else if err != nil {
+				return err
+			}
+			err = repository.SetQuota(tenancy.WithTenant(cmd.Context(), args[0]), entities.QuotaResources, maximum)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Set the quota of resources of tenant %s to %s\n", args[0], args[1])
+			return nil
+		},
+	}
+	tenants.AddCommand(create, list, deleteTenant, quota)
+	return tenants
+}
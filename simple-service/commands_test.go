@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Commands", func() {
+	var (
+		mockCtrl     *gomock.Controller
+		resourceRepo *mocks.MockResourceRepository
+		ctx          = context.Background()
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		resourceRepo = mocks.NewMockResourceRepository(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("has a subcommand for every operation", func() {
+		By("acting")
+		var names []string
+		for _, command := range newCommand().Commands() {
+			names = append(names, command.Name())
+		}
+
+		By("asserting")
+		Expect(names).To(ContainElements("serve", "migrate", "seed", "import", "export", "verify-audit", "api-keys",
+			"tenants"))
+	})
+
+	It("exports resources as a JSON array", func() {
+		By("arranging")
+		parentID := 1
+		resourceRepo.EXPECT().ReadAll(ctx, entities.ListOptions{}).Return([]entities.Resource{
+			{ID: 1, PublicID: "p", Name: "a", Status: entities.StatusDraft},
+			{ID: 2, PublicID: "c", Name: "b", Status: entities.StatusDraft, ParentID: &parentID, ParentPublicID: "p"},
+		}, nil)
+		var out bytes.Buffer
+
+		By("acting")
+		exported, err := exportResources(ctx, resourceRepo, entities.ListOptions{}, &out)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exported).To(Equal(2))
+		Expect(out.String()).To(MatchJSON(`[{"id": "p", "name": "a", "status": "draft"},
+			{"id": "c", "name": "b", "status": "draft", "parent_id": "p"}]`))
+	})
+
+	It("imports children after their parents, under their new IDs", func() {
+		By("arranging")
+		in := strings.NewReader(`[
+			{"id": "c", "name": "child", "parent_id": "p"},
+			{"id": "p", "name": "parent", "status": "active", "parent_id": "existing"}]`)
+		resourceRepo.EXPECT().Resolve(ctx, "existing").Return(7, nil)
+		gomock.InOrder(
+			resourceRepo.EXPECT().Create(ctx, gomock.Any()).
+				DoAndReturn(func(_ context.Context, resource entities.Resource) (*entities.Resource, error) {
+					Expect(resource.Name).To(Equal("parent"))
+					Expect(resource.Status).To(Equal("active"))
+					Expect(*resource.ParentID).To(Equal(7))
+					Expect(resource.PublicID).To(BeEmpty())
+					resource.ID = 10
+					return &resource, nil
+				}),
+			resourceRepo.EXPECT().Create(ctx, gomock.Any()).
+				DoAndReturn(func(_ context.Context, resource entities.Resource) (*entities.Resource, error) {
+					Expect(resource.Name).To(Equal("child"))
+					Expect(resource.Status).To(Equal(entities.DefaultLifecycle.Initial))
+					Expect(*resource.ParentID).To(Equal(10))
+					resource.ID = 11
+					return &resource, nil
+				}),
+		)
+
+		By("acting")
+		imported, err := importResources(ctx, resourceRepo, in)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported).To(Equal(2))
+	})
+
+	It("rejects resources whose parents are nowhere", func() {
+		By("arranging")
+		resourceRepo.EXPECT().Resolve(ctx, "missing").Return(0, pgx.ErrNoRows)
+
+		By("acting")
+		_, err := importResources(ctx, resourceRepo, strings.NewReader(`[{"name": "a", "parent_id": "missing"}]`))
+
+		By("asserting")
+		Expect(errors.Is(err, entities.ErrParentMissing)).To(BeTrue())
+	})
+
+	It("rejects parents forming a cycle", func() {
+		By("acting")
+		_, err := importResources(ctx, resourceRepo, strings.NewReader(`[
+			{"id": "a", "name": "a", "parent_id": "b"},
+			{"id": "b", "name": "b", "parent_id": "a"}]`))
+
+		By("asserting")
+		Expect(err).To(MatchError(`the parents of resources "a", "b" form a cycle`))
+	})
+
+	It("rejects invalid resources", func() {
+		By("arranging")
+		labels := make(map[string]string)
+		for i := 0; i <= entities.MaxLabels; i++ {
+			labels[strings.Repeat("l", i+1)] = "a"
+		}
+		in, _ := json.Marshal([]entities.Resource{{Name: "a", Labels: labels}})
+
+		By("acting")
+		_, err := importResources(ctx, resourceRepo, bytes.NewReader(in))
+
+		By("asserting")
+		Expect(err).To(HaveOccurred())
+	})
+})
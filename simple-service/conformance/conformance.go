@@ -0,0 +1,259 @@
+// Package conformance holds the specs every entity declared through entities.Meta has to pass,
+// repositories.Table and handlers.CRUD serve it as declared once they do.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+// Sample is an entity the specs store, read and serve.
+type Sample[T any] struct {
+	// Entity is the entity as Meta.Scan returns it, it has to pass Meta.Validate.
+	Entity T
+	// Columns name the columns of Meta.Select and Row holds their values for Entity, as pgxmock rows take them.
+	Columns []string
+	Row     []interface{}
+}
+
+const outboxQuery = "INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)"
+
+// DescribeEntity registers the conformance specs of the entity declared by meta.
+func DescribeEntity[T any](meta *entities.Meta[T], sample Sample[T]) bool {
+	return Describe(meta.Name+" conformance", func() {
+		var (
+			ctrl     *gomock.Controller
+			mockDB   *mocks.MockDB
+			mockConn pgxmock.PgxConnIface
+			table    *repositories.Table[T]
+			ctx      context.Context
+		)
+
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			mockDB = mocks.NewMockDB(ctrl)
+			mockConn, _ = pgxmock.NewConn()
+			mockDB.EXPECT().GetConn(gomock.Any()).AnyTimes().Return(mockConn, nil)
+			mockDB.EXPECT().GetReadConn(gomock.Any()).AnyTimes().Return(mockConn, nil)
+			table = repositories.NewTable(mockDB, meta)
+			ctx = context.Background()
+		})
+
+		id := meta.ID(sample.Entity)
+		statement := func(verb string) string {
+			return verb + strings.ToUpper(meta.Name[:1]) + meta.Name[1:]
+		}
+		rows := func() *pgxmock.Rows {
+			return pgxmock.NewRows(sample.Columns).AddRow(sample.Row...)
+		}
+		// values returns the column values of the sample with the names of the columns, leaving out the immutable ones on updates
+		values := func(update bool) ([]string, []interface{}) {
+			var names []string
+			var args []interface{}
+			for _, column := range meta.Columns {
+				if update && column.Immutable {
+					continue
+				}
+				value, err := column.Value(sample.Entity)
+				Expect(err).NotTo(HaveOccurred())
+				names = append(names, column.Name)
+				args = append(args, value)
+			}
+			return names, args
+		}
+		payload := func() []byte {
+			bytes, err := json.Marshal(sample.Entity)
+			Expect(err).NotTo(HaveOccurred())
+			return bytes
+		}
+		expectTx := func() {
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		}
+		expectEvent := func(verb string) {
+			mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).WithArgs(id, meta.Name+"."+verb, payload()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+		}
+		live := ""
+		if meta.SoftDelete {
+			live = " AND deleted_at IS NULL"
+		}
+
+		It("declares the sample as valid", func() {
+			Expect(sample.Row).To(HaveLen(len(sample.Columns)))
+			if meta.Validate != nil {
+				Expect(meta.Validate(sample.Entity)).To(Succeed())
+			}
+		})
+
+		It("creates with every column", func() {
+			By("arranging")
+			names, args := values(false)
+			expectTx()
+			mockConn.ExpectPrepare(statement("create"), regexp.QuoteMeta(fmt.Sprintf("INSERT INTO %s (%s) VALUES (",
+				meta.Table, strings.Join(names, ", ")))).
+				ExpectQuery().WithArgs(args...).WillReturnRows(rows())
+			expectEvent("created")
+
+			By("acting")
+			created, err := table.Create(ctx, sample.Entity)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(Equal(&sample.Entity))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("reads what it selects", func() {
+			By("arranging")
+			mockConn.ExpectPrepare(statement("read"), regexp.QuoteMeta("SELECT "+meta.Select+" FROM "+meta.Table+" WHERE id=$1"+live)).
+				ExpectQuery().WithArgs(id).WillReturnRows(rows())
+			mockConn.ExpectClose()
+
+			By("acting")
+			read, err := table.Read(ctx, id)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(&sample.Entity))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows for missing entities", func() {
+			By("arranging")
+			mockConn.ExpectPrepare(statement("read"), regexp.QuoteMeta("SELECT "+meta.Select+" FROM "+meta.Table)).
+				ExpectQuery().WithArgs(id).WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectClose()
+
+			By("acting")
+			read, err := table.Read(ctx, id)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(read).To(BeNil())
+		})
+
+		It("updates the mutable columns", func() {
+			By("arranging")
+			names, args := values(true)
+			assignments := make([]string, len(names))
+			for i, name := range names {
+				assignments[i] = fmt.Sprintf("%s = $%d", name, i+1)
+			}
+			expectTx()
+			mockConn.ExpectPrepare(statement("update"), regexp.QuoteMeta(fmt.Sprintf("UPDATE %s SET %s WHERE id=$%d%s RETURNING ",
+				meta.Table, strings.Join(assignments, ", "), len(args)+1, live))).
+				ExpectQuery().WithArgs(append(args, id)...).WillReturnRows(rows())
+			expectEvent("updated")
+
+			By("acting")
+			err := table.Update(ctx, id, sample.Entity)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("deletes", func() {
+			By("arranging")
+			query := "DELETE FROM " + meta.Table + " WHERE id=$1 RETURNING "
+			if meta.SoftDelete {
+				query = "UPDATE " + meta.Table + " SET deleted_at = now() WHERE id=$1 AND deleted_at IS NULL RETURNING "
+			}
+			expectTx()
+			mockConn.ExpectPrepare(statement("delete"), regexp.QuoteMeta(query)).
+				ExpectQuery().WithArgs(id).WillReturnRows(rows())
+			expectEvent("deleted")
+
+			By("acting")
+			err := table.Delete(ctx, id)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		Context("served by handlers.CRUD", func() {
+			var router chi.Router
+			BeforeEach(func() {
+				router = chi.NewRouter()
+				handlers.NewCRUD[T](table, meta).Mount(router)
+			})
+
+			It("creates from the JSON it serves", func() {
+				By("arranging")
+				_, args := values(false)
+				expectTx()
+				mockConn.ExpectPrepare(statement("create"), regexp.QuoteMeta("INSERT INTO "+meta.Table)).
+					ExpectQuery().WithArgs(args...).WillReturnRows(rows())
+				expectEvent("created")
+				req := httptest.NewRequest(http.MethodPost, meta.Path+"/", bytes.NewReader(payload()))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+
+				By("acting")
+				router.ServeHTTP(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusCreated))
+				Expect(w.Body.String()).To(MatchJSON(fmt.Sprintf(`{"id": %q}`, meta.PublicID(sample.Entity))))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("serves the entity under its public ID", func() {
+				By("arranging")
+				publicID := meta.PublicID(sample.Entity)
+				mockConn.ExpectQuery(regexp.QuoteMeta("SELECT id FROM " + meta.Table + " WHERE public_id=$1")).WithArgs(publicID).
+					WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(id))
+				mockConn.ExpectClose()
+				mockConn.ExpectPrepare(statement("read"), regexp.QuoteMeta("SELECT "+meta.Select)).
+					ExpectQuery().WithArgs(id).WillReturnRows(rows())
+				mockConn.ExpectClose()
+				w := httptest.NewRecorder()
+
+				By("acting")
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, meta.Path+"/"+publicID+"/", nil))
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusOK))
+				Expect(w.Body.String()).To(MatchJSON(payload()))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("does not serve integer IDs", func() {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%d/", meta.Path, id), nil))
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			})
+
+			if meta.Labels == "" {
+				It("rejects label selectors", func() {
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, meta.Path+"/?selector=env%3Dprod", nil))
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+				})
+			}
+		})
+	})
+}
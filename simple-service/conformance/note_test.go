@@ -0,0 +1,45 @@
+package conformance_test
+
+import (
+	"errors"
+
+	"github.com/addme96/simple-go-service/simple-service/conformance"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+// note is the smallest entity there is, hard-deleted and without labels.
+type note struct {
+	ID       int    `json:"-"`
+	PublicID string `json:"id"`
+	Text     string `json:"text"`
+}
+
+var noteMeta = entities.Meta[note]{
+	Name:   "note",
+	Path:   "/notes",
+	Table:  "notes",
+	Select: "id, public_id, text",
+	Scan: func(row pgx.Row) (note, error) {
+		var n note
+		err := row.Scan(&n.ID, &n.PublicID, &n.Text)
+		return n, err
+	},
+	Columns: []entities.Column[note]{
+		{Name: "text", Value: func(n note) (interface{}, error) { return n.Text, nil }},
+	},
+	ID:       func(n note) int { return n.ID },
+	PublicID: func(n note) string { return n.PublicID },
+	Validate: func(n note) error {
+		if n.Text == "" {
+			return errors.New("a note needs text")
+		}
+		return nil
+	},
+}
+
+var _ = conformance.DescribeEntity(&noteMeta, conformance.Sample[note]{
+	Entity:  note{ID: 7, PublicID: "01890a5d-ac96-774b-bcce-b302099a8058", Text: "remember the milk"},
+	Columns: []string{"id", "public_id", "text"},
+	Row:     []interface{}{7, "01890a5d-ac96-774b-bcce-b302099a8058", "remember the milk"},
+})
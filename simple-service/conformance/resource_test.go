@@ -0,0 +1,14 @@
+package conformance_test
+
+import (
+	"github.com/addme96/simple-go-service/simple-service/conformance"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+var _ = conformance.DescribeEntity(&entities.ResourceMeta, conformance.Sample[entities.Resource]{
+	Entity: entities.Resource{ID: 101, PublicID: "01890a5d-ac96-774b-bcce-b302099a8057", Name: "Resource Name",
+		Attributes: map[string]interface{}{"replicas": float64(3)}, Labels: map[string]string{"env": "prod"}, Status: "active"},
+	Columns: []string{"id", "public_id", "name", "deleted_at", "attributes", "labels", "status", "parent_id", "parent_public_id"},
+	Row: []interface{}{101, "01890a5d-ac96-774b-bcce-b302099a8057", "Resource Name", nil,
+		[]byte(`{"replicas":3}`), []byte(`{"env":"prod"}`), "active", nil, ""},
+})
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitOpenError is returned instead of connecting while the Breaker is open.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("database unavailable, retry after %v", e.RetryAfter)
+}
+
+// Breaker stops connecting to the database after Threshold consecutive failures. It fails fast for Cooldown,
+// then lets a single probe through and closes again once the probe connects.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	rejected int64
+}
+
+// BreakerStats are published as metrics.
+type BreakerStats struct {
+	State BreakerState `json:"state"`
+	// Failures counts the consecutive failures, the breaker opens at Threshold.
+	Failures int `json:"failures"`
+	// Rejected counts the connections failed fast.
+	Rejected int64 `json:"rejected"`
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, now: time.Now, state: BreakerClosed}
+}
+
+// WithClock replaces time.Now, for tests.
+func (b *Breaker) WithClock(now func() time.Time) *Breaker {
+	b.now = now
+	return b
+}
+
+// Allow returns a *CircuitOpenError unless a connection may be attempted, its outcome has to be recorded.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.Cooldown)) {
+		b.state = BreakerHalfOpen
+	}
+	switch {
+	case b.state == BreakerClosed:
+		return nil
+	case b.state == BreakerHalfOpen && !b.probing:
+		b.probing = true
+		return nil
+	}
+	b.rejected++
+	return &CircuitOpenError{RetryAfter: b.retryAfter()}
+}
+
+// Record closes the breaker on success and counts failures, cancelled attempts tell nothing about the database.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing
+	b.probing = false
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err == nil:
+		b.state, b.failures = BreakerClosed, 0
+	case probe:
+		b.state, b.openedAt = BreakerOpen, b.now()
+	default:
+		if b.failures++; b.state == BreakerClosed && b.failures >= b.Threshold {
+			b.state, b.openedAt = BreakerOpen, b.now()
+		}
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// RetryAfter is how long the breaker stays open, zero unless it is.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerOpen {
+		return 0
+	}
+	return b.retryAfter()
+}
+
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{State: b.state, Failures: b.failures, Rejected: b.rejected}
+}
+
+// retryAfter is at least a second, the probe of a half-open breaker takes about as long as a connection.
+func (b *Breaker) retryAfter() time.Duration {
+	remaining := b.openedAt.Add(b.Cooldown).Sub(b.now())
+	if remaining < time.Second {
+		return time.Second
+	}
+	return remaining
+}
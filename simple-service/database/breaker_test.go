@@ -0,0 +1,87 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Breaker", func() {
+	var (
+		now     time.Time
+		breaker *database.Breaker
+	)
+	failure := errors.New("connection refused")
+
+	BeforeEach(func() {
+		now = time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+		breaker = database.NewBreaker(2, 10*time.Second).WithClock(func() time.Time { return now })
+	})
+
+	fail := func() {
+		Expect(breaker.Allow()).To(Succeed())
+		breaker.Record(failure)
+	}
+
+	It("opens after Threshold consecutive failures", func() {
+		fail()
+		Expect(breaker.State()).To(Equal(database.BreakerClosed))
+		fail()
+
+		Expect(breaker.State()).To(Equal(database.BreakerOpen))
+		Expect(breaker.RetryAfter()).To(Equal(10 * time.Second))
+		Expect(breaker.Allow()).To(Equal(&database.CircuitOpenError{RetryAfter: 10 * time.Second}))
+		Expect(breaker.Stats()).To(Equal(database.BreakerStats{State: database.BreakerOpen, Failures: 2, Rejected: 1}))
+	})
+
+	It("counts consecutive failures only", func() {
+		fail()
+		Expect(breaker.Allow()).To(Succeed())
+		breaker.Record(nil)
+		fail()
+
+		Expect(breaker.State()).To(Equal(database.BreakerClosed))
+	})
+
+	It("ignores cancelled attempts", func() {
+		fail()
+		Expect(breaker.Allow()).To(Succeed())
+		breaker.Record(context.Canceled)
+
+		Expect(breaker.Stats().Failures).To(Equal(1))
+	})
+
+	Context("after Cooldown", func() {
+		BeforeEach(func() {
+			fail()
+			fail()
+			now = now.Add(10 * time.Second)
+		})
+
+		It("lets a single probe through", func() {
+			Expect(breaker.Allow()).To(Succeed())
+			Expect(breaker.State()).To(Equal(database.BreakerHalfOpen))
+			Expect(breaker.Allow()).To(Equal(&database.CircuitOpenError{RetryAfter: time.Second}))
+		})
+
+		It("closes when the probe succeeds", func() {
+			Expect(breaker.Allow()).To(Succeed())
+			breaker.Record(nil)
+
+			Expect(breaker.State()).To(Equal(database.BreakerClosed))
+			Expect(breaker.Allow()).To(Succeed())
+		})
+
+		It("opens again when the probe fails", func() {
+			Expect(breaker.Allow()).To(Succeed())
+			breaker.Record(failure)
+
+			Expect(breaker.State()).To(Equal(database.BreakerOpen))
+			Expect(breaker.RetryAfter()).To(Equal(10 * time.Second))
+		})
+	})
+})
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+)
+
+// Migration is a versioned change of the schema, Down reverts Up. Migrations without Down cannot be reverted.
+// Migrations are applied to the default schema and to the schema of every tenant, except for the Shared ones,
+// which change what the tenants share and are only applied to the default schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+	Shared  bool
+}
+
+// Migrations are applied in order of their versions. The baseline is the schema Seed creates, which
+// databases got before migrations were recorded, its statements can run again over it.
+var Migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: schema},
+	{Version: 2, Name: "api_keys",
+		Up: []string{`CREATE TABLE IF NOT EXISTS api_keys (
+id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+principal varchar NOT NULL,
+prefix varchar NOT NULL,
+hash varchar NOT NULL UNIQUE,
+created_at timestamptz NOT NULL DEFAULT now(),
+revoked_at timestamptz
+)`},
+		Down: []string{`DROP TABLE IF EXISTS api_keys`}},
+	// the changes of every tenant are heard on the same channel
+	{Version: 3, Name: "change_schemas",
+		Up:   []string{notifyResourceChange(", 'schema', TG_TABLE_SCHEMA")},
+		Down: []string{notifyResourceChange("")}},
+	{Version: 4, Name: "quotas",
+		Up: []string{`CREATE TABLE IF NOT EXISTS quotas (
+name varchar PRIMARY KEY,
+maximum INT NOT NULL CHECK (maximum >= 0)
+)`},
+		Down: []string{`DROP TABLE IF EXISTS quotas`}},
+	{Version: 5, Name: "tenants", Shared: true,
+		Up: []string{`CREATE TABLE IF NOT EXISTS tenants (
+id varchar PRIMARY KEY,
+schema varchar NOT NULL UNIQUE,
+created_at timestamptz NOT NULL DEFAULT now()
+)`},
+		Down: []string{`DROP TABLE IF EXISTS tenants`}},
+	// audit entries keep the public ID of their resource, which outlives a purge of the resource
+	{Version: 6, Name: "audit_public_ids",
+		Up:   []string{`ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS resource_public_id uuid`},
+		Down: []string{`ALTER TABLE audit_log DROP COLUMN IF EXISTS resource_public_id`}},
+	// tenants are served once their schema is migrated, those registered before were migrated already
+	{Version: 7, Name: "tenant_readiness", Shared: true,
+		Up: []string{`ALTER TABLE tenants ADD COLUMN IF NOT EXISTS ready boolean NOT NULL DEFAULT true`,
+			`ALTER TABLE tenants ALTER COLUMN ready SET DEFAULT false`},
+		Down: []string{`ALTER TABLE tenants DROP COLUMN IF EXISTS ready`}},
+}
+
+// LatestMigration is the version Migrate brings the schema to by default.
+func LatestMigration() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// MigrationState tells whether a migration was applied, and when.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+version INT PRIMARY KEY,
+name varchar NOT NULL,
+applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// migrationLock keeps instances starting at the same time from applying a migration twice.
+const migrationLock = 5_732_114_023
+
+// Migrate applies the migrations up to the target version that were not applied yet, each in its own
+// transaction, and returns them.
+func (p *DB) Migrate(ctx context.Context, target int) ([]Migration, error) {
+	conn, err := p.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	if _, err = conn.Exec(ctx, migrationsTable); err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, migration := range migrationsFor(ctx) {
+		if migration.Version > target {
+			break
+		}
+		changed, err := runMigration(ctx, conn, migration, true)
+		if err != nil {
+			return applied, fmt.Errorf("applying migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if changed {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// Rollback reverts the last steps applied migrations, newest first, and returns them. It stops at the first
+// migration that cannot be reverted.
+func (p *DB) Rollback(ctx context.Context, steps int) ([]Migration, error) {
+	states, err := p.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := p.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	var reverted []Migration
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := states[i].Migration
+		if states[i].AppliedAt == nil {
+			continue
+		}
+		if migration.Down == nil {
+			return reverted, fmt.Errorf("migration %d %s cannot be reverted", migration.Version, migration.Name)
+		}
+		changed, err := runMigration(ctx, conn, migration, false)
+		if err != nil {
+			return reverted, fmt.Errorf("reverting migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		if changed {
+			reverted = append(reverted, migration)
+		}
+	}
+	return reverted, nil
+}
+
+// MigrationStatus returns every migration of the schema, in order, with when it was applied.
+func (p *DB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	conn, err := p.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	if _, err = conn.Exec(ctx, migrationsTable); err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	migrations := migrationsFor(ctx)
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i].Migration = migration
+		if appliedAt, ok := applied[migration.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// Reset empties every table of the schema but schema_migrations and restarts their identities, which brings
+// it back to the state of a fresh migration. The tenants and their quotas are kept. It is meant for development
+// and integration tests. The audit log is append-only, its trigger is bypassed as replicas do, which takes a
+// superuser.
+func (p *DB) Reset(ctx context.Context) error {
+	conn, err := p.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, "SELECT quote_ident(tablename) FROM pg_tables "+
+		"WHERE schemaname = current_schema() AND tablename NOT IN ('schema_migrations', 'tenants', 'quotas') "+
+		"ORDER BY tablename")
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(tables) == 0 {
+		return err
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, "SET LOCAL session_replication_role = replica"); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	if _, err = tx.Exec(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// migrationsFor leaves the Shared migrations out of those of tenant schemas.
+func migrationsFor(ctx context.Context) []Migration {
+	if _, ok := tenancy.TenantFromContext(ctx); !ok {
+		return Migrations
+	}
+	var migrations []Migration
+	for _, migration := range Migrations {
+		if !migration.Shared {
+			migrations = append(migrations, migration)
+		}
+	}
+	return migrations
+}
+
+// runMigration applies migration, or reverts it when up is false, unless another instance did already.
+func runMigration(ctx context.Context, conn PgxConn, migration Migration, up bool) (bool, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	changed, err := func() (bool, error) {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+			return false, err
+		}
+		var applied bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)",
+			migration.Version).Scan(&applied)
+		if err != nil || applied == up {
+			return false, err
+		}
+		statements := migration.Down
+		if up {
+			statements = migration.Up
+		}
+		for _, statement := range statements {
+			if _, err = tx.Exec(ctx, statement); err != nil {
+				return false, err
+			}
+		}
+		if up {
+			_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+				migration.Version, migration.Name)
+		} else {
+			_, err = tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+		}
+		return err == nil, err
+	}()
+	if err != nil || !changed {
+		tx.Rollback(ctx)
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
@@ -0,0 +1,173 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/database/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		ctx      context.Context
+		mockPgx  *mocks.MockPgx
+		mockConn pgxmock.PgxConnIface
+		db       *database.DB
+	)
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockPgx = mocks.NewMockPgx(gomock.NewController(GinkgoT()))
+		mockConn, _ = pgxmock.NewConn()
+		db = database.NewDB(mockPgx, "dbURL")
+	})
+
+	now := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+	expectLocked := func(version int, applied bool) {
+		mockConn.ExpectBegin()
+		mockConn.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		mockConn.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)")).
+			WithArgs(version).WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(applied))
+	}
+	expectStatus := func(versions ...int) {
+		mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		rows := pgxmock.NewRows([]string{"version", "applied_at"})
+		for _, version := range versions {
+			rows.AddRow(version, now)
+		}
+		mockConn.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).WillReturnRows(rows)
+		mockConn.ExpectClose()
+	}
+
+	It("applies the migrations that were not applied yet", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, "dbURL").Return(mockConn, nil)
+		mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		expectLocked(1, true)
+		mockConn.ExpectRollback()
+		expectLocked(2, false)
+		mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS api_keys")).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)")).
+			WithArgs(2, "api_keys").WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		mockConn.ExpectCommit()
+		mockConn.ExpectClose()
+
+		By("acting")
+		applied, err := db.Migrate(ctx, 2)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applied).To(HaveLen(1))
+		Expect(applied[0].Name).To(Equal("api_keys"))
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("stops at the first failing migration", func() {
+		By("arranging")
+		expectedErr := errors.New("some Exec error")
+		mockPgx.EXPECT().Connect(ctx, "dbURL").Return(mockConn, nil)
+		mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		expectLocked(1, false)
+		mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS resources")).WillReturnError(expectedErr)
+		mockConn.ExpectRollback()
+		mockConn.ExpectClose()
+
+		By("acting")
+		applied, err := db.Migrate(ctx, database.LatestMigration())
+
+		By("asserting")
+		Expect(errors.Is(err, expectedErr)).To(BeTrue())
+		Expect(err.Error()).To(HavePrefix("applying migration 1 baseline"))
+		Expect(applied).To(BeEmpty())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reverts the newest migrations down to one that cannot be reverted", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, "dbURL").Times(2).Return(mockConn, nil)
+		expectStatus(1, 2)
+		expectLocked(2, true)
+		mockConn.ExpectExec(regexp.QuoteMeta("DROP TABLE IF EXISTS api_keys")).
+			WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+		mockConn.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).
+			WithArgs(2).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		mockConn.ExpectCommit()
+		mockConn.ExpectClose()
+
+		By("acting")
+		reverted, err := db.Rollback(ctx, 2)
+
+		By("asserting")
+		Expect(err).To(MatchError("migration 1 baseline cannot be reverted"))
+		Expect(reverted).To(HaveLen(1))
+		Expect(reverted[0].Version).To(Equal(2))
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("reports which migrations were applied", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, "dbURL").Return(mockConn, nil)
+		expectStatus(1)
+
+		By("acting")
+		states, err := db.MigrationStatus(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(HaveLen(len(database.Migrations)))
+		Expect(states[0].AppliedAt).To(Equal(&now))
+		Expect(states[1].AppliedAt).To(BeNil())
+	})
+
+	It("empties every table but the migrations", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, "dbURL").Return(mockConn, nil)
+		mockConn.ExpectQuery(regexp.QuoteMeta("SELECT quote_ident(tablename) FROM pg_tables")).
+			WillReturnRows(pgxmock.NewRows([]string{"quote_ident"}).AddRow("api_keys").AddRow("audit_log").
+				AddRow("resources"))
+		mockConn.ExpectBegin()
+		mockConn.ExpectExec(regexp.QuoteMeta("SET LOCAL session_replication_role = replica")).
+			WillReturnResult(pgxmock.NewResult("SET", 0))
+		mockConn.ExpectExec(regexp.QuoteMeta("TRUNCATE api_keys, audit_log, resources RESTART IDENTITY CASCADE")).
+			WillReturnResult(pgxmock.NewResult("TRUNCATE", 0))
+		mockConn.ExpectCommit()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := db.Reset(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("keeps the tables when they cannot be emptied", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, "dbURL").Return(mockConn, nil)
+		mockConn.ExpectQuery(regexp.QuoteMeta("SELECT quote_ident(tablename) FROM pg_tables")).
+			WillReturnRows(pgxmock.NewRows([]string{"quote_ident"}).AddRow("audit_log"))
+		mockConn.ExpectBegin()
+		mockConn.ExpectExec(regexp.QuoteMeta("SET LOCAL session_replication_role = replica")).
+			WillReturnError(errors.New("permission denied to set parameter"))
+		mockConn.ExpectRollback()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := db.Reset(ctx)
+
+		By("asserting")
+		Expect(err).To(HaveOccurred())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+})
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ResourceChangesChannel is the channel the resources trigger NOTIFYs on every mutation.
+const ResourceChangesChannel = "resource_changes"
+
+type ChangeOp string
+
+const (
+	ChangeInsert ChangeOp = "INSERT"
+	ChangeUpdate ChangeOp = "UPDATE"
+	ChangeDelete ChangeOp = "DELETE"
+	// ChangeResync is dispatched after the listener reconnects, as notifications sent
+	// while it was disconnected are lost and subscribers should drop derived state.
+	ChangeResync ChangeOp = "RESYNC"
+)
+
+type ChangeEvent struct {
+	Op ChangeOp `json:"op"`
+	ID int      `json:"id"`
+	// PublicID lets subscribers name resources that can no longer be read, it is empty on ChangeResync.
+	PublicID string `json:"public_id"`
+	// Schema is where the resource changed, the IDs of resources are only unique within a schema.
+	Schema string `json:"schema,omitempty"`
+}
+
+// InScope reports whether the change was made in the schema of the tenant of ctx, or in the default schema
+// when ctx has no tenant. A single listener hears the changes of every tenant, subscribers serving one
+// pass on the changes in scope only. ChangeResync concerns every tenant.
+func (e ChangeEvent) InScope(ctx context.Context) bool {
+	if e.Op == ChangeResync {
+		return true
+	}
+	changed, ok := tenancy.FromSchema(e.Schema)
+	tenant, scoped := tenancy.TenantFromContext(ctx)
+	if !scoped {
+		return !ok
+	}
+	return ok && changed == tenant
+}
+
+// ListenConn is implemented by *pgx.Conn, pgxmock connections do not wait for notifications
+type ListenConn interface {
+	PgxConn
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+}
+
+var errNotListenConn = errors.New("connection does not support waiting for notifications")
+
+const (
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Listener keeps a dedicated connection LISTENing on a channel and fans the
+// notifications out to in-process subscribers, reconnecting whenever the connection drops.
+type Listener struct {
+	db      *DB
+	channel string
+
+	mu          sync.Mutex
+	subscribers map[int]*subscriber
+	nextID      int
+}
+
+// subscriber is resyncing while a ChangeResync waits for room in its buffer, the events dispatched meanwhile
+// are dropped as the resync covers them. dropped tells the resync whether some were dropped while it was sent.
+type subscriber struct {
+	events    chan ChangeEvent
+	done      chan struct{}
+	resyncing bool
+	dropped   bool
+	wg        sync.WaitGroup
+}
+
+func NewListener(db *DB, channel string) *Listener {
+	return &Listener{db: db, channel: channel, subscribers: make(map[int]*subscriber)}
+}
+
+// Subscribe registers a subscriber with the given buffer. A slow subscriber cannot stall the others, once its
+// buffer is full the events for it are replaced by a ChangeResync, which it gets as soon as it catches up.
+func (l *Listener) Subscribe(buffer int) (<-chan ChangeEvent, func()) {
+	s := &subscriber{events: make(chan ChangeEvent, buffer), done: make(chan struct{})}
+	l.mu.Lock()
+	id := l.nextID
+	l.nextID++
+	l.subscribers[id] = s
+	l.mu.Unlock()
+	var once sync.Once
+	return s.events, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subscribers, id)
+			l.mu.Unlock()
+			close(s.done)
+			// a pending resync has to give up before events can be closed
+			s.wg.Wait()
+			close(s.events)
+		})
+	}
+}
+
+// Run listens until ctx is cancelled.
+func (l *Listener) Run(ctx context.Context) error {
+	delay := minReconnectDelay
+	connected := false
+	for {
+		err := l.listen(ctx, func() {
+			if connected {
+				l.dispatch(ChangeEvent{Op: ChangeResync})
+			}
+			connected = true
+			delay = minReconnectDelay
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fmt.Fprintf(os.Stderr, "Listener on %s disconnected: %v\n", l.channel, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, onListening func()) error {
+	conn, err := l.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	listenConn, ok := conn.(ListenConn)
+	if !ok {
+		return errNotListenConn
+	}
+	if _, err = listenConn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return err
+	}
+	onListening()
+	for {
+		notification, err := listenConn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var event ChangeEvent
+		if err = json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid payload on %s: %v\n", l.channel, err)
+			continue
+		}
+		l.dispatch(event)
+	}
+}
+
+func (l *Listener) dispatch(event ChangeEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range l.subscribers {
+		if s.resyncing {
+			s.dropped = true
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			s.resyncing = true
+			s.wg.Add(1)
+			go l.resync(s)
+		}
+	}
+}
+
+// resync waits for room in the buffer of s for a ChangeResync. Events dropped while it waited may have been
+// dropped after it was sent, when the subscriber could have resynced already, so it sends another.
+func (l *Listener) resync(s *subscriber) {
+	defer s.wg.Done()
+	for {
+		l.mu.Lock()
+		s.dropped = false
+		l.mu.Unlock()
+		select {
+		case s.events <- ChangeEvent{Op: ChangeResync}:
+		case <-s.done:
+			return
+		}
+		l.mu.Lock()
+		if !s.dropped {
+			s.resyncing = false
+			l.mu.Unlock()
+			return
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,189 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/database/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgconn"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+type fakeListenConn struct {
+	pgxmock.PgxConnIface
+	notifications chan *pgconn.Notification
+}
+
+func (c fakeListenConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case n, ok := <-c.notifications:
+		if !ok {
+			return nil, errors.New("connection lost")
+		}
+		return n, nil
+	}
+}
+
+func newFakeListenConn() fakeListenConn {
+	mockConn, _ := pgxmock.NewConn()
+	mockConn.MatchExpectationsInOrder(false)
+	mockConn.ExpectExec(`LISTEN "resource_changes"`).WillReturnResult(pgxmock.NewResult("LISTEN", 0))
+	mockConn.ExpectClose()
+	return fakeListenConn{PgxConnIface: mockConn, notifications: make(chan *pgconn.Notification, 10)}
+}
+
+var _ = Describe("Listener", func() {
+	var (
+		ctx      context.Context
+		cancel   context.CancelFunc
+		ctrl     *gomock.Controller
+		mockPgx  *mocks.MockPgx
+		listener *database.Listener
+		done     chan error
+	)
+	databaseURL := "dbURL"
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		ctrl = gomock.NewController(GinkgoT())
+		mockPgx = mocks.NewMockPgx(ctrl)
+		listener = database.NewListener(database.NewDB(mockPgx, databaseURL), database.ResourceChangesChannel)
+		done = make(chan error, 1)
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(Receive(Equal(context.Canceled)))
+	})
+
+	run := func() {
+		go func() { done <- listener.Run(ctx) }()
+	}
+
+	It("dispatches typed events to every subscriber", func() {
+		By("arranging")
+		conn := newFakeListenConn()
+		mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(conn, nil)
+		first, _ := listener.Subscribe(1)
+		second, _ := listener.Subscribe(1)
+
+		By("acting")
+		run()
+		conn.notifications <- &pgconn.Notification{Payload: `{"op":"UPDATE","id":7,"public_id":"00000000-0000-7000-8000-000000000007"}`}
+
+		By("asserting")
+		expected := database.ChangeEvent{Op: database.ChangeUpdate, ID: 7, PublicID: "00000000-0000-7000-8000-000000000007"}
+		Eventually(first).Should(Receive(Equal(expected)))
+		Eventually(second).Should(Receive(Equal(expected)))
+	})
+
+	It("skips invalid payloads", func() {
+		By("arranging")
+		conn := newFakeListenConn()
+		mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(conn, nil)
+		events, _ := listener.Subscribe(2)
+
+		By("acting")
+		run()
+		conn.notifications <- &pgconn.Notification{Payload: `not json`}
+		conn.notifications <- &pgconn.Notification{Payload: `{"op":"DELETE","id":3}`}
+
+		By("asserting")
+		Eventually(events).Should(Receive(Equal(database.ChangeEvent{Op: database.ChangeDelete, ID: 3})))
+	})
+
+	It("stops delivering after unsubscribe", func() {
+		By("arranging")
+		conn := newFakeListenConn()
+		mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(conn, nil)
+		events, unsubscribe := listener.Subscribe(1)
+
+		By("acting")
+		run()
+		unsubscribe()
+		conn.notifications <- &pgconn.Notification{Payload: `{"op":"INSERT","id":1}`}
+
+		By("asserting")
+		Eventually(events).Should(BeClosed())
+	})
+
+	It("asks subscribers whose buffer filled up to resync once they catch up", func() {
+		By("arranging")
+		conn := newFakeListenConn()
+		mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(conn, nil)
+		slow, _ := listener.Subscribe(1)
+		fast, _ := listener.Subscribe(10)
+
+		By("acting")
+		run()
+		for id := 1; id <= 3; id++ {
+			conn.notifications <- &pgconn.Notification{Payload: fmt.Sprintf(`{"op":"UPDATE","id":%d}`, id)}
+			Eventually(fast).Should(Receive(Equal(database.ChangeEvent{Op: database.ChangeUpdate, ID: id})))
+		}
+
+		By("asserting")
+		Expect(slow).To(Receive(Equal(database.ChangeEvent{Op: database.ChangeUpdate, ID: 1})))
+		Eventually(slow).Should(Receive(Equal(database.ChangeEvent{Op: database.ChangeResync})))
+		// events dispatched until it has caught up with the resyncs are covered by them rather than delivered
+		Eventually(func() database.ChangeEvent {
+			conn.notifications <- &pgconn.Notification{Payload: `{"op":"UPDATE","id":4}`}
+			select {
+			case event := <-slow:
+				return event
+			case <-time.After(10 * time.Millisecond):
+				return database.ChangeEvent{}
+			}
+		}).Should(Equal(database.ChangeEvent{Op: database.ChangeUpdate, ID: 4}))
+	})
+
+	It("closes subscriptions whose resync is still waiting", func() {
+		By("arranging")
+		conn := newFakeListenConn()
+		mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(conn, nil)
+		slow, unsubscribe := listener.Subscribe(1)
+		fast, _ := listener.Subscribe(10)
+		run()
+		for id := 1; id <= 2; id++ {
+			conn.notifications <- &pgconn.Notification{Payload: fmt.Sprintf(`{"op":"UPDATE","id":%d}`, id)}
+			Eventually(fast).Should(Receive())
+		}
+
+		By("acting")
+		unsubscribe()
+
+		By("asserting")
+		Expect(slow).To(Receive(Equal(database.ChangeEvent{Op: database.ChangeUpdate, ID: 1})))
+		Expect(slow).To(BeClosed())
+	})
+
+	It("reconnects and asks subscribers to resync", func() {
+		By("arranging")
+		dropped := newFakeListenConn()
+		reconnected := newFakeListenConn()
+		gomock.InOrder(
+			mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(nil, errors.New("connection refused")),
+			mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(dropped, nil),
+			mockPgx.EXPECT().Connect(gomock.Any(), databaseURL).Return(reconnected, nil),
+		)
+		events, _ := listener.Subscribe(10)
+
+		By("acting")
+		run()
+		dropped.notifications <- &pgconn.Notification{Payload: `{"op":"INSERT","id":1}`}
+		Eventually(events, time.Second).Should(Receive(Equal(database.ChangeEvent{Op: database.ChangeInsert, ID: 1})))
+		close(dropped.notifications)
+
+		By("asserting")
+		Eventually(events, time.Second).Should(Receive(Equal(database.ChangeEvent{Op: database.ChangeResync})))
+		reconnected.notifications <- &pgconn.Notification{Payload: `{"op":"INSERT","id":2}`}
+		Eventually(events, time.Second).Should(Receive(Equal(database.ChangeEvent{Op: database.ChangeInsert, ID: 2})))
+	})
+})
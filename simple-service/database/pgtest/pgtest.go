@@ -0,0 +1,198 @@
+// Package pgtest gives the integration tests a real Postgres, either the database at TEST_DATABASE_URL or
+// a throwaway server started from the binaries of POSTGRES_BIN, $PATH or the usual install directories.
+// initdb refuses to run as root, so the throwaway server needs an unprivileged user.
+package pgtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// EnvDatabaseURL is the URL of a database the tests may create and drop schemas in.
+	EnvDatabaseURL = "TEST_DATABASE_URL"
+	// EnvPostgresBin is the directory holding the initdb and postgres binaries.
+	EnvPostgresBin = "POSTGRES_BIN"
+)
+
+var ErrNoPostgres = fmt.Errorf("no Postgres to test against, set %s or %s", EnvDatabaseURL, EnvPostgresBin)
+
+// Server is the Postgres the tests run against, isolated from each other in schemas of their own.
+type Server struct {
+	URL     string
+	dir     string
+	cmd     *exec.Cmd
+	schemas int64
+}
+
+// Start connects to the database at TEST_DATABASE_URL, or starts a server in a temporary directory.
+func Start(ctx context.Context) (*Server, error) {
+	if databaseURL := os.Getenv(EnvDatabaseURL); databaseURL != "" {
+		server := &Server{URL: databaseURL}
+		return server, server.prepare(ctx)
+	}
+	bin, err := findBinaries()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "pgtest")
+	if err != nil {
+		return nil, err
+	}
+	data := filepath.Join(dir, "data")
+	initdb := exec.CommandContext(ctx, filepath.Join(bin, "initdb"), "-D", data, "-U", "postgres", "-A", "trust",
+		"-E", "UTF8", "--no-sync")
+	if output, err := initdb.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %w: %s", err, output)
+	}
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	defer logFile.Close()
+	// durability is of no use to a database that is thrown away
+	cmd := exec.Command(filepath.Join(bin, "postgres"), "-D", data, "-p", strconv.Itoa(port), "-h", "127.0.0.1",
+		"-k", dir, "-F", "-c", "synchronous_commit=off", "-c", "full_page_writes=off")
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	if err = cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	server := &Server{URL: fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port),
+		dir: dir, cmd: cmd}
+	if err = server.waitReady(ctx, 30*time.Second); err != nil {
+		output, _ := os.ReadFile(logFile.Name())
+		server.Stop()
+		return nil, fmt.Errorf("starting postgres: %w: %s", err, output)
+	}
+	return server, server.prepare(ctx)
+}
+
+// Stop stops the server Start started and removes its files, databases at TEST_DATABASE_URL are left alone.
+func (s *Server) Stop() error {
+	if s.cmd == nil {
+		return nil
+	}
+	defer os.RemoveAll(s.dir)
+	// SIGINT is the fast shutdown, which does not wait for clients to disconnect
+	if err := s.cmd.Process.Signal(os.Interrupt); err != nil {
+		return s.cmd.Process.Kill()
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		return s.cmd.Process.Kill()
+	}
+}
+
+// CreateSchema creates an empty schema and returns the URL of connections creating and finding tables in it.
+// Like those of tenants, the connections do not search the public schema the extensions are in.
+func (s *Server) CreateSchema(ctx context.Context) (schema, schemaURL string, err error) {
+	schema = fmt.Sprintf("spec_%d_%d", os.Getpid(), atomic.AddInt64(&s.schemas, 1))
+	if err = s.exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		return "", "", err
+	}
+	parsed, err := url.Parse(s.URL)
+	if err != nil {
+		return "", "", err
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+	return schema, parsed.String(), nil
+}
+
+func (s *Server) DropSchema(ctx context.Context, schema string) error {
+	return s.exec(ctx, "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+}
+
+// prepare installs the extensions of the migrations once, as they belong to the database rather than to a schema.
+func (s *Server) prepare(ctx context.Context) error {
+	return s.exec(ctx, "CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public")
+}
+
+func (s *Server) exec(ctx context.Context, sql string) error {
+	conn, err := pgx.Connect(ctx, s.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+func (s *Server) waitReady(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := pgx.Connect(ctx, s.URL)
+		if err == nil {
+			return conn.Close(ctx)
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// findBinaries returns the directory of initdb and postgres, the newest installed version wins.
+func findBinaries() (string, error) {
+	if bin := os.Getenv(EnvPostgresBin); bin != "" {
+		return bin, nil
+	}
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(initdb), nil
+	}
+	installed, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	more, _ := filepath.Glob("/usr/local/opt/postgresql*/bin/initdb")
+	installed = append(installed, more...)
+	if len(installed) == 0 {
+		return "", ErrNoPostgres
+	}
+	sort.Slice(installed, func(i, j int) bool { return version(installed[i]) > version(installed[j]) })
+	return filepath.Dir(installed[0]), nil
+}
+
+// version is the major version in the install path of initdb, e.g. 16 for /usr/lib/postgresql/16/bin/initdb.
+func version(initdb string) int {
+	for dir := filepath.Dir(initdb); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if major, err := strconv.Atoi(filepath.Base(dir)); err == nil {
+			return major
+		}
+	}
+	return 0
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
@@ -17,6 +18,7 @@ type Pgx interface {
 //PgxConn allows using pgxmock in tests
 type PgxConn interface {
 	Begin(context.Context) (pgx.Tx, error)
+	BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error)
 	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
 	QueryRow(context.Context, string, ...interface{}) pgx.Row
 	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
@@ -25,17 +27,45 @@ type PgxConn interface {
 	Close(context.Context) error
 }
 
+// DB connects to the primary, and GetReadConn to the replicas at replicaURLs while they keep up with it.
 type DB struct {
 	pgx         Pgx
 	databaseURL string
+	replicas    []*replica
+	next        uint32
+	// MaxReplicaLag is how far replicas may fall behind the primary before CheckReplicas ejects them.
+	MaxReplicaLag time.Duration
+	// Retry retries connecting to the primary, Breaker stops it while the primary is down.
+	Retry   RetryPolicy
+	Breaker *Breaker
 }
 
-func NewDB(pgx Pgx, databaseURL string) *DB {
-	return &DB{pgx: pgx, databaseURL: databaseURL}
+func NewDB(pgx Pgx, databaseURL string, replicaURLs ...string) *DB {
+	replicas := make([]*replica, len(replicaURLs))
+	for i, url := range replicaURLs {
+		replicas[i] = &replica{url: url}
+	}
+	return &DB{pgx: pgx, databaseURL: databaseURL, replicas: replicas, MaxReplicaLag: 5 * time.Second,
+		Retry: DefaultRetryPolicy, Breaker: NewBreaker(5, 10*time.Second)}
 }
 
+// GetConn connects to the primary, retrying transient failures. It fails fast with a *CircuitOpenError
+// while the Breaker is open. Connections made for a tenant only find the tables of its schema.
 func (p *DB) GetConn(ctx context.Context) (PgxConn, error) {
-	conn, err := p.pgx.Connect(ctx, p.databaseURL)
+	// an invalid tenant fails before Allow, which would wait for the outcome of a half-open probe
+	databaseURL, err := tenantConnString(ctx, p.databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+	var conn PgxConn
+	err = p.Retry.Do(ctx, IsTransient, func() (err error) {
+		conn, err = p.pgx.Connect(ctx, databaseURL)
+		return err
+	})
+	p.Breaker.Record(err)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
 		return nil, err
@@ -49,9 +79,192 @@ func (p *DB) Seed(ctx context.Context) error {
 		return err
 	}
 	defer conn.Close(ctx)
-	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS resources (
+	for _, statement := range schema {
+		if _, err = conn.Exec(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS resources (
 id INT GENERATED ALWAYS AS IDENTITY, 
 name varchar
-)`)
-	return err
+)`,
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS deleted_at timestamptz`,
+	`CREATE INDEX IF NOT EXISTS resources_deleted_at ON resources (deleted_at) WHERE deleted_at IS NOT NULL`,
+	notifyResourceChange(""),
+	`DROP TRIGGER IF EXISTS resources_notify_change ON resources`,
+	`CREATE TRIGGER resources_notify_change AFTER INSERT OR UPDATE OR DELETE ON resources
+FOR EACH ROW EXECUTE FUNCTION notify_resource_change()`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+aggregate_id INT NOT NULL,
+event_type varchar NOT NULL,
+payload jsonb NOT NULL,
+created_at timestamptz NOT NULL DEFAULT now(),
+attempts INT NOT NULL DEFAULT 0,
+next_attempt_at timestamptz NOT NULL DEFAULT now(),
+last_error text,
+published_at timestamptz
+)`,
+	`CREATE INDEX IF NOT EXISTS outbox_pending ON outbox (aggregate_id, id) WHERE published_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+url varchar NOT NULL,
+events varchar[] NOT NULL DEFAULT '{}',
+secret varchar NOT NULL,
+active boolean NOT NULL DEFAULT true,
+created_at timestamptz NOT NULL DEFAULT now()
+)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+webhook_id INT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+event_id BIGINT NOT NULL,
+event_type varchar NOT NULL,
+payload jsonb NOT NULL,
+status varchar NOT NULL DEFAULT 'pending',
+attempts INT NOT NULL DEFAULT 0,
+last_status_code INT NOT NULL DEFAULT 0,
+last_error text NOT NULL DEFAULT '',
+next_attempt_at timestamptz NOT NULL DEFAULT now(),
+created_at timestamptz NOT NULL DEFAULT now(),
+delivered_at timestamptz,
+UNIQUE (webhook_id, event_id)
+)`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`,
+	`CREATE TABLE IF NOT EXISTS resource_revisions (
+id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+resource_id INT NOT NULL,
+revision INT NOT NULL,
+operation varchar NOT NULL,
+previous jsonb,
+state jsonb,
+actor varchar NOT NULL,
+created_at timestamptz NOT NULL DEFAULT now(),
+UNIQUE (resource_id, revision)
+)`,
+	// the actor and reason are set by repositories with set_config, changes made outside of them are the system's
+	`CREATE OR REPLACE FUNCTION record_resource_revision() RETURNS trigger AS $$
+DECLARE
+	op text := lower(TG_OP);
+	changed_id int;
+	old_state jsonb;
+	new_state jsonb;
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		op := 'create';
+	ELSIF TG_OP = 'DELETE' THEN
+		op := 'purge';
+	ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+		op := 'delete';
+	ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+		op := 'restore';
+	ELSIF OLD.status IS DISTINCT FROM NEW.status THEN
+		op := 'transition';
+	END IF;
+	IF TG_OP <> 'INSERT' THEN
+		changed_id := OLD.id;
+		old_state := public_resource_state(OLD);
+	END IF;
+	IF TG_OP <> 'DELETE' THEN
+		changed_id := NEW.id;
+		new_state := public_resource_state(NEW);
+	END IF;
+	INSERT INTO resource_revisions (resource_id, revision, operation, previous, state, actor, reason)
+	SELECT changed_id, COALESCE(max(revision), 0) + 1, op, old_state, new_state,
+		COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system'),
+		COALESCE(current_setting('app.reason', true), '')
+	FROM resource_revisions WHERE resource_revisions.resource_id = changed_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS resources_record_revision ON resources`,
+	`CREATE TRIGGER resources_record_revision AFTER INSERT OR UPDATE OR DELETE ON resources
+FOR EACH ROW EXECUTE FUNCTION record_resource_revision()`,
+	// lets the audit log pick up the revisions written by the transaction it records
+	`ALTER TABLE resource_revisions ADD COLUMN IF NOT EXISTS txid bigint NOT NULL DEFAULT txid_current()`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+principal varchar NOT NULL,
+remote_addr varchar NOT NULL,
+request_id varchar NOT NULL,
+method varchar NOT NULL,
+route varchar NOT NULL,
+resource_id INT,
+before jsonb,
+after jsonb,
+created_at timestamptz NOT NULL,
+prev_hash varchar NOT NULL UNIQUE,
+hash varchar NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS audit_log_principal ON audit_log (principal, created_at)`,
+	`CREATE INDEX IF NOT EXISTS audit_log_resource ON audit_log (resource_id, created_at)`,
+	`CREATE OR REPLACE FUNCTION reject_audit_log_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log`,
+	`CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_log_change()`,
+	// tenant schemas do not search public, so what the extension installs there is named with its schema
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public`,
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS search tsvector
+GENERATED ALWAYS AS (to_tsvector('simple', coalesce(name, ''))) STORED`,
+	`CREATE INDEX IF NOT EXISTS resources_search ON resources USING gin (search)`,
+	`CREATE INDEX IF NOT EXISTS resources_name_trgm ON resources USING gin (name public.gin_trgm_ops)`,
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS attributes jsonb NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}'`,
+	`CREATE INDEX IF NOT EXISTS resources_labels ON resources USING gin (labels)`,
+	// resources that predate the lifecycle are already in use
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS status varchar NOT NULL DEFAULT 'active'`,
+	`ALTER TABLE resource_revisions ADD COLUMN IF NOT EXISTS reason varchar NOT NULL DEFAULT ''`,
+	// purging a parent orphans the children left in place by its delete policy
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS parent_id integer REFERENCES resources (id) ON DELETE SET NULL`,
+	`CREATE INDEX IF NOT EXISTS resources_parent_id ON resources (parent_id)`,
+	// time-ordered like the identity column without giving away how many rows there are
+	`CREATE OR REPLACE FUNCTION uuid_generate_v7() RETURNS uuid AS $$
+SELECT encode(set_bit(set_bit(overlay(uuid_send(gen_random_uuid())
+	PLACING substring(int8send(floor(extract(epoch FROM clock_timestamp()) * 1000)::bigint) FROM 3)
+	FROM 1 FOR 6), 52, 1), 53, 1), 'hex')::uuid
+$$ LANGUAGE sql VOLATILE`,
+	`ALTER TABLE resources ADD COLUMN IF NOT EXISTS public_id uuid NOT NULL DEFAULT uuid_generate_v7()`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS resources_public_id ON resources (public_id)`,
+	// revisions are served as they are stored, so they name resources by public ID too
+	`CREATE OR REPLACE FUNCTION public_resource_state(resource resources) RETURNS jsonb AS $$
+SELECT to_jsonb(resource) - 'search' - 'public_id' || jsonb_build_object(
+	'id', resource.public_id,
+	'parent_id', (SELECT parent.public_id FROM resources parent WHERE parent.id = resource.parent_id))
+$$ LANGUAGE sql STABLE`,
+	`UPDATE resource_revisions SET
+previous = previous || jsonb_build_object('id', resources.public_id,
+	'parent_id', (SELECT parent.public_id FROM resources parent WHERE parent.id = (previous->>'parent_id')::int)),
+state = state || jsonb_build_object('id', resources.public_id,
+	'parent_id', (SELECT parent.public_id FROM resources parent WHERE parent.id = (state->>'parent_id')::int))
+FROM resources
+WHERE resources.id = resource_revisions.resource_id AND jsonb_typeof(COALESCE(state, previous)->'id') = 'number'`,
+}
+
+// notifyResourceChange announces the changes of resources with their ID and public ID, and the fields of payload.
+// Soft deletes and restores are announced as deletes and inserts, as that is how readers observe them.
+func notifyResourceChange(payload string) string {
+	return `CREATE OR REPLACE FUNCTION notify_resource_change() RETURNS trigger AS $$
+DECLARE
+	op text := TG_OP;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM pg_notify('` + ResourceChangesChannel + `', json_build_object('op', op, 'id', OLD.id, 'public_id', OLD.public_id` + payload + `)::text);
+		RETURN NULL;
+	END IF;
+	IF TG_OP = 'UPDATE' AND OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+		op := 'DELETE';
+	ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+		op := 'INSERT';
+	END IF;
+	PERFORM pg_notify('` + ResourceChangesChannel + `', json_build_object('op', op, 'id', NEW.id, 'public_id', NEW.public_id` + payload + `)::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`
 }
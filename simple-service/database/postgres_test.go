@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"regexp"
+	"time"
 
 	"github.com/addme96/simple-go-service/simple-service/database"
 	"github.com/addme96/simple-go-service/simple-service/database/mocks"
 	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -55,6 +57,40 @@ var _ = Describe("Postgres", func() {
 				Expect(err).To(Equal(expectedErr))
 				Expect(conn).To(BeNil())
 			})
+
+			It("retries transient errors", func() {
+				By("arranging")
+				db.Retry.BaseDelay = time.Millisecond
+				shutdown := &pgconn.PgError{Code: "57P01"}
+				gomock.InOrder(
+					mockPgx.EXPECT().Connect(ctx, databaseURL).Times(1).Return(nil, shutdown),
+					mockPgx.EXPECT().Connect(ctx, databaseURL).Times(1).Return(&pgx.Conn{}, nil),
+				)
+
+				By("acting")
+				conn, err := db.GetConn(ctx)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conn).NotTo(BeNil())
+			})
+
+			It("fails fast while the breaker is open", func() {
+				By("arranging")
+				db.Breaker = database.NewBreaker(1, time.Minute)
+				mockPgx.EXPECT().Connect(ctx, databaseURL).Times(1).Return(nil, errors.New("some pgx Connect error"))
+				_, err := db.GetConn(ctx)
+				Expect(err).To(HaveOccurred())
+
+				By("acting")
+				conn, err := db.GetConn(ctx)
+
+				By("asserting")
+				var circuitOpen *database.CircuitOpenError
+				Expect(errors.As(err, &circuitOpen)).To(BeTrue())
+				Expect(circuitOpen.RetryAfter).To(BeNumerically("~", time.Minute, time.Second))
+				Expect(conn).To(BeNil())
+			})
 		})
 	})
 
@@ -73,6 +109,80 @@ name varchar
 )`
 				mockConn.ExpectExec(
 					regexp.QuoteMeta(query)).WillReturnResult(pgxmock.NewResult("some result", 1))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS deleted_at")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS resources_deleted_at")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE OR REPLACE FUNCTION notify_resource_change()")).
+					WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("DROP TRIGGER IF EXISTS resources_notify_change ON resources")).
+					WillReturnResult(pgxmock.NewResult("DROP TRIGGER", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TRIGGER resources_notify_change")).
+					WillReturnResult(pgxmock.NewResult("CREATE TRIGGER", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS outbox")).
+					WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS outbox_pending")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS webhooks")).
+					WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS webhook_deliveries")).
+					WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS webhook_deliveries_due")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS resource_revisions")).
+					WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE OR REPLACE FUNCTION record_resource_revision()")).
+					WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("DROP TRIGGER IF EXISTS resources_record_revision ON resources")).
+					WillReturnResult(pgxmock.NewResult("DROP TRIGGER", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TRIGGER resources_record_revision")).
+					WillReturnResult(pgxmock.NewResult("CREATE TRIGGER", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resource_revisions ADD COLUMN IF NOT EXISTS txid")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS audit_log")).
+					WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS audit_log_principal")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS audit_log_resource")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE OR REPLACE FUNCTION reject_audit_log_change()")).
+					WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log")).
+					WillReturnResult(pgxmock.NewResult("DROP TRIGGER", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TRIGGER audit_log_append_only")).
+					WillReturnResult(pgxmock.NewResult("CREATE TRIGGER", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE EXTENSION IF NOT EXISTS pg_trgm")).
+					WillReturnResult(pgxmock.NewResult("CREATE EXTENSION", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS search tsvector")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS resources_search")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS resources_name_trgm")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS attributes jsonb")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS labels jsonb")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS resources_labels")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS status")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resource_revisions ADD COLUMN IF NOT EXISTS reason")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS parent_id")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS resources_parent_id")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE OR REPLACE FUNCTION uuid_generate_v7()")).
+					WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("ALTER TABLE resources ADD COLUMN IF NOT EXISTS public_id uuid")).
+					WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE UNIQUE INDEX IF NOT EXISTS resources_public_id")).
+					WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE OR REPLACE FUNCTION public_resource_state(resource resources)")).
+					WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+				mockConn.ExpectExec(regexp.QuoteMeta("UPDATE resource_revisions SET")).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 0))
 				mockConn.ExpectClose()
 
 				By("acting")
@@ -96,6 +206,22 @@ name varchar
 				By("asserting")
 				Expect(err).To(Equal(expectedErr))
 			})
+
+			It("stops at the first failing statement", func() {
+				By("arranging")
+				expectedErr := errors.New("some Exec error")
+				mockPgx.EXPECT().Connect(ctx, databaseURL).Times(1).Return(mockConn, nil)
+				mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS resources")).
+					WillReturnError(expectedErr)
+				mockConn.ExpectClose()
+
+				By("acting")
+				err := db.Seed(ctx)
+
+				By("asserting")
+				Expect(err).To(Equal(expectedErr))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
 		})
 	})
 })
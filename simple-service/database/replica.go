@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// replicaLagQuery reports no lag while the replica has replayed all it received, as the replay
+// timestamp stands still whenever the primary is not written to.
+const replicaLagQuery = `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END`
+
+type replica struct {
+	url string
+	// healthy is set by CheckReplicas, replicas serve no reads until they were checked once
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// setHealthy returns whether the health of the replica changed.
+func (r *replica) setHealthy(healthy bool) bool {
+	var value int32
+	if healthy {
+		value = 1
+	}
+	return atomic.SwapInt32(&r.healthy, value) != value
+}
+
+type primaryKey struct{}
+
+// WithPrimary makes GetReadConn connect to the primary, so that reads see the writes that replicas may not have yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// ReadsPrimary reports whether ctx was made by WithPrimary.
+func ReadsPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// GetReadConn connects to the healthy replicas in turn, or to the primary when ctx reads from it or none is healthy.
+// A replica that refuses the connection is ejected until it passes CheckReplicas again.
+func (p *DB) GetReadConn(ctx context.Context) (PgxConn, error) {
+	if !ReadsPrimary(ctx) && len(p.replicas) > 0 {
+		start := int(atomic.AddUint32(&p.next, 1))
+		for i := range p.replicas {
+			index := (start + i) % len(p.replicas)
+			replica := p.replicas[index]
+			if !replica.isHealthy() {
+				continue
+			}
+			replicaURL, err := tenantConnString(ctx, replica.url)
+			if err != nil {
+				return nil, err
+			}
+			conn, err := p.pgx.Connect(ctx, replicaURL)
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			fmt.Fprintf(os.Stderr, "Unable to connect to replica %d, ejecting it: %v\n", index, err)
+			replica.setHealthy(false)
+		}
+	}
+	return p.GetConn(ctx)
+}
+
+// Replicas counts the replicas, serving reads or not.
+func (p *DB) Replicas() int {
+	return len(p.replicas)
+}
+
+// HealthyReplicas counts the replicas serving reads.
+func (p *DB) HealthyReplicas() int {
+	healthy := 0
+	for _, replica := range p.replicas {
+		if replica.isHealthy() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// CheckReplicas ejects the replicas that are unreachable or lag more than MaxReplicaLag and brings back the others.
+func (p *DB) CheckReplicas(ctx context.Context) {
+	for index, replica := range p.replicas {
+		lag, err := p.replicaLag(ctx, replica)
+		if err == nil && lag > p.MaxReplicaLag {
+			err = fmt.Errorf("lagging %v behind the primary", lag)
+		}
+		if replica.setHealthy(err == nil) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Ejecting replica %d: %v\n", index, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Replica %d is serving reads\n", index)
+			}
+		}
+	}
+}
+
+// MonitorReplicas runs CheckReplicas every interval until ctx is cancelled.
+func (p *DB) MonitorReplicas(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.CheckReplicas(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *DB) replicaLag(ctx context.Context, replica *replica) (time.Duration, error) {
+	conn, err := p.pgx.Connect(ctx, replica.url)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(ctx)
+	var seconds float64
+	if err = conn.QueryRow(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
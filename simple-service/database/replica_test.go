@@ -0,0 +1,123 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/database/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Replicas", func() {
+	var (
+		ctx     context.Context
+		ctrl    *gomock.Controller
+		mockPgx *mocks.MockPgx
+		primary pgxmock.PgxConnIface
+		replica pgxmock.PgxConnIface
+		db      *database.DB
+	)
+	lagQuery := regexp.QuoteMeta("SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn()")
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ctrl = gomock.NewController(GinkgoT())
+		mockPgx = mocks.NewMockPgx(ctrl)
+		primary, _ = pgxmock.NewConn()
+		replica, _ = pgxmock.NewConn()
+		db = database.NewDB(mockPgx, "primaryURL", "replicaURL")
+	})
+
+	// check runs CheckReplicas with the replica lagging seconds behind
+	check := func(seconds float64) {
+		mockPgx.EXPECT().Connect(ctx, "replicaURL").Times(1).Return(replica, nil)
+		replica.ExpectQuery(lagQuery).WillReturnRows(pgxmock.NewRows([]string{"lag"}).AddRow(seconds))
+		replica.ExpectClose()
+		db.CheckReplicas(ctx)
+	}
+
+	It("reads from the primary until the replicas are checked", func() {
+		mockPgx.EXPECT().Connect(ctx, "primaryURL").Times(1).Return(primary, nil)
+
+		conn, err := db.GetReadConn(ctx)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn).To(Equal(primary))
+		Expect(db.HealthyReplicas()).To(Equal(0))
+	})
+
+	It("reads from replicas that keep up", func() {
+		By("arranging")
+		check(0.5)
+		mockPgx.EXPECT().Connect(ctx, "replicaURL").Times(1).Return(replica, nil)
+
+		By("acting")
+		conn, err := db.GetReadConn(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn).To(Equal(replica))
+		Expect(db.HealthyReplicas()).To(Equal(1))
+	})
+
+	It("reads from the primary when asked to", func() {
+		By("arranging")
+		check(0)
+		primaryCtx := database.WithPrimary(ctx)
+		mockPgx.EXPECT().Connect(primaryCtx, "primaryURL").Times(1).Return(primary, nil)
+
+		By("acting")
+		conn, err := db.GetReadConn(primaryCtx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn).To(Equal(primary))
+	})
+
+	It("ejects replicas lagging too far behind", func() {
+		By("arranging")
+		check(0)
+		check(db.MaxReplicaLag.Seconds() + 1)
+		mockPgx.EXPECT().Connect(ctx, "primaryURL").Times(1).Return(primary, nil)
+
+		By("acting")
+		conn, err := db.GetReadConn(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn).To(Equal(primary))
+		Expect(db.HealthyReplicas()).To(Equal(0))
+	})
+
+	It("ejects replicas failing the health check", func() {
+		By("arranging")
+		check(0)
+		mockPgx.EXPECT().Connect(ctx, "replicaURL").Times(1).Return(nil, errors.New("connection refused"))
+
+		By("acting")
+		db.CheckReplicas(ctx)
+
+		By("asserting")
+		Expect(db.HealthyReplicas()).To(Equal(0))
+	})
+
+	It("ejects replicas refusing connections and falls back to the primary", func() {
+		By("arranging")
+		check(0)
+		mockPgx.EXPECT().Connect(ctx, "replicaURL").Times(1).Return(nil, errors.New("connection refused"))
+		mockPgx.EXPECT().Connect(ctx, "primaryURL").Times(1).Return(primary, nil)
+
+		By("acting")
+		conn, err := db.GetReadConn(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn).To(Equal(primary))
+		Expect(db.HealthyReplicas()).To(Equal(0))
+	})
+})
@@ -0,0 +1,88 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/jackc/pgconn"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retries", func() {
+	DescribeTable("IsTransient",
+		func(err error, transient bool) {
+			Expect(database.IsTransient(err)).To(Equal(transient))
+		},
+		Entry("no error", nil, false),
+		Entry("serialization failure", &pgconn.PgError{Code: "40001"}, true),
+		Entry("deadlock", &pgconn.PgError{Code: "40P01"}, true),
+		Entry("admin shutdown", &pgconn.PgError{Code: "57P01"}, true),
+		Entry("starting up", &pgconn.PgError{Code: "57P03"}, true),
+		Entry("connection failure", &pgconn.PgError{Code: "08006"}, true),
+		Entry("unique violation", &pgconn.PgError{Code: "23505"}, false),
+		Entry("connection refused", fmt.Errorf("failed to connect: %w", syscall.ECONNREFUSED), true),
+		Entry("dial error", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true),
+		Entry("other errors", errors.New("some err"), false),
+	)
+
+	Context("RetryPolicy", func() {
+		policy := database.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+		transient := &pgconn.PgError{Code: "57P01"}
+
+		It("retries transient errors until fn succeeds", func() {
+			attempts := 0
+			err := policy.Do(context.Background(), database.IsTransient, func() error {
+				if attempts++; attempts < 3 {
+					return transient
+				}
+				return nil
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attempts).To(Equal(3))
+		})
+
+		It("gives up after MaxRetries", func() {
+			attempts := 0
+			err := policy.Do(context.Background(), database.IsTransient, func() error {
+				attempts++
+				return transient
+			})
+
+			Expect(err).To(Equal(transient))
+			Expect(attempts).To(Equal(3))
+		})
+
+		It("does not retry other errors", func() {
+			attempts := 0
+			err := policy.Do(context.Background(), database.IsTransient, func() error {
+				attempts++
+				return errors.New("some err")
+			})
+
+			Expect(err).To(HaveOccurred())
+			Expect(attempts).To(Equal(1))
+		})
+
+		It("does not wait past the deadline of ctx", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			slow := database.RetryPolicy{MaxRetries: 5, BaseDelay: time.Minute, MaxDelay: time.Minute}
+			attempts := 0
+			start := time.Now()
+			err := slow.Do(ctx, database.IsTransient, func() error {
+				attempts++
+				return transient
+			})
+
+			Expect(err).To(Equal(transient))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+	})
+})
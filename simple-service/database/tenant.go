@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+)
+
+// tenantConnString scopes the connections made for the tenant of ctx to its schema, and to it only, so that
+// nothing left in the default schema is found in its stead. The extensions in public are named with their schema.
+func tenantConnString(ctx context.Context, connString string) (string, error) {
+	tenant, ok := tenancy.TenantFromContext(ctx)
+	if !ok {
+		return connString, nil
+	}
+	if err := tenancy.Validate(tenant); err != nil {
+		return "", err
+	}
+	searchPath := pgx.Identifier{tenancy.Schema(tenant)}.Sanitize()
+	if !strings.HasPrefix(connString, "postgres://") && !strings.HasPrefix(connString, "postgresql://") {
+		// a keyword/value connection string, whose later settings win
+		return connString + " search_path='" + searchPath + "'", nil
+	}
+	parsed, err := url.Parse(connString)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set("search_path", searchPath)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
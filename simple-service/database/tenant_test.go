@@ -0,0 +1,115 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/database/mocks"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Tenants", func() {
+	var (
+		ctx     context.Context
+		mockPgx *mocks.MockPgx
+	)
+
+	BeforeEach(func() {
+		ctx = tenancy.WithTenant(context.Background(), "acme")
+		mockPgx = mocks.NewMockPgx(gomock.NewController(GinkgoT()))
+	})
+
+	It("connects tenants to their schema", func() {
+		By("arranging")
+		db := database.NewDB(mockPgx, "postgres://app@db/app?sslmode=disable")
+		mockPgx.EXPECT().Connect(ctx, "postgres://app@db/app?search_path=%22tenant_acme%22&sslmode=disable").
+			Return(&pgx.Conn{}, nil)
+
+		By("acting")
+		_, err := db.GetConn(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("scopes keyword/value connection strings too", func() {
+		By("arranging")
+		db := database.NewDB(mockPgx, "host=db user=app")
+		mockPgx.EXPECT().Connect(ctx, `host=db user=app search_path='"tenant_acme"'`).Return(&pgx.Conn{}, nil)
+
+		By("acting")
+		_, err := db.GetConn(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("refuses tenants that cannot name a schema", func() {
+		db := database.NewDB(mockPgx, "dbURL")
+
+		_, err := db.GetConn(tenancy.WithTenant(ctx, "acme,public"))
+
+		Expect(err).To(Equal(tenancy.ErrInvalidTenant))
+	})
+
+	It("does not take the probe of a half-open breaker for tenants that cannot name a schema", func() {
+		By("arranging")
+		db := database.NewDB(mockPgx, "dbURL")
+		db.Retry = database.RetryPolicy{}
+		db.Breaker = database.NewBreaker(1, 0)
+		mockPgx.EXPECT().Connect(gomock.Any(), gomock.Any()).Return(nil, errors.New("some pgx Connect error"))
+		_, err := db.GetConn(context.Background())
+		Expect(err).To(HaveOccurred())
+		_, err = db.GetConn(tenancy.WithTenant(ctx, "acme,public"))
+		Expect(err).To(Equal(tenancy.ErrInvalidTenant))
+		mockPgx.EXPECT().Connect(gomock.Any(), gomock.Any()).Return(&pgx.Conn{}, nil)
+
+		By("acting")
+		_, err = db.GetConn(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.Breaker.Stats().State).To(Equal(database.BreakerClosed))
+	})
+
+	It("leaves the shared migrations out of the schemas of tenants", func() {
+		By("arranging")
+		mockConn, _ := pgxmock.NewConn()
+		mockPgx.EXPECT().Connect(ctx, gomock.Any()).Return(mockConn, nil)
+		mockConn.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		mockConn.ExpectQuery(regexp.QuoteMeta("SELECT version, applied_at FROM schema_migrations")).
+			WillReturnRows(pgxmock.NewRows([]string{"version", "applied_at"}))
+		mockConn.ExpectClose()
+
+		By("acting")
+		states, err := database.NewDB(mockPgx, "dbURL").MigrationStatus(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		for _, state := range states {
+			Expect(state.Shared).To(BeFalse(), state.Name)
+		}
+		Expect(len(states)).To(BeNumerically("<", len(database.Migrations)))
+	})
+
+	DescribeTable("scopes changes to the schema they were made in",
+		func(schema string, ctx context.Context, inScope bool) {
+			event := database.ChangeEvent{Op: database.ChangeInsert, ID: 1, Schema: schema}
+
+			Expect(event.InScope(ctx)).To(Equal(inScope))
+		},
+		Entry("of the tenant", "tenant_acme", tenancy.WithTenant(context.Background(), "acme"), true),
+		Entry("of another tenant", "tenant_other", tenancy.WithTenant(context.Background(), "acme"), false),
+		Entry("of the default schema", "public", tenancy.WithTenant(context.Background(), "acme"), false),
+		Entry("of a tenant, without one", "tenant_acme", context.Background(), false),
+		Entry("without a schema", "", context.Background(), true),
+	)
+})
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Querier is what repositories run statements on, satisfied by both PgxConn and pgx.Tx.
+type Querier interface {
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(context.Context, string, ...interface{}) pgx.Row
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
+	Prepare(context.Context, string, string) (*pgconn.StatementDescription, error)
+}
+
+const serializationFailure = "40001"
+
+type TxOptions struct {
+	// IsoLevel defaults to the isolation level of the TxManager.
+	IsoLevel pgx.TxIsoLevel
+	// ForUpdate makes repository reads lock the rows they return until the transaction ends.
+	ForUpdate bool
+}
+
+type txState struct {
+	tx        *trackedTx
+	forUpdate bool
+}
+
+type txKey struct{}
+
+// TxFromContext returns the transaction opened by TxManager, repositories run on it instead of a fresh connection.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// ForUpdate reports whether reads on the transaction carried by ctx should lock their rows.
+func ForUpdate(ctx context.Context) bool {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	return ok && state.forUpdate
+}
+
+// ParseIsoLevel reads an isolation level such as "repeatable read", in any case.
+func ParseIsoLevel(value string) (pgx.TxIsoLevel, error) {
+	isoLevel := pgx.TxIsoLevel(strings.ToLower(strings.TrimSpace(value)))
+	switch isoLevel {
+	case pgx.Serializable, pgx.RepeatableRead, pgx.ReadCommitted, pgx.ReadUncommitted:
+		return isoLevel, nil
+	}
+	return "", fmt.Errorf("unknown isolation level %q", value)
+}
+
+type TxManager struct {
+	db       *DB
+	IsoLevel pgx.TxIsoLevel
+	Retry    RetryPolicy
+}
+
+func NewTxManager(db *DB, isoLevel pgx.TxIsoLevel) *TxManager {
+	return &TxManager{db: db, IsoLevel: isoLevel, Retry: DefaultRetryPolicy}
+}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxOptions(ctx, TxOptions{}, fn)
+}
+
+// WithinTxOptions runs fn with a transaction in its context, committing when fn succeeds.
+// A transaction already in ctx is joined rather than nested. The whole fn is retried when
+// the transaction hits a transient error such as a serialization failure, so fn must not have effects outside of it.
+func (m *TxManager) WithinTxOptions(ctx context.Context, options TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	if options.IsoLevel == "" {
+		options.IsoLevel = m.IsoLevel
+	}
+	return m.Retry.Do(ctx, isTransientTxError, func() error {
+		return m.run(ctx, options, fn)
+	})
+}
+
+func (m *TxManager) run(ctx context.Context, options TxOptions, fn func(ctx context.Context) error) error {
+	conn, err := m.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: options.IsoLevel})
+	if err != nil {
+		return err
+	}
+	tracked := &trackedTx{Tx: tx}
+	err = fn(context.WithValue(ctx, txKey{}, &txState{tx: tracked, forUpdate: options.ForUpdate}))
+	if tracked.err != nil && (err == nil || IsTransient(tracked.err)) {
+		// fn swallowed a failed statement, the transaction is aborted and cannot commit
+		err = tracked.err
+	}
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// isTransientTxError leaves the failures to connect to GetConn, which retries them already.
+func isTransientTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && IsTransient(err)
+}
+
+// trackedTx remembers the first statement rejected by Postgres, so that a transient failure
+// is retried even when it was turned into an HTTP response instead of returned.
+type trackedTx struct {
+	pgx.Tx
+	err error
+}
+
+func (t *trackedTx) track(err error) error {
+	var pgErr *pgconn.PgError
+	if t.err == nil && errors.As(err, &pgErr) {
+		t.err = err
+	}
+	return err
+}
+
+func (t *trackedTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := t.Tx.Exec(ctx, sql, args...)
+	return tag, t.track(err)
+}
+
+func (t *trackedTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := t.Tx.Query(ctx, sql, args...)
+	return rows, t.track(err)
+}
+
+func (t *trackedTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return trackedRow{row: t.Tx.QueryRow(ctx, sql, args...), tx: t}
+}
+
+func (t *trackedTx) Prepare(ctx context.Context, name, sql string) (*pgconn.StatementDescription, error) {
+	stDesc, err := t.Tx.Prepare(ctx, name, sql)
+	return stDesc, t.track(err)
+}
+
+type trackedRow struct {
+	row pgx.Row
+	tx  *trackedTx
+}
+
+func (r trackedRow) Scan(dest ...interface{}) error {
+	return r.tx.track(r.row.Scan(dest...))
+}
@@ -0,0 +1,213 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/database/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("TxManager", func() {
+	var (
+		ctx      context.Context
+		ctrl     *gomock.Controller
+		mockPgx  *mocks.MockPgx
+		mockConn pgxmock.PgxConnIface
+		manager  *database.TxManager
+	)
+	databaseURL := "dbURL"
+	serializationFailure := &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ctrl = gomock.NewController(GinkgoT())
+		mockPgx = mocks.NewMockPgx(ctrl)
+		mockConn, _ = pgxmock.NewConn()
+		manager = database.NewTxManager(database.NewDB(mockPgx, databaseURL), pgx.Serializable)
+	})
+
+	It("commits when fn succeeds and exposes the transaction in the context", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Return(mockConn, nil)
+		mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+		mockConn.ExpectExec("UPDATE resources").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		mockConn.ExpectCommit()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(ctx context.Context) error {
+			tx, ok := database.TxFromContext(ctx)
+			Expect(ok).To(BeTrue())
+			Expect(database.ForUpdate(ctx)).To(BeFalse())
+			_, err := tx.Exec(ctx, "UPDATE resources SET name = 'x'")
+			return err
+		})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("uses the given options", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Return(mockConn, nil)
+		mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.RepeatableRead})
+		mockConn.ExpectCommit()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := manager.WithinTxOptions(ctx, database.TxOptions{IsoLevel: pgx.RepeatableRead, ForUpdate: true},
+			func(ctx context.Context) error {
+				Expect(database.ForUpdate(ctx)).To(BeTrue())
+				return nil
+			})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("rolls back when fn fails", func() {
+		By("arranging")
+		expectedErr := errors.New("some error")
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Return(mockConn, nil)
+		mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+		mockConn.ExpectRollback()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(ctx context.Context) error { return expectedErr })
+
+		By("asserting")
+		Expect(err).To(Equal(expectedErr))
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("rolls back when fn swallowed a failed statement", func() {
+		By("arranging")
+		pgErr := &pgconn.PgError{Code: "23505", Message: "duplicate key"}
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Return(mockConn, nil)
+		mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+		mockConn.ExpectExec("INSERT").WillReturnError(pgErr)
+		mockConn.ExpectRollback()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(ctx context.Context) error {
+			tx, _ := database.TxFromContext(ctx)
+			tx.Exec(ctx, "INSERT INTO resources (name) VALUES ('x')")
+			return nil
+		})
+
+		By("asserting")
+		Expect(err).To(Equal(pgErr))
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("joins a transaction already in the context", func() {
+		By("arranging")
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Times(1).Return(mockConn, nil)
+		mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+		mockConn.ExpectCommit()
+		mockConn.ExpectClose()
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(outer context.Context) error {
+			outerTx, _ := database.TxFromContext(outer)
+			return manager.WithinTx(outer, func(inner context.Context) error {
+				innerTx, _ := database.TxFromContext(inner)
+				Expect(innerTx).To(BeIdenticalTo(outerTx))
+				return nil
+			})
+		})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("retries serialization failures", func() {
+		By("arranging")
+		retryConn, _ := pgxmock.NewConn()
+		gomock.InOrder(
+			mockPgx.EXPECT().Connect(ctx, databaseURL).Return(mockConn, nil),
+			mockPgx.EXPECT().Connect(ctx, databaseURL).Return(retryConn, nil),
+		)
+		mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+		mockConn.ExpectExec("UPDATE").WillReturnError(serializationFailure)
+		mockConn.ExpectRollback()
+		mockConn.ExpectClose()
+		retryConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+		retryConn.ExpectExec("UPDATE").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+		retryConn.ExpectCommit()
+		retryConn.ExpectClose()
+		attempts := 0
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(ctx context.Context) error {
+			attempts++
+			tx, _ := database.TxFromContext(ctx)
+			if _, err := tx.Exec(ctx, "UPDATE resources SET name = 'x'"); err != nil {
+				return errors.New("wrapped and lost the cause")
+			}
+			return nil
+		})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attempts).To(Equal(2))
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		Expect(retryConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("gives up after MaxRetries", func() {
+		By("arranging")
+		manager.Retry.MaxRetries = 1
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Times(2).DoAndReturn(
+			func(context.Context, string) (database.PgxConn, error) {
+				conn, _ := pgxmock.NewConn()
+				conn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.Serializable})
+				conn.ExpectRollback()
+				conn.ExpectClose()
+				return conn, nil
+			})
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(ctx context.Context) error { return serializationFailure })
+
+		By("asserting")
+		Expect(err).To(Equal(serializationFailure))
+	})
+
+	It("returns GetConn error", func() {
+		By("arranging")
+		expectedErr := errors.New("connection refused")
+		mockPgx.EXPECT().Connect(ctx, databaseURL).Return(nil, expectedErr)
+
+		By("acting")
+		err := manager.WithinTx(ctx, func(ctx context.Context) error { return nil })
+
+		By("asserting")
+		Expect(err).To(Equal(expectedErr))
+	})
+})
+
+var _ = DescribeTable("ParseIsoLevel",
+	func(value string, expected pgx.TxIsoLevel, valid bool) {
+		isoLevel, err := database.ParseIsoLevel(value)
+
+		Expect(err == nil).To(Equal(valid))
+		Expect(isoLevel).To(Equal(expected))
+	},
+	Entry("serializable", "serializable", pgx.Serializable, true),
+	Entry("in any case", "Repeatable Read", pgx.RepeatableRead, true),
+	Entry("read committed", "read committed", pgx.ReadCommitted, true),
+	Entry("a typo", "serialisable", pgx.TxIsoLevel(""), false),
+)
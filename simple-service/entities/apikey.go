@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// APIKey authenticates the requests of Principal, only the hash of its secret is kept, Prefix tells keys apart.
+type APIKey struct {
+	ID        int        `json:"id"`
+	Principal string     `json:"principal"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
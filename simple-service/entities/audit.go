@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one mutating API request. Each entry is chained to the one before it:
+// Hash covers the contents of the entry together with PrevHash.
+type AuditEntry struct {
+	ID         int64  `json:"id"`
+	Principal  string `json:"principal"`
+	RemoteAddr string `json:"remote_addr"`
+	RequestID  string `json:"request_id"`
+	Method     string `json:"method"`
+	Route      string `json:"route"`
+	// ResourceID is the public ID of the resource the request changed.
+	ResourceID *string         `json:"resource_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+type AuditFilter struct {
+	Principal string
+	// Since and Until bound CreatedAt, zero values leave that side open.
+	Since, Until time.Time
+	// ResourceID is the public ID of the resource, which matches its entries after it is purged too. Empty
+	// matches entries of any resource.
+	ResourceID string
+	Limit      int
+}
+
+// AuditVerification is the outcome of walking the audit chain, BrokenAt is the ID of the first
+// entry that does not match its hash or its predecessor.
+type AuditVerification struct {
+	Checked  int    `json:"checked"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (v AuditVerification) Valid() bool {
+	return v.BrokenAt == 0
+}
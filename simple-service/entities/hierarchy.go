@@ -0,0 +1,74 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxSubtreeDepth bounds how many levels below a resource a subtree fetch descends.
+const MaxSubtreeDepth = 32
+
+var (
+	ErrHasChildren   = errors.New("resource has children")
+	ErrHierarchyLoop = errors.New("resource cannot be moved below itself")
+	ErrParentMissing = errors.New("parent resource not found")
+)
+
+// DeletePolicy decides what happens to the children of a deleted resource.
+type DeletePolicy string
+
+const (
+	// DeleteRestrict refuses to delete resources that have children.
+	DeleteRestrict DeletePolicy = "restrict"
+	// DeleteCascade deletes the whole subtree.
+	DeleteCascade DeletePolicy = "cascade"
+	// DeleteOrphan turns the children into roots.
+	DeleteOrphan DeletePolicy = "orphan"
+)
+
+func ParseDeletePolicy(value string) (DeletePolicy, error) {
+	switch policy := DeletePolicy(value); policy {
+	case "":
+		return DeleteRestrict, nil
+	case DeleteRestrict, DeleteCascade, DeleteOrphan:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid delete policy %q", value)
+	}
+}
+
+// ResourceNode is a resource together with its descendants.
+type ResourceNode struct {
+	Resource
+	Children []ResourceNode `json:"children,omitempty"`
+}
+
+// BuildTree nests resources below the one with rootID, resources whose parent is not among them are dropped.
+func BuildTree(rootID int, resources []Resource) (*ResourceNode, bool) {
+	children := make(map[int][]Resource)
+	var root *Resource
+	for i := range resources {
+		if resources[i].ID == rootID {
+			root = &resources[i]
+		} else if resources[i].ParentID != nil {
+			children[*resources[i].ParentID] = append(children[*resources[i].ParentID], resources[i])
+		}
+	}
+	if root == nil {
+		return nil, false
+	}
+	var build func(resource Resource, depth int) ResourceNode
+	build = func(resource Resource, depth int) ResourceNode {
+		node := ResourceNode{Resource: resource}
+		// the depth guard keeps a corrupted hierarchy from recursing forever
+		if depth > MaxSubtreeDepth {
+			return node
+		}
+		for _, child := range children[resource.ID] {
+			node.Children = append(node.Children, build(child, depth+1))
+		}
+		return node
+	}
+	tree := build(*root, 0)
+	return &tree, true
+}
@@ -0,0 +1,56 @@
+package entities_test
+
+import (
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hierarchy", func() {
+	parentID := func(id int) *int { return &id }
+
+	DescribeTable("ParseDeletePolicy",
+		func(value string, expected entities.DeletePolicy) {
+			policy, err := entities.ParseDeletePolicy(value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(expected))
+		},
+		Entry("defaults to restrict", "", entities.DeleteRestrict),
+		Entry("restrict", "restrict", entities.DeleteRestrict),
+		Entry("cascade", "cascade", entities.DeleteCascade),
+		Entry("orphan", "orphan", entities.DeleteOrphan),
+	)
+
+	It("rejects unknown delete policies", func() {
+		_, err := entities.ParseDeletePolicy("adopt")
+		Expect(err).To(MatchError(`invalid delete policy "adopt"`))
+	})
+
+	It("nests resources below their parents", func() {
+		tree, ok := entities.BuildTree(1, []entities.Resource{
+			{ID: 1, Name: "Root", ParentID: parentID(9)},
+			{ID: 2, Name: "Child", ParentID: parentID(1)},
+			{ID: 3, Name: "Other child", ParentID: parentID(1)},
+			{ID: 4, Name: "Grandchild", ParentID: parentID(2)},
+			{ID: 5, Name: "Stray", ParentID: parentID(8)},
+		})
+
+		Expect(ok).To(BeTrue())
+		Expect(tree).To(Equal(&entities.ResourceNode{
+			Resource: entities.Resource{ID: 1, Name: "Root", ParentID: parentID(9)},
+			Children: []entities.ResourceNode{
+				{
+					Resource: entities.Resource{ID: 2, Name: "Child", ParentID: parentID(1)},
+					Children: []entities.ResourceNode{{Resource: entities.Resource{ID: 4, Name: "Grandchild", ParentID: parentID(2)}}},
+				},
+				{Resource: entities.Resource{ID: 3, Name: "Other child", ParentID: parentID(1)}},
+			},
+		}))
+	})
+
+	It("reports a missing root", func() {
+		tree, ok := entities.BuildTree(1, []entities.Resource{{ID: 2, ParentID: parentID(1)}})
+		Expect(ok).To(BeFalse())
+		Expect(tree).To(BeNil())
+	})
+})
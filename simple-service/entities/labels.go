@@ -0,0 +1,161 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	MaxLabels          = 64
+	MaxLabelLength     = 63
+	MaxAttributesBytes = 16 * 1024
+)
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_./]*[A-Za-z0-9])?$`)
+
+// Validate enforces the limits on labels and attributes.
+func (r Resource) Validate() error {
+	if len(r.Labels) > MaxLabels {
+		return fmt.Errorf("too many labels - at most %d are allowed", MaxLabels)
+	}
+	for key, value := range r.Labels {
+		if err := validateLabel("key", key); err != nil {
+			return err
+		}
+		if value != "" {
+			if err := validateLabel("value", value); err != nil {
+				return err
+			}
+		}
+	}
+	if r.Attributes != nil {
+		bytes, err := json.Marshal(r.Attributes)
+		if err != nil {
+			return err
+		}
+		if len(bytes) > MaxAttributesBytes {
+			return fmt.Errorf("attributes too large - at most %d bytes of JSON are allowed", MaxAttributesBytes)
+		}
+	}
+	return nil
+}
+
+func validateLabel(kind, label string) error {
+	if len(label) > MaxLabelLength {
+		return fmt.Errorf("label %s %q too long - at most %d characters are allowed", kind, label, MaxLabelLength)
+	}
+	if !labelPattern.MatchString(label) {
+		return fmt.Errorf("invalid label %s %q", kind, label)
+	}
+	return nil
+}
+
+type SelectorOperator string
+
+const (
+	SelectorEquals       SelectorOperator = "="
+	SelectorNotEquals    SelectorOperator = "!="
+	SelectorIn           SelectorOperator = "in"
+	SelectorNotIn        SelectorOperator = "notin"
+	SelectorExists       SelectorOperator = "exists"
+	SelectorDoesNotExist SelectorOperator = "!"
+)
+
+// LabelRequirement is one comma-separated term of a label selector.
+type LabelRequirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// ParseLabelSelector parses selectors such as "env=prod,tier!=cache,region in (eu,us),canary,!legacy".
+// "==" is accepted as "=".
+func ParseLabelSelector(selector string) ([]LabelRequirement, error) {
+	var requirements []LabelRequirement
+	for _, term := range splitSelector(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("empty term in label selector %q", selector)
+		}
+		requirement, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// splitSelector splits on the commas that are not inside a value list.
+func splitSelector(selector string) []string {
+	if strings.TrimSpace(selector) == "" {
+		return nil
+	}
+	var terms []string
+	depth, start := 0, 0
+	for i, char := range selector {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+func parseRequirement(term string) (LabelRequirement, error) {
+	if strings.HasPrefix(term, "!") && !strings.ContainsAny(term, "=()") {
+		key := strings.TrimSpace(term[1:])
+		return LabelRequirement{Key: key, Operator: SelectorDoesNotExist}, validateLabel("key", key)
+	}
+	for _, operator := range []string{"!=", "==", "="} {
+		if i := strings.Index(term, operator); i >= 0 {
+			requirement := LabelRequirement{Key: strings.TrimSpace(term[:i]), Operator: SelectorEquals,
+				Values: []string{strings.TrimSpace(term[i+len(operator):])}}
+			if operator == "!=" {
+				requirement.Operator = SelectorNotEquals
+			}
+			return requirement, validateRequirement(requirement)
+		}
+	}
+	if open := strings.Index(term, "("); open >= 0 {
+		if !strings.HasSuffix(term, ")") {
+			return LabelRequirement{}, fmt.Errorf("unterminated value list in %q", term)
+		}
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 || (fields[1] != string(SelectorIn) && fields[1] != string(SelectorNotIn)) {
+			return LabelRequirement{}, fmt.Errorf("invalid label selector term %q", term)
+		}
+		requirement := LabelRequirement{Key: fields[0], Operator: SelectorOperator(fields[1])}
+		for _, value := range strings.Split(term[open+1:len(term)-1], ",") {
+			requirement.Values = append(requirement.Values, strings.TrimSpace(value))
+		}
+		sort.Strings(requirement.Values)
+		return requirement, validateRequirement(requirement)
+	}
+	return LabelRequirement{Key: term, Operator: SelectorExists}, validateLabel("key", term)
+}
+
+func validateRequirement(requirement LabelRequirement) error {
+	if err := validateLabel("key", requirement.Key); err != nil {
+		return err
+	}
+	for _, value := range requirement.Values {
+		if value == "" {
+			continue
+		}
+		if err := validateLabel("value", value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
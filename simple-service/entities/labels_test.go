@@ -0,0 +1,74 @@
+package entities_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Labels", func() {
+	DescribeTable("ParseLabelSelector",
+		func(selector string, expected []entities.LabelRequirement) {
+			requirements, err := entities.ParseLabelSelector(selector)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requirements).To(Equal(expected))
+		},
+		Entry("empty", "", nil),
+		Entry("equality", "env=prod,tier==web", []entities.LabelRequirement{
+			{Key: "env", Operator: entities.SelectorEquals, Values: []string{"prod"}},
+			{Key: "tier", Operator: entities.SelectorEquals, Values: []string{"web"}},
+		}),
+		Entry("inequality", "tier!=cache", []entities.LabelRequirement{
+			{Key: "tier", Operator: entities.SelectorNotEquals, Values: []string{"cache"}},
+		}),
+		Entry("sets", "region in (us, eu),tier notin (cache)", []entities.LabelRequirement{
+			{Key: "region", Operator: entities.SelectorIn, Values: []string{"eu", "us"}},
+			{Key: "tier", Operator: entities.SelectorNotIn, Values: []string{"cache"}},
+		}),
+		Entry("existence", "canary, !legacy", []entities.LabelRequirement{
+			{Key: "canary", Operator: entities.SelectorExists},
+			{Key: "legacy", Operator: entities.SelectorDoesNotExist},
+		}),
+	)
+
+	DescribeTable("ParseLabelSelector rejects",
+		func(selector string) {
+			_, err := entities.ParseLabelSelector(selector)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("empty terms", "env=prod,,tier=web"),
+		Entry("unterminated sets", "region in (us"),
+		Entry("unknown set operators", "region within (us)"),
+		Entry("invalid keys", "not a key=prod"),
+		Entry("invalid values", "env=pro d"),
+	)
+
+	Context("Validate", func() {
+		It("accepts resources within the limits", func() {
+			resource := entities.Resource{Name: "Resource Name",
+				Attributes: map[string]interface{}{"replicas": 3}, Labels: map[string]string{"app.io/env": "prod", "canary": ""}}
+			Expect(resource.Validate()).To(Succeed())
+		})
+
+		It("rejects too many labels", func() {
+			labels := make(map[string]string)
+			for i := 0; i <= entities.MaxLabels; i++ {
+				labels[fmt.Sprintf("key%d", i)] = "value"
+			}
+			Expect(entities.Resource{Labels: labels}.Validate()).To(MatchError(ContainSubstring("too many labels")))
+		})
+
+		It("rejects long label values", func() {
+			resource := entities.Resource{Labels: map[string]string{"env": strings.Repeat("a", entities.MaxLabelLength+1)}}
+			Expect(resource.Validate()).To(MatchError(ContainSubstring("too long")))
+		})
+
+		It("rejects large attributes", func() {
+			resource := entities.Resource{Attributes: map[string]interface{}{"blob": strings.Repeat("a", entities.MaxAttributesBytes)}}
+			Expect(resource.Validate()).To(MatchError(ContainSubstring("attributes too large")))
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const (
+	StatusDraft    = "draft"
+	StatusActive   = "active"
+	StatusArchived = "archived"
+)
+
+// Transition allows moving a resource From one status To another.
+type Transition struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Principals may make the transition, anyone may when it is empty.
+	Principals []string `json:"principals,omitempty"`
+	// RequireReason rejects the transition unless a reason is given.
+	RequireReason bool `json:"require_reason,omitempty"`
+}
+
+// Lifecycle is the transition table resources move through, they are created in the Initial status.
+type Lifecycle struct {
+	Initial     string       `json:"initial"`
+	Transitions []Transition `json:"transitions"`
+}
+
+var DefaultLifecycle = Lifecycle{
+	Initial: StatusDraft,
+	Transitions: []Transition{
+		{From: StatusDraft, To: StatusActive},
+		{From: StatusDraft, To: StatusArchived},
+		{From: StatusActive, To: StatusArchived},
+		{From: StatusArchived, To: StatusActive, RequireReason: true},
+	},
+}
+
+// ReadLifecycle loads a transition table from a JSON file.
+func ReadLifecycle(path string) (Lifecycle, error) {
+	var lifecycle Lifecycle
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return lifecycle, err
+	}
+	if err = json.Unmarshal(bytes, &lifecycle); err != nil {
+		return lifecycle, err
+	}
+	if lifecycle.Initial == "" {
+		return lifecycle, fmt.Errorf("lifecycle in %s has no initial status", path)
+	}
+	return lifecycle, nil
+}
+
+// TransitionError is returned for moves the lifecycle does not allow, Allowed lists the statuses
+// the principal could move the resource to instead.
+type TransitionError struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Reason  string   `json:"error"`
+	Allowed []string `json:"allowed"`
+}
+
+func (e *TransitionError) Error() string {
+	return e.Reason
+}
+
+// Check reports whether principal may move a resource from one status to another.
+func (l Lifecycle) Check(from, to, principal, reason string) error {
+	for _, transition := range l.Transitions {
+		if transition.From != from || transition.To != to {
+			continue
+		}
+		if !transition.permits(principal) {
+			return l.transitionError(from, to, principal, fmt.Sprintf("%s may not move resources from %s to %s", principal, from, to))
+		}
+		if transition.RequireReason && reason == "" {
+			return l.transitionError(from, to, principal, fmt.Sprintf("moving resources from %s to %s requires a reason", from, to))
+		}
+		return nil
+	}
+	return l.transitionError(from, to, principal, fmt.Sprintf("resources cannot move from %s to %s", from, to))
+}
+
+// Allowed lists the statuses principal may move a resource in status from to.
+func (l Lifecycle) Allowed(from, principal string) []string {
+	allowed := make([]string, 0)
+	for _, transition := range l.Transitions {
+		if transition.From == from && transition.permits(principal) {
+			allowed = append(allowed, transition.To)
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+func (l Lifecycle) transitionError(from, to, principal, reason string) error {
+	return &TransitionError{From: from, To: to, Reason: reason, Allowed: l.Allowed(from, principal)}
+}
+
+func (t Transition) permits(principal string) bool {
+	if len(t.Principals) == 0 {
+		return true
+	}
+	for _, allowed := range t.Principals {
+		if allowed == principal {
+			return true
+		}
+	}
+	return false
+}
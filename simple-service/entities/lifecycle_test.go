@@ -0,0 +1,58 @@
+package entities_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lifecycle", func() {
+	lifecycle := entities.Lifecycle{Initial: entities.StatusDraft, Transitions: []entities.Transition{
+		{From: entities.StatusDraft, To: entities.StatusActive},
+		{From: entities.StatusActive, To: entities.StatusArchived, Principals: []string{"operator"}},
+		{From: entities.StatusArchived, To: entities.StatusActive, RequireReason: true},
+	}}
+
+	It("allows listed transitions", func() {
+		Expect(lifecycle.Check("draft", "active", "anonymous", "")).To(Succeed())
+		Expect(lifecycle.Check("active", "archived", "operator", "")).To(Succeed())
+		Expect(lifecycle.Check("archived", "active", "anonymous", "back in use")).To(Succeed())
+	})
+
+	DescribeTable("rejects",
+		func(from, to, principal, reason, message string, allowed []string) {
+			err := lifecycle.Check(from, to, principal, reason)
+			Expect(err).To(Equal(&entities.TransitionError{From: from, To: to, Reason: message, Allowed: allowed}))
+		},
+		Entry("unlisted transitions", "draft", "archived", "anonymous", "",
+			"resources cannot move from draft to archived", []string{"active"}),
+		Entry("principals the transition is not open to", "active", "archived", "anonymous", "",
+			"anonymous may not move resources from active to archived", []string{}),
+		Entry("transitions missing a required reason", "archived", "active", "anonymous", "",
+			"moving resources from archived to active requires a reason", []string{"active"}),
+	)
+
+	It("reads a transition table from a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "lifecycle.json")
+		Expect(os.WriteFile(path, []byte(`{"initial":"draft","transitions":[{"from":"draft","to":"active","principals":["operator"]}]}`), 0o600)).To(Succeed())
+
+		read, err := entities.ReadLifecycle(path)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(entities.Lifecycle{Initial: "draft", Transitions: []entities.Transition{
+			{From: "draft", To: "active", Principals: []string{"operator"}},
+		}}))
+	})
+
+	It("rejects transition tables without an initial status", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "lifecycle.json")
+		Expect(os.WriteFile(path, []byte(`{"transitions":[]}`), 0o600)).To(Succeed())
+
+		_, err := entities.ReadLifecycle(path)
+
+		Expect(err).To(MatchError(ContainSubstring("no initial status")))
+	})
+})
@@ -0,0 +1,35 @@
+package entities
+
+import "github.com/jackc/pgx/v4"
+
+// Meta declares an entity type T, repositories.Table, handlers.CRUD and the conformance specs
+// serve it from this declaration alone. Its table has an integer id and a unique public_id uuid column.
+type Meta[T any] struct {
+	// Name is singular and lower case, it names prepared statements ("readResource"), outbox events
+	// ("resource.created"), the URL parameter ("resourceID") and the request context key of the entity.
+	Name string
+	// Path is where handlers.CRUD mounts the routes of the entity.
+	Path  string
+	Table string
+	// Select lists the columns read by Scan, it is used as is in SELECT and RETURNING clauses.
+	Select string
+	Scan   func(row pgx.Row) (T, error)
+	// Columns are written by inserts, the ones that are not Immutable by updates too.
+	Columns []Column[T]
+	// ID and PublicID return the identifiers of scanned entities.
+	ID       func(entity T) int
+	PublicID func(entity T) string
+	// Validate checks the entities received from clients, it may be nil.
+	Validate func(entity T) error
+	// SoftDelete hides deleted rows through their deleted_at column instead of removing them.
+	SoftDelete bool
+	// Labels is the jsonb column label selectors filter on, without one entities cannot be listed by selector.
+	Labels string
+}
+
+type Column[T any] struct {
+	Name  string
+	Value func(entity T) (interface{}, error)
+	// Immutable columns are only written by inserts.
+	Immutable bool
+}
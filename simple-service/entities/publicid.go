@@ -0,0 +1,11 @@
+package entities
+
+import "regexp"
+
+var publicIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// ValidPublicID reports whether id is in the canonical lowercase form the database hands out
+// public IDs in, anything else cannot name a resource.
+func ValidPublicID(id string) bool {
+	return publicIDPattern.MatchString(id)
+}
@@ -0,0 +1,19 @@
+package entities_test
+
+import (
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("ValidPublicID",
+	func(id string, valid bool) {
+		Expect(entities.ValidPublicID(id)).To(Equal(valid))
+	},
+	Entry("UUIDv7", "01890a5d-ac96-774b-bcce-b302099a8057", true),
+	Entry("integer ID", "123", false),
+	Entry("upper case", "01890A5D-AC96-774B-BCCE-B302099A8057", false),
+	Entry("without dashes", "01890a5dac96774bbcceb302099a8057", false),
+	Entry("braced", "{01890a5d-ac96-774b-bcce-b302099a8057}", false),
+	Entry("empty", "", false),
+)
@@ -1,6 +1,117 @@
 package entities
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
 type Resource struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	// ID is internal, the service identifies resources by their time-ordered PublicID (a UUIDv7)
+	// so that the row count cannot be read from or enumerated through the API.
+	ID         int                    `json:"-"`
+	PublicID   string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	// Status only changes through transitions of the Lifecycle.
+	Status string `json:"status,omitempty"`
+	// ParentID nests the resource under another one, it only changes through moves.
+	ParentID       *int       `json:"-"`
+	ParentPublicID string     `json:"parent_id,omitempty"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ResourceColumns are the columns scanned by ScanResource.
+const ResourceColumns = "id, public_id, name, deleted_at, attributes, labels, status, parent_id, " +
+	"COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '')"
+
+var ResourceMeta = Meta[Resource]{
+	Name:   "resource",
+	Path:   "/resources",
+	Table:  "resources",
+	Select: ResourceColumns,
+	Scan: func(row pgx.Row) (Resource, error) {
+		return ScanResource(row)
+	},
+	Columns: []Column[Resource]{
+		{Name: "name", Value: func(r Resource) (interface{}, error) { return r.Name, nil }},
+		{Name: "attributes", Value: func(r Resource) (interface{}, error) {
+			attributes, _, err := r.MarshalMetadata()
+			return attributes, err
+		}},
+		{Name: "labels", Value: func(r Resource) (interface{}, error) {
+			_, labels, err := r.MarshalMetadata()
+			return labels, err
+		}},
+		{Name: "status", Value: func(r Resource) (interface{}, error) { return r.Status, nil }, Immutable: true},
+		{Name: "parent_id", Value: func(r Resource) (interface{}, error) { return r.ParentID, nil }, Immutable: true},
+	},
+	ID:         func(r Resource) int { return r.ID },
+	PublicID:   func(r Resource) string { return r.PublicID },
+	Validate:   Resource.Validate,
+	SoftDelete: true,
+	Labels:     "labels",
+}
+
+// ScanResource reads the ResourceColumns of row, followed by extra.
+func ScanResource(row pgx.Row, extra ...interface{}) (Resource, error) {
+	var resource Resource
+	var attributes, labels []byte
+	err := row.Scan(append([]interface{}{&resource.ID, &resource.PublicID, &resource.Name, &resource.DeletedAt,
+		&attributes, &labels, &resource.Status, &resource.ParentID, &resource.ParentPublicID}, extra...)...)
+	if err != nil {
+		return resource, err
+	}
+	// empty objects are left nil, so that they are omitted just like on the way in
+	if len(attributes) > 0 {
+		if err = json.Unmarshal(attributes, &resource.Attributes); err != nil {
+			return resource, err
+		}
+		if len(resource.Attributes) == 0 {
+			resource.Attributes = nil
+		}
+	}
+	if len(labels) > 0 {
+		if err = json.Unmarshal(labels, &resource.Labels); err != nil {
+			return resource, err
+		}
+		if len(resource.Labels) == 0 {
+			resource.Labels = nil
+		}
+	}
+	return resource, nil
+}
+
+// MarshalMetadata encodes the attributes and labels of the resource, a missing map is stored as an empty object.
+func (r Resource) MarshalMetadata() (attributes, labels []byte, err error) {
+	if r.Attributes == nil {
+		r.Attributes = map[string]interface{}{}
+	}
+	if r.Labels == nil {
+		r.Labels = map[string]string{}
+	}
+	if attributes, err = json.Marshal(r.Attributes); err != nil {
+		return nil, nil, err
+	}
+	labels, err = json.Marshal(r.Labels)
+	return attributes, labels, err
+}
+
+type ListOptions struct {
+	// IncludeDeleted lists soft-deleted entities alongside live ones.
+	IncludeDeleted bool
+	// LabelSelector restricts the list to entities whose labels satisfy every requirement.
+	LabelSelector []LabelRequirement
+	// Limit caps the number of entities listed, in ID order, after skipping Offset of them. Zero lists them all.
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a resource matching a search, Snippet is its name with the matched words wrapped in <mark> tags.
+type SearchResult struct {
+	Resource
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
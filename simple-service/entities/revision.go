@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	RevisionCreate     = "create"
+	RevisionUpdate     = "update"
+	RevisionDelete     = "delete"
+	RevisionRestore    = "restore"
+	RevisionPurge      = "purge"
+	RevisionTransition = "transition"
+)
+
+// ResourceRevision is the state of a resource before and after one change to it.
+// Previous is nil for the revision that created the resource and State for the one that purged it,
+// the other one carries the public ID of the resource.
+type ResourceRevision struct {
+	ResourceID int       `json:"-"`
+	Revision   int       `json:"revision"`
+	Operation  string    `json:"operation"`
+	Previous   *Resource `json:"previous"`
+	State      *Resource `json:"state"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffResources lists the JSON fields that differ between two states of a resource, sorted by name.
+func DiffResources(from, to *Resource) ([]FieldChange, error) {
+	fromFields, err := jsonFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := jsonFields(to)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(fromFields)+len(toFields))
+	for name := range fromFields {
+		names[name] = struct{}{}
+	}
+	for name := range toFields {
+		names[name] = struct{}{}
+	}
+	changes := make([]FieldChange, 0)
+	for name := range names {
+		if !reflect.DeepEqual(fromFields[name], toFields[name]) {
+			changes = append(changes, FieldChange{Field: name, From: fromFields[name], To: toFields[name]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func jsonFields(resource *Resource) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if resource == nil {
+		return fields, nil
+	}
+	bytes, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(bytes, &fields)
+}
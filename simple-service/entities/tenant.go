@@ -0,0 +1,20 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+// QuotaResources is the quota of live resources, deleted resources do not count until they are restored.
+const QuotaResources = "resources"
+
+var ErrQuotaExceeded = errors.New("the quota of the tenant is exceeded")
+
+// Tenant is a team hosted on the deployment, its resources are kept in Schema apart from those of other tenants.
+// Tenants are not Ready to be served until their schema is migrated.
+type Tenant struct {
+	ID        string    `json:"id"`
+	Schema    string    `json:"schema"`
+	Ready     bool      `json:"ready"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,122 @@
+//go:generate mockgen -destination=mocks/fixtures.go -package mocks . Store,Resetter
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/invopop/yaml"
+)
+
+// Set is a fixture set, written in YAML or JSON:
+//
+//	resources:
+//	  - name: eu-west
+//	    labels: {region: eu}
+//	    children:
+//	      - name: web
+//	        status: active
+type Set struct {
+	Resources []Resource `json:"resources"`
+}
+
+// Resource is a resource of a Set, it is identified by its name among its siblings, so that loading the
+// set again updates it instead of creating it twice. Status defaults to the initial one of the lifecycle.
+type Resource struct {
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Labels     map[string]string      `json:"labels,omitempty"`
+	Status     string                 `json:"status,omitempty"`
+	Children   []Resource             `json:"children,omitempty"`
+}
+
+// Parse reads a set from YAML, or JSON, which is YAML too. Unknown fields are rejected, they are likely typos.
+func Parse(data []byte) (*Set, error) {
+	var set Set
+	err := yaml.Unmarshal(data, &set, func(decoder *json.Decoder) *json.Decoder {
+		decoder.DisallowUnknownFields()
+		return decoder
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func ReadFile(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return set, nil
+}
+
+type Store interface {
+	Upsert(ctx context.Context, resource entities.Resource) (*entities.Resource, bool, error)
+}
+
+type Resetter interface {
+	Reset(ctx context.Context) error
+}
+
+// Result counts the resources a load created and those it found and updated.
+type Result struct {
+	Created int
+	Updated int
+}
+
+// Load upserts the resources of the sets, parents before their children. Loading the same sets again
+// leaves the database as it was, as long as nothing else changed it.
+func Load(ctx context.Context, store Store, sets ...*Set) (Result, error) {
+	var result Result
+	for _, set := range sets {
+		if err := load(ctx, store, set.Resources, nil, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Reset empties the database and loads the sets, so that every integration test starts from the same state.
+func Reset(ctx context.Context, db Resetter, store Store, sets ...*Set) (Result, error) {
+	if err := db.Reset(ctx); err != nil {
+		return Result{}, err
+	}
+	return Load(ctx, store, sets...)
+}
+
+func load(ctx context.Context, store Store, fixtures []Resource, parentID *int, result *Result) error {
+	for _, fixture := range fixtures {
+		if fixture.Name == "" {
+			return fmt.Errorf("a fixture resource has no name")
+		}
+		resource := entities.Resource{Name: fixture.Name, Attributes: fixture.Attributes, Labels: fixture.Labels,
+			Status: fixture.Status, ParentID: parentID}
+		if resource.Status == "" {
+			resource.Status = entities.DefaultLifecycle.Initial
+		}
+		if err := resource.Validate(); err != nil {
+			return fmt.Errorf("fixture %q: %w", fixture.Name, err)
+		}
+		stored, created, err := store.Upsert(ctx, resource)
+		if err != nil {
+			return fmt.Errorf("loading fixture %q: %w", fixture.Name, err)
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		if err = load(ctx, store, fixture.Children, &stored.ID, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
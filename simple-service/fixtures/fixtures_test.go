@@ -0,0 +1,166 @@
+package fixtures_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/fixtures"
+	"github.com/addme96/simple-go-service/simple-service/fixtures/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fixtures", func() {
+	var (
+		mockCtrl *gomock.Controller
+		store    *mocks.MockStore
+		ctx      = context.Background()
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		store = mocks.NewMockStore(mockCtrl)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("Parse", func() {
+		It("reads YAML", func() {
+			By("acting")
+			set, err := fixtures.Parse([]byte(`
+resources:
+  - name: eu-west
+    attributes: {replicas: 3}
+    children:
+      - name: web
+        status: active
+`))
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set.Resources).To(Equal([]fixtures.Resource{{Name: "eu-west",
+				Attributes: map[string]interface{}{"replicas": float64(3)},
+				Children:   []fixtures.Resource{{Name: "web", Status: "active"}}}}))
+		})
+
+		It("reads JSON", func() {
+			By("acting")
+			set, err := fixtures.Parse([]byte(`{"resources": [{"name": "a", "labels": {"env": "prod"}}]}`))
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set.Resources[0].Labels).To(Equal(map[string]string{"env": "prod"}))
+		})
+
+		It("rejects unknown fields", func() {
+			By("acting")
+			_, err := fixtures.Parse([]byte("resources:\n  - name: a\n    lables: {env: prod}\n"))
+
+			By("asserting")
+			Expect(err).To(MatchError(ContainSubstring(`unknown field "lables"`)))
+		})
+	})
+
+	Context("Load", func() {
+		It("upserts parents before their children", func() {
+			By("arranging")
+			set := &fixtures.Set{Resources: []fixtures.Resource{
+				{Name: "eu-west", Status: "active", Children: []fixtures.Resource{{Name: "web"}}},
+			}}
+			gomock.InOrder(
+				store.EXPECT().Upsert(ctx, entities.Resource{Name: "eu-west", Status: "active"}).
+					Return(&entities.Resource{ID: 7}, false, nil),
+				store.EXPECT().Upsert(ctx, gomock.Any()).
+					DoAndReturn(func(_ context.Context, resource entities.Resource) (*entities.Resource, bool, error) {
+						Expect(resource.Name).To(Equal("web"))
+						Expect(resource.Status).To(Equal(entities.DefaultLifecycle.Initial))
+						Expect(*resource.ParentID).To(Equal(7))
+						return &entities.Resource{ID: 8}, true, nil
+					}),
+			)
+
+			By("acting")
+			result, err := fixtures.Load(ctx, store, set)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(fixtures.Result{Created: 1, Updated: 1}))
+		})
+
+		It("stops at the first failing resource", func() {
+			By("arranging")
+			expectedErr := errors.New("some error")
+			store.EXPECT().Upsert(ctx, gomock.Any()).Return(nil, false, expectedErr)
+
+			By("acting")
+			_, err := fixtures.Load(ctx, store, &fixtures.Set{Resources: []fixtures.Resource{
+				{Name: "a", Children: []fixtures.Resource{{Name: "b"}}},
+			}})
+
+			By("asserting")
+			Expect(errors.Is(err, expectedErr)).To(BeTrue())
+		})
+
+		It("rejects resources without a name", func() {
+			By("acting")
+			_, err := fixtures.Load(ctx, store, &fixtures.Set{Resources: []fixtures.Resource{{Status: "active"}}})
+
+			By("asserting")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("resets the database first when asked to", func() {
+			By("arranging")
+			db := mocks.NewMockResetter(mockCtrl)
+			gomock.InOrder(
+				db.EXPECT().Reset(ctx).Return(nil),
+				store.EXPECT().Upsert(ctx, gomock.Any()).Return(&entities.Resource{ID: 1}, true, nil),
+			)
+
+			By("acting")
+			result, err := fixtures.Reset(ctx, db, store, &fixtures.Set{Resources: []fixtures.Resource{{Name: "a"}}})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Created).To(Equal(1))
+		})
+	})
+
+	Context("Profile", func() {
+		It("loads the demo profile", func() {
+			By("acting")
+			set, err := fixtures.Profile(fixtures.ProfileDemo, 0)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set.Resources).NotTo(BeEmpty())
+		})
+
+		It("generates the rows of the load-test profile the same way every time", func() {
+			By("acting")
+			set, err := fixtures.Profile(fixtures.ProfileLoadTest, 250)
+			again, _ := fixtures.Profile(fixtures.ProfileLoadTest, 250)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set).To(Equal(again))
+			groups := set.Resources[0].Children
+			Expect(groups).To(HaveLen(3))
+			Expect(groups[0].Children).To(HaveLen(100))
+			Expect(groups[2].Children).To(HaveLen(50))
+			Expect(groups[2].Children[49].Name).To(Equal("resource-0000249"))
+		})
+
+		It("rejects unknown profiles", func() {
+			By("acting")
+			_, err := fixtures.Profile("prod", 0)
+
+			By("asserting")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
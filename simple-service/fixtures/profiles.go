@@ -0,0 +1,57 @@
+package fixtures
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+const (
+	// ProfileDemo is a small hierarchy of resources showing off labels, attributes and statuses.
+	ProfileDemo = "demo"
+	// ProfileLoadTest is as many generated resources as asked for, in groups of loadTestGroupSize.
+	ProfileLoadTest = "load-test"
+)
+
+const loadTestGroupSize = 100
+
+//go:embed profiles
+var profiles embed.FS
+
+// Profile returns the named fixture set, rows is how many resources the load-test profile generates.
+func Profile(name string, rows int) (*Set, error) {
+	switch name {
+	case ProfileDemo:
+		data, err := profiles.ReadFile("profiles/demo.yaml")
+		if err != nil {
+			return nil, err
+		}
+		return Parse(data)
+	case ProfileLoadTest:
+		return loadTest(rows), nil
+	}
+	return nil, fmt.Errorf("unknown fixture profile %q, it is one of %s or %s", name, ProfileDemo, ProfileLoadTest)
+}
+
+// loadTest generates the rows below a load-test root, the same ones every time so that loading them again
+// updates them.
+func loadTest(rows int) *Set {
+	statuses := []string{entities.StatusDraft, entities.StatusActive, entities.StatusArchived}
+	tiers := []string{"web", "app", "db", "cache"}
+	root := Resource{Name: "load-test", Labels: map[string]string{"fixture": ProfileLoadTest}}
+	for i := 0; i < rows; i++ {
+		if i%loadTestGroupSize == 0 {
+			root.Children = append(root.Children, Resource{Name: fmt.Sprintf("group-%05d", i/loadTestGroupSize),
+				Labels: map[string]string{"fixture": ProfileLoadTest}})
+		}
+		group := &root.Children[len(root.Children)-1]
+		group.Children = append(group.Children, Resource{
+			Name:       fmt.Sprintf("resource-%07d", i),
+			Attributes: map[string]interface{}{"index": i},
+			Labels:     map[string]string{"fixture": ProfileLoadTest, "tier": tiers[i%len(tiers)]},
+			Status:     statuses[i%len(statuses)],
+		})
+	}
+	return &Set{Resources: []Resource{root}}
+}
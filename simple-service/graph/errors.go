@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/jackc/pgx/v4"
+)
+
+// Error codes are served in the extensions of errors, e.g. {"message": "...", "extensions": {"code": "NOT_FOUND"}}.
+const (
+	CodeBadUserInput       = "BAD_USER_INPUT"
+	CodeNotFound           = "NOT_FOUND"
+	CodeFailedPrecondition = "FAILED_PRECONDITION"
+	CodeUnavailable        = "UNAVAILABLE"
+	CodeQueryTooComplex    = "QUERY_TOO_COMPLEX"
+	CodeInternal           = "INTERNAL"
+)
+
+// Error is an error with a code, graphql-go serves the Extensions of errors next to their message.
+type Error struct {
+	Message string
+	Code    string
+	// RetryAfter is set on UNAVAILABLE when the database breaker is open, in seconds.
+	RetryAfter int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code}
+	if e.RetryAfter > 0 {
+		extensions["retryAfter"] = e.RetryAfter
+	}
+	return extensions
+}
+
+func badInput(message string) error {
+	return &Error{Message: message, Code: CodeBadUserInput}
+}
+
+// graphError maps the errors of the repository to codes, like the handlers map them to HTTP statuses.
+func graphError(err error) error {
+	var graphErr *Error
+	var circuitOpen *database.CircuitOpenError
+	switch {
+	case errors.As(err, &graphErr):
+		return err
+	case errors.Is(err, pgx.ErrNoRows):
+		return &Error{Message: "resource not found", Code: CodeNotFound}
+	case errors.Is(err, errInvalidID), errors.Is(err, entities.ErrParentMissing):
+		return badInput(err.Error())
+	case errors.Is(err, entities.ErrHasChildren), errors.Is(err, entities.ErrHierarchyLoop),
+		errors.Is(err, entities.ErrQuotaExceeded):
+		return &Error{Message: err.Error(), Code: CodeFailedPrecondition}
+	case errors.As(err, &circuitOpen):
+		return &Error{Message: err.Error(), Code: CodeUnavailable,
+			RetryAfter: int(math.Ceil(circuitOpen.RetryAfter.Seconds()))}
+	case database.IsTransient(err), errors.Is(err, context.DeadlineExceeded):
+		return &Error{Message: err.Error(), Code: CodeUnavailable}
+	default:
+		return &Error{Message: err.Error(), Code: CodeInternal}
+	}
+}
+
+// formatErrors formats errors raised outside of resolvers, keeping the extensions graphql-go only keeps
+// for the errors of resolvers.
+func formatErrors(err error) []gqlerrors.FormattedError {
+	formatted := gqlerrors.FormatError(err)
+	var extended gqlerrors.ExtendedError
+	if errors.As(err, &extended) {
+		formatted.Extensions = extended.Extensions()
+	}
+	return []gqlerrors.FormattedError{formatted}
+}
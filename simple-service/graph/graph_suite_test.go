@@ -0,0 +1,18 @@
+package graph_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGraph(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Graph Suite")
+}
+
+func publicID(id int) string {
+	return fmt.Sprintf("00000000-0000-7000-8000-%012d", id)
+}
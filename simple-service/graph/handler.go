@@ -0,0 +1,218 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const (
+	DefaultMaxDepth      = 10
+	DefaultMaxComplexity = 1000
+)
+
+// Handler serves GraphQL over HTTP: queries as GET or POST, mutations as POST and subscriptions
+// as server-sent events, which end with the request like any other response.
+type Handler struct {
+	Schema     graphql.Schema
+	Repository handlers.ResourceRepository
+	// MaxDepth and MaxComplexity bound the operations that are run, see measure.
+	MaxDepth, MaxComplexity int
+	// Runner runs mutations as a single transaction like handlers.Transactional, Audit records
+	// them like handlers.Audit.Record. Both are optional.
+	Runner handlers.TxRunner
+	Audit  handlers.AuditRepository
+	// GraphiQL serves the GraphiQL IDE to browsers, it is meant for development.
+	GraphiQL bool
+}
+
+func NewHandler(resolver *Resolver) (*Handler, error) {
+	schema, err := resolver.Schema()
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{Schema: schema, Repository: resolver.Repository,
+		MaxDepth: DefaultMaxDepth, MaxComplexity: DefaultMaxComplexity}, nil
+}
+
+type params struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+var errRollback = errors.New("mutation responded with an error")
+
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if h.GraphiQL && request.Method == http.MethodGet && strings.Contains(request.Header.Get("Accept"), "text/html") {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.Write([]byte(graphiQLPage))
+		return
+	}
+	p, ok := decodeParams(writer, request)
+	if !ok {
+		return
+	}
+	document, err := parser.Parse(parser.ParseParams{Source: p.Query})
+	if err != nil {
+		writeResult(writer, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := graphql.ValidateDocument(&h.Schema, document, nil); !validation.IsValid {
+		writeResult(writer, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+	operation, fragments := operation(document, p.OperationName)
+	if operation == nil {
+		http.Error(writer, fmt.Sprintf("unknown operation %q", p.OperationName), http.StatusBadRequest)
+		return
+	}
+	if err = checkLimits(operation, fragments, p.Variables, h.MaxDepth, h.MaxComplexity); err != nil {
+		writeResult(writer, http.StatusBadRequest, &graphql.Result{Errors: formatErrors(err)})
+		return
+	}
+	execute := graphql.ExecuteParams{Schema: h.Schema, AST: document, OperationName: p.OperationName, Args: p.Variables}
+	switch operation.Operation {
+	case ast.OperationTypeSubscription:
+		h.subscribe(writer, request, execute)
+	case ast.OperationTypeMutation:
+		if request.Method != http.MethodPost {
+			writer.Header().Set("Allow", http.MethodPost)
+			http.Error(writer, "mutations are only run on POST", http.StatusMethodNotAllowed)
+			return
+		}
+		writeResult(writer, http.StatusOK, h.mutate(request, execute))
+	default:
+		execute.Context = withLoader(request.Context(), h.Repository)
+		writeResult(writer, http.StatusOK, graphql.Execute(execute))
+	}
+}
+
+// mutate runs the mutation in a transaction and records it in the audit log, a mutation with errors is rolled back.
+func (h *Handler) mutate(request *http.Request, execute graphql.ExecuteParams) *graphql.Result {
+	if h.Runner == nil {
+		execute.Context = withLoader(request.Context(), h.Repository)
+		return graphql.Execute(execute)
+	}
+	var result *graphql.Result
+	err := h.Runner.WithinTxOptions(request.Context(), database.TxOptions{ForUpdate: true}, func(ctx context.Context) error {
+		execute.Context = withLoader(ctx, h.Repository)
+		if result = graphql.Execute(execute); result.HasErrors() {
+			return errRollback
+		}
+		if h.Audit == nil {
+			return nil
+		}
+		return h.Audit.Record(ctx, entities.AuditEntry{
+			Principal:  auth.PrincipalFromContext(ctx),
+			RemoteAddr: request.RemoteAddr,
+			RequestID:  middleware.GetReqID(ctx),
+			Method:     request.Method,
+			Route:      request.URL.Path,
+		})
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return &graphql.Result{Errors: formatErrors(graphError(err))}
+	}
+	return result
+}
+
+// subscribe streams the results as server-sent events, following the distinct connections mode of GraphQL over SSE.
+func (h *Handler) subscribe(writer http.ResponseWriter, request *http.Request, execute graphql.ExecuteParams) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+	execute.Context = ctx
+	results := graphql.ExecuteSubscription(execute)
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for result := range results {
+		bytes, _ := json.Marshal(result)
+		fmt.Fprintf(writer, "event: next\ndata: %s\n\n", bytes)
+		flusher.Flush()
+	}
+	fmt.Fprint(writer, "event: complete\ndata:\n\n")
+	flusher.Flush()
+}
+
+// decodeParams reads the query from the URL of GET requests and from the JSON body of POST requests,
+// it responds with an error itself when there is none.
+func decodeParams(writer http.ResponseWriter, request *http.Request) (params, bool) {
+	var p params
+	switch request.Method {
+	case http.MethodGet:
+		query := request.URL.Query()
+		p.Query, p.OperationName = query.Get("query"), query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &p.Variables); err != nil {
+				http.Error(writer, "invalid variables - should be a JSON object", http.StatusBadRequest)
+				return p, false
+			}
+		}
+	case http.MethodPost:
+		if !strings.HasPrefix(request.Header.Get("Content-Type"), "application/json") {
+			http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+			return p, false
+		}
+		if err := json.NewDecoder(request.Body).Decode(&p); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return p, false
+		}
+	default:
+		writer.Header().Set("Allow", "GET, POST")
+		http.Error(writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return p, false
+	}
+	if p.Query == "" {
+		http.Error(writer, "missing query", http.StatusBadRequest)
+		return p, false
+	}
+	return p, true
+}
+
+// operation returns the operation of document named name, or its only operation when name is empty.
+func operation(document *ast.Document, name string) (*ast.OperationDefinition, map[string]*ast.FragmentDefinition) {
+	var found *ast.OperationDefinition
+	fragments := make(map[string]*ast.FragmentDefinition)
+	operations := 0
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.OperationDefinition:
+			operations++
+			if name == "" || (definition.Name != nil && definition.Name.Value == name) {
+				found = definition
+			}
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		}
+	}
+	if name == "" && operations > 1 {
+		return nil, fragments
+	}
+	return found, fragments
+}
+
+func writeResult(writer http.ResponseWriter, status int, result *graphql.Result) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(result)
+}
@@ -0,0 +1,424 @@
+package graph_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/graph"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// changes stands in for the database.Listener.
+type changes chan database.ChangeEvent
+
+func (c changes) Subscribe(int) (<-chan database.ChangeEvent, func()) {
+	return c, func() {}
+}
+
+// batchRepository adds ReadBatch to the mock, recording the IDs of every batch.
+type batchRepository struct {
+	*mocks.MockResourceRepository
+	resources map[int]entities.Resource
+	batches   [][]int
+}
+
+func (b *batchRepository) ReadBatch(_ context.Context, ids []int) ([]entities.Resource, error) {
+	b.batches = append(b.batches, ids)
+	var found []entities.Resource
+	for _, id := range ids {
+		if resource, ok := b.resources[id]; ok {
+			found = append(found, resource)
+		}
+	}
+	return found, nil
+}
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		events   changes
+		handler  *graph.Handler
+	)
+	parentID := 7
+	resource := entities.Resource{ID: 123, PublicID: publicID(123), Name: "Some Name",
+		Attributes: map[string]interface{}{"size": 3.0}, Labels: map[string]string{"tier": "web", "env": "prod"},
+		Status: "active", ParentID: &parentID, ParentPublicID: publicID(7)}
+
+	newHandler := func(repository graph.BatchReader) {
+		var err error
+		resolver := graph.NewResolver(mockRepo, events)
+		if repository != nil {
+			resolver.Repository = repository.(*batchRepository)
+		}
+		handler, err = graph.NewHandler(resolver)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		events = make(changes, 8)
+		newHandler(nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	post := func(query string, variables map[string]interface{}) (*httptest.ResponseRecorder, response) {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		request := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		request.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		var decoded response
+		Expect(json.Unmarshal(w.Body.Bytes(), &decoded)).To(Succeed())
+		return w, decoded
+	}
+
+	Context("queries", func() {
+		It("returns the fields asked for", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(&resource, nil)
+
+			By("acting")
+			w, _ := post(`query ($id: ID!) { resource(id: $id) { id name attributes labels { key value } status } }`,
+				map[string]interface{}{"id": publicID(123)})
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"data": {"resource": {"id": "` + publicID(123) + `", "name": "Some Name",
+				"attributes": {"size": 3}, "labels": [{"key": "env", "value": "prod"}, {"key": "tier", "value": "web"}],
+				"status": "active"}}}`))
+		})
+
+		It("returns null for resources that are not found", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(0, pgx.ErrNoRows)
+
+			By("acting")
+			_, got := post(`{ resource(id: "`+publicID(123)+`") { name } }`, nil)
+
+			By("asserting")
+			Expect(got.Errors).To(BeEmpty())
+			Expect(got.Data).To(HaveKeyWithValue("resource", BeNil()))
+		})
+
+		It("rejects invalid IDs", func() {
+			mockRepo.EXPECT().Resolve(gomock.Any(), gomock.Any()).Times(0)
+
+			_, got := post(`{ resource(id: "123") { name } }`, nil)
+
+			Expect(got.Errors).To(HaveLen(1))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("code", graph.CodeBadUserInput))
+		})
+
+		It("reports the retry delay while the database breaker is open", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).
+				Return(0, &database.CircuitOpenError{RetryAfter: 1500 * time.Millisecond})
+
+			By("acting")
+			_, got := post(`{ resource(id: "`+publicID(123)+`") { name } }`, nil)
+
+			By("asserting")
+			Expect(got.Errors).To(HaveLen(1))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("code", graph.CodeUnavailable))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("retryAfter", 2.0))
+		})
+
+		It("pages through resources in the order they were created", func() {
+			By("arranging")
+			all := []entities.Resource{
+				{ID: 3, PublicID: publicID(3), Name: "Third"},
+				{ID: 1, PublicID: publicID(1), Name: "First"},
+				{ID: 2, PublicID: publicID(2), Name: "Second"},
+			}
+			mockRepo.EXPECT().ReadAll(gomock.Any(), entities.ListOptions{}).Times(2).
+				DoAndReturn(func(context.Context, entities.ListOptions) ([]entities.Resource, error) {
+					return append([]entities.Resource(nil), all...), nil
+				})
+			query := `query ($after: String) { resources(first: 2, after: $after) {
+				edges { node { name } } pageInfo { hasNextPage endCursor } totalCount } }`
+
+			By("acting")
+			_, first := post(query, nil)
+			endCursor := first.Data["resources"].(map[string]interface{})["pageInfo"].(map[string]interface{})["endCursor"]
+			_, second := post(query, map[string]interface{}{"after": endCursor})
+
+			By("asserting")
+			Expect(first.Errors).To(BeEmpty())
+			Expect(first.Data["resources"]).To(Equal(map[string]interface{}{
+				"edges": []interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"name": "First"}},
+					map[string]interface{}{"node": map[string]interface{}{"name": "Second"}},
+				},
+				"pageInfo":   map[string]interface{}{"hasNextPage": true, "endCursor": endCursor},
+				"totalCount": 3.0,
+			}))
+			Expect(second.Data["resources"]).To(HaveKeyWithValue("edges", []interface{}{
+				map[string]interface{}{"node": map[string]interface{}{"name": "Third"}},
+			}))
+			Expect(second.Data["resources"]).To(HaveKeyWithValue("pageInfo", HaveKeyWithValue("hasNextPage", false)))
+		})
+
+		It("rejects invalid selectors", func() {
+			mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(0)
+
+			_, got := post(`{ resources(selector: "env=prod,") { totalCount } }`, nil)
+
+			Expect(got.Errors).To(HaveLen(1))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("code", graph.CodeBadUserInput))
+		})
+
+		Context("parents", func() {
+			otherParentID := 8
+			children := []entities.Resource{
+				{ID: 1, PublicID: publicID(1), ParentID: &parentID},
+				{ID: 2, PublicID: publicID(2), ParentID: &otherParentID},
+				{ID: 3, PublicID: publicID(3), ParentID: &parentID},
+				{ID: 4, PublicID: publicID(4)},
+			}
+			query := `{ resources { edges { node { parent { name } } } } }`
+
+			BeforeEach(func() {
+				mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(1).Return(children, nil)
+			})
+
+			It("are read in one batch", func() {
+				By("arranging")
+				repository := &batchRepository{MockResourceRepository: mockRepo, resources: map[int]entities.Resource{
+					7: {ID: 7, PublicID: publicID(7), Name: "Seven"},
+				}}
+				newHandler(repository)
+
+				By("acting")
+				_, got := post(query, nil)
+
+				By("asserting")
+				Expect(got.Errors).To(BeEmpty())
+				Expect(repository.batches).To(Equal([][]int{{7, 8}}))
+				Expect(got.Data["resources"].(map[string]interface{})["edges"]).To(Equal([]interface{}{
+					map[string]interface{}{"node": map[string]interface{}{"parent": map[string]interface{}{"name": "Seven"}}},
+					map[string]interface{}{"node": map[string]interface{}{"parent": nil}},
+					map[string]interface{}{"node": map[string]interface{}{"parent": map[string]interface{}{"name": "Seven"}}},
+					map[string]interface{}{"node": map[string]interface{}{"parent": nil}},
+				}))
+			})
+
+			It("are read once each without ReadBatch", func() {
+				By("arranging")
+				mockRepo.EXPECT().Read(gomock.Any(), 7).Times(1).Return(&entities.Resource{ID: 7, Name: "Seven"}, nil)
+				mockRepo.EXPECT().Read(gomock.Any(), 8).Times(1).Return(nil, pgx.ErrNoRows)
+
+				By("acting")
+				_, got := post(query, nil)
+
+				By("asserting")
+				Expect(got.Errors).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("limits", func() {
+		It("rejects queries that are too deep", func() {
+			By("arranging")
+			handler.MaxDepth = 3
+			mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			w, got := post(`{ resources { edges { node { parent { name } } } } }`, nil)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("code", graph.CodeQueryTooComplex))
+		})
+
+		It("counts the fields below lists once per item", func() {
+			By("arranging")
+			handler.MaxComplexity = 10
+			mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(1).Return(nil, nil)
+			query := `query ($first: Int) { resources(first: $first) { edges { node { id name } } } }`
+
+			By("acting")
+			tooComplex, _ := post(query, map[string]interface{}{"first": 5})
+			simple, _ := post(query, map[string]interface{}{"first": 1})
+
+			By("asserting")
+			Expect(tooComplex.Code).To(Equal(http.StatusBadRequest))
+			Expect(simple.Code).To(Equal(http.StatusOK))
+		})
+
+		It("does not count introspection", func() {
+			handler.MaxDepth = 2
+
+			w, got := post(`{ __schema { types { fields { type { ofType { name } } } } } }`, nil)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(got.Errors).To(BeEmpty())
+		})
+	})
+
+	Context("mutations", func() {
+		var (
+			mockRunner *mocks.MockTxRunner
+			mockAudit  *mocks.MockAuditRepository
+		)
+		BeforeEach(func() {
+			mockRunner = mocks.NewMockTxRunner(mockCtrl)
+			mockAudit = mocks.NewMockAuditRepository(mockCtrl)
+			handler.Runner, handler.Audit = mockRunner, mockAudit
+			mockRunner.EXPECT().WithinTxOptions(gomock.Any(), database.TxOptions{ForUpdate: true}, gomock.Any()).AnyTimes().
+				DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+					return fn(ctx)
+				})
+		})
+
+		It("creates the resource in a transaction and audits it", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(7)).Times(1).Return(7, nil)
+			mockRepo.EXPECT().Create(gomock.Any(), entities.Resource{Name: "Some Name",
+				Attributes: map[string]interface{}{"size": 3.0}, Labels: map[string]string{"env": "prod"},
+				Status: entities.DefaultLifecycle.Initial, ParentID: &parentID}).Times(1).Return(&resource, nil)
+			mockAudit.EXPECT().Record(gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(ctx context.Context, entry entities.AuditEntry) error {
+					Expect(entry.Method).To(Equal(http.MethodPost))
+					Expect(entry.Route).To(Equal("/graphql"))
+					return nil
+				})
+
+			By("acting")
+			_, got := post(`mutation { createResource(input: {name: "Some Name", attributes: {size: 3},
+				labels: [{key: "env", value: "prod"}], parentId: "`+publicID(7)+`"}) { id } }`, nil)
+
+			By("asserting")
+			Expect(got.Errors).To(BeEmpty())
+			Expect(got.Data).To(Equal(map[string]interface{}{"createResource": map[string]interface{}{"id": publicID(123)}}))
+		})
+
+		It("rolls back mutations with errors and does not audit them", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(&resource, nil)
+			mockRepo.EXPECT().DeleteTree(gomock.Any(), 123, entities.DeleteRestrict).Times(1).Return(entities.ErrHasChildren)
+			mockAudit.EXPECT().Record(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			_, got := post(`mutation { deleteResource(id: "`+publicID(123)+`") }`, nil)
+
+			By("asserting")
+			Expect(got.Errors).To(HaveLen(1))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("code", graph.CodeFailedPrecondition))
+		})
+
+		It("rejects invalid resources", func() {
+			mockRepo.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			mockAudit.EXPECT().Record(gomock.Any(), gomock.Any()).Times(0)
+
+			_, got := post(`mutation { updateResource(id: "`+publicID(123)+`",
+				input: {name: "Some Name", labels: [{key: "-invalid", value: "prod"}]}) { id } }`, nil)
+
+			Expect(got.Errors).To(HaveLen(1))
+			Expect(got.Errors[0].Extensions).To(HaveKeyWithValue("code", graph.CodeBadUserInput))
+		})
+
+		It("are not run on GET", func() {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+				"/graphql?query="+url.QueryEscape(`mutation { deleteResource(id: "`+publicID(123)+`") }`), nil))
+
+			Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Context("subscriptions", func() {
+		It("streams the changes of resources as server-sent events", func() {
+			By("arranging")
+			var readsPrimary bool
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).
+				DoAndReturn(func(ctx context.Context, id int) (*entities.Resource, error) {
+					readsPrimary = database.ReadsPrimary(ctx)
+					return &resource, nil
+				})
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			request, _ := http.NewRequestWithContext(ctx, http.MethodGet,
+				server.URL+"?query="+url.QueryEscape(`subscription { resourceChanged { type id resource { name } } }`), nil)
+			request.Header.Set("Accept", "text/event-stream")
+			resp, err := http.DefaultClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			lines := bufio.NewScanner(resp.Body)
+			next := func() string {
+				for lines.Scan() {
+					if data := strings.TrimPrefix(lines.Text(), "data: "); data != lines.Text() {
+						return data
+					}
+				}
+				return ""
+			}
+
+			By("acting")
+			events <- database.ChangeEvent{Op: database.ChangeUpdate, ID: 123, PublicID: publicID(123)}
+			events <- database.ChangeEvent{Op: database.ChangeDelete, ID: 123, PublicID: publicID(123)}
+
+			By("asserting")
+			Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+			Expect(next()).To(MatchJSON(`{"data": {"resourceChanged": {"type": "UPDATED", "id": "` + publicID(123) + `",
+				"resource": {"name": "Some Name"}}}}`))
+			Expect(next()).To(MatchJSON(`{"data": {"resourceChanged": {"type": "DELETED", "id": "` + publicID(123) + `",
+				"resource": null}}}`))
+			Expect(readsPrimary).To(BeTrue())
+		})
+	})
+
+	Context("GraphiQL", func() {
+		request := func() *http.Request {
+			request := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+			request.Header.Set("Accept", "text/html")
+			return request
+		}
+
+		It("is served when enabled", func() {
+			handler.GraphiQL = true
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request())
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring("GraphiQL"))
+		})
+
+		It("is not served by default", func() {
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, request())
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
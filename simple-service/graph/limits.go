@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listField is a field returning a list, its selections count once per item it may return.
+type listField struct {
+	// sizeArg is the argument bounding the list, estimate is its default or, without one, the size assumed.
+	sizeArg  string
+	estimate int
+}
+
+var listFields = map[string]listField{
+	"resources": {sizeArg: "first", estimate: defaultPageSize},
+	"search":    {sizeArg: "limit", estimate: defaultSearchLimit},
+	"children":  {estimate: childrenEstimate},
+}
+
+// measure returns the depth and complexity of the operation: depth counts nested selections, complexity
+// counts the fields the operation may resolve. Introspection is bounded by the schema and not counted.
+func measure(operation *ast.OperationDefinition, fragments map[string]*ast.FragmentDefinition,
+	variables map[string]interface{}) (depth, complexity int) {
+	m := measurer{fragments: fragments, variables: variables}
+	return m.selectionSet(operation.GetSelectionSet())
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (m measurer) selectionSet(set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			d, c = m.selectionSet(selection.SelectionSet)
+			d, c = d+1, 1+m.size(selection)*c
+		case *ast.InlineFragment:
+			d, c = m.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			// cycles are rejected by validation before the operation is measured
+			if fragment, ok := m.fragments[selection.Name.Value]; ok {
+				d, c = m.selectionSet(fragment.SelectionSet)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	return depth, complexity
+}
+
+// size is the number of items field may return, 1 unless it is a list field.
+func (m measurer) size(field *ast.Field) int {
+	list, ok := listFields[field.Name.Value]
+	if !ok {
+		return 1
+	}
+	size := list.estimate
+	for _, argument := range field.Arguments {
+		if list.sizeArg == "" || argument.Name.Value != list.sizeArg {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			size, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			switch variable := m.variables[value.Name.Value].(type) {
+			case float64:
+				size = int(variable)
+			case int:
+				size = variable
+			}
+		}
+	}
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+// checkLimits rejects operations deeper than maxDepth or more complex than maxComplexity.
+func checkLimits(operation *ast.OperationDefinition, fragments map[string]*ast.FragmentDefinition,
+	variables map[string]interface{}, maxDepth, maxComplexity int) error {
+	depth, complexity := measure(operation, fragments, variables)
+	if depth > maxDepth {
+		return &Error{Message: fmt.Sprintf("query is %d levels deep - at most %d are allowed", depth, maxDepth),
+			Code: CodeQueryTooComplex}
+	}
+	if complexity > maxComplexity {
+		return &Error{Message: fmt.Sprintf("query complexity is %d - at most %d is allowed", complexity, maxComplexity),
+			Code: CodeQueryTooComplex}
+	}
+	return nil
+}
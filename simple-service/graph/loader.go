@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/jackc/pgx/v4"
+)
+
+// BatchReader is implemented by repositories.Resource and by the cache in front of it. The loader reads
+// resources one ID at a time from repositories without it.
+type BatchReader interface {
+	ReadBatch(ctx context.Context, ids []int) ([]entities.Resource, error)
+}
+
+type loaderKey struct{}
+
+// loader batches and deduplicates the reads of one query: resolvers register the IDs they need and
+// return thunks, which graphql-go runs once the whole level of the query has been resolved,
+// so the first thunk to run reads every ID registered by that level at once.
+type loader struct {
+	ctx        context.Context
+	repository handlers.ResourceRepository
+
+	mu      sync.Mutex
+	pending []int
+	loaded  map[int]*loaded
+}
+
+type loaded struct {
+	resource *entities.Resource
+	err      error
+}
+
+func withLoader(ctx context.Context, repository handlers.ResourceRepository) context.Context {
+	l := &loader{repository: repository, loaded: make(map[int]*loaded)}
+	l.ctx = context.WithValue(ctx, loaderKey{}, l)
+	return l.ctx
+}
+
+// loaderFromContext returns nil outside of queries and mutations, subscriptions
+// read every event afresh.
+func loaderFromContext(ctx context.Context) *loader {
+	l, _ := ctx.Value(loaderKey{}).(*loader)
+	return l
+}
+
+// load returns a thunk resolving to the resource with id, or to null when it is gone.
+func (l *loader) load(id int) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.loaded[id]; !ok {
+		l.loaded[id] = nil
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+	return func() (interface{}, error) {
+		l.dispatch()
+		l.mu.Lock()
+		result := l.loaded[id]
+		l.mu.Unlock()
+		if errors.Is(result.err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		if result.err != nil {
+			return nil, graphError(result.err)
+		}
+		return result.resource, nil
+	}
+}
+
+func (l *loader) dispatch() {
+	l.mu.Lock()
+	ids := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+	results := make(map[int]*loaded, len(ids))
+	if batchReader, ok := l.repository.(BatchReader); ok {
+		resources, err := batchReader.ReadBatch(l.ctx, ids)
+		for i := range resources {
+			results[resources[i].ID] = &loaded{resource: &resources[i]}
+		}
+		for _, id := range ids {
+			if err != nil {
+				results[id] = &loaded{err: err}
+			} else if results[id] == nil {
+				results[id] = &loaded{err: pgx.ErrNoRows}
+			}
+		}
+	} else {
+		for _, id := range ids {
+			resource, err := l.repository.Read(l.ctx, id)
+			results[id] = &loaded{resource: resource, err: err}
+		}
+	}
+	l.mu.Lock()
+	for id, result := range results {
+		l.loaded[id] = result
+	}
+	l.mu.Unlock()
+}
@@ -0,0 +1,587 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/jackc/pgx/v4"
+)
+
+// Subscriber is implemented by *database.Listener.
+type Subscriber interface {
+	Subscribe(buffer int) (<-chan database.ChangeEvent, func())
+}
+
+const (
+	defaultPageSize    = 50
+	maxPageSize        = 100
+	defaultSearchLimit = 20
+	// childrenEstimate is what a children field counts towards the complexity of a query, per child field
+	childrenEstimate = 10
+	// subscriptionBuffer is the number of changes a subscriber may fall behind by, the listener drops changes beyond it
+	subscriptionBuffer = 256
+)
+
+// Resolver serves the GraphQL schema of resources from the same repository as handlers.Resource.
+type Resolver struct {
+	Repository handlers.ResourceRepository
+	Changes    Subscriber
+	Lifecycle  entities.Lifecycle
+}
+
+func NewResolver(repository handlers.ResourceRepository, changes Subscriber) *Resolver {
+	return &Resolver{Repository: repository, Changes: changes, Lifecycle: entities.DefaultLifecycle}
+}
+
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any JSON value, attributes are JSON objects.",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseJSONLiteral,
+})
+
+// parseJSONLiteral reads values written inline in a query, numbers become float64 like they do in JSON.
+func parseJSONLiteral(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.StringValue:
+		return value.Value
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.IntValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.FloatValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(value.Values))
+		for _, item := range value.Values {
+			list = append(list, parseJSONLiteral(item))
+		}
+		return list
+	case *ast.ObjectValue:
+		object := make(map[string]interface{}, len(value.Fields))
+		for _, field := range value.Fields {
+			object[field.Name.Value] = parseJSONLiteral(field.Value)
+		}
+		return object
+	default:
+		return nil
+	}
+}
+
+// label is how labels are served, GraphQL has no maps.
+type label struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+var labelType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Label",
+	Fields: graphql.Fields{
+		"key":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"value": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var labelInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "LabelInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"key":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"value": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var deletePolicyType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "DeletePolicy",
+	Values: graphql.EnumValueConfigMap{
+		"RESTRICT": &graphql.EnumValueConfig{Value: string(entities.DeleteRestrict)},
+		"CASCADE":  &graphql.EnumValueConfig{Value: string(entities.DeleteCascade)},
+		"ORPHAN":   &graphql.EnumValueConfig{Value: string(entities.DeleteOrphan)},
+	},
+})
+
+var eventTypeType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ResourceEventType",
+	Values: graphql.EnumValueConfigMap{
+		"CREATED": &graphql.EnumValueConfig{Value: database.ChangeInsert},
+		"UPDATED": &graphql.EnumValueConfig{Value: database.ChangeUpdate},
+		"DELETED": &graphql.EnumValueConfig{Value: database.ChangeDelete},
+		// RESYNC follows a gap in the stream, changes may have been missed and clients should query again
+		"RESYNC": &graphql.EnumValueConfig{Value: database.ChangeResync},
+	},
+})
+
+// connection is a page of resources, Relay style.
+type connection struct {
+	resources   []entities.Resource
+	hasNextPage bool
+	totalCount  int
+}
+
+// Schema builds the schema, its resolvers read through r.
+func (r *Resolver) Schema() (graphql.Schema, error) {
+	resourceType := graphql.NewObject(graphql.ObjectConfig{Name: "Resource", Fields: graphql.Fields{}})
+	resourceType.AddFieldConfig("id", &graphql.Field{Type: graphql.NewNonNull(graphql.ID),
+		Resolve: resourceField(func(resource *entities.Resource) interface{} { return resource.PublicID })})
+	resourceType.AddFieldConfig("name", &graphql.Field{Type: graphql.NewNonNull(graphql.String),
+		Resolve: resourceField(func(resource *entities.Resource) interface{} { return resource.Name })})
+	resourceType.AddFieldConfig("attributes", &graphql.Field{Type: jsonScalar,
+		Resolve: resourceField(func(resource *entities.Resource) interface{} { return resource.Attributes })})
+	resourceType.AddFieldConfig("labels", &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(labelType))),
+		Resolve: resourceField(func(resource *entities.Resource) interface{} { return labels(resource.Labels) })})
+	resourceType.AddFieldConfig("status", &graphql.Field{Type: graphql.NewNonNull(graphql.String),
+		Resolve: resourceField(func(resource *entities.Resource) interface{} { return resource.Status })})
+	resourceType.AddFieldConfig("deletedAt", &graphql.Field{Type: graphql.String,
+		Description: "When the resource was deleted, as an RFC 3339 timestamp.",
+		Resolve: resourceField(func(resource *entities.Resource) interface{} {
+			if resource.DeletedAt == nil {
+				return nil
+			}
+			return resource.DeletedAt.Format(time.RFC3339Nano)
+		})})
+	resourceType.AddFieldConfig("parent", &graphql.Field{Type: resourceType,
+		Description: "The parent of the resource, parents of one level of a query are read together.",
+		Resolve:     r.parent})
+	resourceType.AddFieldConfig("children", &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(resourceType))),
+		Resolve: r.children})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: connectionField(func(page *connection) interface{} { return page.hasNextPage })},
+			"endCursor": &graphql.Field{Type: graphql.String,
+				Resolve: connectionField(func(page *connection) interface{} {
+					if len(page.resources) == 0 {
+						return nil
+					}
+					return cursor(page.resources[len(page.resources)-1])
+				})},
+		},
+	})
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ResourceEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String),
+				Resolve: resourceField(func(resource *entities.Resource) interface{} { return cursor(*resource) })},
+			"node": &graphql.Field{Type: graphql.NewNonNull(resourceType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }},
+		},
+	})
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ResourceConnection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType))),
+				Resolve: connectionField(func(page *connection) interface{} {
+					edges := make([]*entities.Resource, 0, len(page.resources))
+					for i := range page.resources {
+						edges = append(edges, &page.resources[i])
+					}
+					return edges
+				})},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int),
+				Resolve: connectionField(func(page *connection) interface{} { return page.totalCount })},
+		},
+	})
+	searchResultType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SearchResult",
+		Fields: graphql.Fields{
+			"resource": &graphql.Field{Type: graphql.NewNonNull(resourceType),
+				Resolve: searchResultField(func(result *entities.SearchResult) interface{} { return &result.Resource })},
+			"rank": &graphql.Field{Type: graphql.NewNonNull(graphql.Float),
+				Resolve: searchResultField(func(result *entities.SearchResult) interface{} { return result.Rank })},
+			"snippet": &graphql.Field{Type: graphql.NewNonNull(graphql.String),
+				Description: "The name with the matched words wrapped in <mark> tags.",
+				Resolve:     searchResultField(func(result *entities.SearchResult) interface{} { return result.Snippet })},
+		},
+	})
+	eventType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ResourceEvent",
+		Fields: graphql.Fields{
+			"type": &graphql.Field{Type: graphql.NewNonNull(eventTypeType),
+				Resolve: eventField(func(event database.ChangeEvent) interface{} { return event.Op })},
+			"id": &graphql.Field{Type: graphql.ID,
+				Description: "The ID of the resource, null on RESYNC.",
+				Resolve: eventField(func(event database.ChangeEvent) interface{} {
+					if event.PublicID == "" {
+						return nil
+					}
+					return event.PublicID
+				})},
+			"resource": &graphql.Field{Type: resourceType,
+				Description: "The resource as it is when the change is sent, null once it is deleted.",
+				Resolve:     r.eventResource},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"resource": &graphql.Field{Type: resourceType,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.resource},
+			"resources": &graphql.Field{Type: graphql.NewNonNull(connectionType),
+				Description: "Resources ordered by creation, selector takes the syntax of the selector query parameter.",
+				Args: graphql.FieldConfigArgument{
+					"first":          &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":          &graphql.ArgumentConfig{Type: graphql.String},
+					"selector":       &graphql.ArgumentConfig{Type: graphql.String},
+					"includeDeleted": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: r.resources},
+			"search": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(searchResultType))),
+				Args: graphql.FieldConfigArgument{
+					"query":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultSearchLimit},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: r.search},
+		},
+	})
+
+	resourceFields := graphql.InputObjectConfigFieldMap{
+		"name":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"attributes": &graphql.InputObjectFieldConfig{Type: jsonScalar},
+		"labels":     &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(labelInputType))},
+	}
+	createFields := graphql.InputObjectConfigFieldMap{"parentId": &graphql.InputObjectFieldConfig{Type: graphql.ID}}
+	for name, field := range resourceFields {
+		createFields[name] = field
+	}
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createResource": &graphql.Field{Type: graphql.NewNonNull(resourceType),
+				Description: "Creates the resource in the initial status of the lifecycle.",
+				Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(
+					graphql.NewInputObject(graphql.InputObjectConfig{Name: "CreateResourceInput", Fields: createFields}))}},
+				Resolve: r.createResource},
+			"updateResource": &graphql.Field{Type: graphql.NewNonNull(resourceType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(
+						graphql.NewInputObject(graphql.InputObjectConfig{Name: "UpdateResourceInput", Fields: resourceFields}))},
+				},
+				Resolve: r.updateResource},
+			"deleteResource": &graphql.Field{Type: graphql.NewNonNull(graphql.ID),
+				Description: "Soft-deletes the resource and returns its ID.",
+				Args: graphql.FieldConfigArgument{
+					"id":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"children": &graphql.ArgumentConfig{Type: deletePolicyType, DefaultValue: string(entities.DeleteRestrict)},
+				},
+				Resolve: r.deleteResource},
+		},
+	})
+
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"resourceChanged": &graphql.Field{Type: graphql.NewNonNull(eventType),
+				Subscribe: r.subscribe,
+				Resolve:   func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation, Subscription: subscription})
+}
+
+func (r *Resolver) resource(p graphql.ResolveParams) (interface{}, error) {
+	id, err := r.resolve(p.Context, p.Args["id"].(string))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphError(err)
+	}
+	resource, err := r.Repository.Read(p.Context, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphError(err)
+	}
+	return resource, nil
+}
+
+// resources pages through ReadAll, ordered by public ID as UUIDv7 are ordered by creation.
+func (r *Resolver) resources(p graphql.ResolveParams) (interface{}, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 0 || first > maxPageSize {
+		return nil, badInput(fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+	}
+	selector, _ := p.Args["selector"].(string)
+	requirements, err := entities.ParseLabelSelector(selector)
+	if err != nil {
+		return nil, badInput(err.Error())
+	}
+	includeDeleted, _ := p.Args["includeDeleted"].(bool)
+	all, err := r.Repository.ReadAll(p.Context, entities.ListOptions{IncludeDeleted: includeDeleted, LabelSelector: requirements})
+	if err != nil {
+		return nil, graphError(err)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].PublicID < all[j].PublicID })
+	page := &connection{totalCount: len(all)}
+	if after, ok := p.Args["after"].(string); ok {
+		publicID, err := base64.StdEncoding.DecodeString(after)
+		if err != nil {
+			return nil, badInput(fmt.Sprintf("invalid cursor %q", after))
+		}
+		all = all[sort.Search(len(all), func(i int) bool { return all[i].PublicID > string(publicID) }):]
+	}
+	if len(all) > first {
+		all, page.hasNextPage = all[:first], true
+	}
+	page.resources = all
+	return page, nil
+}
+
+func (r *Resolver) search(p graphql.ResolveParams) (interface{}, error) {
+	limit, _ := p.Args["limit"].(int)
+	offset, _ := p.Args["offset"].(int)
+	if limit < 0 || limit > maxPageSize || offset < 0 {
+		return nil, badInput(fmt.Sprintf("limit must be between 0 and %d, offset must not be negative", maxPageSize))
+	}
+	results, err := r.Repository.Search(p.Context, p.Args["query"].(string), limit, offset)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	list := make([]*entities.SearchResult, 0, len(results))
+	for i := range results {
+		list = append(list, &results[i])
+	}
+	return list, nil
+}
+
+// parent is read through the loader of the request, a parent that is deleted is null.
+func (r *Resolver) parent(p graphql.ResolveParams) (interface{}, error) {
+	resource := p.Source.(*entities.Resource)
+	if resource.ParentID == nil {
+		return nil, nil
+	}
+	if loader := loaderFromContext(p.Context); loader != nil {
+		return loader.load(*resource.ParentID), nil
+	}
+	parent, err := r.Repository.Read(p.Context, *resource.ParentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphError(err)
+	}
+	return parent, nil
+}
+
+func (r *Resolver) children(p graphql.ResolveParams) (interface{}, error) {
+	children, err := r.Repository.Children(p.Context, p.Source.(*entities.Resource).ID)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	list := make([]*entities.Resource, 0, len(children))
+	for i := range children {
+		list = append(list, &children[i])
+	}
+	return list, nil
+}
+
+func (r *Resolver) createResource(p graphql.ResolveParams) (interface{}, error) {
+	input := p.Args["input"].(map[string]interface{})
+	resource, err := fromInput(input)
+	if err != nil {
+		return nil, err
+	}
+	resource.Status = r.Lifecycle.Initial
+	if parentID, ok := input["parentId"].(string); ok && parentID != "" {
+		id, err := r.resolve(p.Context, parentID)
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errInvalidID) {
+			return nil, badInput(entities.ErrParentMissing.Error())
+		}
+		if err != nil {
+			return nil, graphError(err)
+		}
+		resource.ParentID = &id
+	}
+	created, err := r.Repository.Create(p.Context, resource)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	return created, nil
+}
+
+func (r *Resolver) updateResource(p graphql.ResolveParams) (interface{}, error) {
+	resource, err := fromInput(p.Args["input"].(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	current, err := r.read(p.Context, p.Args["id"].(string))
+	if err != nil {
+		return nil, err
+	}
+	if err = r.Repository.Update(p.Context, current.ID, resource); err != nil {
+		return nil, graphError(err)
+	}
+	updated, err := r.Repository.Read(p.Context, current.ID)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	return updated, nil
+}
+
+func (r *Resolver) deleteResource(p graphql.ResolveParams) (interface{}, error) {
+	policy, err := entities.ParseDeletePolicy(p.Args["children"].(string))
+	if err != nil {
+		return nil, badInput(err.Error())
+	}
+	current, err := r.read(p.Context, p.Args["id"].(string))
+	if err != nil {
+		return nil, err
+	}
+	if err = r.Repository.DeleteTree(p.Context, current.ID, policy); err != nil {
+		return nil, graphError(err)
+	}
+	return current.PublicID, nil
+}
+
+// subscribe sends the changes of resources of the tenant of the request until the request is done.
+func (r *Resolver) subscribe(p graphql.ResolveParams) (interface{}, error) {
+	events, unsubscribe := r.Changes.Subscribe(subscriptionBuffer)
+	changes := make(chan interface{})
+	go func() {
+		defer close(changes)
+		defer unsubscribe()
+		for {
+			select {
+			case <-p.Context.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if !event.InScope(p.Context) {
+					continue
+				}
+				select {
+				case changes <- event:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// eventResource reads the resource of a change from the primary, as a replica may not have replayed it yet.
+func (r *Resolver) eventResource(p graphql.ResolveParams) (interface{}, error) {
+	event := p.Source.(database.ChangeEvent)
+	if event.Op != database.ChangeInsert && event.Op != database.ChangeUpdate {
+		return nil, nil
+	}
+	resource, err := r.Repository.Read(database.WithPrimary(p.Context), event.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphError(err)
+	}
+	return resource, nil
+}
+
+// read returns the live resource with publicID.
+func (r *Resolver) read(ctx context.Context, publicID string) (*entities.Resource, error) {
+	id, err := r.resolve(ctx, publicID)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	resource, err := r.Repository.Read(ctx, id)
+	if err != nil {
+		return nil, graphError(err)
+	}
+	return resource, nil
+}
+
+var errInvalidID = errors.New("invalid resource ID")
+
+// resolve returns the internal ID of the resource with publicID, deleted or not.
+func (r *Resolver) resolve(ctx context.Context, publicID string) (int, error) {
+	if !entities.ValidPublicID(publicID) {
+		return 0, fmt.Errorf("%w %q", errInvalidID, publicID)
+	}
+	return r.Repository.Resolve(ctx, publicID)
+}
+
+// fromInput returns the input of a mutation as a valid resource, empty maps are left nil like in JSON.
+func fromInput(input map[string]interface{}) (entities.Resource, error) {
+	resource := entities.Resource{Name: input["name"].(string)}
+	if attributes, ok := input["attributes"]; ok && attributes != nil {
+		object, ok := attributes.(map[string]interface{})
+		if !ok {
+			return resource, badInput("attributes must be a JSON object")
+		}
+		if len(object) > 0 {
+			resource.Attributes = object
+		}
+	}
+	if list, ok := input["labels"].([]interface{}); ok && len(list) > 0 {
+		resource.Labels = make(map[string]string, len(list))
+		for _, item := range list {
+			item := item.(map[string]interface{})
+			resource.Labels[item["key"].(string)] = item["value"].(string)
+		}
+	}
+	if err := resource.Validate(); err != nil {
+		return resource, badInput(err.Error())
+	}
+	return resource, nil
+}
+
+func labels(labels map[string]string) []label {
+	list := make([]label, 0, len(labels))
+	for key, value := range labels {
+		list = append(list, label{Key: key, Value: value})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+// cursor is opaque to clients, it is the public ID of the last resource they have seen.
+func cursor(resource entities.Resource) string {
+	return base64.StdEncoding.EncodeToString([]byte(resource.PublicID))
+}
+
+func resourceField(value func(resource *entities.Resource) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return value(p.Source.(*entities.Resource)), nil
+	}
+}
+
+func connectionField(value func(page *connection) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return value(p.Source.(*connection)), nil
+	}
+}
+
+func searchResultField(value func(result *entities.SearchResult) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return value(p.Source.(*entities.SearchResult)), nil
+	}
+}
+
+func eventField(value func(event database.ChangeEvent) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return value(p.Source.(database.ChangeEvent)), nil
+	}
+}
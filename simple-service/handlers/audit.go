@@ -0,0 +1,100 @@
+//go:generate mockgen -destination=mocks/audit.go -package mocks . AuditRepository
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type AuditRepository interface {
+	Record(ctx context.Context, entry entities.AuditEntry) error
+	Query(ctx context.Context, filter entities.AuditFilter) ([]entities.AuditEntry, error)
+}
+
+type Audit struct {
+	Repository AuditRepository
+}
+
+func NewAudit(repository AuditRepository) *Audit {
+	return &Audit{Repository: repository}
+}
+
+// Record appends every successful mutating request to the audit log. It has to run inside
+// Transactional, so that the entry is committed or rolled back together with the request.
+func (a *Audit) Record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method == http.MethodGet || request.Method == http.MethodHead || request.Method == http.MethodOptions {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		response := newBufferedResponse()
+		next.ServeHTTP(response, request)
+		if response.status < http.StatusBadRequest {
+			entry := entities.AuditEntry{
+				Principal:  auth.PrincipalFromContext(request.Context()),
+				RemoteAddr: request.RemoteAddr,
+				RequestID:  middleware.GetReqID(request.Context()),
+				Method:     request.Method,
+			}
+			// routing happened in next, the route context now holds what was matched
+			if routeContext := chi.RouteContext(request.Context()); routeContext != nil {
+				entry.Route = routeContext.RoutePattern()
+			}
+			// legacy integer IDs are left to the repository, which takes the resource from the revisions
+			if resourceID := chi.URLParam(request, "resourceID"); entities.ValidPublicID(resourceID) {
+				entry.ResourceID = &resourceID
+			}
+			if err := a.Repository.Record(request.Context(), entry); err != nil {
+				serverError(writer, err)
+				return
+			}
+		}
+		response.writeTo(writer)
+	})
+}
+
+// List returns audit entries oldest first, filtered by the actor, since, until, resource_id and limit query parameters.
+func (a *Audit) List(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter := entities.AuditFilter{Principal: query.Get("actor")}
+	var err error
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if value := query.Get(param.name); value != "" {
+			if *param.dest, err = time.Parse(time.RFC3339, value); err != nil {
+				http.Error(writer, "invalid "+param.name+" - should be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			http.Error(writer, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("resource_id"); value != "" {
+		if !entities.ValidPublicID(value) {
+			http.Error(writer, "invalid resource_id", http.StatusBadRequest)
+			return
+		}
+		filter.ResourceID = value
+	}
+	entries, err := a.Repository.Query(request.Context(), filter)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(entries)
+	writer.Write(bytes)
+}
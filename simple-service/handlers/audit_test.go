@@ -0,0 +1,207 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockAuditRepository
+		w        *httptest.ResponseRecorder
+		router   chi.Router
+		status   int
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockAuditRepository(mockCtrl)
+		w = httptest.NewRecorder()
+		status = http.StatusOK
+		router = chi.NewRouter()
+		router.Use(middleware.RequestID)
+		router.Use(handlers.NewAudit(mockRepo).Record)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte("done"))
+		}
+		router.Get("/resources/{resourceID}", handler)
+		router.Put("/resources/{resourceID}", handler)
+		router.Post("/resources", handler)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("Record", func() {
+		It("records successful mutations with the matched route", func() {
+			By("arranging")
+			resourceID := publicID(123)
+			req := httptest.NewRequest(http.MethodPut, "/resources/"+resourceID, nil)
+			req.RemoteAddr = "192.0.2.1"
+			mockRepo.EXPECT().Record(gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(_ interface{}, entry entities.AuditEntry) error {
+					Expect(entry.Principal).To(Equal("anonymous"))
+					Expect(entry.RemoteAddr).To(Equal("192.0.2.1"))
+					Expect(entry.RequestID).NotTo(BeEmpty())
+					Expect(entry.Method).To(Equal(http.MethodPut))
+					Expect(entry.Route).To(Equal("/resources/{resourceID}"))
+					Expect(entry.ResourceID).To(Equal(&resourceID))
+					return nil
+				})
+
+			By("acting")
+			router.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("done"))
+		})
+
+		It("leaves legacy integer IDs to the repository", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPut, "/resources/123", nil)
+			mockRepo.EXPECT().Record(gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(_ interface{}, entry entities.AuditEntry) error {
+					Expect(entry.ResourceID).To(BeNil())
+					return nil
+				})
+
+			By("acting")
+			router.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		It("leaves the resource ID to the repository when the route has none", func() {
+			By("arranging")
+			status = http.StatusCreated
+			req := httptest.NewRequest(http.MethodPost, "/resources", nil)
+			mockRepo.EXPECT().Record(gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(_ interface{}, entry entities.AuditEntry) error {
+					Expect(entry.ResourceID).To(BeNil())
+					return nil
+				})
+
+			By("acting")
+			router.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusCreated))
+		})
+
+		It("does not record reads", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/resources/123", nil)
+			mockRepo.EXPECT().Record(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			router.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		It("does not record failed requests", func() {
+			By("arranging")
+			status = http.StatusNotFound
+			req := httptest.NewRequest(http.MethodPut, "/resources/123", nil)
+			mockRepo.EXPECT().Record(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			router.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("fails the request when it cannot be recorded", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPut, "/resources/123", nil)
+			mockRepo.EXPECT().Record(gomock.Any(), gomock.Any()).Times(1).Return(errors.New("some err"))
+
+			By("acting")
+			router.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(w.Body.String()).To(Equal("some err\n"))
+		})
+	})
+
+	Context("List", func() {
+		It("passes the filters to the repository", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet,
+				"/?actor=operator&since=2022-04-15T05:20:00Z&until=2022-04-16T05:20:00Z&resource_id="+publicID(123)+"&limit=10", nil)
+			createdAt := time.Date(2022, 4, 15, 6, 0, 0, 0, time.UTC)
+			resourceID := publicID(123)
+			mockRepo.EXPECT().Query(req.Context(), entities.AuditFilter{
+				Principal:  "operator",
+				Since:      time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC),
+				Until:      time.Date(2022, 4, 16, 5, 20, 0, 0, time.UTC),
+				ResourceID: publicID(123),
+				Limit:      10,
+			}).Times(1).Return([]entities.AuditEntry{{ID: 1, Principal: "operator", Method: "DELETE",
+				Route: "/resources/{resourceID}/", ResourceID: &resourceID, CreatedAt: createdAt, PrevHash: "genesis", Hash: "abc"}}, nil)
+
+			By("acting")
+			handlers.NewAudit(mockRepo).List(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`[{"id":1,"principal":"operator","remote_addr":"","request_id":"",
+				"method":"DELETE","route":"/resources/{resourceID}/","resource_id":"00000000-0000-7000-8000-000000000123","before":null,"after":null,
+				"created_at":"2022-04-15T06:00:00Z","prev_hash":"genesis","hash":"abc"}]`))
+		})
+
+		It("returns 400 for resource IDs that are not public IDs", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/?resource_id=123", nil)
+			mockRepo.EXPECT().Query(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewAudit(mockRepo).List(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 400 for invalid timestamps", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/?since=yesterday", nil)
+			mockRepo.EXPECT().Query(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewAudit(mockRepo).List(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 500 when the repository fails", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			mockRepo.EXPECT().Query(req.Context(), entities.AuditFilter{}).Times(1).Return(nil, errors.New("some err"))
+
+			By("acting")
+			handlers.NewAudit(mockRepo).List(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
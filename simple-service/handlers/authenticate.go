@@ -0,0 +1,61 @@
+//go:generate mockgen -destination=mocks/apikey.go -package mocks . APIKeyRepository
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/jackc/pgx/v4"
+)
+
+type APIKeyRepository interface {
+	Authenticate(ctx context.Context, secret string) (string, error)
+}
+
+// Authenticate makes the principal of the API key in the bearer token the principal of the request.
+// Requests without a token stay anonymous, those with an unknown or revoked key are rejected. JWTs name the
+// tenant of the request rather than its principal, they are verified by ResolveTenant and stay anonymous here.
+func Authenticate(keys APIKeyRepository) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			header := request.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			secret := strings.TrimPrefix(header, "Bearer ")
+			if secret == header || secret == "" {
+				http.Error(writer, "authorization is not a bearer token", http.StatusUnauthorized)
+				return
+			}
+			if auth.IsJWT(secret) {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			principal, err := keys.Authenticate(request.Context(), secret)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(writer, "API key is unknown or revoked", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				serverError(writer, err)
+				return
+			}
+			next.ServeHTTP(writer, request.WithContext(auth.WithPrincipal(request.Context(), principal)))
+		})
+	}
+}
+
+// RequireAuthenticated rejects the requests Authenticate left anonymous, those carrying a JWT included.
+func RequireAuthenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if auth.PrincipalFromContext(request.Context()) == auth.Anonymous {
+			http.Error(writer, "the request has to be authenticated with an API key", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
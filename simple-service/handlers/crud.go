@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4"
+)
+
+// Store is the repository CRUD serves T from, repositories.Table implements it for any T.
+type Store[T any] interface {
+	Create(ctx context.Context, entity T) (*T, error)
+	Resolve(ctx context.Context, publicID string) (int, error)
+	Read(ctx context.Context, id int) (*T, error)
+	ReadAll(ctx context.Context, options entities.ListOptions) ([]T, error)
+	Update(ctx context.Context, id int, entity T) error
+	Delete(ctx context.Context, id int) error
+}
+
+// CRUD is the HTTP handler of the entities declared by Meta.
+type CRUD[T any] struct {
+	Store Store[T]
+	Meta  *entities.Meta[T]
+	// LegacyIDs also accepts the integer IDs entities were addressed by before public IDs.
+	LegacyIDs bool
+}
+
+func NewCRUD[T any](store Store[T], meta *entities.Meta[T]) *CRUD[T] {
+	return &CRUD[T]{Store: store, Meta: meta}
+}
+
+// Mount serves the routes of the entities at Meta.Path.
+func (c *CRUD[T]) Mount(r chi.Router) {
+	r.Route(c.Meta.Path, c.Routes)
+}
+
+// Routes registers the collection and item routes on r, for entities with routes of their own next to them.
+func (c *CRUD[T]) Routes(r chi.Router) {
+	r.Get("/", c.List)
+	r.Post("/", c.Post)
+	r.Route("/{"+c.param()+"}", func(r chi.Router) {
+		r.Use(c.GetCtx)
+		r.Get("/", c.Get)
+		r.Put("/", c.Put)
+		r.Delete("/", c.Delete)
+	})
+}
+
+func (c *CRUD[T]) Post(writer http.ResponseWriter, request *http.Request) {
+	entity, ok := c.decode(writer, request)
+	if !ok {
+		return
+	}
+	created, err := c.Store.Create(request.Context(), entity)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusCreated)
+	resp := fmt.Sprintf(`{"id": %q}`, c.Meta.PublicID(*created))
+	writer.Write([]byte(resp))
+}
+
+// GetCtx puts the entity named by the URL parameter into the request context under Meta.Name.
+func (c *CRUD[T]) GetCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ID, ok := c.resolveID(writer, request)
+		if !ok {
+			return
+		}
+		entity, err := c.Store.Read(request.Context(), ID)
+		if unavailable(writer, err) {
+			return
+		}
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		}
+		ctx := context.WithValue(request.Context(), c.Meta.Name, entity)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+func (c *CRUD[T]) Get(writer http.ResponseWriter, request *http.Request) {
+	entity, ok := c.fromCtx(writer, request)
+	if !ok {
+		return
+	}
+	bytes, _ := json.Marshal(entity)
+	writer.Write(bytes)
+}
+
+func (c *CRUD[T]) List(writer http.ResponseWriter, request *http.Request) {
+	var options entities.ListOptions
+	switch include := request.URL.Query().Get("include"); {
+	case include == "":
+	case include == "deleted" && c.Meta.SoftDelete:
+		options.IncludeDeleted = true
+	default:
+		http.Error(writer, fmt.Sprintf("invalid include %q", include), http.StatusBadRequest)
+		return
+	}
+	selector, err := entities.ParseLabelSelector(request.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(selector) > 0 && c.Meta.Labels == "" {
+		http.Error(writer, fmt.Sprintf("%s has no labels to select by", c.Meta.Name), http.StatusBadRequest)
+		return
+	}
+	options.LabelSelector = selector
+	// the listing is paged for clients asking for pages only, the others get the array they always got
+	query := request.URL.Query()
+	if !query.Has("limit") && !query.Has("cursor") {
+		all, err := c.Store.ReadAll(request.Context(), options)
+		if err != nil {
+			serverError(writer, err)
+			return
+		}
+		bytes, _ := json.Marshal(all)
+		writer.Write(bytes)
+		return
+	}
+	limit, offset, ok := readPage(writer, request)
+	if !ok {
+		return
+	}
+	// one more than asked for tells whether there is a next page
+	options.Limit, options.Offset = limit+1, offset
+	all, err := c.Store.ReadAll(request.Context(), options)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(newPage(all, limit, offset))
+	writer.Write(bytes)
+}
+
+func (c *CRUD[T]) Put(writer http.ResponseWriter, request *http.Request) {
+	current, ok := c.fromCtx(writer, request)
+	if !ok {
+		return
+	}
+	entity, ok := c.decode(writer, request)
+	if !ok {
+		return
+	}
+	if err := c.Store.Update(request.Context(), c.Meta.ID(*current), entity); err != nil {
+		serverError(writer, err)
+		return
+	}
+}
+
+func (c *CRUD[T]) Delete(writer http.ResponseWriter, request *http.Request) {
+	current, ok := c.fromCtx(writer, request)
+	if !ok {
+		return
+	}
+	if err := c.Store.Delete(request.Context(), c.Meta.ID(*current)); err != nil {
+		serverError(writer, err)
+		return
+	}
+}
+
+// decode reads a valid entity from the JSON body, it responds with an error itself when there is none.
+func (c *CRUD[T]) decode(writer http.ResponseWriter, request *http.Request) (T, bool) {
+	var entity T
+	if request.Header.Get("Content-Type") != "application/json" {
+		http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+		return entity, false
+	}
+	bytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		serverError(writer, err)
+		return entity, false
+	}
+	if err = json.Unmarshal(bytes, &entity); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return entity, false
+	}
+	if c.Meta.Validate != nil {
+		if err = c.Meta.Validate(entity); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return entity, false
+		}
+	}
+	return entity, true
+}
+
+func (c *CRUD[T]) fromCtx(writer http.ResponseWriter, request *http.Request) (*T, bool) {
+	entity, ok := request.Context().Value(c.Meta.Name).(*T)
+	if !ok {
+		http.Error(writer, fmt.Sprintf("failed to read %s from the context", c.Meta.Name), http.StatusBadRequest)
+	}
+	return entity, ok
+}
+
+// resolveID returns the internal ID of the entity named by the URL parameter,
+// it responds with an error itself when there is none.
+func (c *CRUD[T]) resolveID(writer http.ResponseWriter, request *http.Request) (int, bool) {
+	param := chi.URLParam(request, c.param())
+	if !entities.ValidPublicID(param) {
+		if ID, err := strconv.Atoi(param); err == nil && c.LegacyIDs {
+			return ID, true
+		}
+		http.Error(writer, fmt.Sprintf("invalid %s ID %q", c.Meta.Name, param), http.StatusBadRequest)
+		return 0, false
+	}
+	ID, err := c.Store.Resolve(request.Context(), param)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, c.Meta.Name+" not found", http.StatusNotFound)
+		return 0, false
+	}
+	if err != nil {
+		serverError(writer, err)
+		return 0, false
+	}
+	return ID, true
+}
+
+// param is the name of the URL parameter holding the public ID, e.g. "resourceID".
+func (c *CRUD[T]) param() string {
+	return c.Meta.Name + "ID"
+}
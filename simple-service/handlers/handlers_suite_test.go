@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"fmt"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -11,3 +12,8 @@ func TestHandlers(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Handlers Suite")
 }
+
+// publicID returns the public ID the tests know the resource with the internal id by.
+func publicID(id int) string {
+	return fmt.Sprintf("00000000-0000-7000-8000-%012d", id)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+)
+
+// Ready is the readiness check, unlike the liveness check at /healthz it fails while the database breaker is open
+// so that load balancers send the traffic elsewhere.
+func Ready(breaker *database.Breaker) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		stats := breaker.Stats()
+		status := http.StatusOK
+		if stats.State == database.BreakerOpen {
+			setRetryAfter(writer, breaker.RetryAfter())
+			status = http.StatusServiceUnavailable
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(status)
+		json.NewEncoder(writer).Encode(map[string]database.BreakerStats{"database": stats})
+	}
+}
+
+// serverError responds with 503 while the database is unavailable, and with 500 otherwise.
+func serverError(writer http.ResponseWriter, err error) {
+	if !unavailable(writer, err) {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// unavailable responds with 503 and tells the client when to retry if err comes from an open database breaker.
+func unavailable(writer http.ResponseWriter, err error) bool {
+	var circuitOpen *database.CircuitOpenError
+	if !errors.As(err, &circuitOpen) {
+		return false
+	}
+	setRetryAfter(writer, circuitOpen.RetryAfter)
+	http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+	return true
+}
+
+func setRetryAfter(writer http.ResponseWriter, retryAfter time.Duration) {
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var w *httptest.ResponseRecorder
+	BeforeEach(func() {
+		w = httptest.NewRecorder()
+	})
+
+	Context("Ready", func() {
+		It("is ready while the breaker is closed", func() {
+			handlers.Ready(database.NewBreaker(1, time.Minute))(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"database":{"state":"closed","failures":0,"rejected":0}}`))
+		})
+
+		It("is not ready while the breaker is open", func() {
+			By("arranging")
+			breaker := database.NewBreaker(1, time.Minute)
+			breaker.Record(errors.New("connection refused"))
+
+			By("acting")
+			handlers.Ready(breaker)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(w.Header().Get("Retry-After")).To(Equal("60"))
+			Expect(w.Body.String()).To(MatchJSON(`{"database":{"state":"open","failures":1,"rejected":0}}`))
+		})
+	})
+
+	Context("while the database is unavailable", func() {
+		var (
+			mockCtrl *gomock.Controller
+			mockRepo *mocks.MockResourceRepository
+		)
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		})
+
+		AfterEach(func() {
+			mockCtrl.Finish()
+		})
+
+		request := func() *http.Request {
+			routeParams := chi.RouteParams{}
+			routeParams.Add("resourceID", publicID(123))
+			ctx := context.WithValue(context.TODO(), chi.RouteCtxKey, &chi.Context{URLParams: routeParams})
+			return httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		}
+		circuitOpen := &database.CircuitOpenError{RetryAfter: 1500 * time.Millisecond}
+
+		It("responds with 503 and Retry-After", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(0, circuitOpen)
+
+			By("acting")
+			handlers.NewResource(mockRepo).GetCtx(nil).ServeHTTP(w, request())
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(w.Header().Get("Retry-After")).To(Equal("2"))
+		})
+
+		It("does not report the resource as not found", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(nil, circuitOpen)
+
+			By("acting")
+			handlers.NewResource(mockRepo).GetCtx(nil).ServeHTTP(w, request())
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+func (r *Resource) Children(writer http.ResponseWriter, request *http.Request) {
+	currentResource, ok := request.Context().Value("resource").(*entities.Resource)
+	if !ok {
+		http.Error(writer, getFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	children, err := r.Repository.Children(request.Context(), currentResource.ID)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(children)
+	writer.Write(bytes)
+}
+
+func (r *Resource) Ancestors(writer http.ResponseWriter, request *http.Request) {
+	currentResource, ok := request.Context().Value("resource").(*entities.Resource)
+	if !ok {
+		http.Error(writer, getFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	ancestors, err := r.Repository.Ancestors(request.Context(), currentResource.ID)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(ancestors)
+	writer.Write(bytes)
+}
+
+// Subtree returns the resource with its descendants nested below it, down to the depth parameter.
+func (r *Resource) Subtree(writer http.ResponseWriter, request *http.Request) {
+	currentResource, ok := request.Context().Value("resource").(*entities.Resource)
+	if !ok {
+		http.Error(writer, getFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	depth := entities.MaxSubtreeDepth
+	if value := request.URL.Query().Get("depth"); value != "" {
+		var err error
+		if depth, err = strconv.Atoi(value); err != nil || depth < 0 || depth > entities.MaxSubtreeDepth {
+			http.Error(writer, fmt.Sprintf("invalid depth %q, should be between 0 and %d", value,
+				entities.MaxSubtreeDepth), http.StatusBadRequest)
+			return
+		}
+	}
+	tree, err := r.Repository.Subtree(request.Context(), currentResource.ID, depth)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, "resource not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(tree)
+	writer.Write(bytes)
+}
+
+type moveRequest struct {
+	ParentID *string `json:"parent_id"`
+}
+
+// Move puts the resource below the parent of the body, a null parent makes it a root.
+func (r *Resource) Move(writer http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+		return
+	}
+	ID, ok := r.resolveID(writer, request)
+	if !ok {
+		return
+	}
+	bytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	var move moveRequest
+	if err = json.Unmarshal(bytes, &move); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var parentID *int
+	if move.ParentID != nil {
+		if parentID, err = r.resolveParentID(request, *move.ParentID); err != nil {
+			writeParentError(writer, err)
+			return
+		}
+	}
+	resource, err := r.Repository.Move(request.Context(), ID, parentID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		http.Error(writer, "resource not found", http.StatusNotFound)
+		return
+	case errors.Is(err, entities.ErrParentMissing):
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, entities.ErrHierarchyLoop):
+		http.Error(writer, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		serverError(writer, err)
+		return
+	}
+	bytes, _ = json.Marshal(resource)
+	writer.Write(bytes)
+}
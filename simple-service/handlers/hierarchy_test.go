@@ -0,0 +1,224 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource hierarchy", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		w        *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		w = httptest.NewRecorder()
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).AnyTimes().Return(123, nil)
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(7)).AnyTimes().Return(7, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	parentID := func(id int) *int { return &id }
+	resource := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name", Status: entities.StatusActive}
+	resourceRequest := func(target string) *http.Request {
+		ctx := context.WithValue(context.TODO(), "resource", resource)
+		return httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	}
+
+	Context("Children", func() {
+		It("lists the children", func() {
+			By("arranging")
+			req := resourceRequest("/")
+			mockRepo.EXPECT().Children(req.Context(), 123).Times(1).
+				Return([]entities.Resource{{ID: 124, PublicID: publicID(124), Name: "Child", ParentID: parentID(123), ParentPublicID: publicID(123)}}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Children(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`[{"id":"00000000-0000-7000-8000-000000000124","name":"Child","parent_id":"00000000-0000-7000-8000-000000000123"}]`))
+		})
+
+		It("returns 500 when the repository fails", func() {
+			By("arranging")
+			req := resourceRequest("/")
+			mockRepo.EXPECT().Children(req.Context(), 123).Times(1).Return(nil, errors.New("some err"))
+
+			By("acting")
+			handlers.NewResource(mockRepo).Children(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("Ancestors", func() {
+		It("lists the ancestors", func() {
+			By("arranging")
+			req := resourceRequest("/")
+			mockRepo.EXPECT().Ancestors(req.Context(), 123).Times(1).
+				Return([]entities.Resource{{ID: 122, PublicID: publicID(122), Name: "Parent", ParentID: parentID(121), ParentPublicID: publicID(121)}, {ID: 121, PublicID: publicID(121), Name: "Root"}}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Ancestors(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`[{"id":"00000000-0000-7000-8000-000000000122","name":"Parent","parent_id":"00000000-0000-7000-8000-000000000121"},{"id":"00000000-0000-7000-8000-000000000121","name":"Root"}]`))
+		})
+	})
+
+	Context("Subtree", func() {
+		It("returns the nested subtree", func() {
+			By("arranging")
+			req := resourceRequest("/?depth=1")
+			mockRepo.EXPECT().Subtree(req.Context(), 123, 1).Times(1).Return(&entities.ResourceNode{
+				Resource: *resource,
+				Children: []entities.ResourceNode{{Resource: entities.Resource{ID: 124, PublicID: publicID(124), Name: "Child", ParentID: parentID(123), ParentPublicID: publicID(123)}}},
+			}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Subtree(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(
+				`{"id":"00000000-0000-7000-8000-000000000123","name":"Resource Name","status":"active","children":[{"id":"00000000-0000-7000-8000-000000000124","name":"Child","parent_id":"00000000-0000-7000-8000-000000000123"}]}`))
+		})
+
+		It("descends to the maximum depth by default", func() {
+			By("arranging")
+			req := resourceRequest("/")
+			mockRepo.EXPECT().Subtree(req.Context(), 123, entities.MaxSubtreeDepth).Times(1).
+				Return(&entities.ResourceNode{Resource: *resource}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Subtree(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		DescribeTable("rejects invalid depths",
+			func(target string) {
+				req := resourceRequest(target)
+				mockRepo.EXPECT().Subtree(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+				handlers.NewResource(mockRepo).Subtree(w, req)
+
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+			},
+			Entry("not a number", "/?depth=all"),
+			Entry("negative", "/?depth=-1"),
+			Entry("too deep", "/?depth=33"),
+		)
+
+		It("returns 404 when the resource is gone", func() {
+			By("arranging")
+			req := resourceRequest("/")
+			mockRepo.EXPECT().Subtree(req.Context(), 123, entities.MaxSubtreeDepth).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Subtree(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Move", func() {
+		moveRequest := func(body string) *http.Request {
+			routeParams := chi.RouteParams{}
+			routeParams.Add("resourceID", publicID(123))
+			ctx := context.WithValue(context.TODO(), chi.RouteCtxKey, &chi.Context{URLParams: routeParams})
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body))).WithContext(ctx)
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}
+
+		It("moves the resource below the parent", func() {
+			By("arranging")
+			req := moveRequest(`{"parent_id":"00000000-0000-7000-8000-000000000007"}`)
+			mockRepo.EXPECT().Move(req.Context(), 123, parentID(7)).Times(1).
+				Return(&entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name", ParentID: parentID(7), ParentPublicID: publicID(7)}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Move(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"id":"00000000-0000-7000-8000-000000000123","name":"Resource Name","parent_id":"00000000-0000-7000-8000-000000000007"}`))
+		})
+
+		It("makes the resource a root", func() {
+			By("arranging")
+			req := moveRequest(`{"parent_id":null}`)
+			mockRepo.EXPECT().Move(req.Context(), 123, (*int)(nil)).Times(1).
+				Return(&entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name"}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Move(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+
+		DescribeTable("maps repository errors",
+			func(err error, status int) {
+				req := moveRequest(`{"parent_id":"00000000-0000-7000-8000-000000000007"}`)
+				mockRepo.EXPECT().Move(req.Context(), 123, parentID(7)).Times(1).Return(nil, err)
+
+				handlers.NewResource(mockRepo).Move(w, req)
+
+				Expect(w.Code).To(Equal(status))
+			},
+			Entry("missing resource", pgx.ErrNoRows, http.StatusNotFound),
+			Entry("missing parent", entities.ErrParentMissing, http.StatusBadRequest),
+			Entry("loop", entities.ErrHierarchyLoop, http.StatusConflict),
+			Entry("anything else", errors.New("some err"), http.StatusInternalServerError),
+		)
+
+		It("returns 400 for unknown parents", func() {
+			By("arranging")
+			req := moveRequest(`{"parent_id":"00000000-0000-7000-8000-000000000999"}`)
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(999)).Times(1).Return(0, pgx.ErrNoRows)
+			mockRepo.EXPECT().Move(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Move(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 400 for invalid bodies", func() {
+			By("arranging")
+			req := moveRequest(`{"parent_id":7}`)
+			mockRepo.EXPECT().Move(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Move(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -35,11 +35,41 @@ func (m *MockResourceRepository) EXPECT() *MockResourceRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Ancestors mocks base method.
+func (m *MockResourceRepository) Ancestors(arg0 context.Context, arg1 int) ([]entities.Resource, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ancestors", arg0, arg1)
+	ret0, _ := ret[0].([]entities.Resource)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Ancestors indicates an expected call of Ancestors.
+func (mr *MockResourceRepositoryMockRecorder) Ancestors(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ancestors", reflect.TypeOf((*MockResourceRepository)(nil).Ancestors), arg0, arg1)
+}
+
+// Children mocks base method.
+func (m *MockResourceRepository) Children(arg0 context.Context, arg1 int) ([]entities.Resource, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Children", arg0, arg1)
+	ret0, _ := ret[0].([]entities.Resource)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Children indicates an expected call of Children.
+func (mr *MockResourceRepositoryMockRecorder) Children(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Children", reflect.TypeOf((*MockResourceRepository)(nil).Children), arg0, arg1)
+}
+
 // Create mocks base method.
-func (m *MockResourceRepository) Create(arg0 context.Context, arg1 entities.Resource) (int, error) {
+func (m *MockResourceRepository) Create(arg0 context.Context, arg1 entities.Resource) (*entities.Resource, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", arg0, arg1)
-	ret0, _ := ret[0].(int)
+	ret0, _ := ret[0].(*entities.Resource)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -64,6 +94,35 @@ func (mr *MockResourceRepositoryMockRecorder) Delete(arg0, arg1 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockResourceRepository)(nil).Delete), arg0, arg1)
 }
 
+// DeleteTree mocks base method.
+func (m *MockResourceRepository) DeleteTree(arg0 context.Context, arg1 int, arg2 entities.DeletePolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTree", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTree indicates an expected call of DeleteTree.
+func (mr *MockResourceRepositoryMockRecorder) DeleteTree(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTree", reflect.TypeOf((*MockResourceRepository)(nil).DeleteTree), arg0, arg1, arg2)
+}
+
+// Move mocks base method.
+func (m *MockResourceRepository) Move(arg0 context.Context, arg1 int, arg2 *int) (*entities.Resource, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Move", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*entities.Resource)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Move indicates an expected call of Move.
+func (mr *MockResourceRepositoryMockRecorder) Move(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Move", reflect.TypeOf((*MockResourceRepository)(nil).Move), arg0, arg1, arg2)
+}
+
 // Read mocks base method.
 func (m *MockResourceRepository) Read(arg0 context.Context, arg1 int) (*entities.Resource, error) {
 	m.ctrl.T.Helper()
@@ -80,18 +139,138 @@ func (mr *MockResourceRepositoryMockRecorder) Read(arg0, arg1 interface{}) *gomo
 }
 
 // ReadAll mocks base method.
-func (m *MockResourceRepository) ReadAll(arg0 context.Context) ([]entities.Resource, error) {
+func (m *MockResourceRepository) ReadAll(arg0 context.Context, arg1 entities.ListOptions) ([]entities.Resource, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadAll", arg0)
+	ret := m.ctrl.Call(m, "ReadAll", arg0, arg1)
 	ret0, _ := ret[0].([]entities.Resource)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ReadAll indicates an expected call of ReadAll.
-func (mr *MockResourceRepositoryMockRecorder) ReadAll(arg0 interface{}) *gomock.Call {
+func (mr *MockResourceRepositoryMockRecorder) ReadAll(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadAll", reflect.TypeOf((*MockResourceRepository)(nil).ReadAll), arg0, arg1)
+}
+
+// Resolve mocks base method.
+func (m *MockResourceRepository) Resolve(arg0 context.Context, arg1 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resolve", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resolve indicates an expected call of Resolve.
+func (mr *MockResourceRepositoryMockRecorder) Resolve(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resolve", reflect.TypeOf((*MockResourceRepository)(nil).Resolve), arg0, arg1)
+}
+
+// Restore mocks base method.
+func (m *MockResourceRepository) Restore(arg0 context.Context, arg1 int) (*entities.Resource, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", arg0, arg1)
+	ret0, _ := ret[0].(*entities.Resource)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockResourceRepositoryMockRecorder) Restore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockResourceRepository)(nil).Restore), arg0, arg1)
+}
+
+// Revert mocks base method.
+func (m *MockResourceRepository) Revert(arg0 context.Context, arg1, arg2 int) (*entities.Resource, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revert", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*entities.Resource)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revert indicates an expected call of Revert.
+func (mr *MockResourceRepositoryMockRecorder) Revert(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revert", reflect.TypeOf((*MockResourceRepository)(nil).Revert), arg0, arg1, arg2)
+}
+
+// Revision mocks base method.
+func (m *MockResourceRepository) Revision(arg0 context.Context, arg1, arg2 int) (*entities.ResourceRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revision", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*entities.ResourceRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revision indicates an expected call of Revision.
+func (mr *MockResourceRepositoryMockRecorder) Revision(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revision", reflect.TypeOf((*MockResourceRepository)(nil).Revision), arg0, arg1, arg2)
+}
+
+// Revisions mocks base method.
+func (m *MockResourceRepository) Revisions(arg0 context.Context, arg1 int) ([]entities.ResourceRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revisions", arg0, arg1)
+	ret0, _ := ret[0].([]entities.ResourceRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revisions indicates an expected call of Revisions.
+func (mr *MockResourceRepositoryMockRecorder) Revisions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revisions", reflect.TypeOf((*MockResourceRepository)(nil).Revisions), arg0, arg1)
+}
+
+// Search mocks base method.
+func (m *MockResourceRepository) Search(arg0 context.Context, arg1 string, arg2, arg3 int) ([]entities.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]entities.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockResourceRepositoryMockRecorder) Search(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockResourceRepository)(nil).Search), arg0, arg1, arg2, arg3)
+}
+
+// Subtree mocks base method.
+func (m *MockResourceRepository) Subtree(arg0 context.Context, arg1, arg2 int) (*entities.ResourceNode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subtree", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*entities.ResourceNode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Subtree indicates an expected call of Subtree.
+func (mr *MockResourceRepositoryMockRecorder) Subtree(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subtree", reflect.TypeOf((*MockResourceRepository)(nil).Subtree), arg0, arg1, arg2)
+}
+
+// Transition mocks base method.
+func (m *MockResourceRepository) Transition(arg0 context.Context, arg1 int, arg2, arg3, arg4 string) (*entities.Resource, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transition", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(*entities.Resource)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transition indicates an expected call of Transition.
+func (mr *MockResourceRepositoryMockRecorder) Transition(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadAll", reflect.TypeOf((*MockResourceRepository)(nil).ReadAll), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transition", reflect.TypeOf((*MockResourceRepository)(nil).Transition), arg0, arg1, arg2, arg3, arg4)
 }
 
 // Update mocks base method.
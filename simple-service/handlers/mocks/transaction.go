@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/addme96/simple-go-service/simple-service/handlers (interfaces: TxRunner)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	database "github.com/addme96/simple-go-service/simple-service/database"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTxRunner is a mock of TxRunner interface.
+type MockTxRunner struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxRunnerMockRecorder
+}
+
+// MockTxRunnerMockRecorder is the mock recorder for MockTxRunner.
+type MockTxRunnerMockRecorder struct {
+	mock *MockTxRunner
+}
+
+// NewMockTxRunner creates a new mock instance.
+func NewMockTxRunner(ctrl *gomock.Controller) *MockTxRunner {
+	mock := &MockTxRunner{ctrl: ctrl}
+	mock.recorder = &MockTxRunnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxRunner) EXPECT() *MockTxRunnerMockRecorder {
+	return m.recorder
+}
+
+// WithinTxOptions mocks base method.
+func (m *MockTxRunner) WithinTxOptions(arg0 context.Context, arg1 database.TxOptions, arg2 func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithinTxOptions", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithinTxOptions indicates an expected call of WithinTxOptions.
+func (mr *MockTxRunnerMockRecorder) WithinTxOptions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithinTxOptions", reflect.TypeOf((*MockTxRunner)(nil).WithinTxOptions), arg0, arg1, arg2)
+}
@@ -6,130 +6,139 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"strconv"
 
 	"github.com/addme96/simple-go-service/simple-service/entities"
-	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4"
 )
 
 type ResourceRepository interface {
-	Create(ctx context.Context, newResource entities.Resource) (int, error)
-	Read(ctx context.Context, id int) (*entities.Resource, error)
-	ReadAll(ctx context.Context) ([]entities.Resource, error)
-	Update(ctx context.Context, id int, newResource entities.Resource) error
-	Delete(ctx context.Context, id int) error
+	Store[entities.Resource]
+	DeleteTree(ctx context.Context, id int, policy entities.DeletePolicy) error
+	Restore(ctx context.Context, id int) (*entities.Resource, error)
+	Revisions(ctx context.Context, id int) ([]entities.ResourceRevision, error)
+	Revision(ctx context.Context, id, revision int) (*entities.ResourceRevision, error)
+	Revert(ctx context.Context, id, revision int) (*entities.Resource, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]entities.SearchResult, error)
+	Transition(ctx context.Context, id int, from, to, reason string) (*entities.Resource, error)
+	Children(ctx context.Context, id int) ([]entities.Resource, error)
+	Ancestors(ctx context.Context, id int) ([]entities.Resource, error)
+	Subtree(ctx context.Context, id, depth int) (*entities.ResourceNode, error)
+	Move(ctx context.Context, id int, parentID *int) (*entities.Resource, error)
 }
 
+// Resource serves resources, CRUD handles what they have in common with other entities.
 type Resource struct {
+	*CRUD[entities.Resource]
 	Repository ResourceRepository
+	Lifecycle  entities.Lifecycle
 }
 
 func NewResource(repository ResourceRepository) *Resource {
-	return &Resource{Repository: repository}
+	return &Resource{
+		CRUD:       NewCRUD[entities.Resource](repository, &entities.ResourceMeta),
+		Repository: repository,
+		Lifecycle:  entities.DefaultLifecycle,
+	}
 }
 
+// Post creates the resource in the initial status of the Lifecycle, below the parent of the body if it has one.
 func (r *Resource) Post(writer http.ResponseWriter, request *http.Request) {
-	if request.Header.Get("Content-Type") != "application/json" {
-		writer.WriteHeader(http.StatusBadRequest)
+	newResource, ok := r.decode(writer, request)
+	if !ok {
 		return
 	}
-	var newResource entities.Resource
-	bytes, err := io.ReadAll(request.Body)
-	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
-		return
+	var err error
+	newResource.Status = r.Lifecycle.Initial
+	if newResource.ParentPublicID != "" {
+		if newResource.ParentID, err = r.resolveParentID(request, newResource.ParentPublicID); err != nil {
+			writeParentError(writer, err)
+			return
+		}
 	}
-	if err = json.Unmarshal(bytes, &newResource); err != nil {
+	created, err := r.Repository.Create(request.Context(), newResource)
+	if errors.Is(err, entities.ErrParentMissing) {
 		http.Error(writer, err.Error(), http.StatusBadRequest)
 		return
 	}
-	var id int
-	if id, err = r.Repository.Create(request.Context(), newResource); err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	if errors.Is(err, entities.ErrQuotaExceeded) {
+		http.Error(writer, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
 		return
 	}
 	writer.WriteHeader(http.StatusCreated)
-	resp := fmt.Sprintf(`{"id": %d}`, id)
+	resp := fmt.Sprintf(`{"id": %q}`, created.PublicID)
 	writer.Write([]byte(resp))
 }
 
-func (r *Resource) GetCtx(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		resourceID := chi.URLParam(request, "resourceID")
-		ID, err := strconv.Atoi(resourceID)
-		if err != nil {
-			http.Error(writer, err.Error(), http.StatusBadRequest)
-			return
-		}
-		resource, err := r.Repository.Read(request.Context(), ID)
-		if err != nil {
-			http.Error(writer, err.Error(), http.StatusNotFound)
-			return
-		}
-		ctx := context.WithValue(request.Context(), "resource", resource)
-		next.ServeHTTP(writer, request.WithContext(ctx))
-	})
-}
-
-var getFromCtxError = errors.New("failed to read resource from the context")
-
-func (r *Resource) Get(writer http.ResponseWriter, request *http.Request) {
-	resource, ok := request.Context().Value("resource").(*entities.Resource)
-	if !ok {
-		http.Error(writer, getFromCtxError.Error(), http.StatusBadRequest)
-		return
+// resolveParentID returns the internal ID of the parent named by publicID, or entities.ErrParentMissing.
+func (r *Resource) resolveParentID(request *http.Request, publicID string) (*int, error) {
+	if !entities.ValidPublicID(publicID) {
+		return nil, entities.ErrParentMissing
+	}
+	parentID, err := r.Repository.Resolve(request.Context(), publicID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entities.ErrParentMissing
 	}
-	bytes, _ := json.Marshal(resource)
-	writer.Write(bytes)
-}
-
-func (r *Resource) List(writer http.ResponseWriter, request *http.Request) {
-	resources, err := r.Repository.ReadAll(request.Context())
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	bytes, _ := json.Marshal(resources)
-	writer.Write(bytes)
+	return &parentID, nil
 }
 
-func (r *Resource) Put(writer http.ResponseWriter, request *http.Request) {
-	if request.Header.Get("Content-Type") != "application/json" {
-		http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+func writeParentError(writer http.ResponseWriter, err error) {
+	if errors.Is(err, entities.ErrParentMissing) {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
 		return
 	}
-	currentResource, ok := request.Context().Value("resource").(*entities.Resource)
+	serverError(writer, err)
+}
+
+var getFromCtxError = errors.New("failed to read resource from the context")
+
+// Delete soft-deletes the resource, the children parameter decides what happens to its children.
+func (r *Resource) Delete(writer http.ResponseWriter, request *http.Request) {
+	currentResource, ok := r.fromCtx(writer, request)
 	if !ok {
-		http.Error(writer, getFromCtxError.Error(), http.StatusBadRequest)
 		return
 	}
-	var newResource entities.Resource
-	bytes, err := io.ReadAll(request.Body)
+	policy, err := entities.ParseDeletePolicy(request.URL.Query().Get("children"))
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		http.Error(writer, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err = json.Unmarshal(bytes, &newResource); err != nil {
-		http.Error(writer, err.Error(), http.StatusBadRequest)
+	err = r.Repository.DeleteTree(request.Context(), currentResource.ID, policy)
+	if errors.Is(err, entities.ErrHasChildren) {
+		http.Error(writer, err.Error(), http.StatusConflict)
 		return
 	}
-	if err = r.Repository.Update(request.Context(), currentResource.ID, newResource); err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	if err != nil {
+		serverError(writer, err)
 		return
 	}
 }
 
-func (r *Resource) Delete(writer http.ResponseWriter, request *http.Request) {
-	currentResource, ok := request.Context().Value("resource").(*entities.Resource)
+func (r *Resource) Restore(writer http.ResponseWriter, request *http.Request) {
+	ID, ok := r.resolveID(writer, request)
 	if !ok {
-		http.Error(writer, getFromCtxError.Error(), http.StatusBadRequest)
 		return
 	}
-	err := r.Repository.Delete(request.Context(), currentResource.ID)
+	resource, err := r.Repository.Restore(request.Context(), ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, "resource is not deleted", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, entities.ErrQuotaExceeded) || errors.Is(err, entities.ErrParentMissing) {
+		http.Error(writer, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		serverError(writer, err)
 		return
 	}
+	bytes, _ := json.Marshal(resource)
+	writer.Write(bytes)
 }
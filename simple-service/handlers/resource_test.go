@@ -9,7 +9,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"strconv"
+	"net/url"
+	"time"
 
 	"github.com/addme96/simple-go-service/simple-service/entities"
 	"github.com/addme96/simple-go-service/simple-service/handlers"
@@ -40,7 +41,11 @@ var _ = Describe("Resource", func() {
 	Context("NewResource", func() {
 		It("creates resource handler with a given repository", func() {
 			handler := handlers.NewResource(mockRepo)
-			Expect(*handler).To(Equal(handlers.Resource{Repository: mockRepo}))
+			Expect(*handler).To(Equal(handlers.Resource{
+				CRUD:       handlers.NewCRUD[entities.Resource](mockRepo, &entities.ResourceMeta),
+				Repository: mockRepo,
+				Lifecycle:  entities.DefaultLifecycle,
+			}))
 		})
 	})
 
@@ -55,8 +60,9 @@ var _ = Describe("Resource", func() {
 				Expect(err).ShouldNot(HaveOccurred())
 				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
 				req.Header.Set("Content-Type", "application/json")
-				returningID := 1
-				mockRepo.EXPECT().Create(req.Context(), r).Times(1).Return(returningID, nil)
+				created := &entities.Resource{ID: 1, PublicID: publicID(1)}
+				r.Status = entities.StatusDraft
+				mockRepo.EXPECT().Create(req.Context(), r).Times(1).Return(created, nil)
 
 				By("acting")
 				handlers.NewResource(mockRepo).Post(w, req)
@@ -67,7 +73,108 @@ var _ = Describe("Resource", func() {
 				defer res.Body.Close()
 				resp, err := io.ReadAll(res.Body)
 				Expect(err).ShouldNot(HaveOccurred())
-				Expect(string(resp)).To(Equal(fmt.Sprintf(`{"id": %d}`, returningID)))
+				Expect(string(resp)).To(Equal(fmt.Sprintf(`{"id": %q}`, created.PublicID)))
+			})
+
+			It("creates the resource with its attributes and labels", func() {
+				By("arranging")
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(
+					`{"name":"Resource Name","attributes":{"replicas":3},"labels":{"env":"prod"}}`)))
+				req.Header.Set("Content-Type", "application/json")
+				mockRepo.EXPECT().Create(req.Context(), entities.Resource{
+					Name:       "Resource Name",
+					Attributes: map[string]interface{}{"replicas": float64(3)},
+					Labels:     map[string]string{"env": "prod"},
+					Status:     entities.StatusDraft,
+				}).Times(1).Return(&entities.Resource{ID: 1, PublicID: publicID(1)}, nil)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Post(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusCreated))
+			})
+
+			It("creates the resource below its parent", func() {
+				By("arranging")
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(
+					`{"name":"Child","parent_id":"00000000-0000-7000-8000-000000000007"}`)))
+				req.Header.Set("Content-Type", "application/json")
+				parentID := 7
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(7)).Times(1).Return(parentID, nil)
+				mockRepo.EXPECT().Create(req.Context(), entities.Resource{
+					Name:           "Child",
+					Status:         entities.StatusDraft,
+					ParentID:       &parentID,
+					ParentPublicID: publicID(7),
+				}).Times(1).Return(&entities.Resource{ID: 8, PublicID: publicID(8)}, nil)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Post(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusCreated))
+				Expect(w.Body.String()).To(MatchJSON(`{"id":"00000000-0000-7000-8000-000000000008"}`))
+			})
+
+			It("rejects unknown parents", func() {
+				By("arranging")
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(
+					`{"name":"Child","parent_id":"00000000-0000-7000-8000-000000000999"}`)))
+				req.Header.Set("Content-Type", "application/json")
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(999)).Times(1).Return(0, pgx.ErrNoRows)
+				mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Post(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(Equal("parent resource not found\n"))
+			})
+
+			It("rejects parents that disappear before the insert", func() {
+				By("arranging")
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(
+					`{"name":"Child","parent_id":"00000000-0000-7000-8000-000000000007"}`)))
+				req.Header.Set("Content-Type", "application/json")
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(7)).Times(1).Return(7, nil)
+				mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(nil, entities.ErrParentMissing)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Post(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(Equal("parent resource not found\n"))
+			})
+
+			It("refuses resources beyond the quota of the tenant", func() {
+				By("arranging")
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"name":"Resource Name"}`)))
+				req.Header.Set("Content-Type", "application/json")
+				mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(nil, entities.ErrQuotaExceeded)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Post(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusConflict))
+			})
+
+			It("rejects invalid labels", func() {
+				By("arranging")
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(
+					`{"name":"Resource Name","labels":{"not a key":"prod"}}`)))
+				req.Header.Set("Content-Type", "application/json")
+				mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Post(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusBadRequest))
+				Expect(w.Body.String()).To(ContainSubstring(`invalid label key "not a key"`))
 			})
 
 			When("repository errors", func() {
@@ -80,7 +187,8 @@ var _ = Describe("Resource", func() {
 					Expect(err).ShouldNot(HaveOccurred())
 					req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
 					req.Header.Set("Content-Type", "application/json")
-					mockRepo.EXPECT().Create(req.Context(), r).Times(1).Return(0, errors.New("some err"))
+					r.Status = entities.StatusDraft
+					mockRepo.EXPECT().Create(req.Context(), r).Times(1).Return(nil, errors.New("some err"))
 
 					By("acting")
 					handlers.NewResource(mockRepo).Post(w, req)
@@ -136,7 +244,7 @@ var _ = Describe("Resource", func() {
 				defer res.Body.Close()
 				resp, err := io.ReadAll(res.Body)
 				Expect(err).ShouldNot(HaveOccurred())
-				Expect(resp).To(BeEmpty())
+				Expect(string(resp)).To(Equal("invalid Content-Type - should be application/json\n"))
 			})
 		})
 
@@ -181,11 +289,13 @@ var _ = Describe("Resource", func() {
 				By("arranging")
 				resourceID := 123
 				expectedResource := &entities.Resource{
-					ID:   resourceID,
-					Name: "Resource Name",
+					ID:       resourceID,
+					PublicID: publicID(resourceID),
+					Name:     "Resource Name",
 				}
-				routeCtx := prepareRouteCtxWithURLParam("resourceID", strconv.Itoa(resourceID))
+				routeCtx := prepareRouteCtxWithURLParam("resourceID", publicID(resourceID))
 				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(resourceID)).Times(1).Return(resourceID, nil)
 				mockRepo.EXPECT().Read(req.Context(), resourceID).Times(1).Return(expectedResource, nil)
 
 				By("acting")
@@ -199,8 +309,25 @@ var _ = Describe("Resource", func() {
 			It("returns 404 if resource not found", func() {
 				By("arranging")
 				resourceID := 123
-				routeCtx := prepareRouteCtxWithURLParam("resourceID", strconv.Itoa(resourceID))
+				routeCtx := prepareRouteCtxWithURLParam("resourceID", publicID(resourceID))
 				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(resourceID)).Times(1).Return(0, pgx.ErrNoRows)
+				mockRepo.EXPECT().Read(gomock.Any(), gomock.Any()).Times(0)
+
+				By("acting")
+				resourceHandler.GetCtx(nextHandler).ServeHTTP(w, req)
+
+				By("asserting")
+				res := w.Result()
+				Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+				Expect(resource).To(BeNil())
+			})
+			It("returns 404 if resource was deleted after resolving", func() {
+				By("arranging")
+				resourceID := 123
+				routeCtx := prepareRouteCtxWithURLParam("resourceID", publicID(resourceID))
+				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(resourceID)).Times(1).Return(resourceID, nil)
 				mockRepo.EXPECT().Read(req.Context(), resourceID).Times(1).Return(nil, pgx.ErrNoRows)
 
 				By("acting")
@@ -211,9 +338,53 @@ var _ = Describe("Resource", func() {
 				Expect(res.StatusCode).To(Equal(http.StatusNotFound))
 				Expect(resource).To(BeNil())
 			})
+			It("accepts legacy integer IDs when enabled", func() {
+				By("arranging")
+				resourceHandler.LegacyIDs = true
+				expectedResource := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name"}
+				routeCtx := prepareRouteCtxWithURLParam("resourceID", "123")
+				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
+				mockRepo.EXPECT().Resolve(gomock.Any(), gomock.Any()).Times(0)
+				mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(expectedResource, nil)
+
+				By("acting")
+				resourceHandler.GetCtx(nextHandler).ServeHTTP(w, req)
+
+				By("asserting")
+				Expect(w.Result().StatusCode).To(Equal(http.StatusOK))
+				Expect(resource).To(Equal(expectedResource))
+			})
 		})
 		When("invalid request", func() {
-			It("returns 400 when not int", func() {
+			It("returns 400 for legacy integer IDs when disabled", func() {
+				By("arranging")
+				routeCtx := prepareRouteCtxWithURLParam("resourceID", "123")
+				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
+				mockRepo.EXPECT().Resolve(gomock.Any(), gomock.Any()).Times(0)
+				mockRepo.EXPECT().Read(gomock.Any(), gomock.Any()).Times(0)
+
+				By("acting")
+				resourceHandler.GetCtx(nextHandler).ServeHTTP(w, req)
+
+				By("asserting")
+				Expect(w.Result().StatusCode).To(Equal(http.StatusBadRequest))
+				Expect(resource).To(BeNil())
+			})
+			It("returns 500 when resolving fails", func() {
+				By("arranging")
+				routeCtx := prepareRouteCtxWithURLParam("resourceID", publicID(123))
+				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
+				mockRepo.EXPECT().Resolve(req.Context(), publicID(123)).Times(1).Return(0, errors.New("some err"))
+				mockRepo.EXPECT().Read(gomock.Any(), gomock.Any()).Times(0)
+
+				By("acting")
+				resourceHandler.GetCtx(nextHandler).ServeHTTP(w, req)
+
+				By("asserting")
+				Expect(w.Result().StatusCode).To(Equal(http.StatusInternalServerError))
+				Expect(resource).To(BeNil())
+			})
+			It("returns 400 when not a public ID", func() {
 				By("arranging")
 				routeCtx := prepareRouteCtxWithURLParam("resourceID", "definitely-not-int")
 				req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(routeCtx)
@@ -301,7 +472,7 @@ var _ = Describe("Resource", func() {
 				It("returns empty list", func() {
 					By("arranging")
 					req := httptest.NewRequest(http.MethodGet, "/", nil)
-					mockRepo.EXPECT().ReadAll(req.Context()).Times(1).Return([]entities.Resource{}, nil)
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{}).Times(1).Return([]entities.Resource{}, nil)
 
 					By("acting")
 					handlers.NewResource(mockRepo).List(w, req)
@@ -316,6 +487,67 @@ var _ = Describe("Resource", func() {
 				})
 			})
 
+			When("deleted resources are included", func() {
+				It("lists the trash alongside live resources", func() {
+					By("arranging")
+					deletedAt := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+					resources := []entities.Resource{{ID: 123, PublicID: publicID(123), Name: "Deleted", DeletedAt: &deletedAt}}
+					req := httptest.NewRequest(http.MethodGet, "/?include=deleted", nil)
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{IncludeDeleted: true}).Times(1).
+						Return(resources, nil)
+
+					By("acting")
+					handlers.NewResource(mockRepo).List(w, req)
+
+					By("asserting")
+					res := w.Result()
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+					defer res.Body.Close()
+					body, err := io.ReadAll(res.Body)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(body).To(MatchJSON(`[{"id":"00000000-0000-7000-8000-000000000123","name":"Deleted","deleted_at":"2022-04-15T05:20:00Z"}]`))
+				})
+
+				It("filters by label selector", func() {
+					By("arranging")
+					req := httptest.NewRequest(http.MethodGet, "/?selector="+url.QueryEscape("env=prod,tier notin (cache)"), nil)
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{LabelSelector: []entities.LabelRequirement{
+						{Key: "env", Operator: entities.SelectorEquals, Values: []string{"prod"}},
+						{Key: "tier", Operator: entities.SelectorNotIn, Values: []string{"cache"}},
+					}}).Times(1).Return([]entities.Resource{}, nil)
+
+					By("acting")
+					handlers.NewResource(mockRepo).List(w, req)
+
+					By("asserting")
+					Expect(w.Code).To(Equal(http.StatusOK))
+				})
+
+				It("rejects invalid label selectors", func() {
+					By("arranging")
+					req := httptest.NewRequest(http.MethodGet, "/?selector="+url.QueryEscape("env in (prod"), nil)
+					mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(0)
+
+					By("acting")
+					handlers.NewResource(mockRepo).List(w, req)
+
+					By("asserting")
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+				})
+
+				It("rejects unknown includes", func() {
+					By("arranging")
+					req := httptest.NewRequest(http.MethodGet, "/?include=everything", nil)
+					mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(0)
+
+					By("acting")
+					handlers.NewResource(mockRepo).List(w, req)
+
+					By("asserting")
+					Expect(w.Result().StatusCode).To(Equal(http.StatusBadRequest))
+				})
+			})
+
 			When("single resource exists", func() {
 				It("returns single resource", func() {
 					By("arranging")
@@ -328,7 +560,7 @@ var _ = Describe("Resource", func() {
 					expectedBody, err := json.Marshal(resources)
 					Expect(err).NotTo(HaveOccurred())
 					req := httptest.NewRequest(http.MethodGet, "/", nil)
-					mockRepo.EXPECT().ReadAll(req.Context()).Times(1).Return(resources, nil)
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{}).Times(1).Return(resources, nil)
 
 					By("acting")
 					handlers.NewResource(mockRepo).List(w, req)
@@ -359,7 +591,7 @@ var _ = Describe("Resource", func() {
 					expectedBody, err := json.Marshal(resources)
 					Expect(err).NotTo(HaveOccurred())
 					req := httptest.NewRequest(http.MethodGet, "/", nil)
-					mockRepo.EXPECT().ReadAll(req.Context()).Times(1).Return(resources, nil)
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{}).Times(1).Return(resources, nil)
 
 					By("acting")
 					handlers.NewResource(mockRepo).List(w, req)
@@ -373,11 +605,43 @@ var _ = Describe("Resource", func() {
 					Expect(body).To(MatchJSON(expectedBody))
 				})
 			})
+			When("there are more resources than fit a page", func() {
+				It("returns a cursor to the next page", func() {
+					By("arranging")
+					resources := []entities.Resource{{ID: 3, Name: "c"}, {ID: 4, Name: "d"}, {ID: 5, Name: "e"}}
+					req := httptest.NewRequest(http.MethodGet, "/?limit=2&cursor=Mg", nil)
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{Limit: 3, Offset: 2}).Times(1).Return(resources, nil)
+
+					By("acting")
+					handlers.NewResource(mockRepo).List(w, req)
+
+					By("asserting")
+					res := w.Result()
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+					defer res.Body.Close()
+					body, err := io.ReadAll(res.Body)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(body).To(MatchJSON(`{"items":[{"id":"","name":"c"},{"id":"","name":"d"}],"next_cursor":"NA"}`))
+				})
+
+				It("rejects invalid cursors", func() {
+					By("arranging")
+					req := httptest.NewRequest(http.MethodGet, "/?cursor=-", nil)
+					mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(0)
+
+					By("acting")
+					handlers.NewResource(mockRepo).List(w, req)
+
+					By("asserting")
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+				})
+			})
+
 			When("repository errors", func() {
 				It("returns 500", func() {
 					By("arranging")
 					req := httptest.NewRequest(http.MethodGet, "/", nil)
-					mockRepo.EXPECT().ReadAll(req.Context()).Times(1).Return(nil, fmt.Errorf("error"))
+					mockRepo.EXPECT().ReadAll(req.Context(), entities.ListOptions{}).Times(1).Return(nil, fmt.Errorf("error"))
 
 					By("acting")
 					handlers.NewResource(mockRepo).List(w, req)
@@ -569,7 +833,7 @@ var _ = Describe("Resource", func() {
 				ctxWithResource := context.WithValue(context.TODO(), "resource", &resource)
 				req := httptest.NewRequest(http.MethodDelete, "/", nil).WithContext(ctxWithResource)
 				req.Header.Set("Content-Type", "application/json")
-				mockRepo.EXPECT().Delete(req.Context(), resource.ID).Times(1).Return(nil)
+				mockRepo.EXPECT().DeleteTree(req.Context(), resource.ID, entities.DeleteRestrict).Times(1).Return(nil)
 
 				By("acting")
 				handlers.NewResource(mockRepo).Delete(w, req)
@@ -583,6 +847,38 @@ var _ = Describe("Resource", func() {
 				Expect(resp).To(BeEmpty())
 			})
 
+			It("passes the children policy on", func() {
+				By("arranging")
+				resource := entities.Resource{ID: 123, Name: "Resource Name"}
+				ctxWithResource := context.WithValue(context.TODO(), "resource", &resource)
+				req := httptest.NewRequest(http.MethodDelete, "/?children=cascade", nil).WithContext(ctxWithResource)
+				mockRepo.EXPECT().DeleteTree(req.Context(), resource.ID, entities.DeleteCascade).Times(1).Return(nil)
+
+				By("acting")
+				handlers.NewResource(mockRepo).Delete(w, req)
+
+				By("asserting")
+				Expect(w.Code).To(Equal(http.StatusOK))
+			})
+
+			When("the resource has children", func() {
+				It("returns 409", func() {
+					By("arranging")
+					resource := entities.Resource{ID: 123, Name: "Resource Name"}
+					ctxWithResource := context.WithValue(context.TODO(), "resource", &resource)
+					req := httptest.NewRequest(http.MethodDelete, "/", nil).WithContext(ctxWithResource)
+					mockRepo.EXPECT().DeleteTree(req.Context(), resource.ID, entities.DeleteRestrict).Times(1).
+						Return(entities.ErrHasChildren)
+
+					By("acting")
+					handlers.NewResource(mockRepo).Delete(w, req)
+
+					By("asserting")
+					Expect(w.Code).To(Equal(http.StatusConflict))
+					Expect(w.Body.String()).To(Equal("resource has children\n"))
+				})
+			})
+
 			When("repository errors", func() {
 				It("returns 500", func() {
 					By("arranging")
@@ -591,7 +887,7 @@ var _ = Describe("Resource", func() {
 					}
 					ctxWithResource := context.WithValue(context.TODO(), "resource", &resource)
 					req := httptest.NewRequest(http.MethodDelete, "/", nil).WithContext(ctxWithResource)
-					mockRepo.EXPECT().Delete(req.Context(), resource.ID).Times(1).Return(errors.New("some err"))
+					mockRepo.EXPECT().DeleteTree(req.Context(), resource.ID, entities.DeleteRestrict).Times(1).Return(errors.New("some err"))
 
 					By("acting")
 					handlers.NewResource(mockRepo).Delete(w, req)
@@ -608,6 +904,23 @@ var _ = Describe("Resource", func() {
 		})
 
 		When("invalid request", func() {
+			When("unknown children policy", func() {
+				It("returns 400", func() {
+					By("arranging")
+					resource := entities.Resource{ID: 123, Name: "Resource Name"}
+					ctxWithResource := context.WithValue(context.TODO(), "resource", &resource)
+					req := httptest.NewRequest(http.MethodDelete, "/?children=adopt", nil).WithContext(ctxWithResource)
+					mockRepo.EXPECT().DeleteTree(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+					By("acting")
+					handlers.NewResource(mockRepo).Delete(w, req)
+
+					By("asserting")
+					Expect(w.Code).To(Equal(http.StatusBadRequest))
+					Expect(w.Body.String()).To(Equal("invalid delete policy \"adopt\"\n"))
+				})
+			})
+
 			When("invalid type in the context", func() {
 				It("returns 400", func() {
 					By("arranging")
@@ -630,6 +943,77 @@ var _ = Describe("Resource", func() {
 			})
 		})
 	})
+
+	Context("Restore", func() {
+		It("restores the deleted resource", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(prepareRouteCtxWithURLParam("resourceID", publicID(123)))
+			mockRepo.EXPECT().Resolve(req.Context(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Restore(req.Context(), 123).Times(1).Return(&entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name"}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Restore(w, req)
+
+			By("asserting")
+			res := w.Result()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(MatchJSON(`{"id":"00000000-0000-7000-8000-000000000123","name":"Resource Name"}`))
+		})
+
+		It("returns 404 when the resource is not in the trash", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(prepareRouteCtxWithURLParam("resourceID", publicID(123)))
+			mockRepo.EXPECT().Resolve(req.Context(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Restore(req.Context(), 123).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Restore(w, req)
+
+			By("asserting")
+			Expect(w.Result().StatusCode).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns 409 while the parent is in the trash", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(prepareRouteCtxWithURLParam("resourceID", publicID(123)))
+			mockRepo.EXPECT().Resolve(req.Context(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Restore(req.Context(), 123).Times(1).Return(nil, entities.ErrParentMissing)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Restore(w, req)
+
+			By("asserting")
+			Expect(w.Result().StatusCode).To(Equal(http.StatusConflict))
+		})
+
+		It("returns 500 when the repository fails", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(prepareRouteCtxWithURLParam("resourceID", publicID(123)))
+			mockRepo.EXPECT().Resolve(req.Context(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Restore(req.Context(), 123).Times(1).Return(nil, errors.New("some err"))
+
+			By("acting")
+			handlers.NewResource(mockRepo).Restore(w, req)
+
+			By("asserting")
+			Expect(w.Result().StatusCode).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("returns 400 for invalid IDs", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(prepareRouteCtxWithURLParam("resourceID", "abc"))
+			mockRepo.EXPECT().Restore(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Restore(w, req)
+
+			By("asserting")
+			Expect(w.Result().StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
 })
 
 func prepareRouteCtxWithURLParam(key, val string) context.Context {
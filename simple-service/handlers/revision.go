@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4"
+)
+
+func (r *Resource) Revisions(writer http.ResponseWriter, request *http.Request) {
+	ID, ok := r.resolveID(writer, request)
+	if !ok {
+		return
+	}
+	revisions, err := r.Repository.Revisions(request.Context(), ID)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	if len(revisions) == 0 {
+		http.Error(writer, "resource has no revisions", http.StatusNotFound)
+		return
+	}
+	bytes, _ := json.Marshal(revisions)
+	writer.Write(bytes)
+}
+
+func (r *Resource) Revision(writer http.ResponseWriter, request *http.Request) {
+	ID, ok := r.resolveID(writer, request)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(chi.URLParam(request, "revision"))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resourceRevision, err := r.Repository.Revision(request.Context(), ID, revision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, "revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(resourceRevision)
+	writer.Write(bytes)
+}
+
+type revisionDiff struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes []entities.FieldChange `json:"changes"`
+}
+
+// DiffRevisions compares the states of the resource after the revisions given by the from and to query parameters.
+func (r *Resource) DiffRevisions(writer http.ResponseWriter, request *http.Request) {
+	ID, ok := r.resolveID(writer, request)
+	if !ok {
+		return
+	}
+	diff := revisionDiff{}
+	var err error
+	if diff.From, err = strconv.Atoi(request.URL.Query().Get("from")); err != nil {
+		http.Error(writer, "invalid from revision", http.StatusBadRequest)
+		return
+	}
+	if diff.To, err = strconv.Atoi(request.URL.Query().Get("to")); err != nil {
+		http.Error(writer, "invalid to revision", http.StatusBadRequest)
+		return
+	}
+	states := make([]*entities.Resource, 0, 2)
+	for _, revision := range []int{diff.From, diff.To} {
+		resourceRevision, err := r.Repository.Revision(request.Context(), ID, revision)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(writer, "revision not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serverError(writer, err)
+			return
+		}
+		states = append(states, resourceRevision.State)
+	}
+	if diff.Changes, err = entities.DiffResources(states[0], states[1]); err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(diff)
+	writer.Write(bytes)
+}
+
+type revertRequest struct {
+	Revision int `json:"revision"`
+}
+
+func (r *Resource) Revert(writer http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+		return
+	}
+	ID, ok := r.resolveID(writer, request)
+	if !ok {
+		return
+	}
+	bytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	var revert revertRequest
+	if err = json.Unmarshal(bytes, &revert); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resource, err := r.Repository.Revert(request.Context(), ID, revert.Revision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, "revision not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, entities.ErrQuotaExceeded) || errors.Is(err, entities.ErrHasChildren) ||
+		errors.Is(err, entities.ErrParentMissing) {
+		http.Error(writer, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ = json.Marshal(resource)
+	writer.Write(bytes)
+}
@@ -0,0 +1,233 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource revisions", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		w        *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		w = httptest.NewRecorder()
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).AnyTimes().Return(123, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	createdAt := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+	first := entities.ResourceRevision{ResourceID: 123, Revision: 1, Operation: entities.RevisionCreate,
+		State: &entities.Resource{ID: 123, PublicID: publicID(123), Name: "First"}, Actor: "anonymous", CreatedAt: createdAt}
+	second := entities.ResourceRevision{ResourceID: 123, Revision: 2, Operation: entities.RevisionUpdate,
+		Previous: first.State, State: &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Second"}, Actor: "operator", CreatedAt: createdAt}
+
+	revisionRequest := func(method, target string, body io.Reader, revision string) *http.Request {
+		routeParams := chi.RouteParams{}
+		routeParams.Add("resourceID", publicID(123))
+		if revision != "" {
+			routeParams.Add("revision", revision)
+		}
+		ctx := context.WithValue(context.TODO(), chi.RouteCtxKey, &chi.Context{URLParams: routeParams})
+		return httptest.NewRequest(method, target, body).WithContext(ctx)
+	}
+
+	Context("Revisions", func() {
+		It("lists the revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/", nil, "")
+			mockRepo.EXPECT().Revisions(req.Context(), 123).Times(1).
+				Return([]entities.ResourceRevision{first}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revisions(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`[{"revision":1,"operation":"create","previous":null,
+				"state":{"id":"00000000-0000-7000-8000-000000000123","name":"First"},"actor":"anonymous","created_at":"2022-04-15T05:20:00Z"}]`))
+		})
+
+		It("returns 404 for resources without revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/", nil, "")
+			mockRepo.EXPECT().Revisions(req.Context(), 123).Times(1).Return([]entities.ResourceRevision{}, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revisions(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Revision", func() {
+		It("returns the revision", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/", nil, "2")
+			mockRepo.EXPECT().Revision(req.Context(), 123, 2).Times(1).Return(&second, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revision(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"revision":2,"operation":"update",
+				"previous":{"id":"00000000-0000-7000-8000-000000000123","name":"First"},"state":{"id":"00000000-0000-7000-8000-000000000123","name":"Second"},"actor":"operator",
+				"created_at":"2022-04-15T05:20:00Z"}`))
+		})
+
+		It("returns 404 for unknown revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/", nil, "9")
+			mockRepo.EXPECT().Revision(req.Context(), 123, 9).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revision(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns 400 for invalid revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/", nil, "latest")
+			mockRepo.EXPECT().Revision(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revision(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("DiffRevisions", func() {
+		It("lists the fields that changed between the revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/?from=1&to=2", nil, "")
+			mockRepo.EXPECT().Revision(req.Context(), 123, 1).Times(1).Return(&first, nil)
+			mockRepo.EXPECT().Revision(req.Context(), 123, 2).Times(1).Return(&second, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).DiffRevisions(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"from":1,"to":2,"changes":[{"field":"name","from":"First","to":"Second"}]}`))
+		})
+
+		It("returns 400 without both revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/?from=1", nil, "")
+			mockRepo.EXPECT().Revision(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewResource(mockRepo).DiffRevisions(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 404 for unknown revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodGet, "/?from=1&to=9", nil, "")
+			mockRepo.EXPECT().Revision(req.Context(), 123, 1).Times(1).Return(&first, nil)
+			mockRepo.EXPECT().Revision(req.Context(), 123, 9).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewResource(mockRepo).DiffRevisions(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("Revert", func() {
+		It("reverts the resource to the revision", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"revision":1}`)), "")
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Revert(req.Context(), 123, 1).Times(1).Return(first.State, nil)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revert(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(MatchJSON(`{"id":"00000000-0000-7000-8000-000000000123","name":"First"}`))
+		})
+
+		It("returns 404 for unknown revisions", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"revision":9}`)), "")
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Revert(req.Context(), 123, 9).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revert(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns 409 when the revision would break the hierarchy", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"revision":2}`)), "")
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Revert(req.Context(), 123, 2).Times(1).Return(nil, entities.ErrHasChildren)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revert(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusConflict))
+		})
+
+		It("returns 500 when the repository fails", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"revision":1}`)), "")
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Revert(req.Context(), 123, 1).Times(1).Return(nil, errors.New("some err"))
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revert(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		It("returns 400 for invalid bodies", func() {
+			By("arranging")
+			req := revisionRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"revision":"one"}`)), "")
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Revert(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewResource(mockRepo).Revert(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// page is the envelope of paginated responses, NextCursor is passed back as the cursor
+// query parameter to fetch the following page and is left out on the last one.
+type page struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// Search returns live resources matching the q query parameter, best matches first.
+func (r *Resource) Search(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		http.Error(writer, "missing q", http.StatusBadRequest)
+		return
+	}
+	limit, offset, ok := readPage(writer, request)
+	if !ok {
+		return
+	}
+	// one more than asked for tells whether there is a next page
+	results, err := r.Repository.Search(request.Context(), q, limit+1, offset)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(newPage(results, limit, offset))
+	writer.Write(bytes)
+}
+
+// newPage is the page of the items read at offset, which were asked for one more than limit of.
+func newPage[T any](items []T, limit, offset int) page {
+	if len(items) > limit {
+		return page{Items: items[:limit], NextCursor: encodeCursor(offset + limit)}
+	}
+	return page{Items: items}
+}
+
+func readPage(writer http.ResponseWriter, request *http.Request) (limit, offset int, ok bool) {
+	query := request.URL.Query()
+	limit = defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			http.Error(writer, "invalid limit - should be between 1 and "+strconv.Itoa(maxPageSize), http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		if offset, err = decodeCursor(cursor); err != nil {
+			http.Error(writer, "invalid cursor", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(bytes))
+	if err == nil && offset < 0 {
+		err = strconv.ErrRange
+	}
+	return offset, err
+}
@@ -0,0 +1,106 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource search", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		w        *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		w = httptest.NewRecorder()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	results := []entities.SearchResult{
+		{Resource: entities.Resource{ID: 1, PublicID: publicID(1), Name: "Red Apple"}, Rank: 1.5, Snippet: "Red <mark>Apple</mark>"},
+		{Resource: entities.Resource{ID: 2, PublicID: publicID(2), Name: "Apple Pie"}, Rank: 1, Snippet: "<mark>Apple</mark> Pie"},
+	}
+
+	It("returns the last page without a cursor", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/search?q=apple", nil)
+		mockRepo.EXPECT().Search(req.Context(), "apple", 21, 0).Times(1).Return(results, nil)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Search(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"items":[
+			{"id":"00000000-0000-7000-8000-000000000001","name":"Red Apple","rank":1.5,"snippet":"Red <mark>Apple</mark>"},
+			{"id":"00000000-0000-7000-8000-000000000002","name":"Apple Pie","rank":1,"snippet":"<mark>Apple</mark> Pie"}]}`))
+	})
+
+	It("returns a cursor to the next page", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/search?q=apple&limit=1", nil)
+		mockRepo.EXPECT().Search(req.Context(), "apple", 2, 0).Times(1).Return(results, nil)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Search(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var response struct {
+			Items      []entities.SearchResult `json:"items"`
+			NextCursor string                  `json:"next_cursor"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Items).To(HaveLen(1))
+		Expect(response.Items[0].PublicID).To(Equal(results[0].PublicID))
+		Expect(response.NextCursor).NotTo(BeEmpty())
+
+		By("following the cursor")
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/search?q=apple&limit=1&cursor="+response.NextCursor, nil)
+		mockRepo.EXPECT().Search(req.Context(), "apple", 2, 1).Times(1).Return(results[1:], nil)
+		handlers.NewResource(mockRepo).Search(w, req)
+		Expect(w.Body.String()).NotTo(ContainSubstring("next_cursor"))
+	})
+
+	DescribeTable("rejects invalid queries",
+		func(target string) {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			mockRepo.EXPECT().Search(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			handlers.NewResource(mockRepo).Search(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		},
+		Entry("without q", "/search"),
+		Entry("with a non-numeric limit", "/search?q=apple&limit=all"),
+		Entry("with a limit above the maximum", "/search?q=apple&limit=1000"),
+		Entry("with a malformed cursor", "/search?q=apple&cursor=!!"),
+	)
+
+	It("returns 500 when the repository fails", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/search?q=apple", nil)
+		mockRepo.EXPECT().Search(req.Context(), "apple", 21, 0).Times(1).Return(nil, errors.New("some err"))
+
+		By("acting")
+		handlers.NewResource(mockRepo).Search(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+	})
+})
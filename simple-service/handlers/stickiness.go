@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+)
+
+// PrimaryUntil is the cookie and header that pin the reads of a client to the primary, as a unix timestamp.
+// Clients without cookies send the header back as they received it.
+const PrimaryUntil = "X-Primary-Until"
+
+const primaryUntilCookie = "primary_until"
+
+// ReadYourWrites pins the reads of a client to the primary for window after each of its mutating requests,
+// so that it sees its writes even on a lagging replica. window should be no less than database.DB.MaxReplicaLag,
+// replicas lagging further are ejected.
+func ReadYourWrites(window time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			now := time.Now()
+			if request.Method != http.MethodGet && request.Method != http.MethodHead && request.Method != http.MethodOptions {
+				until := now.Add(window)
+				value := strconv.FormatInt(until.Unix(), 10)
+				writer.Header().Set(PrimaryUntil, value)
+				http.SetCookie(writer, &http.Cookie{Name: primaryUntilCookie, Value: value, Path: "/", Expires: until,
+					HttpOnly: true, SameSite: http.SameSiteLaxMode})
+				request = request.WithContext(database.WithPrimary(request.Context()))
+			} else if pinnedToPrimary(request, now) {
+				request = request.WithContext(database.WithPrimary(request.Context()))
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+func pinnedToPrimary(request *http.Request, now time.Time) bool {
+	value := request.Header.Get(PrimaryUntil)
+	if cookie, err := request.Cookie(primaryUntilCookie); err == nil {
+		value = cookie.Value
+	}
+	until, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && now.Unix() < until
+}
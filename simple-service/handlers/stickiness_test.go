@@ -0,0 +1,79 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadYourWrites", func() {
+	var (
+		readsPrimary bool
+		handler      http.Handler
+		w            *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		readsPrimary = false
+		handler = handlers.ReadYourWrites(5 * time.Second)(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			readsPrimary = database.ReadsPrimary(request.Context())
+		}))
+		w = httptest.NewRecorder()
+	})
+
+	It("pins the client to the primary after a mutation", func() {
+		By("acting")
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/resources/1/", nil))
+
+		By("asserting")
+		Expect(readsPrimary).To(BeTrue())
+		until, err := strconv.ParseInt(w.Header().Get(handlers.PrimaryUntil), 10, 64)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Unix(until, 0)).To(BeTemporally("~", time.Now().Add(5*time.Second), time.Second))
+		cookies := w.Result().Cookies()
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].Value).To(Equal(w.Header().Get(handlers.PrimaryUntil)))
+	})
+
+	It("reads from the primary while the cookie is fresh", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/resources/", nil)
+		req.AddCookie(&http.Cookie{Name: "primary_until", Value: strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)})
+
+		By("acting")
+		handler.ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(readsPrimary).To(BeTrue())
+		Expect(w.Header().Get(handlers.PrimaryUntil)).To(BeEmpty())
+	})
+
+	It("reads from the primary while the header is fresh", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/resources/", nil)
+		req.Header.Set(handlers.PrimaryUntil, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+
+		By("acting")
+		handler.ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(readsPrimary).To(BeTrue())
+	})
+
+	It("lets reads go to replicas otherwise", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/resources/", nil)
+		req.Header.Set(handlers.PrimaryUntil, strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+
+		By("acting")
+		handler.ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(readsPrimary).To(BeFalse())
+	})
+})
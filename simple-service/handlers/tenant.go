@@ -0,0 +1,165 @@
+//go:generate mockgen -destination=mocks/tenant.go -package mocks . TenantRepository
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+)
+
+type TenantRepository interface {
+	Read(ctx context.Context, id string) (*entities.Tenant, error)
+}
+
+// TenantResolver returns the tenant a request names, or "" when it names none the way the resolver looks for.
+type TenantResolver func(request *http.Request) (string, error)
+
+// TenantFromHeader reads the tenant from the header name.
+func TenantFromHeader(name string) TenantResolver {
+	return func(request *http.Request) (string, error) {
+		return request.Header.Get(name), nil
+	}
+}
+
+// TenantFromSubdomain reads the tenant from the subdomain of domain the request is made to,
+// acme.example.com is made to the tenant acme of example.com.
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(domain)
+	return func(request *http.Request) (string, error) {
+		host := request.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return "", nil
+		}
+		return strings.TrimSuffix(host, suffix), nil
+	}
+}
+
+// TenantFromClaim reads the tenant from the claim of the JWT in the bearer token, which has to be signed
+// with key. Requests carrying an API key rather than a JWT name no tenant this way.
+func TenantFromClaim(claim string, key []byte) TenantResolver {
+	return func(request *http.Request) (string, error) {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if !auth.IsJWT(token) {
+			return "", nil
+		}
+		claims, err := auth.VerifyJWT(token, key, time.Now())
+		if err != nil {
+			return "", err
+		}
+		tenant, _ := claims[claim].(string)
+		return tenant, nil
+	}
+}
+
+// ParseTenantResolvers builds the resolvers of a comma separated configuration such as
+// "header:X-Tenant-ID,subdomain:example.com,claim:tenant", claims are verified with jwtKey.
+func ParseTenantResolvers(spec string, jwtKey []byte) ([]TenantResolver, error) {
+	var resolvers []TenantResolver
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kind, value, found := strings.Cut(part, ":")
+		if !found || value == "" {
+			return nil, fmt.Errorf("tenant resolver %q is not of the form kind:value", part)
+		}
+		switch kind {
+		case "header":
+			resolvers = append(resolvers, TenantFromHeader(value))
+		case "subdomain":
+			resolvers = append(resolvers, TenantFromSubdomain(value))
+		case "claim":
+			if len(jwtKey) == 0 {
+				return nil, fmt.Errorf("tenant resolver %q needs a key to verify tokens with", part)
+			}
+			resolvers = append(resolvers, TenantFromClaim(value, jwtKey))
+		default:
+			return nil, fmt.Errorf("tenant resolver %q is unknown", kind)
+		}
+	}
+	return resolvers, nil
+}
+
+var (
+	ErrNoTenant       = errors.New("the request names no tenant")
+	ErrTenantsDiffer  = errors.New("the request names more than one tenant")
+	ErrUnknownTenant  = errors.New("the tenant is unknown")
+	ErrTenantNotReady = errors.New("the tenant is being created")
+)
+
+// TenantOf returns the registered tenant the resolvers find in request, resolvers naming different ones are
+// an error. Requests other than HTTP ones, such as gRPC calls, are resolved through a request standing in for them.
+func TenantOf(request *http.Request, tenants TenantRepository, resolvers ...TenantResolver) (string, error) {
+	var tenant string
+	for _, resolve := range resolvers {
+		named, err := resolve(request)
+		if err != nil {
+			return "", err
+		}
+		if named == "" {
+			continue
+		}
+		if tenant != "" && named != tenant {
+			return "", ErrTenantsDiffer
+		}
+		tenant = named
+	}
+	if tenant == "" {
+		return "", ErrNoTenant
+	}
+	if err := tenancy.Validate(tenant); err != nil {
+		return "", err
+	}
+	registered, err := tenants.Read(request.Context(), tenant)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrUnknownTenant
+	}
+	if err != nil {
+		return "", err
+	}
+	if !registered.Ready {
+		return "", ErrTenantNotReady
+	}
+	return tenant, nil
+}
+
+// ResolveTenant puts the tenant named by the request in its context, so that it only reaches the schema of
+// that tenant. Every request has to name a registered tenant that is ready, and resolvers naming different ones
+// are rejected.
+func ResolveTenant(tenants TenantRepository, resolvers ...TenantResolver) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			tenant, err := TenantOf(request, tenants, resolvers...)
+			switch {
+			case errors.Is(err, auth.ErrInvalidToken):
+				http.Error(writer, err.Error(), http.StatusUnauthorized)
+				return
+			case errors.Is(err, ErrTenantNotReady):
+				http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+				return
+			case errors.Is(err, ErrNoTenant), errors.Is(err, ErrTenantsDiffer), errors.Is(err, ErrUnknownTenant),
+				errors.Is(err, tenancy.ErrInvalidTenant):
+				http.Error(writer, err.Error(), http.StatusBadRequest)
+				return
+			case err != nil:
+				serverError(writer, err)
+				return
+			}
+			next.ServeHTTP(writer, request.WithContext(tenancy.WithTenant(request.Context(), tenant)))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package handlers_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResolveTenant", func() {
+	var (
+		mockCtrl *gomock.Controller
+		tenants  *mocks.MockTenantRepository
+		tenant   string
+		called   bool
+		handler  http.Handler
+		w        *httptest.ResponseRecorder
+		req      *http.Request
+	)
+	key := []byte("secret")
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		tenants = mocks.NewMockTenantRepository(mockCtrl)
+		tenant, called = "", false
+		resolvers, err := handlers.ParseTenantResolvers("header:X-Tenant-ID,subdomain:example.com,claim:tenant", key)
+		Expect(err).NotTo(HaveOccurred())
+		handler = handlers.ResolveTenant(tenants, resolvers...)(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			called = true
+			tenant, _ = tenancy.TenantFromContext(request.Context())
+		}))
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "http://api.example.org/resources/", nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	DescribeTable("puts the tenant named by the request in its context",
+		func(arrange func(req *http.Request)) {
+			By("arranging")
+			arrange(req)
+			tenants.EXPECT().Read(gomock.Any(), "acme").Return(&entities.Tenant{ID: "acme", Ready: true}, nil)
+
+			By("acting")
+			handler.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(called).To(BeTrue())
+			Expect(tenant).To(Equal("acme"))
+		},
+		Entry("in a header", func(req *http.Request) { req.Header.Set("X-Tenant-ID", "acme") }),
+		Entry("as a subdomain", func(req *http.Request) { req.Host = "acme.example.com:8080" }),
+		Entry("in a claim", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+signJWT(key, `{"tenant":"acme","exp":4102444800}`))
+		}),
+		Entry("the same way twice", func(req *http.Request) {
+			req.Host = "acme.example.com"
+			req.Header.Set("X-Tenant-ID", "acme")
+		}),
+	)
+
+	DescribeTable("rejects requests naming no tenant they may use",
+		func(arrange func(req *http.Request), status int) {
+			By("arranging")
+			arrange(req)
+
+			By("acting")
+			handler.ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(called).To(BeFalse())
+			Expect(w.Code).To(Equal(status))
+		},
+		Entry("none", func(req *http.Request) {}, http.StatusBadRequest),
+		Entry("different ones", func(req *http.Request) {
+			req.Host = "acme.example.com"
+			req.Header.Set("X-Tenant-ID", "other")
+		}, http.StatusBadRequest),
+		Entry("one that cannot be", func(req *http.Request) { req.Header.Set("X-Tenant-ID", "Acme") }, http.StatusBadRequest),
+		Entry("one signed with another key", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+signJWT([]byte("other"), `{"tenant":"acme"}`))
+		}, http.StatusUnauthorized),
+		Entry("one in an expired token", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+signJWT(key, `{"tenant":"acme","exp":1}`))
+		}, http.StatusUnauthorized),
+	)
+
+	It("rejects unknown tenants", func() {
+		By("arranging")
+		req.Header.Set("X-Tenant-ID", "acme")
+		tenants.EXPECT().Read(gomock.Any(), "acme").Return(nil, pgx.ErrNoRows)
+
+		By("acting")
+		handler.ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("holds back tenants until their schema is migrated", func() {
+		By("arranging")
+		req.Header.Set("X-Tenant-ID", "acme")
+		tenants.EXPECT().Read(gomock.Any(), "acme").Return(&entities.Tenant{ID: "acme"}, nil)
+
+		By("acting")
+		handler.ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("fails when the tenants cannot be read", func() {
+		By("arranging")
+		req.Header.Set("X-Tenant-ID", "acme")
+		tenants.EXPECT().Read(gomock.Any(), "acme").Return(nil, errors.New("some error"))
+
+		By("acting")
+		handler.ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(called).To(BeFalse())
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("refuses configurations it cannot resolve tenants with", func() {
+		_, unknown := handlers.ParseTenantResolvers("cookie:tenant", key)
+		_, keyless := handlers.ParseTenantResolvers("claim:tenant", nil)
+
+		Expect(unknown).To(HaveOccurred())
+		Expect(keyless).To(HaveOccurred())
+	})
+})
+
+func signJWT(key []byte, claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(claims))
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + encode(mac.Sum(nil))
+}
@@ -0,0 +1,88 @@
+//go:generate mockgen -destination=mocks/transaction.go -package mocks . TxRunner
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+)
+
+type TxRunner interface {
+	WithinTxOptions(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error
+}
+
+var errRollback = errors.New("handler responded with an error")
+
+// Transactional runs mutating requests as a single unit of work: every repository call made while
+// serving the request joins one transaction, reads lock their rows, and the request is replayed
+// when the transaction hits a serialization failure. Responses are buffered until the outcome is known.
+func Transactional(runner TxRunner) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method == http.MethodGet || request.Method == http.MethodHead || request.Method == http.MethodOptions {
+				next.ServeHTTP(writer, request)
+				return
+			}
+			body, err := io.ReadAll(request.Body)
+			if err != nil {
+				serverError(writer, err)
+				return
+			}
+			var response *bufferedResponse
+			err = runner.WithinTxOptions(request.Context(), database.TxOptions{ForUpdate: true}, func(ctx context.Context) error {
+				response = newBufferedResponse()
+				attempt := request.WithContext(ctx)
+				attempt.Body = io.NopCloser(bytes.NewReader(body))
+				next.ServeHTTP(response, attempt)
+				if response.status >= http.StatusBadRequest {
+					return errRollback
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, errRollback) {
+				serverError(writer, err)
+				return
+			}
+			response.writeTo(writer)
+		})
+	}
+}
+
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *bufferedResponse) Write(bytes []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(bytes)
+}
+
+func (r *bufferedResponse) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *bufferedResponse) writeTo(writer http.ResponseWriter) {
+	for key, values := range r.header {
+		writer.Header()[key] = values
+	}
+	writer.WriteHeader(r.status)
+	writer.Write(r.body.Bytes())
+}
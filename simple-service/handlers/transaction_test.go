@@ -0,0 +1,150 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transactional", func() {
+	var (
+		mockCtrl   *gomock.Controller
+		mockRunner *mocks.MockTxRunner
+		w          *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRunner = mocks.NewMockTxRunner(mockCtrl)
+		w = httptest.NewRecorder()
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	runOnce := func(ctx context.Context, _ database.TxOptions, fn func(ctx context.Context) error) error {
+		return fn(ctx)
+	}
+
+	It("passes reads through without a transaction", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		handlers.Transactional(mockRunner)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("read"))
+		})).ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(w.Body.String()).To(Equal("read"))
+	})
+
+	It("runs writes in a locking transaction and writes the response once it committed", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte("body")))
+		mockRunner.EXPECT().WithinTxOptions(req.Context(), database.TxOptions{ForUpdate: true}, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+				Expect(fn(ctx)).To(Succeed())
+				Expect(w.Body.String()).To(BeEmpty())
+				return nil
+			})
+
+		By("acting")
+		handlers.Transactional(mockRunner)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/resources/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		})).ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusCreated))
+		Expect(w.Header().Get("Location")).To(Equal("/resources/1"))
+		Expect(w.Body.String()).To(Equal("created"))
+	})
+
+	It("replays the request body and discards the responses of retried attempts", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader([]byte("body")))
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+				fn(ctx)
+				return fn(ctx)
+			})
+		var bodies []string
+
+		By("acting")
+		handlers.Transactional(mockRunner)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			w.Write([]byte("attempt"))
+		})).ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(bodies).To(Equal([]string{"body", "body"}))
+		Expect(w.Body.String()).To(Equal("attempt"))
+	})
+
+	It("rolls back and forwards error responses", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		var fnErr error
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+				fnErr = fn(ctx)
+				return fnErr
+			})
+
+		By("acting")
+		handlers.Transactional(mockRunner)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "some err", http.StatusInternalServerError)
+		})).ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(fnErr).To(HaveOccurred())
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(w.Body.String()).To(Equal("some err\n"))
+	})
+
+	It("returns 500 when the transaction fails to commit", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+				runOnce(ctx, options, fn)
+				return errors.New("commit failed")
+			})
+
+		By("acting")
+		handlers.Transactional(mockRunner)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})).ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(w.Body.String()).To(Equal("commit failed\n"))
+	})
+
+	It("returns 500 when the request body cannot be read", func() {
+		By("arranging")
+		req := httptest.NewRequest(http.MethodPost, "/", mocks.ErrReader{})
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		handlers.Transactional(mockRunner)(nil).ServeHTTP(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+		Expect(w.Body.String()).To(Equal("test error\n"))
+	})
+})
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+type transitionRequest struct {
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// Transition moves the resource to another status of the lifecycle, moves the lifecycle
+// does not allow are rejected with 409 and the statuses that could be chosen instead.
+func (r *Resource) Transition(writer http.ResponseWriter, request *http.Request) {
+	if request.Header.Get("Content-Type") != "application/json" {
+		http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+		return
+	}
+	ID, ok := r.resolveID(writer, request)
+	if !ok {
+		return
+	}
+	bytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	var transition transitionRequest
+	if err = json.Unmarshal(bytes, &transition); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if transition.To == "" {
+		http.Error(writer, "missing target status", http.StatusBadRequest)
+		return
+	}
+	current, err := r.Repository.Read(request.Context(), ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, "resource not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	principal := auth.PrincipalFromContext(request.Context())
+	if err = r.Lifecycle.Check(current.Status, transition.To, principal, transition.Reason); err != nil {
+		writeTransitionError(writer, err)
+		return
+	}
+	resource, err := r.Repository.Transition(request.Context(), ID, current.Status, transition.To, transition.Reason)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the resource changed status since it was read
+		writeTransitionError(writer, &entities.TransitionError{From: current.Status, To: transition.To,
+			Reason: "resource is no longer " + current.Status, Allowed: r.Lifecycle.Allowed(current.Status, principal)})
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ = json.Marshal(resource)
+	writer.Write(bytes)
+}
+
+func writeTransitionError(writer http.ResponseWriter, err error) {
+	var transitionErr *entities.TransitionError
+	if !errors.As(err, &transitionErr) {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(transitionErr)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusConflict)
+	writer.Write(bytes)
+}
@@ -0,0 +1,178 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Resource transitions", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		w        *httptest.ResponseRecorder
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		w = httptest.NewRecorder()
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).AnyTimes().Return(123, nil)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	transitionRequest := func(body string) *http.Request {
+		routeParams := chi.RouteParams{}
+		routeParams.Add("resourceID", publicID(123))
+		ctx := context.WithValue(context.TODO(), chi.RouteCtxKey, &chi.Context{URLParams: routeParams})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body))).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	draft := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name", Status: entities.StatusDraft}
+	archived := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name", Status: entities.StatusArchived}
+
+	It("moves the resource to the requested status", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(draft, nil)
+		mockRepo.EXPECT().Transition(req.Context(), 123, "draft", "active", "").Times(1).
+			Return(&entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name", Status: entities.StatusActive}, nil)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(MatchJSON(`{"id":"00000000-0000-7000-8000-000000000123","name":"Resource Name","status":"active"}`))
+	})
+
+	It("passes the reason on", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active","reason":"back in use"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(archived, nil)
+		mockRepo.EXPECT().Transition(req.Context(), 123, "archived", "active", "back in use").Times(1).
+			Return(&entities.Resource{ID: 123, PublicID: publicID(123), Name: "Resource Name", Status: entities.StatusActive}, nil)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusOK))
+	})
+
+	It("rejects moves the lifecycle does not allow with the allowed statuses", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"draft"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(archived, nil)
+		mockRepo.EXPECT().Transition(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusConflict))
+		Expect(w.Body.String()).To(MatchJSON(
+			`{"from":"archived","to":"draft","error":"resources cannot move from archived to draft","allowed":["active"]}`))
+	})
+
+	It("rejects moves missing a required reason", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(archived, nil)
+		mockRepo.EXPECT().Transition(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusConflict))
+		Expect(w.Body.String()).To(ContainSubstring("requires a reason"))
+	})
+
+	It("rejects principals the transition is not open to", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active"}`)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), "intern"))
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(draft, nil)
+		handler := handlers.NewResource(mockRepo)
+		handler.Lifecycle = entities.Lifecycle{Initial: entities.StatusDraft, Transitions: []entities.Transition{
+			{From: entities.StatusDraft, To: entities.StatusActive, Principals: []string{"operator"}},
+			{From: entities.StatusDraft, To: entities.StatusArchived},
+		}}
+
+		By("acting")
+		handler.Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusConflict))
+		Expect(w.Body.String()).To(MatchJSON(
+			`{"from":"draft","to":"active","error":"intern may not move resources from draft to active","allowed":["archived"]}`))
+	})
+
+	It("returns 409 when the resource changed status concurrently", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(draft, nil)
+		mockRepo.EXPECT().Transition(req.Context(), 123, "draft", "active", "").Times(1).Return(nil, pgx.ErrNoRows)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusConflict))
+		Expect(w.Body.String()).To(ContainSubstring(`"allowed":["active","archived"]`))
+	})
+
+	It("returns 404 for unknown resources", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(nil, pgx.ErrNoRows)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("returns 500 when the repository fails", func() {
+		By("arranging")
+		req := transitionRequest(`{"to":"active"}`)
+		mockRepo.EXPECT().Read(req.Context(), 123).Times(1).Return(draft, nil)
+		mockRepo.EXPECT().Transition(req.Context(), 123, "draft", "active", "").Times(1).Return(nil, errors.New("some err"))
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("returns 400 without a target status", func() {
+		By("arranging")
+		req := transitionRequest(`{"reason":"because"}`)
+		mockRepo.EXPECT().Read(gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		handlers.NewResource(mockRepo).Transition(w, req)
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+})
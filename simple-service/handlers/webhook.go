@@ -0,0 +1,200 @@
+//go:generate mockgen -destination=mocks/webhook.go -package mocks . WebhookRepository
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4"
+)
+
+type WebhookRepository interface {
+	Create(ctx context.Context, newWebhook entities.Webhook) (int, error)
+	Read(ctx context.Context, id int) (*entities.Webhook, error)
+	ReadAll(ctx context.Context) ([]entities.Webhook, error)
+	Update(ctx context.Context, id int, newWebhook entities.Webhook) error
+	Delete(ctx context.Context, id int) error
+	Deliveries(ctx context.Context, webhookID int) ([]entities.WebhookDelivery, error)
+	Redeliver(ctx context.Context, webhookID int, deliveryID int64) error
+}
+
+type Webhook struct {
+	Repository WebhookRepository
+}
+
+func NewWebhook(repository WebhookRepository) *Webhook {
+	return &Webhook{Repository: repository}
+}
+
+var getWebhookFromCtxError = errors.New("failed to read webhook from the context")
+
+// Post registers a webhook, the signing secret is generated unless provided and is only ever returned here.
+func (h *Webhook) Post(writer http.ResponseWriter, request *http.Request) {
+	newWebhook, ok := readWebhook(writer, request)
+	if !ok {
+		return
+	}
+	if newWebhook.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			serverError(writer, err)
+			return
+		}
+		newWebhook.Secret = hex.EncodeToString(secret)
+	}
+	id, err := h.Repository.Create(request.Context(), newWebhook)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusCreated)
+	bytes, _ := json.Marshal(map[string]interface{}{"id": id, "secret": newWebhook.Secret})
+	writer.Write(bytes)
+}
+
+func (h *Webhook) GetCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ID, err := strconv.Atoi(chi.URLParam(request, "webhookID"))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		webhook, err := h.Repository.Read(request.Context(), ID)
+		if unavailable(writer, err) {
+			return
+		}
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusNotFound)
+			return
+		}
+		ctx := context.WithValue(request.Context(), "webhook", webhook)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+func (h *Webhook) Get(writer http.ResponseWriter, request *http.Request) {
+	webhook, ok := request.Context().Value("webhook").(*entities.Webhook)
+	if !ok {
+		http.Error(writer, getWebhookFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	response := *webhook
+	response.Secret = ""
+	bytes, _ := json.Marshal(response)
+	writer.Write(bytes)
+}
+
+func (h *Webhook) List(writer http.ResponseWriter, request *http.Request) {
+	webhooks, err := h.Repository.ReadAll(request.Context())
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	bytes, _ := json.Marshal(webhooks)
+	writer.Write(bytes)
+}
+
+func (h *Webhook) Put(writer http.ResponseWriter, request *http.Request) {
+	currentWebhook, ok := request.Context().Value("webhook").(*entities.Webhook)
+	if !ok {
+		http.Error(writer, getWebhookFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	newWebhook, ok := readWebhook(writer, request)
+	if !ok {
+		return
+	}
+	if err := h.Repository.Update(request.Context(), currentWebhook.ID, newWebhook); err != nil {
+		serverError(writer, err)
+		return
+	}
+}
+
+func (h *Webhook) Delete(writer http.ResponseWriter, request *http.Request) {
+	currentWebhook, ok := request.Context().Value("webhook").(*entities.Webhook)
+	if !ok {
+		http.Error(writer, getWebhookFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Repository.Delete(request.Context(), currentWebhook.ID); err != nil {
+		serverError(writer, err)
+		return
+	}
+}
+
+func (h *Webhook) Deliveries(writer http.ResponseWriter, request *http.Request) {
+	currentWebhook, ok := request.Context().Value("webhook").(*entities.Webhook)
+	if !ok {
+		http.Error(writer, getWebhookFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	deliveries, err := h.Repository.Deliveries(request.Context(), currentWebhook.ID)
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	bytes, _ := json.Marshal(deliveries)
+	writer.Write(bytes)
+}
+
+func (h *Webhook) Redeliver(writer http.ResponseWriter, request *http.Request) {
+	currentWebhook, ok := request.Context().Value("webhook").(*entities.Webhook)
+	if !ok {
+		http.Error(writer, getWebhookFromCtxError.Error(), http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(request, "deliveryID"), 10, 64)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.Repository.Redeliver(request.Context(), currentWebhook.ID, deliveryID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(writer, err)
+		return
+	}
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+func readWebhook(writer http.ResponseWriter, request *http.Request) (entities.Webhook, bool) {
+	webhook := entities.Webhook{Active: true}
+	if request.Header.Get("Content-Type") != "application/json" {
+		http.Error(writer, "invalid Content-Type - should be application/json", http.StatusBadRequest)
+		return webhook, false
+	}
+	bytes, err := io.ReadAll(request.Body)
+	if err != nil {
+		serverError(writer, err)
+		return webhook, false
+	}
+	if err = json.Unmarshal(bytes, &webhook); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return webhook, false
+	}
+	target, err := url.Parse(webhook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		http.Error(writer, fmt.Sprintf("invalid webhook url %q", webhook.URL), http.StatusBadRequest)
+		return webhook, false
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	return webhook, true
+}
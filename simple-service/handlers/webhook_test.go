@@ -0,0 +1,276 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhook", func() {
+	var (
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockWebhookRepository
+		w        *httptest.ResponseRecorder
+		current  entities.Webhook
+	)
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockWebhookRepository(mockCtrl)
+		w = httptest.NewRecorder()
+		current = entities.Webhook{ID: 5, URL: "https://example.com/hook", Events: []string{}, Secret: "s3cr3t", Active: true}
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	withWebhook := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), "webhook", &current))
+	}
+
+	readBody := func() string {
+		res := w.Result()
+		defer res.Body.Close()
+		resp, err := io.ReadAll(res.Body)
+		Expect(err).ShouldNot(HaveOccurred())
+		return string(resp)
+	}
+
+	Context("Post", func() {
+		It("registers an active webhook with a generated secret", func() {
+			By("arranging")
+			body := []byte(`{"url":"https://example.com/hook","events":["resource.created"]}`)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			var created entities.Webhook
+			mockRepo.EXPECT().Create(req.Context(), gomock.Any()).Times(1).
+				DoAndReturn(func(_ context.Context, webhook entities.Webhook) (int, error) {
+					created = webhook
+					return 5, nil
+				})
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Post(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusCreated))
+			Expect(created.URL).To(Equal("https://example.com/hook"))
+			Expect(created.Events).To(Equal([]string{"resource.created"}))
+			Expect(created.Active).To(BeTrue())
+			Expect(created.Secret).To(HaveLen(64))
+			var resp map[string]interface{}
+			Expect(json.Unmarshal([]byte(readBody()), &resp)).To(Succeed())
+			Expect(resp).To(Equal(map[string]interface{}{"id": float64(5), "secret": created.Secret}))
+		})
+
+		It("rejects URLs that are not http(s)", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"url":"ftp://example.com"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Post(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+			Expect(readBody()).To(Equal("invalid webhook url \"ftp://example.com\"\n"))
+		})
+
+		It("rejects invalid Content-Type", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{}`)))
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Post(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 500 when the repository fails", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"url":"http://example.com"}`)))
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(0, errors.New("some err"))
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Post(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(readBody()).To(Equal("some err\n"))
+		})
+	})
+
+	Context("GetCtx", func() {
+		It("puts the webhook into the context", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/", nil).
+				WithContext(prepareRouteCtxWithURLParam("webhookID", "5"))
+			mockRepo.EXPECT().Read(req.Context(), 5).Return(&current, nil)
+			var got *entities.Webhook
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).GetCtx(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r.Context().Value("webhook").(*entities.Webhook)
+			})).ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(got).To(Equal(&current))
+		})
+
+		It("returns 404 when the webhook does not exist", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/", nil).
+				WithContext(prepareRouteCtxWithURLParam("webhookID", "5"))
+			mockRepo.EXPECT().Read(req.Context(), 5).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).GetCtx(nil).ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns 400 for invalid IDs", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/", nil).
+				WithContext(prepareRouteCtxWithURLParam("webhookID", "five"))
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).GetCtx(nil).ServeHTTP(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("Get", func() {
+		It("returns the webhook without its secret", func() {
+			By("acting")
+			handlers.NewWebhook(mockRepo).Get(w, withWebhook(httptest.NewRequest(http.MethodGet, "/", nil)))
+
+			By("asserting")
+			Expect(readBody()).To(Equal(`{"id":5,"url":"https://example.com/hook","events":[],"active":true}`))
+			Expect(current.Secret).To(Equal("s3cr3t"))
+		})
+	})
+
+	Context("List", func() {
+		It("returns webhooks without their secrets", func() {
+			By("arranging")
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			mockRepo.EXPECT().ReadAll(req.Context()).Return([]entities.Webhook{current}, nil)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).List(w, req)
+
+			By("asserting")
+			Expect(readBody()).To(Equal(`[{"id":5,"url":"https://example.com/hook","events":[],"active":true}]`))
+		})
+	})
+
+	Context("Put", func() {
+		It("updates the webhook", func() {
+			By("arranging")
+			body := []byte(`{"url":"https://example.com/other","active":false}`)
+			req := withWebhook(httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(body)))
+			req.Header.Set("Content-Type", "application/json")
+			mockRepo.EXPECT().Update(req.Context(), 5, entities.Webhook{URL: "https://example.com/other", Events: []string{}}).
+				Return(nil)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Put(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("Delete", func() {
+		It("deletes the webhook", func() {
+			By("arranging")
+			req := withWebhook(httptest.NewRequest(http.MethodDelete, "/", nil))
+			mockRepo.EXPECT().Delete(req.Context(), 5).Return(nil)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Delete(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Context("Deliveries", func() {
+		It("returns the delivery history", func() {
+			By("arranging")
+			req := withWebhook(httptest.NewRequest(http.MethodGet, "/", nil))
+			deliveries := []entities.WebhookDelivery{{ID: 9, WebhookID: 5, Status: entities.DeliveryDead, Attempts: 10}}
+			mockRepo.EXPECT().Deliveries(req.Context(), 5).Return(deliveries, nil)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Deliveries(w, req)
+
+			By("asserting")
+			var resp []entities.WebhookDelivery
+			Expect(json.Unmarshal([]byte(readBody()), &resp)).To(Succeed())
+			Expect(resp).To(HaveLen(1))
+			Expect(resp[0].Status).To(Equal(entities.DeliveryDead))
+		})
+	})
+
+	Context("Redeliver", func() {
+		redeliverRequest := func(deliveryID string) *http.Request {
+			routeParams := chi.RouteParams{}
+			routeParams.Add("deliveryID", deliveryID)
+			ctx := context.WithValue(context.TODO(), chi.RouteCtxKey, &chi.Context{URLParams: routeParams})
+			return withWebhook(httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx))
+		}
+
+		It("schedules the delivery again", func() {
+			By("arranging")
+			req := redeliverRequest("9")
+			mockRepo.EXPECT().Redeliver(req.Context(), 5, int64(9)).Return(nil)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Redeliver(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusAccepted))
+		})
+
+		It("returns 404 for deliveries of other webhooks", func() {
+			By("arranging")
+			req := redeliverRequest("9")
+			mockRepo.EXPECT().Redeliver(req.Context(), 5, int64(9)).Return(pgx.ErrNoRows)
+
+			By("acting")
+			handlers.NewWebhook(mockRepo).Redeliver(w, req)
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("returns 400 for invalid delivery IDs", func() {
+			By("acting")
+			handlers.NewWebhook(mockRepo).Redeliver(w, redeliverRequest("nine"))
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
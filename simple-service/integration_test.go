@@ -0,0 +1,307 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/addme96/simple-go-service/simple-service/client"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/database/adapters"
+	"github.com/addme96/simple-go-service/simple-service/database/pgtest"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/fixtures"
+	"github.com/addme96/simple-go-service/simple-service/graph"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// The integration specs run the HTTP stack against Postgres, each in a schema of its own:
+//
+//	go test -tags integration ./simple-service/
+//
+// They start a throwaway Postgres unless pgtest.EnvDatabaseURL names a database to use.
+var postgres *pgtest.Server
+
+var _ = BeforeSuite(func() {
+	var err error
+	postgres, err = pgtest.Start(context.Background())
+	Expect(err).NotTo(HaveOccurred())
+})
+
+var _ = AfterSuite(func() {
+	if postgres != nil {
+		Expect(postgres.Stop()).To(Succeed())
+	}
+})
+
+var _ = Describe("Integration", func() {
+	var (
+		ctx    = context.Background()
+		db     *database.DB
+		server *httptest.Server
+		c      *client.Client
+	)
+
+	BeforeEach(func() {
+		schema, schemaURL, err := postgres.CreateSchema(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(postgres.DropSchema, ctx, schema)
+		db = database.NewDB(adapters.Pgx(pgx.Connect), schemaURL)
+		_, err = db.Migrate(ctx, database.LatestMigration())
+		Expect(err).NotTo(HaveOccurred())
+		resourceRepository := repositories.NewResource(db)
+		graphHandler, err := graph.NewHandler(graph.NewResolver(resourceRepository,
+			database.NewListener(db, database.ResourceChangesChannel)))
+		Expect(err).NotTo(HaveOccurred())
+		txManager := database.NewTxManager(db, pgx.ReadCommitted)
+		auditRepository := repositories.NewAudit(db)
+		graphHandler.Runner, graphHandler.Audit = txManager, auditRepository
+		router, err := newRouter(routes{resources: handlers.NewResource(resourceRepository),
+			webhooks: handlers.NewWebhook(repositories.NewWebhook(db)), audit: handlers.NewAudit(auditRepository),
+			graph: graphHandler, txManager: txManager, apiKeys: repositories.NewAPIKey(db), breaker: db.Breaker,
+			validateResponses: true})
+		Expect(err).NotTo(HaveOccurred())
+		server = httptest.NewServer(router)
+		DeferCleanup(server.Close)
+		c = client.New(server.URL)
+	})
+
+	It("creates, reads, updates and deletes resources", func() {
+		By("creating")
+		id, err := c.Create(ctx, entities.Resource{Name: "orders-db", Labels: map[string]string{"tier": "db"},
+			Attributes: map[string]interface{}{"engine": "postgres"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entities.ValidPublicID(id)).To(BeTrue())
+
+		By("reading")
+		resource, err := c.Get(ctx, id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resource.Name).To(Equal("orders-db"))
+		Expect(resource.Status).To(Equal(entities.DefaultLifecycle.Initial))
+		Expect(resource.Attributes).To(Equal(map[string]interface{}{"engine": "postgres"}))
+
+		By("updating")
+		resource.Labels["env"] = "prod"
+		Expect(c.Update(ctx, id, *resource)).To(Succeed())
+		listed, err := c.List(ctx, client.ListOptions{Selector: "env=prod"}).All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(HaveLen(1))
+		Expect(listed[0].PublicID).To(Equal(id))
+
+		By("deleting")
+		Expect(c.Delete(ctx, id, "")).To(Succeed())
+		_, err = c.Get(ctx, id)
+		Expect(errors.Is(err, client.ErrNotFound)).To(BeTrue())
+		listed, err = c.List(ctx, client.ListOptions{IncludeDeleted: true}).All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(HaveLen(1))
+		Expect(listed[0].DeletedAt).NotTo(BeNil())
+	})
+
+	It("lists no resources when there are none", func() {
+		listed, err := c.List(ctx, client.ListOptions{}).All()
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(BeEmpty())
+	})
+
+	It("keeps parents with children unless their deletion cascades", func() {
+		By("arranging")
+		parent, err := c.Create(ctx, entities.Resource{Name: "eu-west"})
+		Expect(err).NotTo(HaveOccurred())
+		child, err := c.Create(ctx, entities.Resource{Name: "web", ParentPublicID: parent})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("acting")
+		restricted := c.Delete(ctx, parent, entities.DeleteRestrict)
+		cascaded := c.Delete(ctx, parent, entities.DeleteCascade)
+
+		By("asserting")
+		Expect(errors.Is(restricted, client.ErrConflict)).To(BeTrue())
+		Expect(cascaded).To(Succeed())
+		_, err = c.Get(ctx, child)
+		Expect(errors.Is(err, client.ErrNotFound)).To(BeTrue())
+	})
+
+	It("rolls back the requests that fail", func() {
+		By("acting")
+		_, err := c.Create(ctx, entities.Resource{Name: "web", ParentPublicID: "00000000-0000-7000-8000-000000000001"})
+
+		By("asserting")
+		Expect(errors.Is(err, client.ErrInvalid)).To(BeTrue())
+		listed, err := c.List(ctx, client.ListOptions{IncludeDeleted: true}).All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(BeEmpty())
+	})
+
+	It("searches resources by words of their names", func() {
+		By("arranging")
+		for _, name := range []string{"orders database", "web frontend", "orders cache"} {
+			_, err := c.Create(ctx, entities.Resource{Name: name})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("acting")
+		results, err := c.Search(ctx, "orders", 1).All()
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, result := range results {
+			names = append(names, result.Name)
+		}
+		Expect(names).To(ConsistOf("orders database", "orders cache"))
+	})
+
+	It("records the principal of the API key as the author of changes", func() {
+		By("arranging")
+		_, secret, err := repositories.NewAPIKey(db).Create(ctx, "ops")
+		Expect(err).NotTo(HaveOccurred())
+		c = client.New(server.URL, client.WithBearerToken(secret))
+
+		By("acting")
+		id, err := c.Create(ctx, entities.Resource{Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("asserting")
+		response, err := http.Get(server.URL + "/resources/" + id + "/revisions")
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		var revisions []entities.ResourceRevision
+		Expect(json.NewDecoder(response.Body).Decode(&revisions)).To(Succeed())
+		Expect(revisions).To(HaveLen(1))
+		Expect(revisions[0].Actor).To(Equal("ops"))
+		entries, err := repositories.NewAudit(db).Query(ctx, entities.AuditFilter{Principal: "ops"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		verification, err := repositories.NewAudit(db).Verify(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verification.Valid()).To(BeTrue())
+	})
+
+	It("rejects revoked API keys", func() {
+		By("arranging")
+		keys := repositories.NewAPIKey(db)
+		key, secret, err := keys.Create(ctx, "ops")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(keys.Revoke(ctx, key.ID)).To(Succeed())
+
+		By("acting")
+		_, err = client.New(server.URL, client.WithBearerToken(secret)).Create(ctx, entities.Resource{Name: "web"})
+
+		By("asserting")
+		var apiError *client.Error
+		Expect(errors.As(err, &apiError)).To(BeTrue())
+		Expect(apiError.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("loads fixtures again without duplicating them, and resets to them", func() {
+		By("arranging")
+		demo, err := fixtures.Profile(fixtures.ProfileDemo, 0)
+		Expect(err).NotTo(HaveOccurred())
+		store := repositories.NewResource(db)
+		first, err := fixtures.Load(ctx, store, demo)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.Create(ctx, entities.Resource{Name: "stray"})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("acting")
+		again, err := fixtures.Load(ctx, store, demo)
+		Expect(err).NotTo(HaveOccurred())
+		reset, err := fixtures.Reset(ctx, db, store, demo)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("asserting")
+		Expect(first.Updated).To(BeZero())
+		Expect(again).To(Equal(fixtures.Result{Updated: first.Created}))
+		Expect(reset).To(Equal(first))
+		listed, err := c.List(ctx, client.ListOptions{Selector: "fixture=demo"}).All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listed).To(HaveLen(first.Created))
+		all, err := c.List(ctx, client.ListOptions{}).All()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).To(HaveLen(first.Created))
+	})
+
+	It("keeps the resources of tenants apart and within their quota", func() {
+		By("arranging")
+		tenantRepository := repositories.NewTenant(db)
+		resourceRepository := repositories.NewResource(db)
+		auditRepository := repositories.NewAudit(db)
+		router, err := newRouter(routes{resources: handlers.NewResource(resourceRepository),
+			webhooks: handlers.NewWebhook(repositories.NewWebhook(db)), audit: handlers.NewAudit(auditRepository),
+			graph: http.NotFoundHandler(), txManager: database.NewTxManager(db, pgx.ReadCommitted),
+			apiKeys: repositories.NewAPIKey(db), breaker: db.Breaker, validateResponses: true, tenants: tenantRepository,
+			tenantResolvers: []handlers.TenantResolver{handlers.TenantFromHeader("X-Tenant-ID")}})
+		Expect(err).NotTo(HaveOccurred())
+		tenantServer := httptest.NewServer(router)
+		DeferCleanup(tenantServer.Close)
+		clients := make(map[string]*client.Client)
+		for _, name := range []string{"acme", "other"} {
+			// tenant schemas are named after their tenant rather than the schema of the spec, so the IDs are unique to the run
+			tenant, err := tenantRepository.Create(ctx, fmt.Sprintf("%s-%d", name, os.Getpid()))
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(tenantRepository.Delete, ctx, tenant.ID)
+			tenantCtx := tenancy.WithTenant(ctx, tenant.ID)
+			_, err = db.Migrate(tenantCtx, database.LatestMigration())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tenantRepository.MarkReady(ctx, tenant.ID)).To(Succeed())
+			if name == "acme" {
+				maximum := 1
+				Expect(tenantRepository.SetQuota(tenantCtx, entities.QuotaResources, &maximum)).To(Succeed())
+			}
+			clients[name] = client.New(tenantServer.URL, client.WithAuth(func(request *http.Request) error {
+				request.Header.Set("X-Tenant-ID", tenant.ID)
+				return nil
+			}))
+		}
+
+		By("acting")
+		_, err = clients["acme"].Create(ctx, entities.Resource{Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		_, overQuota := clients["acme"].Create(ctx, entities.Resource{Name: "db"})
+		_, err = clients["other"].Create(ctx, entities.Resource{Name: "web"})
+		Expect(err).NotTo(HaveOccurred())
+		_, unscoped := client.New(tenantServer.URL).List(ctx, client.ListOptions{}).All()
+
+		By("asserting")
+		Expect(errors.Is(overQuota, client.ErrConflict)).To(BeTrue(), "%v", overQuota)
+		Expect(errors.Is(unscoped, client.ErrInvalid)).To(BeTrue(), "%v", unscoped)
+		for name, tenantClient := range clients {
+			listed, err := tenantClient.List(ctx, client.ListOptions{}).All()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listed).To(HaveLen(1), name)
+		}
+		stored, err := resourceRepository.ReadAll(ctx, entities.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored).To(BeEmpty(), "the schema of the spec holds no resource of the tenants")
+	})
+
+	It("reverts and applies migrations again", func() {
+		By("acting")
+		reverted, err := db.Rollback(ctx, 1)
+		Expect(err).NotTo(HaveOccurred())
+		applied, err := db.Migrate(ctx, database.LatestMigration())
+		Expect(err).NotTo(HaveOccurred())
+
+		By("asserting")
+		Expect(reverted).To(Equal(applied))
+		states, err := db.MigrationStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		for _, state := range states {
+			Expect(state.AppliedAt).NotTo(BeNil(), state.Name)
+		}
+	})
+})
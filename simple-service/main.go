@@ -2,20 +2,29 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/addme96/simple-go-service/simple-service/cache"
 	"github.com/addme96/simple-go-service/simple-service/database"
 	"github.com/addme96/simple-go-service/simple-service/database/adapters"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/graph"
 	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/outbox"
 	"github.com/addme96/simple-go-service/simple-service/repositories"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
+	"github.com/addme96/simple-go-service/simple-service/rpc"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/addme96/simple-go-service/simple-service/webhooks"
 	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -23,55 +32,365 @@ const (
 	envDBUsername = "DB_USERNAME"
 	envDBName     = "DB_NAME"
 	envDBPassword = "DB_PASSWORD"
+	// envDBReplicaEndpoints are the comma separated endpoints of read replicas, reached with the credentials of the primary
+	envDBReplicaEndpoints = "DB_REPLICA_ENDPOINTS"
+	// envDBReplicaMaxLag is how far replicas may lag behind the primary before they are ejected, as a time.Duration (default 5s)
+	envDBReplicaMaxLag = "DB_REPLICA_MAX_LAG"
+	envOutboxSink      = "OUTBOX_SINK"
+	// envOutboxSinkTimeout is how long the OUTBOX_SINK webhook has to respond, as a time.Duration (default 10s)
+	envOutboxSinkTimeout = "OUTBOX_SINK_TIMEOUT"
+	// envDBIsolationLevel is one of "serializable", "repeatable read", "read committed" (default), "read uncommitted"
+	envDBIsolationLevel = "DB_ISOLATION_LEVEL"
+	// envResourceRetention is how long deleted resources can be restored, as a time.Duration (default 720h)
+	envResourceRetention = "RESOURCE_RETENTION"
+	// envResourceLifecycle is the path of a JSON transition table replacing entities.DefaultLifecycle
+	envResourceLifecycle = "RESOURCE_LIFECYCLE"
+	// envResourceLegacyIDs set to true keeps accepting the integer IDs of resources next to their public IDs
+	envResourceLegacyIDs = "RESOURCE_LEGACY_IDS"
+	// envResourceCacheSize is how many resources are cached in process, 0 turns the cache off (default 10000)
+	envResourceCacheSize = "RESOURCE_CACHE_SIZE"
+	// envResourceCacheTTL is how long resources are cached, as a time.Duration (default 1m)
+	envResourceCacheTTL = "RESOURCE_CACHE_TTL"
+	// envGRPCAddress is where the gRPC API listens, next to the REST API on :80 (default :9090)
+	envGRPCAddress = "GRPC_ADDRESS"
+	// envDevMode serves development tools such as the GraphiQL IDE at /graphql (default false)
+	envDevMode = "DEV_MODE"
+	// envTenantResolvers are the comma separated ways requests name their tenant, such as
+	// "header:X-Tenant-ID,subdomain:example.com,claim:tenant", requests reach the default schema when it is unset
+	envTenantResolvers = "TENANT_RESOLVERS"
+	// envTenantJWTKey is the HS256 key the JWTs of the claim resolver are signed with
+	envTenantJWTKey = "TENANT_JWT_KEY"
 )
 
 func main() {
-	db := database.NewDB(adapters.Pgx(pgx.Connect), getConnectionString())
-	if err := db.Seed(context.Background()); err != nil {
-		panic(err)
+	if err := newCommand().Execute(); err != nil {
+		os.Exit(1)
 	}
-	resourceHandler := handlers.NewResource(repositories.NewResource(db))
-	r := chi.NewRouter()
-	// Basic CORS. For more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
-		MaxAge:           300, // Maximum value not ignored by any of major browsers
-	}))
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(middleware.Heartbeat("/healthz"))
-	r.Route("/resources", func(r chi.Router) {
-		r.Get("/", resourceHandler.List)
-		r.Post("/", resourceHandler.Post)
-		r.Route("/{resourceID}", func(r chi.Router) {
-			r.Use(resourceHandler.GetCtx)
-			r.Get("/", resourceHandler.Get)
-			r.Put("/", resourceHandler.Put)
-			r.Delete("/", resourceHandler.Delete)
+}
+
+// newDB connects to the database the environment configures, for the server and every command alike.
+func newDB() (*database.DB, error) {
+	databaseURL, err := getConnectionString("")
+	if err != nil {
+		return nil, err
+	}
+	var replicaURLs []string
+	if endpoints, ok := os.LookupEnv(envDBReplicaEndpoints); ok && endpoints != "" {
+		for _, endpoint := range strings.Split(endpoints, ",") {
+			replicaURL, err := getConnectionString(strings.TrimSpace(endpoint))
+			if err != nil {
+				return nil, err
+			}
+			replicaURLs = append(replicaURLs, replicaURL)
+		}
+	}
+	db := database.NewDB(adapters.Pgx(pgx.Connect), databaseURL, replicaURLs...)
+	if value, ok := os.LookupEnv(envDBReplicaMaxLag); ok {
+		maxLag, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", envDBReplicaMaxLag, err)
+		}
+		db.MaxReplicaLag = maxLag
+	}
+	return db, nil
+}
+
+// serve migrates the database and the schemas of the tenants to the latest version and serves the REST,
+// gRPC and GraphQL APIs.
+func serve(db *database.DB) {
+	err := migrateAll(context.Background(), db, database.LatestMigration(),
+		func(tenant string, applied []database.Migration) {
+			for _, migration := range applied {
+				log.Printf("Applied migration %d %s%s", migration.Version, migration.Name, ofTenant(tenant))
+			}
 		})
-	})
+	if err != nil {
+		panic(err)
+	}
+	tenantRepository := repositories.NewTenant(db)
+	var tenantResolvers []handlers.TenantResolver
+	if spec, ok := os.LookupEnv(envTenantResolvers); ok {
+		if tenantResolvers, err = handlers.ParseTenantResolvers(spec, []byte(os.Getenv(envTenantJWTKey))); err != nil {
+			panic(err)
+		}
+	}
+	auditRepository := repositories.NewAudit(db)
+	listener := database.NewListener(db, database.ResourceChangesChannel)
+	go listener.Run(context.Background())
+	webhookRepository := repositories.NewWebhook(db)
+	sinks := outbox.Fanout{webhooks.NewSink(webhookRepository)}
+	if spec, ok := os.LookupEnv(envOutboxSink); ok {
+		timeout := outbox.DefaultSinkTimeout
+		if value, ok := os.LookupEnv(envOutboxSinkTimeout); ok {
+			if timeout, err = time.ParseDuration(value); err != nil {
+				panic(fmt.Errorf("%s: %w", envOutboxSinkTimeout, err))
+			}
+		}
+		sink, err := outbox.ParseSink(spec, timeout)
+		if err != nil {
+			panic(err)
+		}
+		sinks = append(sinks, sink)
+	}
+	relay := outbox.NewRelay(db, sinks)
+	worker := webhooks.NewWorker(webhookRepository, &http.Client{Timeout: 10 * time.Second})
+	isoLevel := pgx.ReadCommitted
+	if value, ok := os.LookupEnv(envDBIsolationLevel); ok {
+		if isoLevel, err = database.ParseIsoLevel(value); err != nil {
+			panic(fmt.Errorf("%s: %w", envDBIsolationLevel, err))
+		}
+	}
+	txManager := database.NewTxManager(db, isoLevel)
+	resourceRepository := repositories.NewResource(db)
+	retention := getResourceRetention()
+	go runPerTenant(context.Background(), tenantRepository, 10*time.Second,
+		func(ctx context.Context) { relay.Run(ctx) },
+		func(ctx context.Context) { worker.Run(ctx) },
+		func(ctx context.Context) { purgeDeletedResources(ctx, resourceRepository, retention, time.Hour) })
+	resourceStore := newResourceCache(context.Background(), resourceRepository, listener)
+	resourceHandler := handlers.NewResource(resourceStore)
+	if path, ok := os.LookupEnv(envResourceLifecycle); ok {
+		lifecycle, err := entities.ReadLifecycle(path)
+		if err != nil {
+			panic(err)
+		}
+		resourceHandler.Lifecycle = lifecycle
+	}
+	if value, ok := os.LookupEnv(envResourceLegacyIDs); ok {
+		legacyIDs, err := strconv.ParseBool(value)
+		if err != nil {
+			panic(err)
+		}
+		resourceHandler.LegacyIDs = legacyIDs
+	}
+	resourceServer := rpc.NewServer(resourceStore, listener)
+	resourceServer.Lifecycle = resourceHandler.Lifecycle
+	// like the HTTP routes, calls are authenticated once their tenant is resolved
+	apiKeyRepository := repositories.NewAPIKey(db)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{rpc.Authenticate(apiKeyRepository), rpc.Transactional(txManager),
+		rpc.Audited(auditRepository)}
+	streamInterceptors := []grpc.StreamServerInterceptor{rpc.AuthenticateStream(apiKeyRepository)}
+	if len(tenantResolvers) > 0 {
+		unaryInterceptors = append([]grpc.UnaryServerInterceptor{rpc.ResolveTenant(tenantRepository, tenantResolvers...)}, unaryInterceptors...)
+		streamInterceptors = append([]grpc.StreamServerInterceptor{rpc.ResolveStreamTenant(tenantRepository, tenantResolvers...)}, streamInterceptors...)
+	}
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...))
+	go rpc.WatchBreaker(context.Background(), rpc.Register(grpcServer, resourceServer), db.Breaker, time.Second)
+	go serveGRPC(grpcServer)
+	graphResolver := graph.NewResolver(resourceStore, listener)
+	graphResolver.Lifecycle = resourceHandler.Lifecycle
+	graphHandler, err := graph.NewHandler(graphResolver)
+	if err != nil {
+		panic(err)
+	}
+	graphHandler.Runner, graphHandler.Audit = txManager, auditRepository
+	if value, ok := os.LookupEnv(envDevMode); ok {
+		devMode, err := strconv.ParseBool(value)
+		if err != nil {
+			panic(err)
+		}
+		graphHandler.GraphiQL = devMode
+	}
+	webhookHandler := handlers.NewWebhook(webhookRepository)
+	auditHandler := handlers.NewAudit(auditRepository)
+	if db.Replicas() > 0 {
+		go db.MonitorReplicas(context.Background(), time.Second)
+		expvar.Publish("database_healthy_replicas", expvar.Func(func() interface{} { return db.HealthyReplicas() }))
+	}
+	expvar.Publish("database_breaker", expvar.Func(func() interface{} { return db.Breaker.Stats() }))
+	r, err := newRouter(routes{resources: resourceHandler, webhooks: webhookHandler, audit: auditHandler,
+		graph: graphHandler, txManager: txManager, apiKeys: apiKeyRepository, tenants: tenantRepository,
+		tenantResolvers: tenantResolvers, breaker: db.Breaker, replicas: db.Replicas() > 0, maxReplicaLag: db.MaxReplicaLag})
+	if err != nil {
+		panic(err)
+	}
 	log.Println("Listening for requests at http://localhost:80")
 	log.Fatal(http.ListenAndServe(":80", r))
 }
 
-func getConnectionString() string {
+// migrateAll migrates the default schema and then the schema of every tenant to target, report is told what
+// was applied to each, the default schema being the tenant "".
+func migrateAll(ctx context.Context, db *database.DB, target int,
+	report func(tenant string, applied []database.Migration)) error {
+	applied, err := db.Migrate(ctx, target)
+	report("", applied)
+	if err != nil {
+		return err
+	}
+	tenants, err := repositories.NewTenant(db).ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		applied, err = db.Migrate(tenancy.WithTenant(ctx, tenant.ID), target)
+		report(tenant.ID, applied)
+		if err != nil {
+			return fmt.Errorf("migrating tenant %s: %w", tenant.ID, err)
+		}
+	}
+	return nil
+}
+
+func ofTenant(tenant string) string {
+	if tenant == "" {
+		return ""
+	}
+	return " of tenant " + tenant
+}
+
+// runPerTenant runs the jobs for the default schema and for every tenant until ctx is cancelled. The tenants
+// are polled every interval, the jobs of a tenant start once it is created and are cancelled once it is deleted.
+func runPerTenant(ctx context.Context, tenants *repositories.Tenant, interval time.Duration,
+	jobs ...func(ctx context.Context)) {
+	for _, job := range jobs {
+		go job(ctx)
+	}
+	running := make(map[string]context.CancelFunc)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		registered, err := tenants.ReadAll(ctx)
+		if err != nil {
+			log.Printf("Reading the tenants failed: %v", err)
+		} else {
+			live := make(map[string]bool, len(registered))
+			for _, tenant := range registered {
+				if !tenant.Ready {
+					continue
+				}
+				live[tenant.ID] = true
+				if _, ok := running[tenant.ID]; ok {
+					continue
+				}
+				tenantCtx, cancel := context.WithCancel(tenancy.WithTenant(ctx, tenant.ID))
+				running[tenant.ID] = cancel
+				for _, job := range jobs {
+					go job(tenantCtx)
+				}
+			}
+			for tenant, cancel := range running {
+				if !live[tenant] {
+					cancel()
+					delete(running, tenant)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getConnectionString connects to endpoint, or to the primary at DB_ENDPOINT when it is empty.
+func getConnectionString(endpoint string) (string, error) {
 	env, err := readAllEnvVars(envDBEndpoint, envDBUsername, envDBName, envDBPassword)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	if endpoint == "" {
+		endpoint = env[envDBEndpoint]
 	}
 	return fmt.Sprintf("postgres://%s:%s@%s/%s",
 		env[envDBUsername],
 		env[envDBPassword],
-		env[envDBEndpoint],
+		endpoint,
 		env[envDBName],
-	)
+	), nil
+}
+
+func serveGRPC(server *grpc.Server) {
+	address, ok := os.LookupEnv(envGRPCAddress)
+	if !ok {
+		address = ":9090"
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Listening for gRPC requests at %s", address)
+	log.Fatal(server.Serve(listener))
+}
+
+func getResourceRetention() time.Duration {
+	value, ok := os.LookupEnv(envResourceRetention)
+	if !ok {
+		return 30 * 24 * time.Hour
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil {
+		panic(err)
+	}
+	return retention
+}
+
+// newResourceCache puts the resource cache in front of repository, unless it is turned off.
+// The cache drops the resources the listener announces as changed and publishes its stats to expvar.
+func newResourceCache(ctx context.Context, repository handlers.ResourceRepository, listener *database.Listener) handlers.ResourceRepository {
+	size := 10000
+	if value, ok := os.LookupEnv(envResourceCacheSize); ok {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil {
+			panic(err)
+		}
+	}
+	if size <= 0 {
+		return repository
+	}
+	lru := cache.NewLRU(size)
+	resourceCache := cache.NewResource(repository, lru)
+	if value, ok := os.LookupEnv(envResourceCacheTTL); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			panic(err)
+		}
+		resourceCache.TTL = ttl
+	}
+	events, _ := listener.Subscribe(1024)
+	go resourceCache.Watch(ctx, events)
+	expvar.Publish("resource_cache", expvar.Func(func() interface{} {
+		return struct {
+			cache.Stats
+			Entries   int   `json:"entries"`
+			Evictions int64 `json:"evictions"`
+		}{resourceCache.Stats(), lru.Len(), lru.Evictions()}
+	}))
+	return resourceCache
+}
+
+func purgeDeletedResources(ctx context.Context, repository *repositories.Resource, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := repository.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("Purging deleted resources failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted resources", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// verifyAuditLog is run by the verify-audit command, it exits non-zero when the audit chain is broken.
+func verifyAuditLog(ctx context.Context, repository *repositories.Audit) int {
+	verification, err := repository.Verify(ctx)
+	if err != nil {
+		log.Printf("Verifying the audit log failed: %v", err)
+		return 2
+	}
+	if !verification.Valid() {
+		log.Printf("Audit log is broken at entry %d: %s", verification.BrokenAt, verification.Reason)
+		return 1
+	}
+	log.Printf("Audit log is intact, %d entries checked", verification.Checked)
+	return 0
 }
 
 func readAllEnvVars(keys ...string) (map[string]string, error) {
@@ -0,0 +1,444 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Version is the version of the OpenAPI specification Handler serves the document in.
+const Version = "3.1.0"
+
+const (
+	tagResources  = "resources"
+	tagWebhooks   = "webhooks"
+	tagAudit      = "audit"
+	tagGraphQL    = "graphql"
+	tagOperations = "operations"
+)
+
+// Document describes the HTTP API served by the router of main. It is modelled in OpenAPI 3.0, which
+// kin-openapi validates requests against, and only converted to 3.1 when it is served.
+func Document() *openapi3.T {
+	s := make(schemas)
+	resource := s.ref(entities.Resource{})
+	for _, name := range []string{"id", "status", "deleted_at"} {
+		resource.Value.Properties[name].Value.ReadOnly = true
+	}
+	resource.Value.Properties["id"].Value.Description = "The public ID of the resource, a UUIDv7."
+	resource.Value.Properties["labels"].Value.WithMaxProperties(entities.MaxLabels)
+	s["ResourceInput"] = input(resource.Value, "name")
+	webhook := s.ref(entities.Webhook{})
+	webhook.Value.Properties["id"].Value.ReadOnly = true
+	webhook.Value.Properties["secret"].Value.Description =
+		"Signs the deliveries, it is only returned when the webhook is created."
+	s["WebhookInput"] = input(webhook.Value, "url")
+	s["RevisionDiff"] = openapi3.NewSchemaRef("", object("from", "to", "changes").
+		WithProperty("from", openapi3.NewIntegerSchema()).
+		WithProperty("to", openapi3.NewIntegerSchema()).
+		WithPropertyRef("changes", arrayOf(s.ref(entities.FieldChange{}))))
+	s["Error"] = openapi3.NewSchemaRef("", described(openapi3.NewStringSchema(),
+		"The error message followed by a newline, as written by http.Error."))
+
+	d := document{T: &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:       "simple-go-service",
+			Description: "Manages resources, their hierarchy, revisions and lifecycle, and notifies webhooks of their changes.",
+			Version:     "1.0.0",
+		},
+		Paths: openapi3.Paths{},
+		Components: &openapi3.Components{
+			Schemas:    openapi3.Schemas(s),
+			Parameters: parameters(),
+			Responses:  responses(s.ref(ValidationError{})),
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearerAuth": &openapi3.SecuritySchemeRef{Value: openapi3.NewSecurityScheme().WithType("http").
+					WithScheme("bearer").WithDescription("An API key identifying the principal that changes are recorded for, " +
+					"requests without it are anonymous. Deployments hosting tenants may take a JWT naming the tenant instead.")},
+			},
+		},
+		// authentication is optional, the empty requirement allows anonymous requests
+		Security: openapi3.SecurityRequirements{{}, {"bearerAuth": []string{}}},
+		Tags: openapi3.Tags{
+			{Name: tagResources, Description: "Resources and their labels, attributes, hierarchy and revisions."},
+			{Name: tagWebhooks, Description: "Webhooks notified of the changes of resources."},
+			{Name: tagAudit, Description: "The tamper-evident log of the changes made through the API."},
+			{Name: tagGraphQL, Description: "The GraphQL API over resources."},
+			{Name: tagOperations, Description: "Health, metrics and this document."},
+		},
+	}}
+	d.resources(s, resource)
+	d.webhooks(s, webhook)
+	d.operations(s)
+	return d.T
+}
+
+type document struct {
+	*openapi3.T
+}
+
+func (d document) resources(s schemas, resource *openapi3.SchemaRef) {
+	resources := arrayOf(resource)
+	resourceID := parameterRef("resourceID")
+	d.add(http.MethodGet, "/resources", &openapi3.Operation{OperationID: "listResources", Tags: []string{tagResources},
+		Summary: "Lists live resources, optionally with soft-deleted ones and filtered by labels.",
+		Parameters: openapi3.Parameters{
+			query("include", "Set to deleted to list soft-deleted resources too.", openapi3.NewStringSchema().WithEnum("deleted")),
+			query("selector", "A label selector such as env=prod,tier!=db,team in (a,b),!legacy.", openapi3.NewStringSchema()),
+			parameterRef("limit"),
+			parameterRef("cursor"),
+		},
+		Responses: d.ok(openapi3.NewSchemaRef("", described(openapi3.NewOneOfSchema(resources.Value, pageOf(resource).Value),
+			"The resources, in a page when limit or cursor is given.")), "400")})
+	d.add(http.MethodPost, "/resources", &openapi3.Operation{OperationID: "createResource", Tags: []string{tagResources},
+		Summary:     "Creates a resource in the initial status of the lifecycle, below parent_id if it is given.",
+		RequestBody: body(componentRef(s, "ResourceInput")),
+		Responses:   d.created("400", "409")})
+	d.add(http.MethodGet, "/resources/search", &openapi3.Operation{OperationID: "searchResources", Tags: []string{tagResources},
+		Summary: "Searches the names, labels and attributes of live resources, best matches first.",
+		Parameters: openapi3.Parameters{
+			requiredQuery("q", "The words to search for.", openapi3.NewStringSchema().WithMinLength(1)),
+			parameterRef("limit"),
+			parameterRef("cursor"),
+		},
+		Responses: d.ok(pageOf(s.ref(entities.SearchResult{})), "400")})
+	d.add(http.MethodGet, "/resources/{resourceID}", &openapi3.Operation{OperationID: "getResource", Tags: []string{tagResources},
+		Summary:    "Returns a live resource.",
+		Parameters: openapi3.Parameters{resourceID},
+		Responses:  d.ok(resource, "400", "404")})
+	d.add(http.MethodPut, "/resources/{resourceID}", &openapi3.Operation{OperationID: "updateResource", Tags: []string{tagResources},
+		Summary:     "Replaces the name, attributes and labels of a resource.",
+		Parameters:  openapi3.Parameters{resourceID},
+		RequestBody: body(componentRef(s, "ResourceInput")),
+		Responses:   d.empty("The resource was updated.", "400", "404")})
+	d.add(http.MethodDelete, "/resources/{resourceID}", &openapi3.Operation{OperationID: "deleteResource", Tags: []string{tagResources},
+		Summary: "Soft-deletes a resource, children decides what happens to its children.",
+		Parameters: openapi3.Parameters{resourceID,
+			query("children", "restrict refuses to delete resources with children, cascade deletes them too "+
+				"and orphan makes them roots.", openapi3.NewStringSchema().WithEnum(string(entities.DeleteRestrict),
+				string(entities.DeleteCascade), string(entities.DeleteOrphan)).WithDefault(string(entities.DeleteRestrict))),
+		},
+		Responses: d.empty("The resource was deleted.", "400", "404", "409")})
+	d.add(http.MethodPost, "/resources/{resourceID}:restore", &openapi3.Operation{OperationID: "restoreResource",
+		Tags: []string{tagResources}, Summary: "Restores a soft-deleted resource.",
+		Parameters: openapi3.Parameters{resourceID},
+		Responses:  d.ok(resource, "400", "404", "409")})
+	d.add(http.MethodPost, "/resources/{resourceID}:revert", &openapi3.Operation{OperationID: "revertResource",
+		Tags: []string{tagResources}, Summary: "Reverts a resource to the state after one of its revisions.",
+		Parameters: openapi3.Parameters{resourceID},
+		RequestBody: body(openapi3.NewSchemaRef("", object("revision").
+			WithProperty("revision", openapi3.NewIntegerSchema()))),
+		Responses: d.ok(resource, "400", "404", "409")})
+	transitionConflict := response("The lifecycle does not allow the transition.", s.ref(entities.TransitionError{}))
+	d.add(http.MethodPost, "/resources/{resourceID}:transition", &openapi3.Operation{OperationID: "transitionResource",
+		Tags: []string{tagResources}, Summary: "Moves a resource to another status of the lifecycle.",
+		Parameters: openapi3.Parameters{resourceID},
+		RequestBody: body(openapi3.NewSchemaRef("", object("to").
+			WithProperty("to", openapi3.NewStringSchema().WithMinLength(1)).
+			WithProperty("reason", openapi3.NewStringSchema()))),
+		Responses: with(d.ok(resource, "400", "404"), "409", transitionConflict)})
+	d.add(http.MethodPost, "/resources/{resourceID}:move", &openapi3.Operation{OperationID: "moveResource",
+		Tags: []string{tagResources}, Summary: "Puts a resource below another one, or makes it a root.",
+		Parameters: openapi3.Parameters{resourceID},
+		RequestBody: body(openapi3.NewSchemaRef("", object("parent_id").
+			WithPropertyRef("parent_id", openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true,
+				Description: "The public ID of the new parent, null makes the resource a root."})))),
+		Responses: d.ok(resource, "400", "404", "409")})
+	revision := s.ref(entities.ResourceRevision{})
+	d.add(http.MethodGet, "/resources/{resourceID}/revisions", &openapi3.Operation{OperationID: "listRevisions",
+		Tags: []string{tagResources}, Summary: "Lists the revisions of a resource, oldest first.",
+		Parameters: openapi3.Parameters{resourceID},
+		Responses:  d.ok(arrayOf(revision), "400", "404")})
+	d.add(http.MethodGet, "/resources/{resourceID}/revisions/diff", &openapi3.Operation{OperationID: "diffRevisions",
+		Tags: []string{tagResources}, Summary: "Compares the states of a resource after two of its revisions.",
+		Parameters: openapi3.Parameters{resourceID,
+			requiredQuery("from", "The revision to compare from.", openapi3.NewIntegerSchema()),
+			requiredQuery("to", "The revision to compare to.", openapi3.NewIntegerSchema()),
+		},
+		Responses: d.ok(componentRef(s, "RevisionDiff"), "400", "404")})
+	d.add(http.MethodGet, "/resources/{resourceID}/revisions/{revision}", &openapi3.Operation{OperationID: "getRevision",
+		Tags: []string{tagResources}, Summary: "Returns a revision of a resource.",
+		Parameters: openapi3.Parameters{resourceID, path("revision", openapi3.NewIntegerSchema())},
+		Responses:  d.ok(revision, "400", "404")})
+	d.add(http.MethodGet, "/resources/{resourceID}/children", &openapi3.Operation{OperationID: "listChildren",
+		Tags: []string{tagResources}, Summary: "Lists the live children of a resource.",
+		Parameters: openapi3.Parameters{resourceID},
+		Responses:  d.ok(resources, "400", "404")})
+	d.add(http.MethodGet, "/resources/{resourceID}/ancestors", &openapi3.Operation{OperationID: "listAncestors",
+		Tags: []string{tagResources}, Summary: "Lists the ancestors of a resource, its parent first.",
+		Parameters: openapi3.Parameters{resourceID},
+		Responses:  d.ok(resources, "400", "404")})
+	d.add(http.MethodGet, "/resources/{resourceID}/subtree", &openapi3.Operation{OperationID: "getSubtree",
+		Tags: []string{tagResources}, Summary: "Returns a resource with its descendants nested below it.",
+		Parameters: openapi3.Parameters{resourceID,
+			query("depth", "How many levels of descendants to return.", openapi3.NewIntegerSchema().
+				WithMin(0).WithMax(entities.MaxSubtreeDepth).WithDefault(entities.MaxSubtreeDepth)),
+		},
+		Responses: d.ok(s.ref(entities.ResourceNode{}), "400", "404")})
+	d.add(http.MethodGet, "/audit", &openapi3.Operation{OperationID: "listAuditEntries", Tags: []string{tagAudit},
+		Summary: "Lists audit entries, oldest first.",
+		Parameters: openapi3.Parameters{
+			query("actor", "Only the entries of this principal.", openapi3.NewStringSchema()),
+			query("since", "Only the entries made at or after this time.", openapi3.NewDateTimeSchema()),
+			query("until", "Only the entries made before this time.", openapi3.NewDateTimeSchema()),
+			query("resource_id", "Only the entries of the resource with this public ID.", openapi3.NewStringSchema()),
+			query("limit", "The most entries to return.", openapi3.NewIntegerSchema()),
+		},
+		Responses: d.ok(arrayOf(s.ref(entities.AuditEntry{})), "400")})
+}
+
+func (d document) webhooks(s schemas, webhook *openapi3.SchemaRef) {
+	webhookID := path("webhookID", openapi3.NewIntegerSchema())
+	d.add(http.MethodGet, "/webhooks", &openapi3.Operation{OperationID: "listWebhooks", Tags: []string{tagWebhooks},
+		Summary:   "Lists the webhooks, without their secrets.",
+		Responses: d.ok(arrayOf(webhook))})
+	d.add(http.MethodPost, "/webhooks", &openapi3.Operation{OperationID: "createWebhook", Tags: []string{tagWebhooks},
+		Summary:     "Registers a webhook, its secret is generated unless one is given.",
+		RequestBody: body(componentRef(s, "WebhookInput")),
+		Responses: with(d.errorResponses("400"), "201", response("The webhook was registered.",
+			openapi3.NewSchemaRef("", object("id", "secret").
+				WithProperty("id", openapi3.NewIntegerSchema()).
+				WithProperty("secret", openapi3.NewStringSchema()))))})
+	d.add(http.MethodGet, "/webhooks/{webhookID}", &openapi3.Operation{OperationID: "getWebhook", Tags: []string{tagWebhooks},
+		Summary:    "Returns a webhook, without its secret.",
+		Parameters: openapi3.Parameters{webhookID},
+		Responses:  d.ok(webhook, "400", "404")})
+	d.add(http.MethodPut, "/webhooks/{webhookID}", &openapi3.Operation{OperationID: "updateWebhook", Tags: []string{tagWebhooks},
+		Summary:     "Replaces a webhook.",
+		Parameters:  openapi3.Parameters{webhookID},
+		RequestBody: body(componentRef(s, "WebhookInput")),
+		Responses:   d.empty("The webhook was updated.", "400", "404")})
+	d.add(http.MethodDelete, "/webhooks/{webhookID}", &openapi3.Operation{OperationID: "deleteWebhook", Tags: []string{tagWebhooks},
+		Summary:    "Deletes a webhook.",
+		Parameters: openapi3.Parameters{webhookID},
+		Responses:  d.empty("The webhook was deleted.", "400", "404")})
+	d.add(http.MethodGet, "/webhooks/{webhookID}/deliveries", &openapi3.Operation{OperationID: "listDeliveries",
+		Tags: []string{tagWebhooks}, Summary: "Lists the deliveries of a webhook.",
+		Parameters: openapi3.Parameters{webhookID},
+		Responses:  d.ok(arrayOf(s.ref(entities.WebhookDelivery{})), "400", "404")})
+	d.add(http.MethodPost, "/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", &openapi3.Operation{
+		OperationID: "redeliver", Tags: []string{tagWebhooks}, Summary: "Queues a delivery to be sent again.",
+		Parameters: openapi3.Parameters{webhookID, path("deliveryID", openapi3.NewIntegerSchema())},
+		Responses: with(d.errorResponses("400", "404"), "202",
+			&openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("The delivery was queued.")})})
+}
+
+func (d document) operations(s schemas) {
+	anyObject := openapi3.NewSchemaRef("", openapi3.NewObjectSchema())
+	d.add(http.MethodGet, "/healthz", &openapi3.Operation{OperationID: "heartbeat", Tags: []string{tagOperations},
+		Summary: "Responds as long as the service is up.", Security: &openapi3.SecurityRequirements{},
+		Responses: openapi3.Responses{"200": &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription("The service is up.").WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(),
+			[]string{"text/plain"}))}}})
+	readiness := openapi3.NewSchemaRef("", object("database").
+		WithPropertyRef("database", s.ref(database.BreakerStats{})))
+	d.add(http.MethodGet, "/readyz", &openapi3.Operation{OperationID: "readiness", Tags: []string{tagOperations},
+		Summary: "Reports whether the database can be reached.", Security: &openapi3.SecurityRequirements{},
+		Responses: openapi3.Responses{
+			"200": response("The database can be reached.", readiness),
+			"503": response("The database breaker is open.", readiness),
+		}})
+	d.add(http.MethodGet, "/debug/vars", &openapi3.Operation{OperationID: "metrics", Tags: []string{tagOperations},
+		Summary: "Returns the metrics published through expvar.",
+		Responses: openapi3.Responses{
+			"200": response("The metrics by name.", anyObject),
+			"401": responseRef(d.Components.Responses, "401"),
+		}})
+	d.add(http.MethodGet, "/openapi.json", &openapi3.Operation{OperationID: "openapi", Tags: []string{tagOperations},
+		Summary: "Returns this document.", Security: &openapi3.SecurityRequirements{},
+		Responses: openapi3.Responses{"200": response("The OpenAPI document.", anyObject)}})
+	d.add(http.MethodGet, "/docs", &openapi3.Operation{OperationID: "docs", Tags: []string{tagOperations},
+		Summary: "Serves Swagger UI for this document.", Security: &openapi3.SecurityRequirements{},
+		Responses: openapi3.Responses{"200": &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription("The Swagger UI page.").WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(),
+			[]string{"text/html"}))}}})
+	graphQLRequest := openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+		WithProperty("query", openapi3.NewStringSchema()).
+		WithProperty("operationName", openapi3.NewStringSchema()).
+		WithProperty("variables", openapi3.NewObjectSchema()))
+	graphQLRequest.Value.Required = []string{"query"}
+	graphQLResult := openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+		WithProperty("data", &openapi3.Schema{Nullable: true}).
+		WithPropertyRef("errors", arrayOf(openapi3.NewSchemaRef("", openapi3.NewObjectSchema()))))
+	// requests are rejected with a result when the query is invalid, and with an error when there is none
+	graphQLResponses := openapi3.Responses{
+		"200": response("The result of the query or mutation.", graphQLResult),
+		"400": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("The request is invalid.").
+			WithContent(openapi3.Content{
+				"application/json": openapi3.NewMediaType().WithSchemaRef(graphQLResult),
+				"text/plain":       openapi3.NewMediaType().WithSchemaRef(componentRef(s, "Error")),
+			})},
+		"401": responseRef(d.Components.Responses, "401"),
+		"405": &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription("Mutations are only run on POST.").
+			WithContent(openapi3.NewContentWithSchemaRef(componentRef(s, "Error"), []string{"text/plain"}))},
+	}
+	d.add(http.MethodGet, "/graphql", &openapi3.Operation{OperationID: "graphqlQuery", Tags: []string{tagGraphQL},
+		Summary: "Runs a GraphQL query or subscription, subscriptions are streamed as server-sent events.",
+		Parameters: openapi3.Parameters{
+			requiredQuery("query", "The GraphQL document.", openapi3.NewStringSchema()),
+			query("operationName", "The operation of the document to run.", openapi3.NewStringSchema()),
+			query("variables", "The variables of the operation, as a JSON object.", openapi3.NewStringSchema()),
+		},
+		Responses: with(graphQLResponses, "200", &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription("The result of the query, or the stream of results of the subscription.").
+			WithContent(openapi3.Content{
+				"application/json":  openapi3.NewMediaType().WithSchemaRef(graphQLResult),
+				"text/event-stream": openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema()),
+			})})})
+	d.add(http.MethodPost, "/graphql", &openapi3.Operation{OperationID: "graphql", Tags: []string{tagGraphQL},
+		Summary:     "Runs a GraphQL query, mutation or subscription.",
+		RequestBody: body(graphQLRequest),
+		Responses:   graphQLResponses})
+}
+
+// add adds the operation, with the response to request bodies the Validator finds not to match their schema.
+func (d document) add(method, path string, operation *openapi3.Operation) {
+	if operation.RequestBody != nil {
+		operation.Responses["422"] = responseRef(d.Components.Responses, "422")
+	}
+	d.AddOperation(path, method, operation)
+}
+
+// input derives the schema of request bodies from the schema of responses, leaving out the read-only properties.
+func input(schema *openapi3.Schema, required ...string) *openapi3.SchemaRef {
+	properties := make(openapi3.Schemas)
+	for name, property := range schema.Properties {
+		if !property.Value.ReadOnly {
+			properties[name] = property
+		}
+	}
+	return openapi3.NewSchemaRef("", &openapi3.Schema{Type: openapi3.TypeObject, Properties: properties, Required: required})
+}
+
+func componentRef(s schemas, name string) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, s[name].Value)
+}
+
+func arrayOf(items *openapi3.SchemaRef) *openapi3.SchemaRef {
+	schema := openapi3.NewArraySchema()
+	schema.Items = items
+	return openapi3.NewSchemaRef("", schema)
+}
+
+// pageOf is the envelope of paginated listings of items.
+func pageOf(items *openapi3.SchemaRef) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("", object("items").
+		WithPropertyRef("items", arrayOf(items)).
+		WithProperty("next_cursor", described(openapi3.NewStringSchema(),
+			"Passed as cursor to fetch the next page, it is left out on the last one.")))
+}
+
+func parameters() openapi3.ParametersMap {
+	return openapi3.ParametersMap{
+		"resourceID": &openapi3.ParameterRef{Value: openapi3.NewPathParameter("resourceID").
+			WithDescription("The public ID of the resource.").WithSchema(openapi3.NewStringSchema())},
+		"limit": &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("limit").
+			WithDescription("The size of the page.").WithSchema(openapi3.NewIntegerSchema().WithMin(1).WithMax(100).WithDefault(20))},
+		"cursor": &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("cursor").
+			WithDescription("The next_cursor of the previous page.").WithSchema(openapi3.NewStringSchema())},
+	}
+}
+
+func parameterRef(name string) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Ref: "#/components/parameters/" + name, Value: parameters()[name].Value}
+}
+
+func path(name string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Value: openapi3.NewPathParameter(name).WithSchema(schema)}
+}
+
+func query(name, description string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	return &openapi3.ParameterRef{Value: openapi3.NewQueryParameter(name).WithDescription(description).WithSchema(schema)}
+}
+
+func requiredQuery(name, description string, schema *openapi3.Schema) *openapi3.ParameterRef {
+	parameter := query(name, description, schema)
+	parameter.Value.Required = true
+	return parameter
+}
+
+func body(schema *openapi3.SchemaRef) *openapi3.RequestBodyRef {
+	return &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).
+		WithContent(openapi3.NewContentWithJSONSchemaRef(schema))}
+}
+
+func response(description string, schema *openapi3.SchemaRef) *openapi3.ResponseRef {
+	return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(description).
+		WithContent(openapi3.NewContentWithJSONSchemaRef(schema))}
+}
+
+// errorResponses are the responses of the statuses, as well as the 400, 401, 500 and 503 every operation may
+// respond with, the 400 when the request names no tenant it may use.
+func (d document) errorResponses(statuses ...string) openapi3.Responses {
+	all := openapi3.Responses{}
+	for _, status := range append(statuses, "400", "401", "500", "503") {
+		all[status] = responseRef(d.Components.Responses, status)
+	}
+	return all
+}
+
+func (d document) ok(schema *openapi3.SchemaRef, errors ...string) openapi3.Responses {
+	return with(d.errorResponses(errors...), "200", response("OK", schema))
+}
+
+func (d document) created(errors ...string) openapi3.Responses {
+	return with(d.errorResponses(errors...), "201", response("The resource was created.",
+		openapi3.NewSchemaRef("", object("id").
+			WithProperty("id", openapi3.NewStringSchema()))))
+}
+
+func (d document) empty(description string, errors ...string) openapi3.Responses {
+	return with(d.errorResponses(errors...), "200",
+		&openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(description)})
+}
+
+func with(responses openapi3.Responses, status string, response *openapi3.ResponseRef) openapi3.Responses {
+	responses[status] = response
+	return responses
+}
+
+// responses are the error responses, written by http.Error as plain text, except for the requests rejected by
+// the Validator, which are described by validationError.
+func responses(validationError *openapi3.SchemaRef) openapi3.Responses {
+	errorResponse := func(description string) *openapi3.ResponseRef {
+		return &openapi3.ResponseRef{Value: openapi3.NewResponse().WithDescription(description).
+			WithContent(openapi3.NewContentWithSchemaRef(
+				openapi3.NewSchemaRef("#/components/schemas/Error", openapi3.NewStringSchema()), []string{"text/plain"}))}
+	}
+	unavailable := errorResponse("The database breaker is open.")
+	unavailable.Value.Headers = openapi3.Headers{"Retry-After": &openapi3.HeaderRef{Value: &openapi3.Header{
+		Parameter: openapi3.Parameter{Description: "The seconds after which the breaker lets requests through again.",
+			Schema: openapi3.NewSchemaRef("", openapi3.NewIntegerSchema())}}}}
+	invalid := errorResponse("The request is invalid.")
+	invalid.Value.Content["application/json"] = openapi3.NewMediaType().WithSchemaRef(validationError)
+	return openapi3.Responses{
+		"400": invalid,
+		"401": errorResponse("The API key is unknown or revoked, or the token is invalid."),
+		"404": errorResponse("The resource is not found."),
+		"409": errorResponse("The request conflicts with the current state of the resource, or exceeds the quota of the tenant."),
+		"422": &openapi3.ResponseRef{Value: openapi3.NewResponse().
+			WithDescription("The request body does not match its schema.").
+			WithContent(openapi3.NewContentWithJSONSchemaRef(validationError))},
+		"500": errorResponse("The request failed."),
+		"503": unavailable,
+	}
+}
+
+// object is an object schema with the required properties.
+func object(required ...string) *openapi3.Schema {
+	schema := openapi3.NewObjectSchema()
+	schema.Required = required
+	return schema
+}
+
+func described(schema *openapi3.Schema, description string) *openapi3.Schema {
+	schema.Description = description
+	return schema
+}
+
+func responseRef(responses openapi3.Responses, status string) *openapi3.ResponseRef {
+	return &openapi3.ResponseRef{Ref: "#/components/responses/" + status, Value: responses[status].Value}
+}
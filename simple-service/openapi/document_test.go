@@ -0,0 +1,88 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/addme96/simple-go-service/simple-service/openapi"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Document", func() {
+	It("is valid", func() {
+		Expect(openapi.Document().Validate(context.Background())).To(Succeed())
+	})
+
+	It("derives the schemas from the entities", func() {
+		resource := openapi.Document().Components.Schemas["Resource"].Value
+
+		Expect(resource.Properties).To(HaveLen(7))
+		Expect(resource.Properties).To(HaveKey("parent_id"))
+		Expect(resource.Properties["deleted_at"].Value.Format).To(Equal("date-time"))
+		Expect(resource.Properties["deleted_at"].Value.Nullable).To(BeTrue())
+		Expect(resource.Properties["labels"].Value.AdditionalProperties.Schema.Value.Type).To(Equal("string"))
+		Expect(resource.Required).To(ConsistOf("id", "name"))
+	})
+
+	It("leaves read-only properties out of request bodies", func() {
+		input := openapi.Document().Components.Schemas["ResourceInput"].Value
+
+		Expect(input.Properties).To(HaveLen(4))
+		Expect(input.Properties).NotTo(HaveKey("id"))
+		Expect(input.Properties).NotTo(HaveKey("status"))
+		Expect(input.Required).To(ConsistOf("name"))
+	})
+
+	It("refers to the schemas of types referring to themselves", func() {
+		node := openapi.Document().Components.Schemas["ResourceNode"].Value
+
+		Expect(node.Properties["children"].Value.Items.Ref).To(Equal("#/components/schemas/ResourceNode"))
+	})
+})
+
+var _ = Describe("Handler", func() {
+	It("serves the document as OpenAPI 3.1", func() {
+		By("arranging")
+		handler, err := openapi.Handler(openapi.Document())
+		Expect(err).NotTo(HaveOccurred())
+		w := httptest.NewRecorder()
+
+		By("acting")
+		handler(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		By("asserting")
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(w.Body.String()).NotTo(ContainSubstring(`"nullable"`))
+		var document struct {
+			OpenAPI    string `json:"openapi"`
+			Components struct {
+				Schemas map[string]struct {
+					Properties map[string]map[string]interface{} `json:"properties"`
+				} `json:"schemas"`
+			} `json:"components"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &document)).To(Succeed())
+		Expect(document.OpenAPI).To(Equal("3.1.0"))
+		revision := document.Components.Schemas["ResourceRevision"].Properties
+		Expect(revision["previous"]).To(Equal(map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"$ref": "#/components/schemas/Resource"},
+			map[string]interface{}{"type": "null"},
+		}}))
+		deletedAt := document.Components.Schemas["Resource"].Properties["deleted_at"]
+		Expect(deletedAt).To(HaveKeyWithValue("type", []interface{}{"string", "null"}))
+	})
+})
+
+var _ = Describe("SwaggerUI", func() {
+	It("points Swagger UI at the document", func() {
+		w := httptest.NewRecorder()
+
+		openapi.SwaggerUI("/openapi.json")(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+		Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/html"))
+		Expect(w.Body.String()).To(ContainSubstring(`url: "/openapi.json"`))
+	})
+})
@@ -0,0 +1,72 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Handler serves the document as OpenAPI 3.1 JSON, it is encoded once when the handler is made.
+func Handler(document *openapi3.T) (http.HandlerFunc, error) {
+	bytes, err := Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(bytes)
+	}, nil
+}
+
+// Marshal encodes the document in OpenAPI 3.1. Nullable schemas become unions with null, the only
+// keyword the document uses that differs between 3.0 and 3.1.
+func Marshal(document *openapi3.T) ([]byte, error) {
+	bytes, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var converted map[string]interface{}
+	if err = json.Unmarshal(bytes, &converted); err != nil {
+		return nil, err
+	}
+	converted["openapi"] = Version
+	convertNullable(converted)
+	return json.Marshal(converted)
+}
+
+func convertNullable(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if nullable, _ := value["nullable"].(bool); nullable {
+			delete(value, "nullable")
+			if schemaType, ok := value["type"]; ok {
+				value["type"] = []interface{}{schemaType, "null"}
+			} else if allOf, ok := value["allOf"].([]interface{}); ok && len(allOf) == 1 {
+				delete(value, "allOf")
+				value["anyOf"] = []interface{}{allOf[0], map[string]interface{}{"type": "null"}}
+			}
+		}
+		for _, v := range value {
+			convertNullable(v)
+		}
+	case []interface{}:
+		for _, v := range value {
+			convertNullable(v)
+		}
+	}
+}
+
+var swaggerUI = template.Must(template.New("swagger-ui").Parse(swaggerUIPage))
+
+// SwaggerUI serves Swagger UI for the document served at url.
+func SwaggerUI(url string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := swaggerUI.Execute(writer, url); err != nil {
+			http.Error(writer, fmt.Sprintf("rendering Swagger UI failed: %v", err), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas derives component schemas from the JSON encoding of Go types, so that the document follows
+// the entities instead of being kept in sync with them by hand.
+type schemas openapi3.Schemas
+
+// ref returns a reference to the component schema of the type of v, named after the type.
+func (s schemas) ref(v interface{}) *openapi3.SchemaRef {
+	return s.of(reflect.TypeOf(v))
+}
+
+func (s schemas) of(t reflect.Type) *openapi3.SchemaRef {
+	switch {
+	case t == timeType:
+		return openapi3.NewSchemaRef("", openapi3.NewDateTimeSchema())
+	case t == rawMessageType:
+		return openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true, Description: "Any JSON value."})
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		ref := s.of(t.Elem())
+		if ref.Ref != "" {
+			// siblings of $ref are ignored, so the reference is wrapped to make it nullable
+			return openapi3.NewSchemaRef("", &openapi3.Schema{AllOf: openapi3.SchemaRefs{ref}, Nullable: true})
+		}
+		ref.Value.Nullable = true
+		return ref
+	case reflect.Interface:
+		return openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true})
+	case reflect.Bool:
+		return openapi3.NewSchemaRef("", openapi3.NewBoolSchema())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return openapi3.NewSchemaRef("", openapi3.NewIntegerSchema())
+	case reflect.Float32, reflect.Float64:
+		return openapi3.NewSchemaRef("", openapi3.NewFloat64Schema())
+	case reflect.String:
+		return openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+	case reflect.Slice, reflect.Array:
+		schema := openapi3.NewArraySchema()
+		schema.Items = s.of(t.Elem())
+		// nil slices are encoded as null
+		schema.Nullable = t.Kind() == reflect.Slice
+		return openapi3.NewSchemaRef("", schema)
+	case reflect.Map:
+		schema := openapi3.NewObjectSchema()
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Schema: s.of(t.Elem())}
+		schema.Nullable = true
+		return openapi3.NewSchemaRef("", schema)
+	case reflect.Struct:
+		if t.Name() == "" {
+			return openapi3.NewSchemaRef("", s.object(t, openapi3.NewObjectSchema()))
+		}
+		return s.component(t)
+	}
+	return openapi3.NewSchemaRef("", &openapi3.Schema{})
+}
+
+// component returns a reference to the schema of the named struct t, which is added to the components
+// before its fields are, so that types referring to themselves such as entities.ResourceNode end.
+func (s schemas) component(t reflect.Type) *openapi3.SchemaRef {
+	ref := "#/components/schemas/" + t.Name()
+	if component, ok := s[t.Name()]; ok {
+		return openapi3.NewSchemaRef(ref, component.Value)
+	}
+	schema := openapi3.NewObjectSchema()
+	s[t.Name()] = openapi3.NewSchemaRef("", schema)
+	s.object(t, schema)
+	return openapi3.NewSchemaRef(ref, schema)
+}
+
+// object adds the fields of t to schema like encoding/json marshals them: embedded structs are
+// flattened, fields tagged "-" are left out and fields without omitempty are required.
+func (s schemas) object(t reflect.Type, schema *openapi3.Schema) *openapi3.Schema {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.object(field.Type, schema)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.WithPropertyRef(name, s.of(field.Type))
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
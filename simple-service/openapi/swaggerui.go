@@ -0,0 +1,19 @@
+package openapi
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the URL of the document it is executed with.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>simple-go-service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script crossorigin src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{.}}, dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
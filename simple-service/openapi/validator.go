@@ -0,0 +1,240 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/v5"
+)
+
+func init() {
+	// the Swagger UI page is the only response that is neither JSON nor plain text
+	openapi3filter.RegisterBodyDecoder("text/html", func(body io.Reader, _ http.Header, _ *openapi3.SchemaRef,
+		_ openapi3filter.EncodingFn) (interface{}, error) {
+		data, err := io.ReadAll(body)
+		return string(data), err
+	})
+}
+
+// ValidationError is the body of the responses to requests, and of the responses, that do not match the document.
+type ValidationError struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+// FieldError is one way a request does not match the document: a parameter, found by In and Name, or the
+// value at Pointer in the body.
+type FieldError struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Pointer string `json:"pointer,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Validator rejects requests that do not match the operations of Document before they reach the handlers,
+// Routes matches requests to operations the same way the router serving them does.
+type Validator struct {
+	Document *openapi3.T
+	Routes   chi.Routes
+	// Responses are validated too when it is set, those that do not match the document are replaced by a 500.
+	// They are buffered to be validated, which is meant for tests.
+	Responses bool
+}
+
+func NewValidator(document *openapi3.T, routes chi.Routes) *Validator {
+	return &Validator{Document: document, Routes: routes}
+}
+
+// Validate responds with 400 to malformed requests, and with 422 to those whose body only does not match
+// its schema. Requests for operations missing from the document are left to the router.
+func (v *Validator) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route, pathParams := v.route(request)
+		if route == nil {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		input := &openapi3filter.RequestValidationInput{Request: request, PathParams: pathParams, Route: route,
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+				SkipSettingDefaults: true,
+			}}
+		if err := openapi3filter.ValidateRequest(request.Context(), input); err != nil {
+			status, problems := requestProblems(err)
+			writeValidationError(writer, status, "The request does not match the API description.", problems)
+			return
+		}
+		// streams never end, so they cannot be buffered to be validated
+		if !v.Responses || strings.Contains(request.Header.Get("Accept"), "text/event-stream") {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		response := newBufferedResponse()
+		next.ServeHTTP(response, request)
+		err := openapi3filter.ValidateResponse(request.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 response.status,
+			Header:                 response.header,
+			Body:                   io.NopCloser(bytes.NewReader(response.body.Bytes())),
+			Options:                &openapi3filter.Options{MultiError: true, IncludeResponseStatus: true},
+		})
+		if err != nil {
+			writeValidationError(writer, http.StatusInternalServerError, "The response does not match the API description.",
+				problems("response", "", err))
+			return
+		}
+		response.writeTo(writer)
+	})
+}
+
+// route finds the operation of the request, with its path parameters.
+func (v *Validator) route(request *http.Request) (*routers.Route, map[string]string) {
+	rctx := chi.NewRouteContext()
+	if !v.Routes.Match(rctx, request.Method, request.URL.Path) {
+		return nil, nil
+	}
+	pattern := rctx.RoutePattern()
+	if pattern != "/" {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	pathItem := v.Document.Paths.Find(pattern)
+	if pathItem == nil || pathItem.GetOperation(request.Method) == nil {
+		return nil, nil
+	}
+	pathParams := make(map[string]string, len(rctx.URLParams.Keys))
+	for i, key := range rctx.URLParams.Keys {
+		pathParams[key] = rctx.URLParams.Values[i]
+	}
+	return &routers.Route{Spec: v.Document, Path: pattern, PathItem: pathItem, Method: request.Method,
+		Operation: pathItem.GetOperation(request.Method)}, pathParams
+}
+
+// requestProblems tells whether the request is malformed or only its body does not match its schema.
+func requestProblems(err error) (int, []FieldError) {
+	status := http.StatusUnprocessableEntity
+	var all []FieldError
+	for _, err := range flatten(err) {
+		var requestError *openapi3filter.RequestError
+		if !errors.As(err, &requestError) {
+			status = http.StatusBadRequest
+			all = append(all, FieldError{In: "request", Reason: err.Error()})
+			continue
+		}
+		if requestError.Parameter != nil {
+			status = http.StatusBadRequest
+			all = append(all, problems(requestError.Parameter.In, requestError.Parameter.Name, cause(requestError))...)
+			continue
+		}
+		var schemaError *openapi3.SchemaError
+		if !errors.As(requestError.Err, &schemaError) {
+			// a missing body, an unexpected Content-Type or a body that is not JSON
+			status = http.StatusBadRequest
+		}
+		all = append(all, problems("body", "", cause(requestError))...)
+	}
+	return status, all
+}
+
+func cause(requestError *openapi3filter.RequestError) error {
+	if requestError.Err == nil {
+		return errors.New(requestError.Reason)
+	}
+	return requestError.Err
+}
+
+func problems(in, name string, err error) []FieldError {
+	var all []FieldError
+	for _, err := range flatten(err) {
+		problem := FieldError{In: in, Name: name, Reason: err.Error()}
+		var schemaError *openapi3.SchemaError
+		var responseError *openapi3filter.ResponseError
+		switch {
+		case errors.As(err, &responseError) && responseError.Err != nil:
+			all = append(all, problems(in, name, responseError.Err)...)
+			continue
+		case errors.As(err, &responseError):
+			problem.Reason = responseError.Reason
+		case errors.As(err, &schemaError):
+			problem.Reason = schemaError.Reason
+			problem.Pointer = pointer(schemaError.JSONPointer())
+		}
+		all = append(all, problem)
+	}
+	return all
+}
+
+func flatten(err error) []error {
+	// not errors.As, which would look through the RequestError wrapping a MultiError of its own
+	multiError, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	var all []error
+	for _, err := range multiError {
+		all = append(all, flatten(err)...)
+	}
+	return all
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func pointer(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	for _, token := range tokens {
+		builder.WriteString("/" + pointerEscaper.Replace(token))
+	}
+	return builder.String()
+}
+
+func writeValidationError(writer http.ResponseWriter, status int, message string, problems []FieldError) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(ValidationError{Message: message, Errors: problems})
+}
+
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *bufferedResponse) Write(bytes []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(bytes)
+}
+
+func (r *bufferedResponse) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *bufferedResponse) writeTo(writer http.ResponseWriter) {
+	for key, values := range r.header {
+		writer.Header()[key] = values
+	}
+	writer.WriteHeader(r.status)
+	writer.Write(r.body.Bytes())
+}
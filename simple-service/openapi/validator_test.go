@@ -0,0 +1,187 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/openapi"
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validator", func() {
+	var (
+		validator *openapi.Validator
+		router    chi.Router
+		reached   bool
+		body      []byte
+		response  string
+	)
+
+	BeforeEach(func() {
+		reached, body = false, nil
+		response = `{"id": "0190b7e0-0000-7000-8000-000000000000"}`
+		router = chi.NewRouter()
+		validator = openapi.NewValidator(openapi.Document(), router)
+		router.Use(validator.Validate)
+		respond := func(status int) http.HandlerFunc {
+			return func(writer http.ResponseWriter, request *http.Request) {
+				reached = true
+				body, _ = io.ReadAll(request.Body)
+				writer.Header().Set("Content-Type", "application/json")
+				writer.WriteHeader(status)
+				writer.Write([]byte(response))
+			}
+		}
+		router.Route("/resources", func(r chi.Router) {
+			r.Post("/", respond(http.StatusCreated))
+			r.Get("/search", respond(http.StatusOK))
+			r.Get("/{resourceID}/revisions/{revision}", respond(http.StatusTeapot))
+		})
+		router.Post("/undocumented", respond(http.StatusOK))
+	})
+
+	serve := func(request *http.Request) (*httptest.ResponseRecorder, openapi.ValidationError) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		var validationError openapi.ValidationError
+		if w.Header().Get("Content-Type") == "application/json" && w.Code >= http.StatusBadRequest {
+			Expect(json.Unmarshal(w.Body.Bytes(), &validationError)).To(Succeed())
+		}
+		return w, validationError
+	}
+
+	post := func(path, contentType, body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", contentType)
+		return request
+	}
+
+	It("passes valid requests through with their body", func() {
+		By("acting")
+		w, _ := serve(post("/resources", "application/json", `{"name": "a", "labels": {"env": "prod"}}`))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusCreated))
+		Expect(reached).To(BeTrue())
+		Expect(body).To(MatchJSON(`{"name": "a", "labels": {"env": "prod"}}`))
+	})
+
+	It("rejects query parameters out of their bounds with 400", func() {
+		By("acting")
+		w, validationError := serve(httptest.NewRequest(http.MethodGet, "/resources/search?q=a&limit=1000", nil))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(reached).To(BeFalse())
+		Expect(validationError.Errors).To(HaveLen(1))
+		Expect(validationError.Errors[0].In).To(Equal("query"))
+		Expect(validationError.Errors[0].Name).To(Equal("limit"))
+	})
+
+	It("rejects missing required query parameters with 400", func() {
+		By("acting")
+		w, validationError := serve(httptest.NewRequest(http.MethodGet, "/resources/search", nil))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(validationError.Errors).To(ConsistOf(openapi.FieldError{In: "query", Name: "q",
+			Reason: "value is required but missing"}))
+	})
+
+	It("rejects path parameters of the wrong type with 400", func() {
+		By("acting")
+		w, validationError := serve(httptest.NewRequest(http.MethodGet, "/resources/a/revisions/first", nil))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(validationError.Errors).To(HaveLen(1))
+		Expect(validationError.Errors[0].In).To(Equal("path"))
+		Expect(validationError.Errors[0].Name).To(Equal("revision"))
+	})
+
+	It("rejects bodies that do not match their schema with 422, pointing at the values", func() {
+		By("acting")
+		w, validationError := serve(post("/resources", "application/json", `{"name": 5, "labels": {"env": 1}}`))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(reached).To(BeFalse())
+		var pointers []string
+		for _, fieldError := range validationError.Errors {
+			Expect(fieldError.In).To(Equal("body"))
+			pointers = append(pointers, fieldError.Pointer)
+		}
+		Expect(pointers).To(ConsistOf("/name", "/labels/env"))
+	})
+
+	It("rejects bodies that are not JSON with 400", func() {
+		By("acting")
+		w, validationError := serve(post("/resources", "application/json", `{"name": `))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(validationError.Errors).To(HaveLen(1))
+		Expect(validationError.Errors[0].In).To(Equal("body"))
+	})
+
+	It("rejects bodies of other content types with 400", func() {
+		By("acting")
+		w, _ := serve(post("/resources", "text/plain", `name`))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+		Expect(reached).To(BeFalse())
+	})
+
+	It("leaves requests for undocumented operations to the router", func() {
+		By("acting")
+		w, _ := serve(post("/undocumented", "text/plain", `anything`))
+
+		By("asserting")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(reached).To(BeTrue())
+	})
+
+	Context("validating responses", func() {
+		BeforeEach(func() {
+			validator.Responses = true
+		})
+
+		It("passes responses matching the document through", func() {
+			By("acting")
+			w, _ := serve(post("/resources", "application/json", `{"name": "a"}`))
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusCreated))
+			Expect(w.Body.String()).To(MatchJSON(response))
+		})
+
+		It("replaces responses that do not match the document with 500", func() {
+			By("arranging")
+			response = `{"id": 5}`
+
+			By("acting")
+			w, validationError := serve(post("/resources", "application/json", `{"name": "a"}`))
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(validationError.Errors).To(ConsistOf(openapi.FieldError{In: "response", Pointer: "/id",
+				Reason: `value must be a string`}))
+		})
+
+		It("replaces responses of undocumented statuses with 500", func() {
+			By("acting")
+			w, validationError := serve(httptest.NewRequest(http.MethodGet, "/resources/a/revisions/1", nil))
+
+			By("asserting")
+			Expect(w.Code).To(Equal(http.StatusInternalServerError))
+			Expect(validationError.Errors).To(ConsistOf(openapi.FieldError{In: "response",
+				Reason: "status is not supported"}))
+		})
+	})
+})
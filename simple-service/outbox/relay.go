@@ -0,0 +1,153 @@
+//go:generate mockgen -destination=mocks/outbox.go -package mocks . DB,Sink
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+)
+
+type DB interface {
+	GetConn(ctx context.Context) (database.PgxConn, error)
+}
+
+type Event struct {
+	ID          int64           `json:"id"`
+	Tenant      string          `json:"tenant,omitempty"`
+	AggregateID int             `json:"aggregate_id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Sink receives every event at least once, consumers should deduplicate on Event.Tenant and Event.ID,
+// as every tenant numbers its events on its own.
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Only the oldest pending event of every aggregate is claimed, so events of a
+// resource are published in order even with several relays polling concurrently.
+const claimBatch = `SELECT id, aggregate_id, event_type, payload, created_at, attempts FROM outbox o
+WHERE published_at IS NULL AND next_attempt_at <= now()
+AND NOT EXISTS (
+SELECT 1 FROM outbox p WHERE p.aggregate_id = o.aggregate_id AND p.published_at IS NULL AND p.id < o.id
+)
+ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`
+
+const (
+	markPublished = "UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1"
+	markFailed    = "UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1"
+)
+
+type Relay struct {
+	db           DB
+	sink         Sink
+	BatchSize    int
+	PollInterval time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+}
+
+func NewRelay(db DB, sink Sink) *Relay {
+	return &Relay{
+		db:           db,
+		sink:         sink,
+		BatchSize:    100,
+		PollInterval: time.Second,
+		MinBackoff:   time.Second,
+		MaxBackoff:   5 * time.Minute,
+	}
+}
+
+// Run relays events until ctx is cancelled, polling again right away while there is a backlog.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		n, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Outbox relay failed: %v\n", err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// RelayBatch claims a batch of pending events, publishes them and returns how many were claimed.
+// Failed events are rescheduled with exponential backoff and block later events of the same aggregate.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(ctx)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	events, attempts, err := r.claim(ctx, tx)
+	if err != nil {
+		tx.Rollback(ctx)
+		return 0, err
+	}
+	for i, event := range events {
+		if err = r.sink.Publish(ctx, event); err != nil {
+			_, err = tx.Exec(ctx, markFailed, event.ID, err.Error(), time.Now().Add(r.backoff(attempts[i])))
+		} else {
+			_, err = tx.Exec(ctx, markPublished, event.ID)
+		}
+		if err != nil {
+			tx.Rollback(ctx)
+			return 0, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+func (r *Relay) claim(ctx context.Context, tx pgx.Tx) ([]Event, []int, error) {
+	rows, err := tx.Query(ctx, claimBatch, r.BatchSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	tenant, _ := tenancy.TenantFromContext(ctx)
+	var events []Event
+	var attempts []int
+	for rows.Next() {
+		event := Event{Tenant: tenant}
+		var payload []byte
+		var attempt int
+		if err = rows.Scan(&event.ID, &event.AggregateID, &event.Type, &payload, &event.CreatedAt, &attempt); err != nil {
+			return nil, nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+		attempts = append(attempts, attempt)
+	}
+	return events, attempts, rows.Err()
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.MinBackoff
+	for i := 0; i < attempts && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return backoff
+}
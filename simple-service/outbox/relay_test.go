@@ -0,0 +1,211 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/outbox"
+	"github.com/addme96/simple-go-service/simple-service/outbox/mocks"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Relay", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		mockSink *mocks.MockSink
+		relay    *outbox.Relay
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+	columns := []string{"id", "aggregate_id", "event_type", "payload", "created_at", "attempts"}
+	createdAt := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	claimQuery := regexp.QuoteMeta("FROM outbox o") + ".*" + regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")
+	publishedQuery := regexp.QuoteMeta("UPDATE outbox SET published_at = now()")
+	failedQuery := regexp.QuoteMeta("UPDATE outbox SET attempts = attempts + 1, last_error = $2")
+	expectedErr := errors.New("some error")
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		mockSink = mocks.NewMockSink(ctrl)
+		relay = outbox.NewRelay(mockDB, mockSink)
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+	})
+
+	Context("RelayBatch", func() {
+		When("happy path", func() {
+			It("publishes the claimed events and marks them published", func() {
+				By("arranging")
+				mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				mockConn.ExpectQuery(claimQuery).WithArgs(100).WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(int64(1), 7, "resource.created", []byte(`{"id":7}`), createdAt, 0).
+					AddRow(int64(2), 8, "resource.deleted", []byte(`{"id":8}`), createdAt, 0))
+				gomock.InOrder(
+					mockSink.EXPECT().Publish(ctx, outbox.Event{
+						ID: 1, AggregateID: 7, Type: "resource.created", Payload: json.RawMessage(`{"id":7}`), CreatedAt: createdAt,
+					}).Return(nil),
+					mockSink.EXPECT().Publish(ctx, outbox.Event{
+						ID: 2, AggregateID: 8, Type: "resource.deleted", Payload: json.RawMessage(`{"id":8}`), CreatedAt: createdAt,
+					}).Return(nil),
+				)
+				mockConn.ExpectExec(publishedQuery).WithArgs(int64(1)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mockConn.ExpectExec(publishedQuery).WithArgs(int64(2)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mockConn.ExpectCommit()
+				mockConn.ExpectClose()
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n).To(Equal(2))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("names the tenant of the events it relays", func() {
+				By("arranging")
+				ctx = tenancy.WithTenant(ctx, "acme")
+				mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				mockConn.ExpectQuery(claimQuery).WithArgs(100).WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(int64(1), 7, "resource.created", []byte(`{"id":7}`), createdAt, 0))
+				mockSink.EXPECT().Publish(ctx, outbox.Event{
+					ID: 1, Tenant: "acme", AggregateID: 7, Type: "resource.created", Payload: json.RawMessage(`{"id":7}`),
+					CreatedAt: createdAt,
+				}).Return(nil)
+				mockConn.ExpectExec(publishedQuery).WithArgs(int64(1)).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mockConn.ExpectCommit()
+				mockConn.ExpectClose()
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n).To(Equal(1))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("does nothing when there are no pending events", func() {
+				By("arranging")
+				mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				mockConn.ExpectQuery(claimQuery).WillReturnRows(pgxmock.NewRows(columns))
+				mockConn.ExpectCommit()
+				mockConn.ExpectClose()
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n).To(Equal(0))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+		})
+
+		When("the sink fails", func() {
+			It("reschedules the event with backoff", func() {
+				By("arranging")
+				mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				mockConn.ExpectQuery(claimQuery).WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(int64(1), 7, "resource.created", []byte(`{"id":7}`), createdAt, 3))
+				mockSink.EXPECT().Publish(ctx, gomock.Any()).Return(expectedErr)
+				mockConn.ExpectExec(failedQuery).
+					WithArgs(int64(1), expectedErr.Error(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+				mockConn.ExpectCommit()
+				mockConn.ExpectClose()
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n).To(Equal(1))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+		})
+
+		When("the database fails", func() {
+			It("returns GetConn error", func() {
+				By("arranging")
+				mockDB.EXPECT().GetConn(ctx).Return(nil, expectedErr)
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).To(Equal(expectedErr))
+				Expect(n).To(Equal(0))
+			})
+
+			It("rolls back when claiming fails", func() {
+				By("arranging")
+				mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				mockConn.ExpectQuery(claimQuery).WillReturnError(expectedErr)
+				mockConn.ExpectRollback()
+				mockConn.ExpectClose()
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).To(Equal(expectedErr))
+				Expect(n).To(Equal(0))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("rolls back when marking fails so the event is retried", func() {
+				By("arranging")
+				mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				mockConn.ExpectQuery(claimQuery).WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(int64(1), 7, "resource.created", []byte(`{"id":7}`), createdAt, 0))
+				mockSink.EXPECT().Publish(ctx, gomock.Any()).Return(nil)
+				mockConn.ExpectExec(publishedQuery).WillReturnError(expectedErr)
+				mockConn.ExpectRollback()
+				mockConn.ExpectClose()
+
+				By("acting")
+				n, err := relay.RelayBatch(ctx)
+
+				By("asserting")
+				Expect(err).To(Equal(expectedErr))
+				Expect(n).To(Equal(0))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+		})
+	})
+
+	Context("Run", func() {
+		It("stops when the context is cancelled", func() {
+			By("arranging")
+			ctx, cancel := context.WithCancel(ctx)
+			relay.PollInterval = time.Hour
+			mockDB.EXPECT().GetConn(ctx).DoAndReturn(func(context.Context) (database.PgxConn, error) {
+				cancel()
+				return nil, expectedErr
+			})
+
+			By("acting")
+			err := relay.Run(ctx)
+
+			By("asserting")
+			Expect(err).To(Equal(context.Canceled))
+		})
+	})
+})
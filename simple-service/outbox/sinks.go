@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSinkTimeout bounds the requests of webhook sinks, the relay holds the locks of the events it publishes
+// until the sink returns.
+const DefaultSinkTimeout = 10 * time.Second
+
+// WriterSink writes every event as a line of JSON.
+type WriterSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{writer: writer}
+}
+
+func (s *WriterSink) Publish(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
+
+// NewFileSink appends events to the file at path, creating it if needed.
+func NewFileSink(path string) (*WriterSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(file), nil
+}
+
+// WebhookSink POSTs every event to a URL, any non-2xx response is a failed delivery.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	return &WebhookSink{url: url, client: client}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Idempotency-Key", idempotencyKey(event))
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", response.Status)
+	}
+	return nil
+}
+
+// idempotencyKey is unique across tenants, whose outboxes number their events independently.
+func idempotencyKey(event Event) string {
+	key := strconv.FormatInt(event.ID, 10)
+	if event.Tenant != "" {
+		key = event.Tenant + "/" + key
+	}
+	return key
+}
+
+// Fanout publishes every event to all of its sinks, an event is only published
+// once every sink accepted it so a failing sink gets it retried on all of them.
+type Fanout []Sink
+
+func (f Fanout) Publish(ctx context.Context, event Event) error {
+	for _, sink := range f {
+		if err := sink.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseSink builds a sink from its configuration: "stdout", "file:<path>" or an http(s) URL, which is given
+// timeout to respond.
+func ParseSink(spec string, timeout time.Duration) (Sink, error) {
+	switch {
+	case spec == "stdout":
+		return NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSink(strings.TrimPrefix(spec, "file:"))
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return NewWebhookSink(spec, &http.Client{Timeout: timeout}), nil
+	}
+	return nil, fmt.Errorf("unsupported outbox sink %q", spec)
+}
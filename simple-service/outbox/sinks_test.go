@@ -0,0 +1,147 @@
+package outbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/outbox"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+var _ = Describe("Sinks", func() {
+	ctx := context.Background()
+	event := outbox.Event{ID: 42, AggregateID: 7, Type: "resource.updated", Payload: json.RawMessage(`{"id":7}`)}
+
+	Context("WriterSink", func() {
+		It("writes events as JSON lines", func() {
+			var buffer bytes.Buffer
+			sink := outbox.NewWriterSink(&buffer)
+
+			Expect(sink.Publish(ctx, event)).To(Succeed())
+			Expect(sink.Publish(ctx, event)).To(Succeed())
+
+			line, err := json.Marshal(event)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buffer.String()).To(Equal(string(line) + "\n" + string(line) + "\n"))
+		})
+	})
+
+	Context("FileSink", func() {
+		It("appends events to the file", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "events.jsonl")
+			sink, err := outbox.NewFileSink(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sink.Publish(ctx, event)).To(Succeed())
+
+			content, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"type":"resource.updated"`))
+		})
+	})
+
+	Context("WebhookSink", func() {
+		It("posts the event with its ID as idempotency key", func() {
+			var received outbox.Event
+			var idempotencyKey string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				idempotencyKey = r.Header.Get("Idempotency-Key")
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &received)
+			}))
+			defer server.Close()
+
+			Expect(outbox.NewWebhookSink(server.URL, server.Client()).Publish(ctx, event)).To(Succeed())
+			Expect(idempotencyKey).To(Equal("42"))
+			Expect(received).To(Equal(event))
+		})
+
+		It("scopes the idempotency key to the tenant of the event", func() {
+			var idempotencyKey string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				idempotencyKey = r.Header.Get("Idempotency-Key")
+			}))
+			defer server.Close()
+			scoped := event
+			scoped.Tenant = "acme"
+
+			Expect(outbox.NewWebhookSink(server.URL, server.Client()).Publish(ctx, scoped)).To(Succeed())
+			Expect(idempotencyKey).To(Equal("acme/42"))
+		})
+
+		It("fails on non-2xx responses", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer server.Close()
+
+			err := outbox.NewWebhookSink(server.URL, server.Client()).Publish(ctx, event)
+			Expect(err).To(MatchError("webhook responded with 502 Bad Gateway"))
+		})
+	})
+
+	Context("Fanout", func() {
+		It("publishes to every sink and stops at the first failure", func() {
+			var first, second bytes.Buffer
+			failing := outbox.NewWriterSink(failingWriter{})
+
+			Expect(outbox.Fanout{outbox.NewWriterSink(&first), outbox.NewWriterSink(&second)}.Publish(ctx, event)).
+				To(Succeed())
+			Expect(first.String()).NotTo(BeEmpty())
+			Expect(second.String()).To(Equal(first.String()))
+
+			first.Reset()
+			err := outbox.Fanout{failing, outbox.NewWriterSink(&first)}.Publish(ctx, event)
+			Expect(err).To(MatchError("disk full"))
+			Expect(first.String()).To(BeEmpty())
+		})
+	})
+
+	Context("ParseSink", func() {
+		It("builds sinks from their configuration", func() {
+			sink, err := outbox.ParseSink("stdout", outbox.DefaultSinkTimeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sink).To(BeAssignableToTypeOf(&outbox.WriterSink{}))
+
+			sink, err = outbox.ParseSink("https://example.com/events", outbox.DefaultSinkTimeout)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sink).To(BeAssignableToTypeOf(&outbox.WebhookSink{}))
+
+			_, err = outbox.ParseSink("kafka://broker", outbox.DefaultSinkTimeout)
+			Expect(err).To(MatchError(`unsupported outbox sink "kafka://broker"`))
+		})
+
+		It("gives up on webhooks that do not respond in time", func() {
+			By("arranging")
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}))
+			defer server.Close()
+			defer close(release)
+			sink, err := outbox.ParseSink(server.URL, 10*time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("acting")
+			err = sink.Publish(ctx, outbox.Event{ID: 1})
+
+			By("asserting")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+// apiKeyPrefix starts every secret, so that leaked keys are easy to scan for.
+const apiKeyPrefix = "sgs_"
+
+type APIKey struct {
+	db DB
+}
+
+func NewAPIKey(db DB) *APIKey {
+	return &APIKey{db: db}
+}
+
+// Create makes a key for principal and returns it with its secret, which cannot be read again.
+func (r APIKey) Create(ctx context.Context, principal string) (*entities.APIKey, string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", err
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(random)
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close(ctx)
+	key := entities.APIKey{Principal: principal, Prefix: secret[:len(apiKeyPrefix)+8]}
+	err = conn.QueryRow(ctx,
+		"INSERT INTO api_keys (principal, prefix, hash) VALUES ($1, $2, $3) RETURNING id, created_at",
+		key.Principal, key.Prefix, hashAPIKey(secret)).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return &key, secret, nil
+}
+
+func (r APIKey) ReadAll(ctx context.Context) ([]entities.APIKey, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, "SELECT id, principal, prefix, created_at, revoked_at FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := make([]entities.APIKey, 0)
+	for rows.Next() {
+		var key entities.APIKey
+		if err = rows.Scan(&key.ID, &key.Principal, &key.Prefix, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke stops the key from authenticating, it returns pgx.ErrNoRows when there is no such key left to revoke.
+func (r APIKey) Revoke(ctx context.Context, id int) error {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	tag, err := conn.Exec(ctx, "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Authenticate returns the principal of the key with secret, or pgx.ErrNoRows when it is unknown or revoked.
+func (r APIKey) Authenticate(ctx context.Context, secret string) (string, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(ctx)
+	var principal string
+	err = conn.QueryRow(ctx, "SELECT principal FROM api_keys WHERE hash = $1 AND revoked_at IS NULL",
+		hashAPIKey(secret)).Scan(&principal)
+	if err != nil {
+		return "", err
+	}
+	return principal, nil
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
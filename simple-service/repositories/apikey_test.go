@@ -0,0 +1,169 @@
+package repositories_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("APIKey", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.APIKey
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewAPIKey(mockDB)
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+	})
+
+	expectedErr := errors.New("some error")
+	now := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+	hash := func(secret string) string {
+		sum := sha256.Sum256([]byte(secret))
+		return hex.EncodeToString(sum[:])
+	}
+
+	Context("Create", func() {
+		query := "INSERT INTO api_keys (principal, prefix, hash) VALUES ($1, $2, $3) RETURNING id, created_at"
+
+		It("stores the hash of a new secret", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("ops", pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(pgxmock.NewRows([]string{"id", "created_at"}).AddRow(3, now))
+			mockConn.ExpectClose()
+
+			By("acting")
+			key, secret, err := repo.Create(ctx, "ops")
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret).To(MatchRegexp("^sgs_[0-9a-f]{48}$"))
+			Expect(*key).To(Equal(entities.APIKey{ID: 3, Principal: "ops", Prefix: secret[:12], CreatedAt: now}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns Query error", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(expectedErr)
+			mockConn.ExpectClose()
+
+			By("acting")
+			key, secret, err := repo.Create(ctx, "ops")
+
+			By("asserting")
+			Expect(err).To(Equal(expectedErr))
+			Expect(key).To(BeNil())
+			Expect(secret).To(BeEmpty())
+		})
+	})
+
+	Context("ReadAll", func() {
+		It("returns the keys", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(
+				"SELECT id, principal, prefix, created_at, revoked_at FROM api_keys ORDER BY id")).
+				WillReturnRows(pgxmock.NewRows([]string{"id", "principal", "prefix", "created_at", "revoked_at"}).
+					AddRow(1, "ops", "sgs_0123abcd", now, &now).
+					AddRow(2, "ci", "sgs_4567efab", now, nil))
+			mockConn.ExpectClose()
+
+			By("acting")
+			keys, err := repo.ReadAll(ctx)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]entities.APIKey{
+				{ID: 1, Principal: "ops", Prefix: "sgs_0123abcd", CreatedAt: now, RevokedAt: &now},
+				{ID: 2, Principal: "ci", Prefix: "sgs_4567efab", CreatedAt: now},
+			}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Revoke", func() {
+		query := "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL"
+
+		It("revokes the key", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta(query)).WithArgs(1).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Revoke(ctx, 1)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns ErrNoRows when there is no key to revoke", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta(query)).WithArgs(1).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Revoke(ctx, 1)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+		})
+	})
+
+	Context("Authenticate", func() {
+		query := "SELECT principal FROM api_keys WHERE hash = $1 AND revoked_at IS NULL"
+
+		It("returns the principal of the key", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(hash("sgs_secret")).
+				WillReturnRows(pgxmock.NewRows([]string{"principal"}).AddRow("ops"))
+			mockConn.ExpectClose()
+
+			By("acting")
+			principal, err := repo.Authenticate(ctx, "sgs_secret")
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(principal).To(Equal("ops"))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns ErrNoRows for unknown keys", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(hash("sgs_secret")).WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectClose()
+
+			By("acting")
+			principal, err := repo.Authenticate(ctx, "sgs_secret")
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(principal).To(BeEmpty())
+		})
+	})
+})
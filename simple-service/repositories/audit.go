@@ -0,0 +1,235 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// entries recorded before resource_public_id name their resource by resource_id alone
+	auditColumns = "id, principal, remote_addr, request_id, method, route, resource_id, resource_public_id::text, " +
+		"(SELECT public_id::text FROM resources WHERE resources.id = audit_log.resource_id), before, after, created_at, prev_hash, hash"
+	// genesisHash is the PrevHash of the first entry of the chain.
+	genesisHash = "genesis"
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type Audit struct {
+	db  DB
+	Now func() time.Time
+}
+
+func NewAudit(db DB) *Audit {
+	return &Audit{db: db, Now: time.Now}
+}
+
+// Record appends entry to the audit chain in the transaction carried by ctx, so that it is kept if
+// and only if the request it describes is committed. Before and After are taken from the resource
+// revisions written by that transaction, as is ResourceID when the request did not name one by its public ID.
+// The chain is extended under an advisory lock keyed by the schema, the unique prev_hash rejects forks that could still
+// happen under snapshot isolation.
+func (a Audit) Record(ctx context.Context, entry entities.AuditEntry) error {
+	return inTx(ctx, a.db, func(q database.Querier) error {
+		if _, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('audit_log'))"); err != nil {
+			return err
+		}
+		rows, err := q.Query(ctx, `SELECT (SELECT public_id::text FROM resources WHERE resources.id = resource_revisions.resource_id),
+previous, state FROM resource_revisions WHERE txid = txid_current() ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for first := true; rows.Next(); first = false {
+			var resourceID *string
+			var previous, state []byte
+			if err = rows.Scan(&resourceID, &previous, &state); err != nil {
+				return err
+			}
+			if first {
+				entry.Before = previous
+				if entry.ResourceID == nil {
+					entry.ResourceID = resourceID
+				}
+			}
+			entry.After = state
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		entry.PrevHash = genesisHash
+		err = q.QueryRow(ctx, "SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&entry.PrevHash)
+		if err != nil && err != pgx.ErrNoRows {
+			return err
+		}
+		entry.CreatedAt = a.Now().UTC().Truncate(time.Microsecond)
+		if entry.Hash, err = hashAuditEntry(entry, nil); err != nil {
+			return err
+		}
+		_, err = q.Exec(ctx, `INSERT INTO audit_log (principal, remote_addr, request_id, method, route, resource_id, resource_public_id,
+before, after, created_at, prev_hash, hash)
+VALUES ($1, $2, $3, $4, $5, (SELECT id FROM resources WHERE public_id = $6::uuid), $6::uuid, $7, $8, $9, $10, $11)`,
+			entry.Principal, entry.RemoteAddr, entry.RequestID, entry.Method, entry.Route, entry.ResourceID,
+			nullJSON(entry.Before), nullJSON(entry.After), entry.CreatedAt, entry.PrevHash, entry.Hash)
+		return err
+	})
+}
+
+func (a Audit) Query(ctx context.Context, filter entities.AuditFilter) ([]entities.AuditEntry, error) {
+	q, release, err := querier(ctx, a.db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Principal != "" {
+		where("principal = $%d", filter.Principal)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < $%d", filter.Until)
+	}
+	if filter.ResourceID != "" {
+		// entries recorded before resource_public_id only have the resource_id of a resource that is still there
+		where("(resource_public_id = $%[1]d::uuid OR resource_public_id IS NULL AND "+
+			"resource_id = (SELECT id FROM resources WHERE public_id = $%[1]d::uuid))", filter.ResourceID)
+	}
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > maxAuditLimit {
+		limit = defaultAuditLimit
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := make([]entities.AuditEntry, 0)
+	for rows.Next() {
+		entry, _, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Verify walks the whole chain and reports the first entry whose hash does not match its contents
+// or whose PrevHash does not match the entry before it.
+func (a Audit) Verify(ctx context.Context) (*entities.AuditVerification, error) {
+	q, release, err := querier(ctx, a.db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := q.Query(ctx, "SELECT "+auditColumns+" FROM audit_log ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	verification := &entities.AuditVerification{}
+	prevHash := genesisHash
+	for rows.Next() {
+		entry, legacyID, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		verification.Checked++
+		if entry.PrevHash != prevHash {
+			verification.BrokenAt, verification.Reason = entry.ID, "previous hash does not match the preceding entry"
+			return verification, nil
+		}
+		hash, err := hashAuditEntry(entry, legacyID)
+		if err != nil {
+			return nil, err
+		}
+		if hash != entry.Hash {
+			verification.BrokenAt, verification.Reason = entry.ID, "hash does not match the contents of the entry"
+			return verification, nil
+		}
+		prevHash = entry.Hash
+	}
+	return verification, rows.Err()
+}
+
+// hashAuditEntry hashes every field of entry but its ID and Hash, which are only known once it is stored.
+// Entries recorded before resources had public IDs were hashed with the legacyID of their resource instead.
+func hashAuditEntry(entry entities.AuditEntry, legacyID *int) (string, error) {
+	// the fields in the order entries were first hashed in, so that the chain stays verifiable
+	hashed := struct {
+		ID         int64           `json:"id"`
+		Principal  string          `json:"principal"`
+		RemoteAddr string          `json:"remote_addr"`
+		RequestID  string          `json:"request_id"`
+		Method     string          `json:"method"`
+		Route      string          `json:"route"`
+		ResourceID interface{}     `json:"resource_id"`
+		Before     json.RawMessage `json:"before"`
+		After      json.RawMessage `json:"after"`
+		CreatedAt  time.Time       `json:"created_at"`
+		PrevHash   string          `json:"prev_hash"`
+		Hash       string          `json:"hash"`
+	}{Principal: entry.Principal, RemoteAddr: entry.RemoteAddr, RequestID: entry.RequestID, Method: entry.Method,
+		Route: entry.Route, ResourceID: entry.ResourceID, Before: entry.Before, After: entry.After,
+		CreatedAt: entry.CreatedAt.UTC(), PrevHash: entry.PrevHash}
+	if legacyID != nil {
+		hashed.ResourceID = legacyID
+	}
+	bytes, err := json.Marshal(hashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// scanAuditEntry also returns the legacy ID the entry is hashed with, if it was recorded before resource_public_id.
+func scanAuditEntry(rows pgx.Rows) (entities.AuditEntry, *int, error) {
+	var entry entities.AuditEntry
+	var resourceID *int
+	var publicID, currentPublicID *string
+	var before, after []byte
+	err := rows.Scan(&entry.ID, &entry.Principal, &entry.RemoteAddr, &entry.RequestID, &entry.Method, &entry.Route,
+		&resourceID, &publicID, &currentPublicID, &before, &after, &entry.CreatedAt, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return entry, nil, err
+	}
+	entry.Before, entry.After = before, after
+	if publicID != nil {
+		entry.ResourceID = publicID
+		return entry, nil, nil
+	}
+	entry.ResourceID = currentPublicID
+	return entry, resourceID, nil
+}
+
+// nullJSON stores an absent state as SQL NULL rather than a JSON null.
+func nullJSON(raw json.RawMessage) interface{} {
+	if raw == nil {
+		return nil
+	}
+	return []byte(raw)
+}
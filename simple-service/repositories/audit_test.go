@@ -0,0 +1,233 @@
+package repositories_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+// capture is a pgxmock argument matcher that keeps the argument it was given.
+type capture struct {
+	value interface{}
+}
+
+func (c *capture) Match(value interface{}) bool {
+	c.value = value
+	return true
+}
+
+var _ = Describe("Audit", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.Audit
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	now := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+	columns := []string{"id", "principal", "remote_addr", "request_id", "method", "route", "resource_id",
+		"resource_public_id", "current_public_id", "before", "after", "created_at", "prev_hash", "hash"}
+	revisionsQuery := "FROM resource_revisions WHERE txid = txid_current() ORDER BY id"
+	revisionColumns := []string{"public_id", "previous", "state"}
+	insertQuery := "INSERT INTO audit_log"
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewAudit(mockDB)
+		repo.Now = func() time.Time { return now }
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+	})
+
+	// record runs Record against the mock connection, chaining to prevHash, and returns the stored row
+	// as Query and Verify read it.
+	record := func(id int64, entry entities.AuditEntry, revisions *pgxmock.Rows, prevHash string) []interface{} {
+		conn, _ := pgxmock.NewConn()
+		db := mocks.NewMockDB(ctrl)
+		db.EXPECT().GetConn(ctx).Return(conn, nil)
+		recorder := repositories.NewAudit(db)
+		recorder.Now = repo.Now
+		conn.ExpectBegin()
+		conn.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('audit_log'))")).
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		conn.ExpectQuery(regexp.QuoteMeta(revisionsQuery)).WillReturnRows(revisions)
+		if prevHash == "" {
+			conn.ExpectQuery(regexp.QuoteMeta("SELECT hash FROM audit_log")).WillReturnError(pgx.ErrNoRows)
+		} else {
+			conn.ExpectQuery(regexp.QuoteMeta("SELECT hash FROM audit_log")).
+				WillReturnRows(pgxmock.NewRows([]string{"hash"}).AddRow(prevHash))
+		}
+		args := make([]*capture, 11)
+		matchers := make([]interface{}, len(args))
+		for i := range args {
+			args[i] = &capture{}
+			matchers[i] = args[i]
+		}
+		conn.ExpectExec(regexp.QuoteMeta(insertQuery)).WithArgs(matchers...).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		conn.ExpectCommit()
+		conn.ExpectClose()
+		Expect(recorder.Record(ctx, entry)).To(Succeed())
+		Expect(conn.ExpectationsWereMet()).To(Succeed())
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			values[i] = arg.value
+		}
+		row := append([]interface{}{id}, values[:5]...)
+		row = append(row, nil, values[5], values[5])
+		return append(row, values[6:]...)
+	}
+
+	Context("Record", func() {
+		It("takes before and after from the revisions of the transaction and chains the entry", func() {
+			By("arranging")
+			resourceID := "00000000-0000-7000-8000-000000000101"
+			revisions := pgxmock.NewRows(revisionColumns).
+				AddRow(&resourceID, []byte(`{"name":"First"}`), []byte(`{"name":"Second"}`)).
+				AddRow(&resourceID, []byte(`{"name":"Second"}`), []byte(`{"name":"Third"}`))
+
+			By("acting")
+			row := record(1, entities.AuditEntry{Principal: "anonymous", Method: "PUT", Route: "/resources/{resourceID}/"},
+				revisions, "")
+
+			By("asserting")
+			Expect(row[7]).To(Equal(&resourceID))
+			Expect(row[9]).To(Equal([]byte(`{"name":"First"}`)))
+			Expect(row[10]).To(Equal([]byte(`{"name":"Third"}`)))
+			Expect(row[11]).To(Equal(now))
+			Expect(row[12]).To(Equal("genesis"))
+			Expect(row[13]).To(HaveLen(64))
+		})
+
+		It("keeps the public ID the request named", func() {
+			By("arranging")
+			resourceID, otherID := "00000000-0000-7000-8000-000000000101", "00000000-0000-7000-8000-000000000102"
+			revisions := pgxmock.NewRows(revisionColumns).AddRow(&otherID, nil, []byte(`{"name":"Child"}`))
+
+			By("acting")
+			row := record(1, entities.AuditEntry{Method: "DELETE", Route: "/resources/{resourceID}/", ResourceID: &resourceID},
+				revisions, "")
+
+			By("asserting")
+			Expect(row[7]).To(Equal(&resourceID))
+		})
+	})
+
+	Context("Verify", func() {
+		var rows [][]interface{}
+
+		BeforeEach(func() {
+			noRevisions := func() *pgxmock.Rows { return pgxmock.NewRows(revisionColumns) }
+			first := record(1, entities.AuditEntry{Principal: "anonymous", Method: "POST", Route: "/resources/"}, noRevisions(), "")
+			second := record(2, entities.AuditEntry{Principal: "operator", Method: "DELETE", Route: "/resources/{resourceID}/"},
+				noRevisions(), first[13].(string))
+			rows = [][]interface{}{first, second}
+		})
+
+		It("accepts an intact chain", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM audit_log ORDER BY id")).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(rows[0]...).AddRow(rows[1]...))
+			mockConn.ExpectClose()
+
+			By("acting")
+			verification, err := repo.Verify(ctx)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verification).To(Equal(&entities.AuditVerification{Checked: 2}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("accepts entries hashed with the integer IDs of their resources", func() {
+			By("arranging")
+			// the entry as it was hashed before resources had public IDs
+			legacy := []byte(`{"id":0,"principal":"operator","remote_addr":"","request_id":"","method":"PUT",` +
+				`"route":"/resources/{resourceID}/","resource_id":101,"before":null,"after":null,` +
+				`"created_at":"2022-04-15T05:20:00Z","prev_hash":"genesis","hash":""}`)
+			sum := sha256.Sum256(legacy)
+			resourceID := "00000000-0000-7000-8000-000000000101"
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM audit_log ORDER BY id")).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(int64(1), "operator", "", "", "PUT", "/resources/{resourceID}/",
+					func() *int { id := 101; return &id }(), nil, &resourceID, nil, nil, now,
+					"genesis", hex.EncodeToString(sum[:])))
+			mockConn.ExpectClose()
+
+			By("acting")
+			verification, err := repo.Verify(ctx)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verification).To(Equal(&entities.AuditVerification{Checked: 1}))
+		})
+
+		It("detects altered entries", func() {
+			By("arranging")
+			rows[1][1] = "intruder"
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM audit_log ORDER BY id")).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(rows[0]...).AddRow(rows[1]...))
+			mockConn.ExpectClose()
+
+			By("acting")
+			verification, err := repo.Verify(ctx)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verification.Valid()).To(BeFalse())
+			Expect(verification.BrokenAt).To(Equal(int64(2)))
+		})
+
+		It("detects removed entries", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM audit_log ORDER BY id")).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(rows[1]...))
+			mockConn.ExpectClose()
+
+			By("acting")
+			verification, err := repo.Verify(ctx)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verification.BrokenAt).To(Equal(int64(2)))
+			Expect(verification.Reason).To(ContainSubstring("previous hash"))
+		})
+	})
+
+	Context("Query", func() {
+		It("filters by the given fields", func() {
+			By("arranging")
+			since := now.Add(-time.Hour)
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM audit_log WHERE principal = $1 AND created_at >= $2 AND "+
+				"(resource_public_id = $3::uuid OR resource_public_id IS NULL AND "+
+				"resource_id = (SELECT id FROM resources WHERE public_id = $3::uuid)) ORDER BY id LIMIT $4")).
+				WithArgs("operator", since, publicID(101), 100).WillReturnRows(pgxmock.NewRows(columns))
+			mockConn.ExpectClose()
+
+			By("acting")
+			entries, err := repo.Query(ctx, entities.AuditFilter{Principal: "operator", Since: since, ResourceID: publicID(101)})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,229 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+// chainCTE walks up from the resource $1 through its ancestors, UNION stops it should the parents loop.
+const chainCTE = `WITH RECURSIVE chain(node_id) AS (
+SELECT $1::integer
+UNION
+SELECT parent_id FROM resources JOIN chain ON id = node_id WHERE parent_id IS NOT NULL
+)
+`
+
+// Children lists the live resources directly below the resource.
+func (r Resource) Children(ctx context.Context, id int) ([]entities.Resource, error) {
+	q, release, err := r.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := q.Query(ctx, "SELECT "+entities.ResourceColumns+" FROM resources WHERE parent_id=$1 AND deleted_at IS NULL ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	return scanResources(rows)
+}
+
+// Ancestors lists the resources above the resource, from its parent up to the root.
+func (r Resource) Ancestors(ctx context.Context, id int) ([]entities.Resource, error) {
+	q, release, err := r.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := q.Query(ctx, chainCTE+"SELECT "+entities.ResourceColumns+" FROM resources JOIN chain ON id = node_id", id)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := scanResources(rows)
+	if err != nil {
+		return nil, err
+	}
+	// the chain comes back unordered, it is walked up from the resource itself
+	byID := make(map[int]entities.Resource, len(chain))
+	for _, resource := range chain {
+		byID[resource.ID] = resource
+	}
+	ancestors := make([]entities.Resource, 0, len(chain))
+	for next := byID[id].ParentID; next != nil && len(ancestors) < len(chain); {
+		ancestor, ok := byID[*next]
+		if !ok {
+			break
+		}
+		ancestors = append(ancestors, ancestor)
+		next = ancestor.ParentID
+	}
+	return ancestors, nil
+}
+
+// Subtree fetches the resource with its live descendants down to depth levels below it.
+// It returns pgx.ErrNoRows when the resource is not found.
+func (r Resource) Subtree(ctx context.Context, id, depth int) (*entities.ResourceNode, error) {
+	q, release, err := r.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := q.Query(ctx, `WITH RECURSIVE subtree(node_id, depth) AS (
+SELECT id, 0 FROM resources WHERE id=$1 AND deleted_at IS NULL
+UNION ALL
+SELECT id, depth + 1 FROM resources JOIN subtree ON parent_id = node_id WHERE deleted_at IS NULL AND depth < $2
+)
+SELECT `+entities.ResourceColumns+` FROM resources JOIN subtree ON id = node_id ORDER BY depth, id`, id, depth)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := scanResources(rows)
+	if err != nil {
+		return nil, err
+	}
+	tree, ok := entities.BuildTree(id, resources)
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return tree, nil
+}
+
+// Move puts the resource and its subtree below parentID, or makes it a root when parentID is nil.
+// It returns pgx.ErrNoRows when the resource is not found.
+func (r Resource) Move(ctx context.Context, id int, parentID *int) (*entities.Resource, error) {
+	var resource entities.Resource
+	err := r.inTx(ctx, func(q database.Querier) error {
+		if err := lockHierarchy(ctx, q); err != nil {
+			return err
+		}
+		if parentID != nil {
+			if err := checkParent(ctx, q, *parentID); err != nil {
+				return err
+			}
+			var loop bool
+			err := q.QueryRow(ctx, chainCTE+"SELECT EXISTS (SELECT 1 FROM chain WHERE node_id = $2)", *parentID, id).Scan(&loop)
+			if err != nil {
+				return err
+			}
+			if loop {
+				return entities.ErrHierarchyLoop
+			}
+		}
+		var err error
+		resource, err = entities.ScanResource(q.QueryRow(ctx,
+			"UPDATE resources SET parent_id = $1 WHERE id=$2 AND deleted_at IS NULL RETURNING "+entities.ResourceColumns,
+			parentID, id))
+		if err != nil {
+			return err
+		}
+		return writeOutboxEvent(ctx, q, ResourceMoved, id, resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+// lockHierarchy serializes the changes of parents, so that concurrent moves cannot close a loop
+// and no child is added below a resource that is being deleted. The lock is keyed by the schema too,
+// tenants have hierarchies of their own.
+func lockHierarchy(ctx context.Context, q database.Querier) error {
+	_, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('resource_hierarchy'))")
+	return err
+}
+
+func checkParent(ctx context.Context, q database.Querier, parentID int) error {
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM resources WHERE id=$1 AND deleted_at IS NULL)", parentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrParentMissing
+	}
+	return nil
+}
+
+// checkChildren returns entities.ErrHasChildren when the resource has live children.
+func checkChildren(ctx context.Context, q database.Querier, id int) error {
+	var hasChildren bool
+	err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM resources WHERE parent_id=$1 AND deleted_at IS NULL)",
+		id).Scan(&hasChildren)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return entities.ErrHasChildren
+	}
+	return nil
+}
+
+// checkParentOf returns entities.ErrParentMissing when the parent of the resource is deleted, so that it
+// cannot be live below it.
+func checkParentOf(ctx context.Context, q database.Querier, id int) error {
+	var parentDeleted bool
+	err := q.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM resources child JOIN resources parent ON parent.id = child.parent_id "+
+		"WHERE child.id=$1 AND parent.deleted_at IS NOT NULL)", id).Scan(&parentDeleted)
+	if err != nil {
+		return err
+	}
+	if parentDeleted {
+		return entities.ErrParentMissing
+	}
+	return nil
+}
+
+func deleteSubtree(ctx context.Context, q database.Querier, id int) error {
+	rows, err := q.Query(ctx, `WITH RECURSIVE subtree(node_id) AS (
+SELECT id FROM resources WHERE id=$1 AND deleted_at IS NULL
+UNION
+SELECT id FROM resources JOIN subtree ON parent_id = node_id WHERE deleted_at IS NULL
+)
+UPDATE resources SET deleted_at = now() WHERE id IN (SELECT node_id FROM subtree) RETURNING `+entities.ResourceColumns, id)
+	if err != nil {
+		return err
+	}
+	deleted, err := scanResources(rows)
+	if err != nil {
+		return err
+	}
+	for _, resource := range deleted {
+		if err = writeOutboxEvent(ctx, q, ResourceDeleted, resource.ID, resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orphanChildren turns the children into roots, deleted ones too so that they are roots once restored.
+func orphanChildren(ctx context.Context, q database.Querier, id int) error {
+	rows, err := q.Query(ctx, "UPDATE resources SET parent_id = NULL WHERE parent_id=$1 RETURNING "+entities.ResourceColumns, id)
+	if err != nil {
+		return err
+	}
+	children, err := scanResources(rows)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err = writeOutboxEvent(ctx, q, ResourceMoved, child.ID, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanResources(rows pgx.Rows) ([]entities.Resource, error) {
+	defer rows.Close()
+	resources := make([]entities.Resource, 0)
+	for rows.Next() {
+		resource, err := entities.ScanResource(rows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	return resources, rows.Err()
+}
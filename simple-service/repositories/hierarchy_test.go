@@ -0,0 +1,333 @@
+package repositories_test
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Resource hierarchy", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.Resource
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewResource(mockDB)
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+	})
+
+	columns := []string{"id", "public_id", "name", "deleted_at", "attributes", "labels", "status", "parent_id", "parent_public_id"}
+	parentID := func(id int) *int { return &id }
+	actorQuery := "SELECT set_config('app.actor', $1, true)"
+	lockQuery := "SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('resource_hierarchy'))"
+	parentQuery := "SELECT EXISTS (SELECT 1 FROM resources WHERE id=$1 AND deleted_at IS NULL)"
+	loopQuery := "(?s)WITH RECURSIVE chain.*SELECT EXISTS \\(SELECT 1 FROM chain WHERE node_id = \\$2\\)"
+	moveQuery := "UPDATE resources SET parent_id = $1 WHERE id=$2 AND deleted_at IS NULL RETURNING id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '')"
+
+	Context("Children", func() {
+		It("lists the live children", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(
+				"SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '') FROM resources WHERE parent_id=$1 AND deleted_at IS NULL ORDER BY id")).
+				WithArgs(101).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(102, publicID(102), "First", nil, []byte(`{}`), []byte(`{}`), "active", parentID(101), publicID(101)).
+					AddRow(103, publicID(103), "Second", nil, []byte(`{}`), []byte(`{}`), "active", parentID(101), publicID(101)))
+			mockConn.ExpectClose()
+
+			By("acting")
+			children, err := repo.Children(ctx, 101)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(children).To(Equal([]entities.Resource{
+				{ID: 102, PublicID: publicID(102), Name: "First", Status: "active", ParentID: parentID(101), ParentPublicID: publicID(101)},
+				{ID: 103, PublicID: publicID(103), Name: "Second", Status: "active", ParentID: parentID(101), ParentPublicID: publicID(101)},
+			}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Ancestors", func() {
+		It("orders the ancestors from the parent up to the root", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery("(?s)WITH RECURSIVE chain.*FROM resources JOIN chain ON id = node_id").
+				WithArgs(103).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(101, publicID(101), "Root", nil, []byte(`{}`), []byte(`{}`), "active", nil, "").
+					AddRow(103, publicID(103), "Leaf", nil, []byte(`{}`), []byte(`{}`), "active", parentID(102), publicID(102)).
+					AddRow(102, publicID(102), "Middle", nil, []byte(`{}`), []byte(`{}`), "active", parentID(101), publicID(101)))
+			mockConn.ExpectClose()
+
+			By("acting")
+			ancestors, err := repo.Ancestors(ctx, 103)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ancestors).To(Equal([]entities.Resource{
+				{ID: 102, PublicID: publicID(102), Name: "Middle", Status: "active", ParentID: parentID(101), ParentPublicID: publicID(101)},
+				{ID: 101, PublicID: publicID(101), Name: "Root", Status: "active"},
+			}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Subtree", func() {
+		subtreeQuery := "(?s)WITH RECURSIVE subtree.*depth < \\$2.*ORDER BY depth, id"
+
+		It("nests the descendants below the resource", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(subtreeQuery).WithArgs(101, 2).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(101, publicID(101), "Root", nil, []byte(`{}`), []byte(`{}`), "active", nil, "").
+					AddRow(102, publicID(102), "Child", nil, []byte(`{}`), []byte(`{}`), "active", parentID(101), publicID(101)).
+					AddRow(103, publicID(103), "Grandchild", nil, []byte(`{}`), []byte(`{}`), "active", parentID(102), publicID(102)))
+			mockConn.ExpectClose()
+
+			By("acting")
+			tree, err := repo.Subtree(ctx, 101, 2)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree).To(Equal(&entities.ResourceNode{
+				Resource: entities.Resource{ID: 101, PublicID: publicID(101), Name: "Root", Status: "active"},
+				Children: []entities.ResourceNode{{
+					Resource: entities.Resource{ID: 102, PublicID: publicID(102), Name: "Child", Status: "active", ParentID: parentID(101), ParentPublicID: publicID(101)},
+					Children: []entities.ResourceNode{{
+						Resource: entities.Resource{ID: 103, PublicID: publicID(103), Name: "Grandchild", Status: "active", ParentID: parentID(102), ParentPublicID: publicID(102)},
+					}},
+				}},
+			}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows when the resource is not found", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(subtreeQuery).WithArgs(101, 2).WillReturnRows(pgxmock.NewRows(columns))
+			mockConn.ExpectClose()
+
+			By("acting")
+			tree, err := repo.Subtree(ctx, 101, 2)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(tree).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Move", func() {
+		expectLockedParent := func(parent int, exists bool) {
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(parent).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(exists))
+		}
+
+		It("moves the resource below the new parent", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			expectLockedParent(102, true)
+			mockConn.ExpectQuery(loopQuery).WithArgs(102, 101).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+			mockConn.ExpectQuery(regexp.QuoteMeta(moveQuery)).WithArgs(parentID(102), 101).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(101, publicID(101), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "active", parentID(102), publicID(102)))
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+				WithArgs(101, repositories.ResourceMoved, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active","parent_id":"00000000-0000-7000-8000-000000000102"}`)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Move(ctx, 101, parentID(102))
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resource).To(Equal(&entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name", Status: "active", ParentID: parentID(102), ParentPublicID: publicID(102)}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("makes the resource a root without checks", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(moveQuery)).WithArgs((*int)(nil), 101).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(101, publicID(101), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+				WithArgs(101, repositories.ResourceMoved, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active"}`)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Move(ctx, 101, nil)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resource.ParentID).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("refuses to move the resource below its own subtree", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			expectLockedParent(103, true)
+			mockConn.ExpectQuery(loopQuery).WithArgs(103, 101).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Move(ctx, 101, parentID(103))
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrHierarchyLoop))
+			Expect(resource).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("refuses missing parents", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			expectLockedParent(999, false)
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Move(ctx, 101, parentID(999))
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrParentMissing))
+			Expect(resource).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Create", func() {
+		It("refuses children of missing parents", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(999).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			created, err := repo.Create(ctx, entities.Resource{Name: "Child", ParentID: parentID(999), ParentPublicID: publicID(999)})
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrParentMissing))
+			Expect(created).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Upsert", func() {
+		keyQuery := "SELECT id FROM resources WHERE name=$1 AND parent_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL ORDER BY id LIMIT 1"
+		updateQuery := "UPDATE resources SET attributes = $1, labels = $2, status = $3 WHERE id=$4 RETURNING "
+		expectLocked := func() {
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		}
+
+		It("overwrites the resource of the same name below the same parent", func() {
+			By("arranging")
+			expectLocked()
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(102).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+			mockConn.ExpectQuery(regexp.QuoteMeta(keyQuery)).WithArgs("web", parentID(102)).
+				WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(101))
+			mockConn.ExpectQuery(regexp.QuoteMeta(updateQuery)).
+				WithArgs([]byte(`{}`), []byte(`{"env":"prod"}`), "active", 101).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(101, publicID(101), "web", nil, []byte(`{}`),
+					[]byte(`{"env":"prod"}`), "active", parentID(102), publicID(102)))
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+				WithArgs(101, repositories.ResourceUpdated, pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, created, err := repo.Upsert(ctx, entities.Resource{Name: "web", Status: "active",
+				Labels: map[string]string{"env": "prod"}, ParentID: parentID(102)})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeFalse())
+			Expect(resource.ID).To(Equal(101))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("creates the resource when there is none of the same name", func() {
+			By("arranging")
+			expectLocked()
+			mockConn.ExpectQuery(regexp.QuoteMeta(keyQuery)).WithArgs("web", (*int)(nil)).WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectPrepare("createResource", regexp.QuoteMeta("INSERT INTO resources")).ExpectQuery().
+				WithArgs("web", []byte(`{}`), []byte(`{}`), "draft", (*int)(nil)).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(101, publicID(101), "web", nil, []byte(`{}`), []byte(`{}`),
+					"draft", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+				WithArgs(101, repositories.ResourceCreated, pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, created, err := repo.Upsert(ctx, entities.Resource{Name: "web", Status: "draft"})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeTrue())
+			Expect(resource.PublicID).To(Equal(publicID(101)))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("refuses parents that are gone", func() {
+			By("arranging")
+			expectLocked()
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(102).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			_, _, err := repo.Upsert(ctx, entities.Resource{Name: "web", ParentID: parentID(102)})
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrParentMissing))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+// labelSelectorSQL translates requirements into conditions on the jsonb column, appending their
+// arguments to args. Conditions are written as containment and key existence checks, which are
+// served by a GIN index on the column.
+func labelSelectorSQL(column string, requirements []entities.LabelRequirement, args []interface{}) ([]string, []interface{}, error) {
+	var conditions []string
+	placeholder := func(arg interface{}) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	contains := func(key string, values []string) (string, error) {
+		matches := make([]string, 0, len(values))
+		for _, value := range values {
+			label, err := json.Marshal(map[string]string{key: value})
+			if err != nil {
+				return "", err
+			}
+			matches = append(matches, column+" @> "+placeholder(label)+"::jsonb")
+		}
+		return "(" + strings.Join(matches, " OR ") + ")", nil
+	}
+	for _, requirement := range requirements {
+		var condition string
+		var err error
+		switch requirement.Operator {
+		case entities.SelectorEquals, entities.SelectorIn:
+			condition, err = contains(requirement.Key, requirement.Values)
+		case entities.SelectorNotEquals, entities.SelectorNotIn:
+			condition, err = contains(requirement.Key, requirement.Values)
+			condition = "NOT " + condition
+		case entities.SelectorExists:
+			condition = column + " ? " + placeholder(requirement.Key)
+		case entities.SelectorDoesNotExist:
+			condition = "NOT " + column + " ? " + placeholder(requirement.Key)
+		default:
+			err = fmt.Errorf("unknown label selector operator %q", requirement.Operator)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, args, nil
+}
@@ -49,3 +49,18 @@ func (mr *MockDBMockRecorder) GetConn(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConn", reflect.TypeOf((*MockDB)(nil).GetConn), arg0)
 }
+
+// GetReadConn mocks base method.
+func (m *MockDB) GetReadConn(arg0 context.Context) (database.PgxConn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReadConn", arg0)
+	ret0, _ := ret[0].(database.PgxConn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReadConn indicates an expected call of GetReadConn.
+func (mr *MockDBMockRecorder) GetReadConn(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReadConn", reflect.TypeOf((*MockDB)(nil).GetReadConn), arg0)
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+)
+
+const (
+	ResourceCreated      = "resource.created"
+	ResourceUpdated      = "resource.updated"
+	ResourceDeleted      = "resource.deleted"
+	ResourceRestored     = "resource.restored"
+	ResourcePurged       = "resource.purged"
+	ResourceReverted     = "resource.reverted"
+	ResourceTransitioned = "resource.transitioned"
+	ResourceMoved        = "resource.moved"
+)
+
+func writeOutboxEvent(ctx context.Context, q database.Querier, eventType string, aggregateID int, payload interface{}) error {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, "INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)",
+		aggregateID, eventType, bytes)
+	return err
+}
@@ -1,6 +1,7 @@
 package repositories_test
 
 import (
+	"fmt"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -11,3 +12,8 @@ func TestRepositories(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Repositories Suite")
 }
+
+// publicID is the public ID the tests give to the resource with id.
+func publicID(id int) string {
+	return fmt.Sprintf("00000000-0000-7000-8000-%012d", id)
+}
@@ -3,7 +3,10 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/addme96/simple-go-service/simple-service/auth"
 	"github.com/addme96/simple-go-service/simple-service/database"
 	"github.com/addme96/simple-go-service/simple-service/entities"
 	"github.com/jackc/pgx/v4"
@@ -11,107 +14,249 @@ import (
 
 type DB interface {
 	GetConn(ctx context.Context) (database.PgxConn, error)
+	// GetReadConn may connect to a replica, it serves the reads that tolerate replication lag.
+	GetReadConn(ctx context.Context) (database.PgxConn, error)
 }
 
+// Resource is the repository of resources, Table serves what they have in common with other entities.
 type Resource struct {
-	db DB
+	*Table[entities.Resource]
 }
 
 func NewResource(db DB) *Resource {
-	return &Resource{db: db}
+	return &Resource{Table: NewTable(db, &entities.ResourceMeta)}
 }
 
-func (r Resource) Create(ctx context.Context, newResource entities.Resource) (int, error) {
-	conn, err := r.db.GetConn(ctx)
-	if err != nil {
-		return 0, err
-	}
-	defer conn.Close(ctx)
-	stDesc, err := conn.Prepare(ctx, "createResource", "INSERT into resources (name) VALUES ($1) RETURNING id")
+// Create inserts newResource below its parent, if it has one, and returns it as stored. It returns
+// entities.ErrQuotaExceeded when the tenant of ctx has as many resources as its quota allows.
+func (r Resource) Create(ctx context.Context, newResource entities.Resource) (*entities.Resource, error) {
+	var created entities.Resource
+	err := r.inTx(ctx, func(q database.Querier) error {
+		if err := checkQuota(ctx, q, 0); err != nil {
+			return err
+		}
+		if newResource.ParentID != nil {
+			if err := lockHierarchy(ctx, q); err != nil {
+				return err
+			}
+			if err := checkParent(ctx, q, *newResource.ParentID); err != nil {
+				return err
+			}
+		}
+		var err error
+		created, err = r.create(ctx, q, newResource)
+		return err
+	})
 	if err != nil {
-		return 0, err
-	}
-	row := conn.QueryRow(ctx, stDesc.Name, newResource.Name)
-	var id int
-	if err = row.Scan(&id); err != nil {
-		return 0, err
+		return nil, err
 	}
-	return id, nil
+	return &created, nil
 }
 
-func (r Resource) Read(ctx context.Context, id int) (*entities.Resource, error) {
-	conn, err := r.db.GetConn(ctx)
+// Upsert creates newResource, or overwrites the live resource of the same name below the same parent, which
+// is its natural key. Unlike Update it sets the status too. It returns the resource as stored and whether it
+// was created.
+func (r Resource) Upsert(ctx context.Context, newResource entities.Resource) (*entities.Resource, bool, error) {
+	var stored entities.Resource
+	var created bool
+	err := r.inTx(ctx, func(q database.Querier) error {
+		// the lock also keeps concurrent upserts from creating the same resource twice
+		if err := lockHierarchy(ctx, q); err != nil {
+			return err
+		}
+		if newResource.ParentID != nil {
+			if err := checkParent(ctx, q, *newResource.ParentID); err != nil {
+				return err
+			}
+		}
+		var id int
+		err := q.QueryRow(ctx, "SELECT id FROM resources WHERE name=$1 AND parent_id IS NOT DISTINCT FROM $2 "+
+			"AND deleted_at IS NULL ORDER BY id LIMIT 1", newResource.Name, newResource.ParentID).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			if err = checkQuota(ctx, q, 0); err != nil {
+				return err
+			}
+			created = true
+			stored, err = r.create(ctx, q, newResource)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		attributes, labels, err := newResource.MarshalMetadata()
+		if err != nil {
+			return err
+		}
+		stored, err = entities.ScanResource(q.QueryRow(ctx,
+			"UPDATE resources SET attributes = $1, labels = $2, status = $3 WHERE id=$4 RETURNING "+entities.ResourceColumns,
+			attributes, labels, newResource.Status, id))
+		if err != nil {
+			return err
+		}
+		return writeOutboxEvent(ctx, q, ResourceUpdated, id, stored)
+	})
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	defer conn.Close(ctx)
-	stDesc, err := conn.Prepare(ctx, "readResource", "SELECT id, name FROM resources WHERE id=$1")
+	return &stored, created, nil
+}
+
+// Delete soft-deletes the resource unless it has children.
+func (r Resource) Delete(ctx context.Context, id int) error {
+	return r.DeleteTree(ctx, id, entities.DeleteRestrict)
+}
+
+// DeleteTree soft-deletes the resource, it is hidden from Read and ReadAll until restored or purged.
+// policy decides what happens to its children.
+func (r Resource) DeleteTree(ctx context.Context, id int, policy entities.DeletePolicy) error {
+	return r.inTx(ctx, func(q database.Querier) error {
+		if err := lockHierarchy(ctx, q); err != nil {
+			return err
+		}
+		switch policy {
+		case entities.DeleteCascade:
+			return deleteSubtree(ctx, q, id)
+		case entities.DeleteOrphan:
+			if err := orphanChildren(ctx, q, id); err != nil {
+				return err
+			}
+		default:
+			if err := checkChildren(ctx, q, id); err != nil {
+				return err
+			}
+		}
+		return r.delete(ctx, q, id)
+	})
+}
+
+// Restore undoes a soft delete, it returns pgx.ErrNoRows unless the resource is in the trash and
+// entities.ErrParentMissing while its parent is.
+func (r Resource) Restore(ctx context.Context, id int) (*entities.Resource, error) {
+	var resource entities.Resource
+	err := r.inTx(ctx, func(q database.Querier) error {
+		if err := checkQuota(ctx, q, id); err != nil {
+			return err
+		}
+		if err := lockHierarchy(ctx, q); err != nil {
+			return err
+		}
+		if err := checkParentOf(ctx, q, id); err != nil {
+			return err
+		}
+		stDesc, err := q.Prepare(ctx, "restoreResource",
+			"UPDATE resources SET deleted_at = NULL WHERE id=$1 AND deleted_at IS NOT NULL RETURNING "+entities.ResourceColumns)
+		if err != nil {
+			return err
+		}
+		if resource, err = entities.ScanResource(q.QueryRow(ctx, stDesc.Name, id)); err != nil {
+			return err
+		}
+		return writeOutboxEvent(ctx, q, ResourceRestored, id, resource)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &resource, nil
+}
+
+// Transition moves the resource from one status to another, recording reason in its revision.
+// It returns pgx.ErrNoRows when the resource is gone or no longer in the from status.
+func (r Resource) Transition(ctx context.Context, id int, from, to, reason string) (*entities.Resource, error) {
 	var resource entities.Resource
-	err = conn.QueryRow(ctx, stDesc.Name, id).Scan(&resource.ID, &resource.Name)
+	err := r.inTx(ctx, func(q database.Querier) error {
+		_, err := q.Exec(ctx, "SELECT set_config('app.reason', $1, true)", reason)
+		if err != nil {
+			return err
+		}
+		resource, err = entities.ScanResource(q.QueryRow(ctx,
+			"UPDATE resources SET status = $1 WHERE id=$2 AND status=$3 AND deleted_at IS NULL RETURNING "+entities.ResourceColumns,
+			to, id, from))
+		if err != nil {
+			return err
+		}
+		// later changes of the transaction are not part of the transition
+		if _, err = q.Exec(ctx, "SELECT set_config('app.reason', '', true)"); err != nil {
+			return err
+		}
+		return writeOutboxEvent(ctx, q, ResourceTransitioned, id, transitionEvent{Resource: resource, From: from, Reason: reason})
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &resource, nil
 }
 
-func (r Resource) ReadAll(ctx context.Context) ([]entities.Resource, error) {
-	conn, err := r.db.GetConn(ctx)
+type transitionEvent struct {
+	entities.Resource
+	From   string `json:"from"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Purge permanently deletes resources that were soft-deleted before deletedBefore.
+func (r Resource) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	q, release, err := r.querier(ctx)
 	if err != nil {
-		return nil, err
-	}
-	defer conn.Close(ctx)
-	rows, err := conn.Query(ctx, "SELECT id, name FROM resources")
-	if err != nil && err != pgx.ErrNoRows {
-		return nil, err
-	}
-	resources := make([]entities.Resource, 0)
-	if err == pgx.ErrNoRows {
-		return resources, nil
+		return 0, err
 	}
-	for rows.Next() {
-		var resource entities.Resource
-		err = rows.Scan(&resource.ID, &resource.Name)
-		if err != nil {
-			return nil, err
-		}
-		resources = append(resources, resource)
+	defer release()
+	tag, err := q.Exec(ctx, `WITH purged AS (
+DELETE FROM resources WHERE deleted_at < $1 RETURNING id, public_id
+)
+INSERT INTO outbox (aggregate_id, event_type, payload)
+SELECT id, $2, json_build_object('id', public_id) FROM purged`, deletedBefore, ResourcePurged)
+	if err != nil {
+		return 0, err
 	}
-	return resources, nil
+	return tag.RowsAffected(), nil
 }
 
-func (r Resource) Update(ctx context.Context, id int, newResource entities.Resource) error {
-	conn, err := r.db.GetConn(ctx)
-	if err != nil {
-		return err
+// querier returns the transaction carried by ctx, or a fresh connection closed by release.
+func querier(ctx context.Context, db DB) (database.Querier, func(), error) {
+	if tx, ok := database.TxFromContext(ctx); ok {
+		return tx, func() {}, nil
 	}
-	defer conn.Close(ctx)
-	stDesc, err := conn.Prepare(ctx, "updateResource", "UPDATE resources SET name = $1 WHERE id=$2")
+	conn, err := db.GetConn(ctx)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	_, err = conn.Exec(ctx, stDesc.Name, newResource.Name, id)
+	return conn, func() { conn.Close(ctx) }, nil
+}
+
+// readQuerier is querier for reads that may be served by a replica.
+func readQuerier(ctx context.Context, db DB) (database.Querier, func(), error) {
+	if tx, ok := database.TxFromContext(ctx); ok {
+		return tx, func() {}, nil
+	}
+	conn, err := db.GetReadConn(ctx)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return nil
+	return conn, func() { conn.Close(ctx) }, nil
 }
 
-func (r Resource) Delete(ctx context.Context, id int) error {
-	conn, err := r.db.GetConn(ctx)
+// inTx runs fn in the transaction carried by ctx, or else in a new one on a fresh
+// connection, so that the outbox row is committed together with the change it describes.
+func inTx(ctx context.Context, db DB, fn func(q database.Querier) error) error {
+	if tx, ok := database.TxFromContext(ctx); ok {
+		return fn(tx)
+	}
+	conn, err := db.GetConn(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Close(ctx)
-	stDesc, err := conn.Prepare(ctx, "deleteResource", "DELETE FROM resources WHERE id=$1")
+	tx, err := conn.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = conn.Exec(ctx, stDesc.Name, id)
-	if err != nil {
+	if err = fn(tx); err != nil {
+		tx.Rollback(ctx)
 		return err
 	}
-	return nil
+	return tx.Commit(ctx)
+}
+
+func setActor(ctx context.Context, q database.Querier) error {
+	_, err := q.Exec(ctx, "SELECT set_config('app.actor', $1, true)", auth.PrincipalFromContext(ctx))
+	return err
 }
@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"regexp"
+	"time"
 
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	dbmocks "github.com/addme96/simple-go-service/simple-service/database/mocks"
 	"github.com/addme96/simple-go-service/simple-service/entities"
 	"github.com/addme96/simple-go-service/simple-service/repositories"
 	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
@@ -34,26 +38,41 @@ var _ = Describe("Resource", func() {
 
 	expectedErr := errors.New("some error")
 
+	columns := []string{"id", "public_id", "name", "deleted_at", "attributes", "labels", "status", "parent_id", "parent_public_id"}
+	outboxQuery := "INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)"
+	actorQuery := "SELECT set_config('app.actor', $1, true)"
+
 	Context("Create", func() {
-		query := "INSERT into resources (name) VALUES ($1) RETURNING id"
+		query := "INSERT INTO resources (name, attributes, labels, status, parent_id) VALUES ($1, $2, $3, $4, $5) RETURNING id, public_id"
 
 		Context("happy path", func() {
 			It("creates the resource", func() {
 				By("arranging")
-				resourceToCreate := entities.Resource{ID: 101, Name: "Resource Name"}
+				resourceToCreate := entities.Resource{ID: 101, Name: "Resource Name",
+					Attributes: map[string]interface{}{"size": 3}, Labels: map[string]string{"env": "prod"}, Status: "draft"}
 				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
 				returningID := 1
-				rows := pgxmock.NewRows([]string{"id"}).AddRow(returningID)
+				rows := pgxmock.NewRows(columns).AddRow(returningID, publicID(returningID), "Resource Name", nil,
+					[]byte(`{"size":3}`), []byte(`{"env":"prod"}`), "draft", nil, "")
+				mockConn.ExpectBegin()
+				mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+					WillReturnResult(pgxmock.NewResult("SELECT", 1))
 				mockConn.ExpectPrepare("createResource", regexp.QuoteMeta(query)).
-					ExpectQuery().WithArgs(resourceToCreate.Name).WillReturnRows(rows)
+					ExpectQuery().WithArgs(resourceToCreate.Name, []byte(`{"size":3}`), []byte(`{"env":"prod"}`), "draft", (*int)(nil)).WillReturnRows(rows)
+				mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+					WithArgs(returningID, repositories.ResourceCreated,
+						[]byte(`{"id":"00000000-0000-7000-8000-000000000001","name":"Resource Name","attributes":{"size":3},"labels":{"env":"prod"},"status":"draft"}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mockConn.ExpectCommit()
 				mockConn.ExpectClose()
 
 				By("acting")
-				id, err := repo.Create(ctx, resourceToCreate)
+				created, err := repo.Create(ctx, resourceToCreate)
 
 				By("asserting")
 				Expect(err).NotTo(HaveOccurred())
-				Expect(id).To(Equal(returningID))
+				Expect(created).To(Equal(&entities.Resource{ID: returningID, PublicID: publicID(returningID), Name: "Resource Name",
+					Attributes: map[string]interface{}{"size": float64(3)}, Labels: map[string]string{"env": "prod"}, Status: "draft"}))
 				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 			})
 		})
@@ -65,11 +84,11 @@ var _ = Describe("Resource", func() {
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(nil, expectedErr)
 
 					By("acting")
-					id, err := repo.Create(ctx, entities.Resource{})
+					created, err := repo.Create(ctx, entities.Resource{})
 
 					By("asserting")
 					Expect(err).To(Equal(expectedErr))
-					Expect(id).To(Equal(0))
+					Expect(created).To(BeNil())
 					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 				})
 			})
@@ -78,15 +97,19 @@ var _ = Describe("Resource", func() {
 				It("returns error", func() {
 					By("arranging")
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
 					mockConn.ExpectPrepare("createResource", regexp.QuoteMeta(query)).WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
 					mockConn.ExpectClose()
 
 					By("acting")
-					id, err := repo.Create(ctx, entities.Resource{})
+					created, err := repo.Create(ctx, entities.Resource{})
 
 					By("asserting")
 					Expect(err).To(Equal(expectedErr))
-					Expect(id).To(Equal(0))
+					Expect(created).To(BeNil())
 					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 				})
 			})
@@ -94,18 +117,87 @@ var _ = Describe("Resource", func() {
 			When("QueryRow fails", func() {
 				It("returns error", func() {
 					By("arranging")
-					expectedResource := entities.Resource{ID: 101, Name: "Resource Name"}
+					expectedResource := entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name"}
+					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
+					mockConn.ExpectPrepare("createResource", regexp.QuoteMeta(query)).
+						ExpectQuery().WithArgs(expectedResource.Name, []byte(`{}`), []byte(`{}`), "", (*int)(nil)).WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
+					mockConn.ExpectClose()
+
+					By("acting")
+					created, err := repo.Create(ctx, expectedResource)
+
+					By("asserting")
+					Expect(err).To(Equal(expectedErr))
+					Expect(created).To(BeNil())
+					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+				})
+			})
+
+			When("Begin fails", func() {
+				It("returns error", func() {
+					By("arranging")
+					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin().WillReturnError(expectedErr)
+					mockConn.ExpectClose()
+
+					By("acting")
+					created, err := repo.Create(ctx, entities.Resource{})
+
+					By("asserting")
+					Expect(err).To(Equal(expectedErr))
+					Expect(created).To(BeNil())
+					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+				})
+			})
+
+			When("writing the outbox event fails", func() {
+				It("rolls back and returns error", func() {
+					By("arranging")
+					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					rows := pgxmock.NewRows(columns).AddRow(1, publicID(1), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "", nil, "")
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
+					mockConn.ExpectPrepare("createResource", regexp.QuoteMeta(query)).
+						ExpectQuery().WillReturnRows(rows)
+					mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
+					mockConn.ExpectClose()
+
+					By("acting")
+					created, err := repo.Create(ctx, entities.Resource{Name: "Resource Name"})
+
+					By("asserting")
+					Expect(err).To(Equal(expectedErr))
+					Expect(created).To(BeNil())
+					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+				})
+			})
+
+			When("Commit fails", func() {
+				It("returns error", func() {
+					By("arranging")
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					rows := pgxmock.NewRows(columns).AddRow(1, publicID(1), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "", nil, "")
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
 					mockConn.ExpectPrepare("createResource", regexp.QuoteMeta(query)).
-						ExpectQuery().WithArgs(expectedResource.Name).WillReturnError(expectedErr)
+						ExpectQuery().WillReturnRows(rows)
+					mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+					mockConn.ExpectCommit().WillReturnError(expectedErr)
 					mockConn.ExpectClose()
 
 					By("acting")
-					id, err := repo.Create(ctx, expectedResource)
+					created, err := repo.Create(ctx, entities.Resource{Name: "Resource Name"})
 
 					By("asserting")
 					Expect(err).To(Equal(expectedErr))
-					Expect(id).To(Equal(0))
+					Expect(created).To(BeNil())
 					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 				})
 			})
@@ -113,14 +205,16 @@ var _ = Describe("Resource", func() {
 	})
 
 	Context("Read", func() {
-		query := "SELECT id, name FROM resources WHERE id=$1"
+		query := "SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '') FROM resources WHERE id=$1 AND deleted_at IS NULL"
 
 		Context("happy path", func() {
 			It("reads the resource", func() {
 				By("arranging")
-				expectedResource := entities.Resource{ID: 101, Name: "Resource Name"}
-				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
-				rows := pgxmock.NewRows([]string{"id", "name"}).AddRow(expectedResource.ID, expectedResource.Name)
+				expectedResource := entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name",
+					Attributes: map[string]interface{}{"owner": "team-a"}, Labels: map[string]string{"env": "prod"}, Status: "active"}
+				mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+				rows := pgxmock.NewRows(columns).AddRow(expectedResource.ID, expectedResource.PublicID, expectedResource.Name, nil,
+					[]byte(`{"owner": "team-a"}`), []byte(`{"env": "prod"}`), "active", nil, "")
 				mockConn.ExpectPrepare("readResource", regexp.QuoteMeta(query)).ExpectQuery().
 					WithArgs(expectedResource.ID).WillReturnRows(rows)
 				mockConn.ExpectClose()
@@ -136,10 +230,10 @@ var _ = Describe("Resource", func() {
 		})
 
 		Context("not so happy path", func() {
-			When("GetConn fails", func() {
+			When("GetReadConn fails", func() {
 				It("returns error", func() {
 					By("arranging")
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(nil, expectedErr)
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(nil, expectedErr)
 
 					By("acting")
 					res, err := repo.Read(ctx, 101)
@@ -154,7 +248,7 @@ var _ = Describe("Resource", func() {
 			When("Prepare fails", func() {
 				It("returns error", func() {
 					By("arranging")
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
 					mockConn.ExpectPrepare("readResource", regexp.QuoteMeta(query)).WillReturnError(expectedErr)
 					mockConn.ExpectClose()
 
@@ -172,7 +266,7 @@ var _ = Describe("Resource", func() {
 				It("returns error", func() {
 					By("arranging")
 					resourceID := 101
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
 					mockConn.ExpectPrepare("readResource", regexp.QuoteMeta(query)).
 						ExpectQuery().WithArgs(resourceID).WillReturnError(expectedErr)
 					mockConn.ExpectClose()
@@ -189,20 +283,60 @@ var _ = Describe("Resource", func() {
 		})
 	})
 
+	Context("ReadBatch", func() {
+		query := "SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '') FROM resources WHERE id = ANY($1) AND deleted_at IS NULL"
+
+		It("reads the resources in one query", func() {
+			By("arranging")
+			mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+			rows := pgxmock.NewRows(columns).
+				AddRow(101, publicID(101), "First", nil, []byte(`{}`), []byte(`{}`), "active", nil, "").
+				AddRow(102, publicID(102), "Second", nil, []byte(`{}`), []byte(`{}`), "active", nil, "")
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs([]int{101, 102, 103}).WillReturnRows(rows)
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.ReadBatch(ctx, []int{101, 102, 103})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal([]entities.Resource{
+				{ID: 101, PublicID: publicID(101), Name: "First", Status: "active"},
+				{ID: 102, PublicID: publicID(102), Name: "Second", Status: "active"},
+			}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns the error of the query", func() {
+			By("arranging")
+			mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs([]int{101}).WillReturnError(expectedErr)
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.ReadBatch(ctx, []int{101})
+
+			By("asserting")
+			Expect(err).To(Equal(expectedErr))
+			Expect(res).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
 	Context("ReadAll", func() {
-		query := "SELECT id, name FROM resources"
+		query := "SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '') FROM resources WHERE deleted_at IS NULL"
 
 		Context("happy path", func() {
 			It("reads one resource", func() {
 				By("arranging")
-				expectedResource := entities.Resource{ID: 101, Name: "Resource Name"}
-				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
-				rows := pgxmock.NewRows([]string{"id", "name"}).AddRow(expectedResource.ID, expectedResource.Name)
+				expectedResource := entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name", Status: "active"}
+				mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+				rows := pgxmock.NewRows(columns).AddRow(expectedResource.ID, publicID(expectedResource.ID), expectedResource.Name, nil, []byte(`{}`), []byte(`{}`), "active", nil, "")
 				mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 				mockConn.ExpectClose()
 
 				By("acting")
-				res, err := repo.ReadAll(ctx)
+				res, err := repo.ReadAll(ctx, entities.ListOptions{})
 
 				By("asserting")
 				Expect(err).NotTo(HaveOccurred())
@@ -213,34 +347,87 @@ var _ = Describe("Resource", func() {
 			It("reads two resources", func() {
 				By("arranging")
 				expectedResources := []entities.Resource{
-					{ID: 101, Name: "Resource Name 1"},
-					{ID: 102, Name: "Resource Name 2"},
+					{ID: 101, PublicID: publicID(101), Name: "Resource Name 1", Status: "active"},
+					{ID: 102, PublicID: publicID(102), Name: "Resource Name 2", Status: "active"},
 				}
-				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
-				rows := pgxmock.NewRows([]string{"id", "name"}).
-					AddRow(expectedResources[0].ID, expectedResources[0].Name).
-					AddRow(expectedResources[1].ID, expectedResources[1].Name)
+				mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+				rows := pgxmock.NewRows(columns).
+					AddRow(expectedResources[0].ID, publicID(expectedResources[0].ID), expectedResources[0].Name, nil, []byte(`{}`), []byte(`{}`), "active", nil, "").
+					AddRow(expectedResources[1].ID, publicID(expectedResources[1].ID), expectedResources[1].Name, nil, []byte(`{}`), []byte(`{}`), "active", nil, "")
 				mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 				mockConn.ExpectClose()
 
 				By("acting")
-				res, err := repo.ReadAll(ctx)
+				res, err := repo.ReadAll(ctx, entities.ListOptions{})
 
 				By("asserting")
 				Expect(err).NotTo(HaveOccurred())
 				Expect(res).To(Equal(expectedResources))
 				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 			})
+
+			It("filters by label selector", func() {
+				By("arranging")
+				selector, err := entities.ParseLabelSelector("env=prod,tier in (cache,db),!legacy")
+				Expect(err).NotTo(HaveOccurred())
+				mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+				mockConn.ExpectQuery(regexp.QuoteMeta(query+" AND (labels @> $1::jsonb) AND (labels @> $2::jsonb OR labels @> $3::jsonb) AND NOT labels ? $4")).
+					WithArgs([]byte(`{"env":"prod"}`), []byte(`{"tier":"cache"}`), []byte(`{"tier":"db"}`), "legacy").
+					WillReturnRows(pgxmock.NewRows(columns))
+				mockConn.ExpectClose()
+
+				By("acting")
+				res, err := repo.ReadAll(ctx, entities.ListOptions{LabelSelector: selector})
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(BeEmpty())
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("reads a page of resources in ID order", func() {
+				By("arranging")
+				mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+				mockConn.ExpectQuery(regexp.QuoteMeta(query+" ORDER BY id LIMIT $1 OFFSET $2")).WithArgs(21, 40).
+					WillReturnRows(pgxmock.NewRows(columns))
+				mockConn.ExpectClose()
+
+				By("acting")
+				res, err := repo.ReadAll(ctx, entities.ListOptions{Limit: 21, Offset: 40})
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(BeEmpty())
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("includes deleted resources when asked to", func() {
+				By("arranging")
+				deletedAt := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+				expectedResource := entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name", Status: "active", DeletedAt: &deletedAt}
+				mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+				rows := pgxmock.NewRows(columns).AddRow(expectedResource.ID, publicID(expectedResource.ID), expectedResource.Name, &deletedAt, []byte(`{}`), []byte(`{}`), "active", nil, "")
+				mockConn.ExpectQuery("^" + regexp.QuoteMeta("SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '') FROM resources") + "$").WillReturnRows(rows)
+				mockConn.ExpectClose()
+
+				By("acting")
+				res, err := repo.ReadAll(ctx, entities.ListOptions{IncludeDeleted: true})
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal([]entities.Resource{expectedResource}))
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
 		})
 
 		Context("not so happy path", func() {
-			When("GetConn fails", func() {
+			When("GetReadConn fails", func() {
 				It("returns error", func() {
 					By("arranging")
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(nil, expectedErr)
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(nil, expectedErr)
 
 					By("acting")
-					res, err := repo.ReadAll(ctx)
+					res, err := repo.ReadAll(ctx, entities.ListOptions{})
 
 					By("asserting")
 					Expect(err).To(Equal(expectedErr))
@@ -252,12 +439,12 @@ var _ = Describe("Resource", func() {
 			When("Query fails", func() {
 				It("returns error other than pgx.ErrNoRows", func() {
 					By("arranging")
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
 					mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(expectedErr)
 					mockConn.ExpectClose()
 
 					By("acting")
-					res, err := repo.ReadAll(ctx)
+					res, err := repo.ReadAll(ctx, entities.ListOptions{})
 
 					By("asserting")
 					Expect(err).To(Equal(expectedErr))
@@ -267,12 +454,12 @@ var _ = Describe("Resource", func() {
 
 				It("returns empty slice in case of pgx.ErrNoRows occurrence", func() {
 					By("arranging")
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
 					mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(pgx.ErrNoRows)
 					mockConn.ExpectClose()
 
 					By("acting")
-					res, err := repo.ReadAll(ctx)
+					res, err := repo.ReadAll(ctx, entities.ListOptions{})
 
 					By("asserting")
 					Expect(err).To(BeNil())
@@ -282,37 +469,62 @@ var _ = Describe("Resource", func() {
 
 				It("returns error when scan errors", func() {
 					By("arranging")
-					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
-					expectedResource := entities.Resource{ID: 101, Name: "Resource Name"}
-					rows := pgxmock.NewRows([]string{"id", "name"}).AddRow(expectedResource.ID, expectedResource.Name).
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+					expectedResource := entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name"}
+					rows := pgxmock.NewRows(columns).AddRow(expectedResource.ID, publicID(expectedResource.ID), expectedResource.Name, nil, []byte(`{}`), []byte(`{}`), "active", nil, "").
 						RowError(0, expectedErr)
 					mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 					mockConn.ExpectClose()
 
 					By("acting")
-					res, err := repo.ReadAll(ctx)
+					res, err := repo.ReadAll(ctx, entities.ListOptions{})
 
 					By("asserting")
 					Expect(err).To(Equal(expectedErr))
 					Expect(res).To(BeNil())
 					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 				})
+
+				It("closes the rows and returns the error that ended them", func() {
+					By("arranging")
+					mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+					rows := pgxmock.NewRows(columns).AddRow(101, publicID(101), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "active", nil, "").
+						RowError(1, expectedErr)
+					mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows).RowsWillBeClosed()
+					mockConn.ExpectClose()
+
+					By("acting")
+					_, err := repo.ReadAll(ctx, entities.ListOptions{})
+
+					By("asserting")
+					Expect(err).To(Equal(expectedErr))
+					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+				})
 			})
 		})
 	})
 
 	Context("Update", func() {
-		query := "UPDATE resources SET name = $1 WHERE id=$2"
+		query := "UPDATE resources SET name = $1, attributes = $2, labels = $3 WHERE id=$4 AND deleted_at IS NULL RETURNING id, public_id"
 		Context("happy path", func() {
 			Context("happy path", func() {
 				It("updates the resource", func() {
 					By("arranging")
-					newResource := entities.Resource{ID: 0, Name: "Resource Name"}
+					newResource := entities.Resource{Name: "Resource Name"}
 					currentResourceID := 101
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
 					mockConn.ExpectPrepare("updateResource", regexp.QuoteMeta(query)).
-						ExpectExec().WithArgs(newResource.Name, currentResourceID).
-						WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+						ExpectQuery().WithArgs(newResource.Name, []byte(`{}`), []byte(`{}`), currentResourceID).
+						WillReturnRows(pgxmock.NewRows(columns).
+							AddRow(currentResourceID, publicID(currentResourceID), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+					mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+						WithArgs(currentResourceID, repositories.ResourceUpdated,
+							[]byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active"}`)).
+						WillReturnResult(pgxmock.NewResult("INSERT", 1))
+					mockConn.ExpectCommit()
 					mockConn.ExpectClose()
 
 					By("acting")
@@ -344,8 +556,12 @@ var _ = Describe("Resource", func() {
 				It("returns error", func() {
 					By("arranging")
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
 					mockConn.ExpectPrepare("updateResource", regexp.QuoteMeta(query)).
 						WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
 					mockConn.ExpectClose()
 
 					By("acting")
@@ -357,14 +573,18 @@ var _ = Describe("Resource", func() {
 				})
 			})
 
-			When("Exec fails", func() {
+			When("QueryRow fails", func() {
 				It("returns error", func() {
 					By("arranging")
-					newResource := entities.Resource{ID: 0, Name: "Resource Name"}
+					newResource := entities.Resource{Name: "Resource Name"}
 					currentResourceID := 101
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+						WillReturnResult(pgxmock.NewResult("SELECT", 1))
 					mockConn.ExpectPrepare("updateResource", regexp.QuoteMeta(query)).
-						ExpectExec().WithArgs(newResource.Name, currentResourceID).WillReturnError(expectedErr)
+						ExpectQuery().WithArgs(newResource.Name, []byte(`{}`), []byte(`{}`), currentResourceID).WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
 					mockConn.ExpectClose()
 
 					By("acting")
@@ -379,15 +599,33 @@ var _ = Describe("Resource", func() {
 	})
 
 	Context("Delete", func() {
-		query := "DELETE FROM resources WHERE id=$1"
+		query := "UPDATE resources SET deleted_at = now() WHERE id=$1 AND deleted_at IS NULL RETURNING id, public_id"
+		lockQuery := "SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('resource_hierarchy'))"
+		childrenQuery := "SELECT EXISTS (SELECT 1 FROM resources WHERE parent_id=$1 AND deleted_at IS NULL)"
+		deletedAt := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+
+		expectHierarchyLock := func() {
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		}
 
 		Context("happy path", func() {
-			It("deletes the resource", func() {
+			It("soft-deletes the resource", func() {
 				By("arranging")
 				resourceID := 101
 				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				expectHierarchyLock()
+				mockConn.ExpectQuery(regexp.QuoteMeta(childrenQuery)).WithArgs(resourceID).
+					WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
 				mockConn.ExpectPrepare("deleteResource", regexp.QuoteMeta(query)).
-					ExpectExec().WithArgs(resourceID).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+					ExpectQuery().WithArgs(resourceID).
+					WillReturnRows(pgxmock.NewRows(columns).AddRow(resourceID, publicID(resourceID), "Resource Name", &deletedAt, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+				mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+					WithArgs(resourceID, repositories.ResourceDeleted, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active","deleted_at":"2022-04-15T05:20:00Z"}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mockConn.ExpectCommit()
 				mockConn.ExpectClose()
 
 				By("acting")
@@ -397,6 +635,64 @@ var _ = Describe("Resource", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
 			})
+
+			It("soft-deletes the whole subtree", func() {
+				By("arranging")
+				resourceID := 101
+				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				expectHierarchyLock()
+				mockConn.ExpectQuery("(?s)WITH RECURSIVE subtree.*UPDATE resources SET deleted_at = now\\(\\)").WithArgs(resourceID).
+					WillReturnRows(pgxmock.NewRows(columns).
+						AddRow(101, publicID(101), "Parent", &deletedAt, []byte(`{}`), []byte(`{}`), "active", nil, "").
+						AddRow(102, publicID(102), "Child", &deletedAt, []byte(`{}`), []byte(`{}`), "active", &resourceID, publicID(101)))
+				mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+					WithArgs(101, repositories.ResourceDeleted,
+						[]byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Parent","status":"active","deleted_at":"2022-04-15T05:20:00Z"}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+					WithArgs(102, repositories.ResourceDeleted,
+						[]byte(`{"id":"00000000-0000-7000-8000-000000000102","name":"Child","status":"active","parent_id":"00000000-0000-7000-8000-000000000101","deleted_at":"2022-04-15T05:20:00Z"}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mockConn.ExpectCommit()
+				mockConn.ExpectClose()
+
+				By("acting")
+				err := repo.DeleteTree(ctx, resourceID, entities.DeleteCascade)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
+
+			It("turns the children into roots", func() {
+				By("arranging")
+				resourceID := 101
+				mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+				mockConn.ExpectBegin()
+				expectHierarchyLock()
+				mockConn.ExpectQuery(regexp.QuoteMeta("UPDATE resources SET parent_id = NULL WHERE parent_id=$1 RETURNING")).
+					WithArgs(resourceID).
+					WillReturnRows(pgxmock.NewRows(columns).AddRow(102, publicID(102), "Child", nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+				mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+					WithArgs(102, repositories.ResourceMoved, []byte(`{"id":"00000000-0000-7000-8000-000000000102","name":"Child","status":"active"}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mockConn.ExpectPrepare("deleteResource", regexp.QuoteMeta(query)).
+					ExpectQuery().WithArgs(resourceID).
+					WillReturnRows(pgxmock.NewRows(columns).AddRow(resourceID, publicID(resourceID), "Resource Name", &deletedAt, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+				mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+					WithArgs(resourceID, repositories.ResourceDeleted, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active","deleted_at":"2022-04-15T05:20:00Z"}`)).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mockConn.ExpectCommit()
+				mockConn.ExpectClose()
+
+				By("acting")
+				err := repo.DeleteTree(ctx, resourceID, entities.DeleteOrphan)
+
+				By("asserting")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+			})
 		})
 
 		Context("not so happy path", func() {
@@ -414,12 +710,37 @@ var _ = Describe("Resource", func() {
 				})
 			})
 
+			When("the resource has children", func() {
+				It("returns entities.ErrHasChildren", func() {
+					By("arranging")
+					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					expectHierarchyLock()
+					mockConn.ExpectQuery(regexp.QuoteMeta(childrenQuery)).WithArgs(101).
+						WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+					mockConn.ExpectRollback()
+					mockConn.ExpectClose()
+
+					By("acting")
+					err := repo.Delete(ctx, 101)
+
+					By("asserting")
+					Expect(err).To(Equal(entities.ErrHasChildren))
+					Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+				})
+			})
+
 			When("Prepare fails", func() {
 				It("returns error", func() {
 					By("arranging")
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					expectHierarchyLock()
+					mockConn.ExpectQuery(regexp.QuoteMeta(childrenQuery)).WithArgs(101).
+						WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
 					mockConn.ExpectPrepare("deleteResource", regexp.QuoteMeta(query)).
 						WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
 					mockConn.ExpectClose()
 
 					By("acting")
@@ -431,13 +752,18 @@ var _ = Describe("Resource", func() {
 				})
 			})
 
-			When("Exec fails", func() {
+			When("QueryRow fails", func() {
 				It("returns error", func() {
 					By("arranging")
 					resourceID := 101
 					mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+					mockConn.ExpectBegin()
+					expectHierarchyLock()
+					mockConn.ExpectQuery(regexp.QuoteMeta(childrenQuery)).WithArgs(resourceID).
+						WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
 					mockConn.ExpectPrepare("deleteResource", regexp.QuoteMeta(query)).
-						ExpectExec().WithArgs(resourceID).WillReturnError(expectedErr)
+						ExpectQuery().WithArgs(resourceID).WillReturnError(expectedErr)
+					mockConn.ExpectRollback()
 					mockConn.ExpectClose()
 
 					By("acting")
@@ -450,4 +776,213 @@ var _ = Describe("Resource", func() {
 			})
 		})
 	})
+
+	Context("Restore", func() {
+		lockQuery := "SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('resource_hierarchy'))"
+		parentQuery := "SELECT EXISTS (SELECT 1 FROM resources child JOIN resources parent ON parent.id = child.parent_id " +
+			"WHERE child.id=$1 AND parent.deleted_at IS NOT NULL)"
+		query := "UPDATE resources SET deleted_at = NULL WHERE id=$1 AND deleted_at IS NOT NULL RETURNING id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '')"
+
+		It("restores the resource", func() {
+			By("arranging")
+			resourceID := 101
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(resourceID).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+			mockConn.ExpectPrepare("restoreResource", regexp.QuoteMeta(query)).
+				ExpectQuery().WithArgs(resourceID).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(resourceID, publicID(resourceID), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+				WithArgs(resourceID, repositories.ResourceRestored, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active"}`)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Restore(ctx, resourceID)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(&entities.Resource{ID: resourceID, PublicID: publicID(resourceID), Name: "Resource Name", Status: "active"}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows when the resource is not deleted", func() {
+			By("arranging")
+			resourceID := 101
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(resourceID).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+			mockConn.ExpectPrepare("restoreResource", regexp.QuoteMeta(query)).
+				ExpectQuery().WithArgs(resourceID).WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Restore(ctx, resourceID)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(res).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("keeps resources in the trash while their parent is", func() {
+			By("arranging")
+			resourceID := 101
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(resourceID).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Restore(ctx, resourceID)
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrParentMissing))
+			Expect(res).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Transition", func() {
+		query := "UPDATE resources SET status = $1 WHERE id=$2 AND status=$3 AND deleted_at IS NULL RETURNING id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '')"
+		reasonQuery := "SELECT set_config('app.reason', $1, true)"
+		resetReasonQuery := "SELECT set_config('app.reason', '', true)"
+
+		It("moves the resource to the new status", func() {
+			By("arranging")
+			resourceID := 101
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(reasonQuery)).WithArgs("back in use").
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("active", resourceID, "archived").
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(resourceID, publicID(resourceID), "Resource Name", nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta(resetReasonQuery)).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).
+				WithArgs(resourceID, repositories.ResourceTransitioned,
+					[]byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Resource Name","status":"active","from":"archived","reason":"back in use"}`)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Transition(ctx, resourceID, "archived", "active", "back in use")
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(&entities.Resource{ID: resourceID, PublicID: publicID(resourceID), Name: "Resource Name", Status: "active"}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows when the resource left the from status", func() {
+			By("arranging")
+			resourceID := 101
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectExec(regexp.QuoteMeta(reasonQuery)).WithArgs("").
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("archived", resourceID, "draft").
+				WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Transition(ctx, resourceID, "draft", "archived", "")
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(res).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Purge", func() {
+		It("deletes resources that stayed in the trash for too long", func() {
+			By("arranging")
+			deletedBefore := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("DELETE FROM resources WHERE deleted_at < $1")).
+				WithArgs(deletedBefore, repositories.ResourcePurged).
+				WillReturnResult(pgxmock.NewResult("INSERT", 3))
+			mockConn.ExpectClose()
+
+			By("acting")
+			purged, err := repo.Purge(ctx, deletedBefore)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(purged).To(Equal(int64(3)))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns error", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("DELETE FROM resources")).WillReturnError(expectedErr)
+			mockConn.ExpectClose()
+
+			By("acting")
+			_, err := repo.Purge(ctx, time.Now())
+
+			By("asserting")
+			Expect(err).To(Equal(expectedErr))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("within a transaction", func() {
+		It("runs every call on the transaction and locks what it reads", func() {
+			By("arranging")
+			mockPgx := dbmocks.NewMockPgx(ctrl)
+			manager := database.NewTxManager(database.NewDB(mockPgx, "dbURL"), pgx.ReadCommitted)
+			mockPgx.EXPECT().Connect(gomock.Any(), "dbURL").Times(1).Return(mockConn, nil)
+			mockDB.EXPECT().GetConn(gomock.Any()).Times(0)
+			resource := entities.Resource{ID: 101, PublicID: publicID(101), Name: "Resource Name"}
+			mockConn.ExpectBeginTx(pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+			mockConn.ExpectPrepare("readResourceForUpdate", regexp.QuoteMeta("SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), '') FROM resources WHERE id=$1 AND deleted_at IS NULL FOR UPDATE")).
+				ExpectQuery().WithArgs(resource.ID).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(resource.ID, publicID(resource.ID), resource.Name, nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta(actorQuery)).WithArgs("operator").
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectPrepare("updateResource", regexp.QuoteMeta("UPDATE resources SET name = $1, attributes = $2, labels = $3 WHERE id=$4 AND deleted_at IS NULL RETURNING id, public_id")).
+				ExpectQuery().WithArgs("New Name", []byte(`{}`), []byte(`{}`), resource.ID).
+				WillReturnRows(pgxmock.NewRows(columns).AddRow(resource.ID, publicID(resource.ID), "New Name", nil, []byte(`{}`), []byte(`{}`), "active", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta(outboxQuery)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := manager.WithinTxOptions(auth.WithPrincipal(ctx, "operator"), database.TxOptions{ForUpdate: true}, func(ctx context.Context) error {
+				current, err := repo.Read(ctx, resource.ID)
+				if err != nil {
+					return err
+				}
+				return repo.Update(ctx, current.ID, entities.Resource{Name: "New Name"})
+			})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
 })
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+const revisionColumns = "resource_id, revision, operation, previous, state, actor, reason, created_at"
+
+// Revisions lists every recorded change of the resource, oldest first. Revisions are written by
+// a trigger on resources, so they outlive the resource itself.
+func (r Resource) Revisions(ctx context.Context, id int) ([]entities.ResourceRevision, error) {
+	q, release, err := r.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := q.Query(ctx,
+		"SELECT "+revisionColumns+" FROM resource_revisions WHERE resource_id=$1 ORDER BY revision", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := make([]entities.ResourceRevision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (r Resource) Revision(ctx context.Context, id, revision int) (*entities.ResourceRevision, error) {
+	q, release, err := r.querier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return readRevision(ctx, q, id, revision)
+}
+
+// Revert sets the resource back to its state after the given revision, which is itself recorded
+// as a new revision. It returns pgx.ErrNoRows when either the revision or the resource is gone. Like Delete and
+// Restore, it returns entities.ErrHasChildren for deleted states of resources with live children and
+// entities.ErrParentMissing for live states of resources whose parent is deleted.
+func (r Resource) Revert(ctx context.Context, id, revision int) (*entities.Resource, error) {
+	var resource entities.Resource
+	err := r.inTx(ctx, func(q database.Querier) error {
+		target, err := readRevision(ctx, q, id, revision)
+		if err != nil {
+			return err
+		}
+		if target.State == nil {
+			return pgx.ErrNoRows
+		}
+		if err = lockHierarchy(ctx, q); err != nil {
+			return err
+		}
+		if target.State.DeletedAt == nil {
+			if err = checkQuota(ctx, q, id); err != nil {
+				return err
+			}
+			if err = checkParentOf(ctx, q, id); err != nil {
+				return err
+			}
+		} else if err = checkChildren(ctx, q, id); err != nil {
+			return err
+		}
+		attributes, labels, err := target.State.MarshalMetadata()
+		if err != nil {
+			return err
+		}
+		resource, err = entities.ScanResource(q.QueryRow(ctx,
+			"UPDATE resources SET name = $1, deleted_at = $2, attributes = $3, labels = $4 WHERE id=$5 RETURNING "+entities.ResourceColumns,
+			target.State.Name, target.State.DeletedAt, attributes, labels, id))
+		if err != nil {
+			return err
+		}
+		return writeOutboxEvent(ctx, q, ResourceReverted, id, resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+func readRevision(ctx context.Context, q database.Querier, id, revision int) (*entities.ResourceRevision, error) {
+	return scanRevision(q.QueryRow(ctx,
+		"SELECT "+revisionColumns+" FROM resource_revisions WHERE resource_id=$1 AND revision=$2", id, revision))
+}
+
+func scanRevision(row pgx.Row) (*entities.ResourceRevision, error) {
+	var revision entities.ResourceRevision
+	var previous, state []byte
+	err := row.Scan(&revision.ResourceID, &revision.Revision, &revision.Operation, &previous, &state,
+		&revision.Actor, &revision.Reason, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if err = json.Unmarshal(previous, &revision.Previous); err != nil {
+			return nil, err
+		}
+	}
+	if state != nil {
+		if err = json.Unmarshal(state, &revision.State); err != nil {
+			return nil, err
+		}
+	}
+	return &revision, nil
+}
@@ -0,0 +1,185 @@
+package repositories_test
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Resource revisions", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.Resource
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewResource(mockDB)
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+		mockDB.EXPECT().GetConn(ctx).Times(1).Return(mockConn, nil)
+	})
+
+	columns := []string{"resource_id", "revision", "operation", "previous", "state", "actor", "reason", "created_at"}
+	createdAt := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+	readQuery := "SELECT resource_id, revision, operation, previous, state, actor, reason, created_at FROM resource_revisions WHERE resource_id=$1 AND revision=$2"
+
+	Context("Revisions", func() {
+		It("lists the revisions oldest first", func() {
+			By("arranging")
+			rows := pgxmock.NewRows(columns).
+				AddRow(101, 1, entities.RevisionCreate, nil, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First"}`), "anonymous", "", createdAt).
+				AddRow(101, 2, entities.RevisionUpdate, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First"}`), []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"Second"}`), "operator", "", createdAt)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM resource_revisions WHERE resource_id=$1 ORDER BY revision")).
+				WithArgs(101).WillReturnRows(rows)
+			mockConn.ExpectClose()
+
+			By("acting")
+			revisions, err := repo.Revisions(ctx, 101)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revisions).To(Equal([]entities.ResourceRevision{
+				{ResourceID: 101, Revision: 1, Operation: entities.RevisionCreate,
+					State: &entities.Resource{PublicID: publicID(101), Name: "First"}, Actor: "anonymous", CreatedAt: createdAt},
+				{ResourceID: 101, Revision: 2, Operation: entities.RevisionUpdate, Previous: &entities.Resource{PublicID: publicID(101), Name: "First"},
+					State: &entities.Resource{PublicID: publicID(101), Name: "Second"}, Actor: "operator", CreatedAt: createdAt},
+			}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Revision", func() {
+		It("returns pgx.ErrNoRows for unknown revisions", func() {
+			By("arranging")
+			mockConn.ExpectQuery(regexp.QuoteMeta(readQuery)).WithArgs(101, 7).WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectClose()
+
+			By("acting")
+			revision, err := repo.Revision(ctx, 101, 7)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(revision).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Revert", func() {
+		lockQuery := "SELECT pg_advisory_xact_lock(hashtext(current_schema()), hashtext('resource_hierarchy'))"
+		parentQuery := "SELECT EXISTS (SELECT 1 FROM resources child JOIN resources parent ON parent.id = child.parent_id " +
+			"WHERE child.id=$1 AND parent.deleted_at IS NOT NULL)"
+		childrenQuery := "SELECT EXISTS (SELECT 1 FROM resources WHERE parent_id=$1 AND deleted_at IS NULL)"
+
+		It("writes the state of the revision back", func() {
+			By("arranging")
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(readQuery)).WithArgs(101, 1).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(101, 1, entities.RevisionCreate, nil, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First","labels":{"env":"prod"}}`), "anonymous", "", createdAt))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(101).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+			mockConn.ExpectQuery(regexp.QuoteMeta("UPDATE resources SET name = $1, deleted_at = $2, attributes = $3, labels = $4 WHERE id=$5")).
+				WithArgs("First", (*time.Time)(nil), []byte(`{}`), []byte(`{"env":"prod"}`), 101).
+				WillReturnRows(pgxmock.NewRows([]string{"id", "public_id", "name", "deleted_at", "attributes", "labels", "status", "parent_id", "parent_public_id"}).
+					AddRow(101, publicID(101), "First", nil, []byte(`{}`), []byte(`{"env": "prod"}`), "draft", nil, ""))
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox")).
+				WithArgs(101, repositories.ResourceReverted, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First","labels":{"env":"prod"},"status":"draft"}`)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Revert(ctx, 101, 1)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resource).To(Equal(&entities.Resource{ID: 101, PublicID: publicID(101), Name: "First", Labels: map[string]string{"env": "prod"}, Status: "draft"}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("keeps live children from losing their parent", func() {
+			By("arranging")
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(readQuery)).WithArgs(101, 2).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(101, 2, entities.RevisionDelete, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First"}`),
+						[]byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First","deleted_at":"2022-04-15T05:20:00Z"}`), "operator", "", createdAt))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(childrenQuery)).WithArgs(101).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Revert(ctx, 101, 2)
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrHasChildren))
+			Expect(resource).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("keeps resources from coming back below a deleted parent", func() {
+			By("arranging")
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(readQuery)).WithArgs(101, 1).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(101, 1, entities.RevisionCreate, nil, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First"}`), "anonymous", "", createdAt))
+			mockConn.ExpectExec(regexp.QuoteMeta(lockQuery)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(parentQuery)).WithArgs(101).
+				WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Revert(ctx, 101, 1)
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrParentMissing))
+			Expect(resource).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows for revisions without a state", func() {
+			By("arranging")
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(readQuery)).WithArgs(101, 3).
+				WillReturnRows(pgxmock.NewRows(columns).
+					AddRow(101, 3, entities.RevisionPurge, []byte(`{"id":"00000000-0000-7000-8000-000000000101","name":"First"}`), nil, "system", "", createdAt))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			resource, err := repo.Revert(ctx, 101, 3)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(resource).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
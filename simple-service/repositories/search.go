@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+// searchQuery matches whole words through the search tsvector and partial or misspelled ones
+// through trigram word similarity, ranking resources by both.
+const searchQuery = `WITH query AS (SELECT websearch_to_tsquery('simple', $1) AS tsquery)
+SELECT ` + entities.ResourceColumns + `,
+ts_rank(search, query.tsquery) + public.word_similarity($1, name) AS rank,
+ts_headline('simple', name, query.tsquery, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS snippet
+FROM resources, query
+WHERE deleted_at IS NULL AND (search @@ query.tsquery OR $1 OPERATOR(public.<%) name)
+ORDER BY rank DESC, id
+LIMIT $2 OFFSET $3`
+
+// Search returns live resources matching query, best matches first.
+func (r Resource) Search(ctx context.Context, query string, limit, offset int) ([]entities.SearchResult, error) {
+	q, release, err := r.readQuerier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := q.Query(ctx, searchQuery, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := make([]entities.SearchResult, 0)
+	for rows.Next() {
+		var result entities.SearchResult
+		if result.Resource, err = entities.ScanResource(rows, &result.Rank, &result.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,73 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Resource search", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.Resource
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewResource(mockDB)
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+		mockDB.EXPECT().GetReadConn(ctx).Times(1).Return(mockConn, nil)
+	})
+
+	columns := []string{"id", "public_id", "name", "deleted_at", "attributes", "labels", "status", "parent_id", "parent_public_id", "rank", "snippet"}
+	query := "(?s)" + regexp.QuoteMeta("SELECT id, public_id, name, deleted_at, attributes, labels, status, parent_id, COALESCE((SELECT parent.public_id::text FROM resources parent WHERE parent.id = resources.parent_id), ''),\nts_rank") +
+		".*" + regexp.QuoteMeta("WHERE deleted_at IS NULL AND (search @@ query.tsquery OR $1 OPERATOR(public.<%) name)")
+
+	It("returns ranked results", func() {
+		By("arranging")
+		rows := pgxmock.NewRows(columns).
+			AddRow(101, publicID(101), "Red Apple", nil, []byte(`{}`), []byte(`{}`), "active", nil, "", 1.1, "Red <mark>Apple</mark>").
+			AddRow(102, publicID(102), "Apricot", nil, []byte(`{}`), []byte(`{}`), "active", nil, "", 0.4, "Apricot")
+		mockConn.ExpectQuery(query).WithArgs("aple", 20, 40).WillReturnRows(rows)
+		mockConn.ExpectClose()
+
+		By("acting")
+		results, err := repo.Search(ctx, "aple", 20, 40)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(Equal([]entities.SearchResult{
+			{Resource: entities.Resource{ID: 101, PublicID: publicID(101), Name: "Red Apple", Status: "active"}, Rank: 1.1, Snippet: "Red <mark>Apple</mark>"},
+			{Resource: entities.Resource{ID: 102, PublicID: publicID(102), Name: "Apricot", Status: "active"}, Rank: 0.4, Snippet: "Apricot"},
+		}))
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("returns error", func() {
+		By("arranging")
+		expectedErr := errors.New("some error")
+		mockConn.ExpectQuery(query).WillReturnError(expectedErr)
+		mockConn.ExpectClose()
+
+		By("acting")
+		results, err := repo.Search(ctx, "apple", 20, 0)
+
+		By("asserting")
+		Expect(err).To(Equal(expectedErr))
+		Expect(results).To(BeNil())
+		Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+	})
+})
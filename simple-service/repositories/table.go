@@ -0,0 +1,257 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+// Table is the repository of the entities declared by its Meta. Writes announce themselves
+// through "<name>.created", "<name>.updated" and "<name>.deleted" outbox events.
+type Table[T any] struct {
+	db   DB
+	meta *entities.Meta[T]
+}
+
+func NewTable[T any](db DB, meta *entities.Meta[T]) *Table[T] {
+	return &Table[T]{db: db, meta: meta}
+}
+
+// Create inserts entity and returns it as stored.
+func (t *Table[T]) Create(ctx context.Context, entity T) (*T, error) {
+	var created T
+	err := t.inTx(ctx, func(q database.Querier) error {
+		var err error
+		created, err = t.create(ctx, q, entity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (t *Table[T]) create(ctx context.Context, q database.Querier, entity T) (T, error) {
+	var created T
+	columns := make([]string, 0, len(t.meta.Columns))
+	placeholders := make([]string, 0, len(t.meta.Columns))
+	args := make([]interface{}, 0, len(t.meta.Columns))
+	for _, column := range t.meta.Columns {
+		value, err := column.Value(entity)
+		if err != nil {
+			return created, err
+		}
+		args = append(args, value)
+		columns = append(columns, column.Name)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	stDesc, err := q.Prepare(ctx, t.statement("create"), fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		t.meta.Table, strings.Join(columns, ", "), strings.Join(placeholders, ", "), t.meta.Select))
+	if err != nil {
+		return created, err
+	}
+	if created, err = t.meta.Scan(q.QueryRow(ctx, stDesc.Name, args...)); err != nil {
+		return created, err
+	}
+	return created, writeOutboxEvent(ctx, q, t.event("created"), t.meta.ID(created), created)
+}
+
+// Resolve returns the internal ID of the entity with publicID, deleted or not.
+func (t *Table[T]) Resolve(ctx context.Context, publicID string) (int, error) {
+	q, release, err := t.querier(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	var id int
+	err = q.QueryRow(ctx, "SELECT id FROM "+t.meta.Table+" WHERE public_id=$1", publicID).Scan(&id)
+	return id, err
+}
+
+func (t *Table[T]) Read(ctx context.Context, id int) (*T, error) {
+	q, release, err := t.readQuerier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	name, query := t.statement("read"), "SELECT "+t.meta.Select+" FROM "+t.meta.Table+" WHERE id=$1"+t.live(" AND ")
+	if database.ForUpdate(ctx) {
+		name, query = name+"ForUpdate", query+" FOR UPDATE"
+	}
+	stDesc, err := q.Prepare(ctx, name, query)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := t.meta.Scan(q.QueryRow(ctx, stDesc.Name, id))
+	if err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+func (t *Table[T]) ReadAll(ctx context.Context, options entities.ListOptions) ([]T, error) {
+	q, release, err := t.readQuerier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var conditions []string
+	if !options.IncludeDeleted && t.meta.SoftDelete {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if len(options.LabelSelector) > 0 && t.meta.Labels == "" {
+		return nil, fmt.Errorf("%s has no labels to select by", t.meta.Name)
+	}
+	selector, args, err := labelSelectorSQL(t.meta.Labels, options.LabelSelector, nil)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + t.meta.Select + " FROM " + t.meta.Table
+	if conditions = append(conditions, selector...); len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if options.Limit > 0 {
+		args = append(args, options.Limit, options.Offset)
+		query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	rows, err := q.Query(ctx, query, args...)
+	all := make([]T, 0)
+	if err == pgx.ErrNoRows {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entity, err := t.meta.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entity)
+	}
+	return all, rows.Err()
+}
+
+// ReadBatch reads the live entities with ids in a single query, the ones that are missing are left out.
+func (t *Table[T]) ReadBatch(ctx context.Context, ids []int) ([]T, error) {
+	q, release, err := t.readQuerier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	query := "SELECT " + t.meta.Select + " FROM " + t.meta.Table + " WHERE id = ANY($1)" + t.live(" AND ")
+	if database.ForUpdate(ctx) {
+		query += " FOR UPDATE"
+	}
+	rows, err := q.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	all := make([]T, 0, len(ids))
+	for rows.Next() {
+		entity, err := t.meta.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entity)
+	}
+	return all, rows.Err()
+}
+
+// Update writes the columns of entity that are not immutable, it returns pgx.ErrNoRows when the entity is gone.
+func (t *Table[T]) Update(ctx context.Context, id int, entity T) error {
+	return t.inTx(ctx, func(q database.Querier) error {
+		assignments := make([]string, 0, len(t.meta.Columns))
+		args := make([]interface{}, 0, len(t.meta.Columns)+1)
+		for _, column := range t.meta.Columns {
+			if column.Immutable {
+				continue
+			}
+			value, err := column.Value(entity)
+			if err != nil {
+				return err
+			}
+			args = append(args, value)
+			assignments = append(assignments, fmt.Sprintf("%s = $%d", column.Name, len(args)))
+		}
+		args = append(args, id)
+		stDesc, err := q.Prepare(ctx, t.statement("update"), fmt.Sprintf("UPDATE %s SET %s WHERE id=$%d%s RETURNING %s",
+			t.meta.Table, strings.Join(assignments, ", "), len(args), t.live(" AND "), t.meta.Select))
+		if err != nil {
+			return err
+		}
+		updated, err := t.meta.Scan(q.QueryRow(ctx, stDesc.Name, args...))
+		if err != nil {
+			return err
+		}
+		return writeOutboxEvent(ctx, q, t.event("updated"), id, updated)
+	})
+}
+
+// Delete removes the entity, or soft-deletes it when its Meta says so. Deleting it twice is not an error.
+func (t *Table[T]) Delete(ctx context.Context, id int) error {
+	return t.inTx(ctx, func(q database.Querier) error {
+		return t.delete(ctx, q, id)
+	})
+}
+
+func (t *Table[T]) delete(ctx context.Context, q database.Querier, id int) error {
+	query := "DELETE FROM " + t.meta.Table + " WHERE id=$1 RETURNING " + t.meta.Select
+	if t.meta.SoftDelete {
+		query = "UPDATE " + t.meta.Table + " SET deleted_at = now() WHERE id=$1" + t.live(" AND ") + " RETURNING " + t.meta.Select
+	}
+	stDesc, err := q.Prepare(ctx, t.statement("delete"), query)
+	if err != nil {
+		return err
+	}
+	deleted, err := t.meta.Scan(q.QueryRow(ctx, stDesc.Name, id))
+	if err == pgx.ErrNoRows {
+		// already deleted, there is nothing to announce
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeOutboxEvent(ctx, q, t.event("deleted"), id, deleted)
+}
+
+// live returns the condition that hides soft-deleted rows, preceded by prefix, if there is one.
+func (t *Table[T]) live(prefix string) string {
+	if !t.meta.SoftDelete {
+		return ""
+	}
+	return prefix + "deleted_at IS NULL"
+}
+
+// statement names the prepared statement of verb, e.g. "readResource".
+func (t *Table[T]) statement(verb string) string {
+	return verb + strings.ToUpper(t.meta.Name[:1]) + t.meta.Name[1:]
+}
+
+func (t *Table[T]) event(verb string) string {
+	return t.meta.Name + "." + verb
+}
+
+func (t *Table[T]) querier(ctx context.Context) (database.Querier, func(), error) {
+	return querier(ctx, t.db)
+}
+
+func (t *Table[T]) readQuerier(ctx context.Context) (database.Querier, func(), error) {
+	return readQuerier(ctx, t.db)
+}
+
+// inTx records the principal of ctx as the actor of the revisions written by the transaction.
+func (t *Table[T]) inTx(ctx context.Context, fn func(q database.Querier) error) error {
+	return inTx(ctx, t.db, func(q database.Querier) error {
+		if err := setActor(ctx, q); err != nil {
+			return err
+		}
+		return fn(q)
+	})
+}
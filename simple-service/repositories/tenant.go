@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/jackc/pgx/v4"
+)
+
+// errNoTenant rejects quotas for the default schema, only tenants have them.
+var errNoTenant = errors.New("quotas are set for tenants")
+
+// Tenant is the registry of tenants, which is kept in the default schema whatever the tenant of ctx,
+// and of the quotas of the tenant of ctx, which are kept in its schema.
+type Tenant struct {
+	db DB
+}
+
+func NewTenant(db DB) *Tenant {
+	return &Tenant{db: db}
+}
+
+// Create registers the tenant and creates its schema, which is empty until it is migrated. The tenant is not
+// served until it is marked ready.
+func (r Tenant) Create(ctx context.Context, id string) (*entities.Tenant, error) {
+	if err := tenancy.Validate(id); err != nil {
+		return nil, err
+	}
+	tenant := entities.Tenant{ID: id, Schema: tenancy.Schema(id)}
+	err := r.inRegistryTx(ctx, func(q database.Querier) error {
+		err := q.QueryRow(ctx, "INSERT INTO tenants (id, schema) VALUES ($1, $2) RETURNING created_at",
+			tenant.ID, tenant.Schema).Scan(&tenant.CreatedAt)
+		if err != nil {
+			return err
+		}
+		_, err = q.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{tenant.Schema}.Sanitize())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Read returns pgx.ErrNoRows when the tenant is not registered.
+func (r Tenant) Read(ctx context.Context, id string) (*entities.Tenant, error) {
+	conn, err := r.db.GetConn(registry(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	tenant := entities.Tenant{ID: id}
+	err = conn.QueryRow(ctx, "SELECT schema, ready, created_at FROM tenants WHERE id = $1", id).
+		Scan(&tenant.Schema, &tenant.Ready, &tenant.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r Tenant) ReadAll(ctx context.Context) ([]entities.Tenant, error) {
+	conn, err := r.db.GetConn(registry(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, "SELECT id, schema, ready, created_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tenants := make([]entities.Tenant, 0)
+	for rows.Next() {
+		var tenant entities.Tenant
+		if err = rows.Scan(&tenant.ID, &tenant.Schema, &tenant.Ready, &tenant.CreatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// MarkReady lets the tenant be served once its schema is migrated, it returns pgx.ErrNoRows for unknown tenants.
+func (r Tenant) MarkReady(ctx context.Context, id string) error {
+	conn, err := r.db.GetConn(registry(ctx))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	tag, err := conn.Exec(ctx, "UPDATE tenants SET ready = true WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Delete drops the schema of the tenant with everything in it, it returns pgx.ErrNoRows for unknown tenants.
+func (r Tenant) Delete(ctx context.Context, id string) error {
+	return r.inRegistryTx(ctx, func(q database.Querier) error {
+		var schema string
+		if err := q.QueryRow(ctx, "DELETE FROM tenants WHERE id = $1 RETURNING schema", id).Scan(&schema); err != nil {
+			return err
+		}
+		_, err := q.Exec(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+		return err
+	})
+}
+
+// SetQuota limits how many of name the tenant of ctx may have, nil lifts the limit.
+func (r Tenant) SetQuota(ctx context.Context, name string, maximum *int) error {
+	if _, ok := tenancy.TenantFromContext(ctx); !ok {
+		return errNoTenant
+	}
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	if maximum == nil {
+		_, err = conn.Exec(ctx, "DELETE FROM quotas WHERE name = $1", name)
+		return err
+	}
+	_, err = conn.Exec(ctx, "INSERT INTO quotas (name, maximum) VALUES ($1, $2) "+
+		"ON CONFLICT (name) DO UPDATE SET maximum = excluded.maximum", name, *maximum)
+	return err
+}
+
+// Quotas returns the limits of the tenant of ctx by name, what has no quota is unlimited.
+func (r Tenant) Quotas(ctx context.Context) (map[string]int, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, "SELECT name, maximum FROM quotas")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	quotas := make(map[string]int)
+	for rows.Next() {
+		var name string
+		var maximum int
+		if err = rows.Scan(&name, &maximum); err != nil {
+			return nil, err
+		}
+		quotas[name] = maximum
+	}
+	return quotas, rows.Err()
+}
+
+// registry reaches the default schema, where the tenants are registered.
+func registry(ctx context.Context) context.Context {
+	return tenancy.WithTenant(ctx, "")
+}
+
+// inRegistryTx runs fn in a transaction of its own on the default schema, rather than joining the
+// transaction ctx may carry, which is on the schema of a tenant.
+func (r Tenant) inRegistryTx(ctx context.Context, fn func(q database.Querier) error) error {
+	conn, err := r.db.GetConn(registry(ctx))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkQuota returns entities.ErrQuotaExceeded unless the resource with id can be live without exceeding the
+// quota of resources of the tenant of ctx, id is 0 for a resource about to be created. The quota stays locked
+// until the transaction ends, so that concurrent creates cannot both take the last resource it allows.
+func checkQuota(ctx context.Context, q database.Querier, id int) error {
+	if _, ok := tenancy.TenantFromContext(ctx); !ok {
+		return nil
+	}
+	var maximum int
+	err := q.QueryRow(ctx, "SELECT maximum FROM quotas WHERE name = $1 FOR UPDATE", entities.QuotaResources).
+		Scan(&maximum)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var live int
+	err = q.QueryRow(ctx, "SELECT count(*) FROM resources WHERE deleted_at IS NULL AND id <> $1", id).Scan(&live)
+	if err != nil {
+		return err
+	}
+	if live >= maximum {
+		return entities.ErrQuotaExceeded
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Tenant", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.Tenant
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewTenant(mockDB)
+		ctx = tenancy.WithTenant(context.Background(), "acme")
+		mockConn, _ = pgxmock.NewConn()
+	})
+
+	now := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+
+	Context("Create", func() {
+		It("registers the tenant and creates its schema", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(defaultSchema{}).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectQuery(regexp.QuoteMeta("INSERT INTO tenants (id, schema) VALUES ($1, $2) RETURNING created_at")).
+				WithArgs("acme-eu", "tenant_acme-eu").WillReturnRows(pgxmock.NewRows([]string{"created_at"}).AddRow(now))
+			mockConn.ExpectExec(regexp.QuoteMeta(`CREATE SCHEMA "tenant_acme-eu"`)).
+				WillReturnResult(pgxmock.NewResult("CREATE SCHEMA", 0))
+			mockConn.ExpectCommit()
+			mockConn.ExpectClose()
+
+			By("acting")
+			tenant, err := repo.Create(ctx, "acme-eu")
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*tenant).To(Equal(entities.Tenant{ID: "acme-eu", Schema: "tenant_acme-eu", CreatedAt: now}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("rejects IDs that cannot name a schema", func() {
+			tenant, err := repo.Create(ctx, `acme"`)
+
+			Expect(err).To(Equal(tenancy.ErrInvalidTenant))
+			Expect(tenant).To(BeNil())
+		})
+	})
+
+	Context("Delete", func() {
+		It("returns pgx.ErrNoRows for unknown tenants", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(defaultSchema{}).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectQuery(regexp.QuoteMeta("DELETE FROM tenants WHERE id = $1 RETURNING schema")).
+				WithArgs("acme").WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Delete(ctx, "acme")
+
+			By("asserting")
+			Expect(errors.Is(err, pgx.ErrNoRows)).To(BeTrue())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("MarkReady", func() {
+		It("marks the tenant ready in the registry", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(defaultSchema{}).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("UPDATE tenants SET ready = true WHERE id = $1")).
+				WithArgs("acme").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.MarkReady(ctx, "acme")
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows for unknown tenants", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(defaultSchema{}).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("UPDATE tenants SET ready = true WHERE id = $1")).
+				WithArgs("acme").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.MarkReady(ctx, "acme")
+
+			By("asserting")
+			Expect(errors.Is(err, pgx.ErrNoRows)).To(BeTrue())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("SetQuota", func() {
+		It("sets the quota in the schema of the tenant", func() {
+			By("arranging")
+			maximum := 10
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO quotas (name, maximum) VALUES ($1, $2)")).
+				WithArgs(entities.QuotaResources, 10).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.SetQuota(ctx, entities.QuotaResources, &maximum)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("leaves the default schema without quotas", func() {
+			maximum := 10
+
+			err := repo.SetQuota(context.Background(), entities.QuotaResources, &maximum)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("quotas of resources", func() {
+		quotaQuery := "SELECT maximum FROM quotas WHERE name = $1 FOR UPDATE"
+		countQuery := "SELECT count(*) FROM resources WHERE deleted_at IS NULL AND id <> $1"
+
+		It("keeps tenants from creating more resources than their quota allows", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(quotaQuery)).WithArgs(entities.QuotaResources).
+				WillReturnRows(pgxmock.NewRows([]string{"maximum"}).AddRow(2))
+			mockConn.ExpectQuery(regexp.QuoteMeta(countQuery)).WithArgs(0).
+				WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(2))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			created, err := repositories.NewResource(mockDB).Create(ctx, entities.Resource{Name: "web"})
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrQuotaExceeded))
+			Expect(created).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("counts the restored resource only if it is not live already", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectBegin()
+			mockConn.ExpectExec(regexp.QuoteMeta("SELECT set_config('app.actor', $1, true)")).WithArgs(auth.Anonymous).
+				WillReturnResult(pgxmock.NewResult("SELECT", 1))
+			mockConn.ExpectQuery(regexp.QuoteMeta(quotaQuery)).WithArgs(entities.QuotaResources).
+				WillReturnRows(pgxmock.NewRows([]string{"maximum"}).AddRow(2))
+			mockConn.ExpectQuery(regexp.QuoteMeta(countQuery)).WithArgs(7).
+				WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(2))
+			mockConn.ExpectRollback()
+			mockConn.ExpectClose()
+
+			By("acting")
+			restored, err := repositories.NewResource(mockDB).Restore(ctx, 7)
+
+			By("asserting")
+			Expect(err).To(Equal(entities.ErrQuotaExceeded))
+			Expect(restored).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
+
+// defaultSchema matches the contexts of the registry, which is in the default schema.
+type defaultSchema struct{}
+
+func (defaultSchema) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	if !ok {
+		return false
+	}
+	_, scoped := tenancy.TenantFromContext(ctx)
+	return !scoped
+}
+
+func (defaultSchema) String() string {
+	return "is a context without a tenant"
+}
@@ -0,0 +1,238 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+)
+
+type Webhook struct {
+	db DB
+}
+
+func NewWebhook(db DB) *Webhook {
+	return &Webhook{db: db}
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at`
+
+func (r Webhook) Create(ctx context.Context, newWebhook entities.Webhook) (int, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(ctx)
+	stDesc, err := conn.Prepare(ctx, "createWebhook",
+		"INSERT INTO webhooks (url, events, secret, active) VALUES ($1, $2, $3, $4) RETURNING id")
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = conn.QueryRow(ctx, stDesc.Name, newWebhook.URL, newWebhook.Events, newWebhook.Secret, newWebhook.Active).
+		Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r Webhook) Read(ctx context.Context, id int) (*entities.Webhook, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	stDesc, err := conn.Prepare(ctx, "readWebhook", "SELECT id, url, events, secret, active FROM webhooks WHERE id=$1")
+	if err != nil {
+		return nil, err
+	}
+	var webhook entities.Webhook
+	err = conn.QueryRow(ctx, stDesc.Name, id).
+		Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Secret, &webhook.Active)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r Webhook) ReadAll(ctx context.Context) ([]entities.Webhook, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, "SELECT id, url, events, secret, active FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	webhooks := make([]entities.Webhook, 0)
+	for rows.Next() {
+		var webhook entities.Webhook
+		if err = rows.Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Secret, &webhook.Active); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r Webhook) Update(ctx context.Context, id int, newWebhook entities.Webhook) error {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	stDesc, err := conn.Prepare(ctx, "updateWebhook",
+		"UPDATE webhooks SET url = $1, events = $2, active = $3 WHERE id=$4")
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, stDesc.Name, newWebhook.URL, newWebhook.Events, newWebhook.Active, id)
+	return err
+}
+
+func (r Webhook) Delete(ctx context.Context, id int) error {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	stDesc, err := conn.Prepare(ctx, "deleteWebhook", "DELETE FROM webhooks WHERE id=$1")
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, stDesc.Name, id)
+	return err
+}
+
+// Enqueue creates a pending delivery of the event for every active webhook subscribed to it.
+// Enqueuing the same event twice is a no-op, so it is safe to call from an at-least-once relay.
+func (r Webhook) Enqueue(ctx context.Context, eventID int64, eventType string, payload json.RawMessage) error {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+SELECT id, $1, $2, $3 FROM webhooks WHERE active AND (cardinality(events) = 0 OR $2 = ANY(events))
+ON CONFLICT (webhook_id, event_id) DO NOTHING`, eventID, eventType, []byte(payload))
+	return err
+}
+
+func (r Webhook) Deliveries(ctx context.Context, webhookID int) ([]entities.WebhookDelivery, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, "SELECT "+deliveryColumns+
+		" FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.id DESC", webhookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := make([]entities.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// Redeliver schedules a delivery of the webhook for immediate retry with a fresh attempt budget.
+func (r Webhook) Redeliver(ctx context.Context, webhookID int, deliveryID int64) error {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	tag, err := conn.Exec(ctx, `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = now()
+WHERE id = $1 AND webhook_id = $2`, deliveryID, webhookID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDeliveries leases due deliveries to the caller, the lease expires unless an attempt is recorded in time.
+func (r Webhook) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entities.WebhookDispatch, error) {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+	rows, err := conn.Query(ctx, `WITH claimed AS (
+UPDATE webhook_deliveries SET next_attempt_at = now() + $2 * interval '1 millisecond'
+WHERE id IN (
+SELECT id FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= now()
+ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+) RETURNING *
+)
+SELECT `+deliveryColumns+`, w.url, w.secret FROM claimed d JOIN webhooks w ON w.id = d.webhook_id ORDER BY d.id`,
+		limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dispatches := make([]entities.WebhookDispatch, 0)
+	for rows.Next() {
+		var dispatch entities.WebhookDispatch
+		dispatch.Delivery, err = scanDelivery(rows, &dispatch.Webhook.URL, &dispatch.Webhook.Secret)
+		if err != nil {
+			return nil, err
+		}
+		dispatch.Webhook.ID = dispatch.Delivery.WebhookID
+		dispatches = append(dispatches, dispatch)
+	}
+	return dispatches, rows.Err()
+}
+
+func (r Webhook) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	return r.recordAttempt(ctx, `UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1,
+last_status_code = $2, last_error = '', delivered_at = now() WHERE id = $1`, id, statusCode)
+}
+
+func (r Webhook) MarkFailed(ctx context.Context, id int64, statusCode int, lastError string, nextAttemptAt time.Time) error {
+	return r.recordAttempt(ctx, `UPDATE webhook_deliveries SET attempts = attempts + 1,
+last_status_code = $2, last_error = $3, next_attempt_at = $4 WHERE id = $1`, id, statusCode, lastError, nextAttemptAt)
+}
+
+// MarkDead moves a delivery that ran out of attempts to the dead-letter state, it is only retried by Redeliver.
+func (r Webhook) MarkDead(ctx context.Context, id int64, statusCode int, lastError string) error {
+	return r.recordAttempt(ctx, `UPDATE webhook_deliveries SET status = 'dead', attempts = attempts + 1,
+last_status_code = $2, last_error = $3 WHERE id = $1`, id, statusCode, lastError)
+}
+
+func (r Webhook) recordAttempt(ctx context.Context, query string, args ...interface{}) error {
+	conn, err := r.db.GetConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, query, args...)
+	return err
+}
+
+func scanDelivery(rows pgx.Rows, extra ...interface{}) (entities.WebhookDelivery, error) {
+	var delivery entities.WebhookDelivery
+	var payload []byte
+	dest := append([]interface{}{&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.LastStatusCode, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.CreatedAt, &delivery.DeliveredAt}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	delivery.Payload = payload
+	return delivery, nil
+}
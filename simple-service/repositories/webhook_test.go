@@ -0,0 +1,335 @@
+package repositories_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/repositories"
+	"github.com/addme96/simple-go-service/simple-service/repositories/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pashagolub/pgxmock"
+)
+
+var _ = Describe("Webhook", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockDB   *mocks.MockDB
+		repo     *repositories.Webhook
+		ctx      context.Context
+		mockConn pgxmock.PgxConnIface
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockDB = mocks.NewMockDB(ctrl)
+		repo = repositories.NewWebhook(mockDB)
+		ctx = context.Background()
+		mockConn, _ = pgxmock.NewConn()
+	})
+
+	expectedErr := errors.New("some error")
+	webhook := entities.Webhook{ID: 5, URL: "https://example.com/hook", Events: []string{"resource.created"}, Secret: "s3cr3t", Active: true}
+	webhookColumns := []string{"id", "url", "events", "secret", "active"}
+	deliveryColumns := []string{"id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts",
+		"last_status_code", "last_error", "next_attempt_at", "created_at", "delivered_at"}
+	now := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+
+	Context("Create", func() {
+		query := "INSERT INTO webhooks (url, events, secret, active) VALUES ($1, $2, $3, $4) RETURNING id"
+
+		It("creates the webhook", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectPrepare("createWebhook", regexp.QuoteMeta(query)).ExpectQuery().
+				WithArgs(webhook.URL, webhook.Events, webhook.Secret, webhook.Active).
+				WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(5))
+			mockConn.ExpectClose()
+
+			By("acting")
+			id, err := repo.Create(ctx, webhook)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(5))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns GetConn error", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(nil, expectedErr)
+
+			By("acting")
+			id, err := repo.Create(ctx, webhook)
+
+			By("asserting")
+			Expect(err).To(Equal(expectedErr))
+			Expect(id).To(Equal(0))
+		})
+
+		It("returns Prepare error", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectPrepare("createWebhook", regexp.QuoteMeta(query)).WillReturnError(expectedErr)
+			mockConn.ExpectClose()
+
+			By("acting")
+			id, err := repo.Create(ctx, webhook)
+
+			By("asserting")
+			Expect(err).To(Equal(expectedErr))
+			Expect(id).To(Equal(0))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Read", func() {
+		query := "SELECT id, url, events, secret, active FROM webhooks WHERE id=$1"
+
+		It("reads the webhook", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectPrepare("readWebhook", regexp.QuoteMeta(query)).ExpectQuery().WithArgs(5).
+				WillReturnRows(pgxmock.NewRows(webhookColumns).
+					AddRow(webhook.ID, webhook.URL, webhook.Events, webhook.Secret, webhook.Active))
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Read(ctx, 5)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(&webhook))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns QueryRow error", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectPrepare("readWebhook", regexp.QuoteMeta(query)).ExpectQuery().WithArgs(5).
+				WillReturnError(pgx.ErrNoRows)
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Read(ctx, 5)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+			Expect(res).To(BeNil())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("ReadAll", func() {
+		query := "SELECT id, url, events, secret, active FROM webhooks ORDER BY id"
+
+		It("reads all webhooks", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(pgxmock.NewRows(webhookColumns).
+				AddRow(webhook.ID, webhook.URL, webhook.Events, webhook.Secret, webhook.Active))
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.ReadAll(ctx)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal([]entities.Webhook{webhook}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns Query error", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(expectedErr)
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.ReadAll(ctx)
+
+			By("asserting")
+			Expect(err).To(Equal(expectedErr))
+			Expect(res).To(BeNil())
+		})
+	})
+
+	Context("Update", func() {
+		It("updates url, events and active but keeps the secret", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectPrepare("updateWebhook",
+				regexp.QuoteMeta("UPDATE webhooks SET url = $1, events = $2, active = $3 WHERE id=$4")).
+				ExpectExec().WithArgs(webhook.URL, webhook.Events, webhook.Active, 5).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Update(ctx, 5, webhook)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Delete", func() {
+		It("deletes the webhook", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectPrepare("deleteWebhook", regexp.QuoteMeta("DELETE FROM webhooks WHERE id=$1")).
+				ExpectExec().WithArgs(5).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Delete(ctx, 5)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Enqueue", func() {
+		It("creates deliveries for subscribed webhooks idempotently", func() {
+			By("arranging")
+			payload := json.RawMessage(`{"id":7}`)
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)")+
+				".*"+regexp.QuoteMeta("ON CONFLICT (webhook_id, event_id) DO NOTHING")).
+				WithArgs(int64(42), "resource.created", []byte(payload)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 2))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Enqueue(ctx, 42, "resource.created", payload)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Deliveries", func() {
+		It("reads the delivery history newest first", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.id DESC")).
+				WithArgs(5).
+				WillReturnRows(pgxmock.NewRows(deliveryColumns).
+					AddRow(int64(9), 5, int64(42), "resource.created", []byte(`{"id":7}`), entities.DeliveryDelivered, 1,
+						204, "", now, now, &now))
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.Deliveries(ctx, 5)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal([]entities.WebhookDelivery{{
+				ID: 9, WebhookID: 5, EventID: 42, EventType: "resource.created", Payload: json.RawMessage(`{"id":7}`),
+				Status: entities.DeliveryDelivered, Attempts: 1, LastStatusCode: 204, NextAttemptAt: now,
+				CreatedAt: now, DeliveredAt: &now,
+			}}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("Redeliver", func() {
+		query := regexp.QuoteMeta("UPDATE webhook_deliveries SET status = 'pending', attempts = 0")
+
+		It("resets the delivery", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(query).WithArgs(int64(9), 5).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Redeliver(ctx, 5, 9)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns pgx.ErrNoRows for unknown deliveries", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(query).WithArgs(int64(9), 5).WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+			mockConn.ExpectClose()
+
+			By("acting")
+			err := repo.Redeliver(ctx, 5, 9)
+
+			By("asserting")
+			Expect(err).To(Equal(pgx.ErrNoRows))
+		})
+	})
+
+	Context("ClaimDeliveries", func() {
+		It("leases due deliveries with their subscription", func() {
+			By("arranging")
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectQuery(regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).WithArgs(20, int64(60000)).
+				WillReturnRows(pgxmock.NewRows(append(deliveryColumns, "url", "secret")).
+					AddRow(int64(9), 5, int64(42), "resource.created", []byte(`{"id":7}`), entities.DeliveryPending, 0,
+						0, "", now, now, nil, webhook.URL, webhook.Secret))
+			mockConn.ExpectClose()
+
+			By("acting")
+			res, err := repo.ClaimDeliveries(ctx, 20, time.Minute)
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(HaveLen(1))
+			Expect(res[0].Delivery.ID).To(Equal(int64(9)))
+			Expect(res[0].Delivery.DeliveredAt).To(BeNil())
+			Expect(res[0].Webhook).To(Equal(entities.Webhook{ID: 5, URL: webhook.URL, Secret: webhook.Secret}))
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Context("recording attempts", func() {
+		It("marks deliveries delivered", func() {
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("SET status = 'delivered'")).WithArgs(int64(9), 200).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			Expect(repo.MarkDelivered(ctx, 9, 200)).To(Succeed())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("reschedules failed deliveries", func() {
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("next_attempt_at = $4")).WithArgs(int64(9), 503, "unavailable", now).
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			Expect(repo.MarkFailed(ctx, 9, 503, "unavailable", now)).To(Succeed())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("dead-letters deliveries", func() {
+			mockDB.EXPECT().GetConn(ctx).Return(mockConn, nil)
+			mockConn.ExpectExec(regexp.QuoteMeta("SET status = 'dead'")).WithArgs(int64(9), 410, "gone").
+				WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+			mockConn.ExpectClose()
+
+			Expect(repo.MarkDead(ctx, 9, 410, "gone")).To(Succeed())
+			Expect(mockConn.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns GetConn error", func() {
+			mockDB.EXPECT().GetConn(ctx).Return(nil, expectedErr)
+
+			Expect(repo.MarkDead(ctx, 9, 410, "gone")).To(Equal(expectedErr))
+		})
+	})
+})
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// routes are the handlers newRouter serves.
+type routes struct {
+	resources *handlers.Resource
+	webhooks  *handlers.Webhook
+	audit     *handlers.Audit
+	graph     http.Handler
+	txManager handlers.TxRunner
+	// apiKeys authenticate the requests carrying a bearer token, tokens are not checked when it is nil.
+	apiKeys handlers.APIKeyRepository
+	// tenants are the tenants tenantResolvers may name, requests are not scoped to a tenant when there are no resolvers.
+	tenants         handlers.TenantRepository
+	tenantResolvers []handlers.TenantResolver
+	breaker         *database.Breaker
+	// replicas keeps clients reading from the primary for maxReplicaLag after they write.
+	replicas      bool
+	maxReplicaLag time.Duration
+	// validateResponses replaces responses that do not match openapi.Document by a 500, it is set in tests.
+	validateResponses bool
+}
+
+// newRouter registers the routes of the API, every route has to be described by openapi.Document, which
+// the requests are validated against.
+func newRouter(routes routes) (chi.Router, error) {
+	document := openapi.Document()
+	serveDocument, err := openapi.Handler(document)
+	if err != nil {
+		return nil, err
+	}
+	r := chi.NewRouter()
+	validator := openapi.NewValidator(document, r)
+	validator.Responses = routes.validateResponses
+	// Basic CORS. For more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"https://*", "http://*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: false,
+		MaxAge:           300, // Maximum value not ignored by any of major browsers
+	}))
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(middleware.Heartbeat("/healthz"))
+	r.Use(validator.Validate)
+	// the API responds with JSON, handlers writing anything else set their own Content-Type
+	r.Use(middleware.SetHeader("Content-Type", "application/json"))
+	if routes.replicas {
+		r.Use(handlers.ReadYourWrites(routes.maxReplicaLag))
+	}
+	r.Get("/readyz", handlers.Ready(routes.breaker))
+	r.Get("/openapi.json", serveDocument)
+	r.Get("/docs", openapi.SwaggerUI("/openapi.json"))
+	r.Group(func(r chi.Router) {
+		// the metrics give away the runtime and configuration, without API keys nobody may read them
+		if routes.apiKeys != nil {
+			r.Use(handlers.Authenticate(routes.apiKeys))
+		}
+		r.Use(handlers.RequireAuthenticated)
+		r.Get("/debug/vars", expvar.Handler().ServeHTTP)
+	})
+	r.Group(func(r chi.Router) {
+		// the API keys of a tenant are in its schema, so the tenant is resolved first
+		if len(routes.tenantResolvers) > 0 {
+			r.Use(handlers.ResolveTenant(routes.tenants, routes.tenantResolvers...))
+		}
+		if routes.apiKeys != nil {
+			r.Use(handlers.Authenticate(routes.apiKeys))
+		}
+		r.Route("/resources", func(r chi.Router) {
+			r.Use(handlers.Transactional(routes.txManager))
+			r.Use(routes.audit.Record)
+			r.Get("/", routes.resources.List)
+			r.Post("/", routes.resources.Post)
+			r.Get("/search", routes.resources.Search)
+			r.Post("/{resourceID}:restore", routes.resources.Restore)
+			r.Post("/{resourceID}:revert", routes.resources.Revert)
+			r.Post("/{resourceID}:transition", routes.resources.Transition)
+			r.Post("/{resourceID}:move", routes.resources.Move)
+			r.Route("/{resourceID}", func(r chi.Router) {
+				r.Get("/revisions", routes.resources.Revisions)
+				r.Get("/revisions/diff", routes.resources.DiffRevisions)
+				r.Get("/revisions/{revision}", routes.resources.Revision)
+				r.Group(func(r chi.Router) {
+					r.Use(routes.resources.GetCtx)
+					r.Get("/", routes.resources.Get)
+					r.Put("/", routes.resources.Put)
+					r.Delete("/", routes.resources.Delete)
+					r.Get("/children", routes.resources.Children)
+					r.Get("/ancestors", routes.resources.Ancestors)
+					r.Get("/subtree", routes.resources.Subtree)
+				})
+			})
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", routes.webhooks.List)
+			r.Post("/", routes.webhooks.Post)
+			r.Route("/{webhookID}", func(r chi.Router) {
+				r.Use(routes.webhooks.GetCtx)
+				r.Get("/", routes.webhooks.Get)
+				r.Put("/", routes.webhooks.Put)
+				r.Delete("/", routes.webhooks.Delete)
+				r.Get("/deliveries", routes.webhooks.Deliveries)
+				r.Post("/deliveries/{deliveryID}/redeliver", routes.webhooks.Redeliver)
+			})
+		})
+		r.Get("/audit", routes.audit.List)
+		r.Method(http.MethodGet, "/graphql", routes.graph)
+		r.Method(http.MethodPost, "/graphql", routes.graph)
+	})
+	return r, nil
+}
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticate is the counterpart of handlers.Authenticate: the API key in the bearer token of the
+// authorization metadata becomes the principal of the call. It has to run after ResolveTenant, as the
+// API keys of a tenant are in its schema.
+func Authenticate(keys handlers.APIKeyRepository) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := principalContext(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// AuthenticateStream authenticates streaming calls like Authenticate does unary ones.
+func AuthenticateStream(keys handlers.APIKeyRepository) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, err := principalContext(stream.Context(), keys)
+		if err != nil {
+			return err
+		}
+		return handler(server, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func principalContext(ctx context.Context, keys handlers.APIKeyRepository) (context.Context, error) {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return ctx, nil
+	}
+	secret := strings.TrimPrefix(values[0], "Bearer ")
+	if len(values) > 1 || secret == values[0] || secret == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization is not a bearer token")
+	}
+	if auth.IsJWT(secret) {
+		return ctx, nil
+	}
+	principal, err := keys.Authenticate(ctx, secret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, status.Error(codes.Unauthenticated, "API key is unknown or revoked")
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
@@ -0,0 +1,122 @@
+package rpc_test
+
+import (
+	"context"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/rpc"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Authenticate", func() {
+	var (
+		ctx      context.Context
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		mockKeys *mocks.MockAPIKeyRepository
+		client   resourcepb.ResourceServiceClient
+	)
+	resource := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Some Name", Status: "active"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		mockKeys = mocks.NewMockAPIKeyRepository(mockCtrl)
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(rpc.Authenticate(mockKeys)),
+			grpc.ChainStreamInterceptor(rpc.AuthenticateStream(mockKeys)))
+		rpc.Register(grpcServer, rpc.NewServer(mockRepo, make(changes)))
+		client = resourcepb.NewResourceServiceClient(serve(grpcServer))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	// principal reads the resource and returns the principal the read was made by.
+	principal := func() *string {
+		var principal string
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+		mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).
+			DoAndReturn(func(ctx context.Context, id int) (*entities.Resource, error) {
+				principal = auth.PrincipalFromContext(ctx)
+				return resource, nil
+			})
+		return &principal
+	}
+
+	It("makes the principal of the API key the principal of the call", func() {
+		By("arranging")
+		mockKeys.EXPECT().Authenticate(gomock.Any(), "sgs_secret").Times(1).Return("ops", nil)
+		principal := principal()
+
+		By("acting")
+		_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer sgs_secret"),
+			&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*principal).To(Equal("ops"))
+	})
+
+	It("leaves calls without a token anonymous", func() {
+		By("arranging")
+		mockKeys.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(0)
+		principal := principal()
+
+		By("acting")
+		_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*principal).To(Equal(auth.Anonymous))
+	})
+
+	It("rejects calls with unknown or revoked keys", func() {
+		By("arranging")
+		mockKeys.EXPECT().Authenticate(gomock.Any(), "sgs_revoked").Times(1).Return("", pgx.ErrNoRows)
+
+		By("acting")
+		_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer sgs_revoked"),
+			&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+
+	It("rejects calls whose authorization is not a bearer token", func() {
+		By("arranging")
+		mockKeys.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx, "authorization", "Basic b3BzOnNlY3JldA=="),
+			&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+
+	It("rejects streams with unknown or revoked keys", func() {
+		By("arranging")
+		mockKeys.EXPECT().Authenticate(gomock.Any(), "sgs_revoked").Times(1).Return("", pgx.ErrNoRows)
+
+		By("acting")
+		stream, err := client.WatchResources(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer sgs_revoked"),
+			&resourcepb.WatchResourcesRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = stream.Recv()
+
+		By("asserting")
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+})
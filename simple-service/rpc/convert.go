@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProto(resource entities.Resource) (*resourcepb.Resource, error) {
+	message := &resourcepb.Resource{
+		Id:       resource.PublicID,
+		Name:     resource.Name,
+		Labels:   resource.Labels,
+		Status:   resource.Status,
+		ParentId: resource.ParentPublicID,
+	}
+	if resource.Attributes != nil {
+		attributes, err := structpb.NewStruct(resource.Attributes)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		message.Attributes = attributes
+	}
+	if resource.DeletedAt != nil {
+		message.DeletedAt = timestamppb.New(*resource.DeletedAt)
+	}
+	return message, nil
+}
+
+// fromProto returns the writable fields of message as a valid resource, empty maps are left nil like in JSON.
+func fromProto(message *resourcepb.Resource) (entities.Resource, error) {
+	if message == nil {
+		return entities.Resource{}, status.Error(codes.InvalidArgument, "missing resource")
+	}
+	resource := entities.Resource{PublicID: message.GetId(), Name: message.GetName()}
+	if attributes := message.GetAttributes().AsMap(); len(attributes) > 0 {
+		resource.Attributes = attributes
+	}
+	if len(message.GetLabels()) > 0 {
+		resource.Labels = message.GetLabels()
+	}
+	if err := resource.Validate(); err != nil {
+		return resource, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return resource, nil
+}
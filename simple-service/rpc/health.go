@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Register serves server on grpcServer together with the health and reflection services,
+// the returned health server reports every service as serving until told otherwise.
+func Register(grpcServer *grpc.Server, server *Server) *health.Server {
+	resourcepb.RegisterResourceServiceServer(grpcServer, server)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+	return healthServer
+}
+
+// WatchBreaker is the counterpart of the /readyz check: the health of the server and of the resource service
+// is NOT_SERVING while the database breaker is open. It polls the breaker every interval until ctx is cancelled.
+func WatchBreaker(ctx context.Context, healthServer *health.Server, breaker *database.Breaker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		serving := healthpb.HealthCheckResponse_SERVING
+		if breaker.State() == database.BreakerOpen {
+			serving = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		healthServer.SetServingStatus("", serving)
+		healthServer.SetServingStatus(resourcepb.ResourceService_ServiceDesc.ServiceName, serving)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
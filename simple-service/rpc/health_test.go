@@ -0,0 +1,76 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/rpc"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		ctx        context.Context
+		grpcServer *grpc.Server
+		conn       *grpc.ClientConn
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		grpcServer = grpc.NewServer()
+	})
+
+	It("is not serving while the database breaker is open", func() {
+		By("arranging")
+		healthServer := rpc.Register(grpcServer, rpc.NewServer(mocks.NewMockResourceRepository(gomock.NewController(GinkgoT())), make(changes)))
+		conn = serve(grpcServer)
+		breaker := database.NewBreaker(1, time.Minute)
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go rpc.WatchBreaker(watchCtx, healthServer, breaker, time.Millisecond)
+		check := func() healthpb.HealthCheckResponse_ServingStatus {
+			response, err := healthpb.NewHealthClient(conn).Check(ctx,
+				&healthpb.HealthCheckRequest{Service: resourcepb.ResourceService_ServiceDesc.ServiceName})
+			Expect(err).NotTo(HaveOccurred())
+			return response.GetStatus()
+		}
+		Eventually(check).Should(Equal(healthpb.HealthCheckResponse_SERVING))
+
+		By("acting")
+		breaker.Record(errors.New("connection refused"))
+
+		By("asserting")
+		Eventually(check).Should(Equal(healthpb.HealthCheckResponse_NOT_SERVING))
+	})
+
+	It("lists the services through reflection", func() {
+		By("arranging")
+		rpc.Register(grpcServer, rpc.NewServer(mocks.NewMockResourceRepository(gomock.NewController(GinkgoT())), make(changes)))
+		conn = serve(grpcServer)
+
+		By("acting")
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+		})).To(Succeed())
+		response, err := stream.Recv()
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		var services []string
+		for _, service := range response.GetListServicesResponse().GetService() {
+			services = append(services, service.GetName())
+		}
+		Expect(services).To(ContainElements(resourcepb.ResourceService_ServiceDesc.ServiceName, healthpb.Health_ServiceDesc.ServiceName))
+	})
+})
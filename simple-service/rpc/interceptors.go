@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// mutating are the methods that run in a transaction and are audited, like the mutating HTTP methods.
+var mutating = map[string]bool{
+	resourcepb.ResourceService_CreateResource_FullMethodName: true,
+	resourcepb.ResourceService_UpdateResource_FullMethodName: true,
+	resourcepb.ResourceService_DeleteResource_FullMethodName: true,
+}
+
+// Transactional is the counterpart of handlers.Transactional: every repository call of a mutating RPC
+// joins one transaction, reads lock their rows, and the RPC is replayed on serialization failures.
+func Transactional(runner handlers.TxRunner) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if !mutating[info.FullMethod] {
+			return handler(ctx, request)
+		}
+		var response interface{}
+		err := runner.WithinTxOptions(ctx, database.TxOptions{ForUpdate: true}, func(ctx context.Context) error {
+			var err error
+			response, err = handler(ctx, request)
+			return err
+		})
+		if err != nil {
+			return nil, statusError(err)
+		}
+		return response, nil
+	}
+}
+
+// Audited records every successful mutating RPC like handlers.Audit.Record, under the method "GRPC"
+// and the full method name as the route. It has to run inside Transactional.
+func Audited(repository handlers.AuditRepository) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		response, err := handler(ctx, request)
+		if err != nil || !mutating[info.FullMethod] {
+			return response, err
+		}
+		entry := entities.AuditEntry{
+			Principal: auth.PrincipalFromContext(ctx),
+			Method:    "GRPC",
+			Route:     info.FullMethod,
+		}
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			entry.RemoteAddr = p.Addr.String()
+		}
+		if values := metadata.ValueFromIncomingContext(ctx, "x-request-id"); len(values) > 0 {
+			entry.RequestID = values[0]
+		}
+		if err = repository.Record(ctx, entry); err != nil {
+			return nil, statusError(err)
+		}
+		return response, nil
+	}
+}
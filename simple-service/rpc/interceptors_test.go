@@ -0,0 +1,104 @@
+package rpc_test
+
+import (
+	"context"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/rpc"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Interceptors", func() {
+	var (
+		ctx        context.Context
+		mockCtrl   *gomock.Controller
+		mockRepo   *mocks.MockResourceRepository
+		mockRunner *mocks.MockTxRunner
+		mockAudit  *mocks.MockAuditRepository
+		client     resourcepb.ResourceServiceClient
+	)
+	resource := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Some Name", Status: "active"}
+
+	BeforeEach(func() {
+		ctx = metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "some-request")
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		mockRunner = mocks.NewMockTxRunner(mockCtrl)
+		mockAudit = mocks.NewMockAuditRepository(mockCtrl)
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(rpc.Transactional(mockRunner), rpc.Audited(mockAudit)))
+		rpc.Register(grpcServer, rpc.NewServer(mockRepo, make(changes)))
+		client = resourcepb.NewResourceServiceClient(serve(grpcServer))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	It("runs reads outside of transactions and does not audit them", func() {
+		By("arranging")
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+		mockAudit.EXPECT().Record(gomock.Any(), gomock.Any()).Times(0)
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+		mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(resource, nil)
+
+		By("acting")
+		_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("runs mutations in a transaction and audits them", func() {
+		By("arranging")
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), database.TxOptions{ForUpdate: true}, gomock.Any()).Times(1).
+			DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(resource, nil)
+		var entry entities.AuditEntry
+		mockAudit.EXPECT().Record(gomock.Any(), gomock.Any()).Times(1).
+			DoAndReturn(func(ctx context.Context, recorded entities.AuditEntry) error {
+				entry = recorded
+				return nil
+			})
+
+		By("acting")
+		_, err := client.CreateResource(ctx, &resourcepb.CreateResourceRequest{Resource: &resourcepb.Resource{Name: "Some Name"}})
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entry.Principal).To(Equal(auth.Anonymous))
+		Expect(entry.Method).To(Equal("GRPC"))
+		Expect(entry.Route).To(Equal(resourcepb.ResourceService_CreateResource_FullMethodName))
+		Expect(entry.RequestID).To(Equal("some-request"))
+		Expect(entry.RemoteAddr).NotTo(BeEmpty())
+	})
+
+	It("does not audit failed mutations", func() {
+		By("arranging")
+		mockRunner.EXPECT().WithinTxOptions(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+			DoAndReturn(func(ctx context.Context, options database.TxOptions, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			})
+		mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+		mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(resource, nil)
+		mockRepo.EXPECT().DeleteTree(gomock.Any(), 123, entities.DeleteRestrict).Times(1).Return(entities.ErrHasChildren)
+		mockAudit.EXPECT().Record(gomock.Any(), gomock.Any()).Times(0)
+
+		By("acting")
+		_, err := client.DeleteResource(ctx, &resourcepb.DeleteResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+	})
+})
@@ -0,0 +1,41 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRPC(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RPC Suite")
+}
+
+func publicID(id int) string {
+	return fmt.Sprintf("00000000-0000-7000-8000-%012d", id)
+}
+
+// serve starts grpcServer on an in-memory listener and returns a client connected to it,
+// both are stopped when the spec ends.
+func serve(grpcServer *grpc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	go grpcServer.Serve(listener)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+	})
+	return conn
+}
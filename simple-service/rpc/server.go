@@ -0,0 +1,216 @@
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative resourcepb/resource.proto
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Subscriber is implemented by *database.Listener.
+type Subscriber interface {
+	Subscribe(buffer int) (<-chan database.ChangeEvent, func())
+}
+
+// watchBuffer is the number of changes a watcher may fall behind by, the listener drops changes beyond it.
+const watchBuffer = 256
+
+// Server serves resources over gRPC from the same repository as handlers.Resource.
+type Server struct {
+	resourcepb.UnimplementedResourceServiceServer
+	Repository handlers.ResourceRepository
+	Changes    Subscriber
+	Lifecycle  entities.Lifecycle
+}
+
+func NewServer(repository handlers.ResourceRepository, changes Subscriber) *Server {
+	return &Server{Repository: repository, Changes: changes, Lifecycle: entities.DefaultLifecycle}
+}
+
+// CreateResource creates the resource in the initial status of the Lifecycle, below its parent if it has one.
+func (s *Server) CreateResource(ctx context.Context, request *resourcepb.CreateResourceRequest) (*resourcepb.Resource, error) {
+	resource, err := fromProto(request.GetResource())
+	if err != nil {
+		return nil, err
+	}
+	resource.Status = s.Lifecycle.Initial
+	if parentID := request.GetResource().GetParentId(); parentID != "" {
+		id, err := s.resolve(ctx, parentID)
+		if status.Code(err) == codes.NotFound || status.Code(err) == codes.InvalidArgument {
+			return nil, status.Error(codes.InvalidArgument, entities.ErrParentMissing.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+		resource.ParentID = &id
+	}
+	created, err := s.Repository.Create(ctx, resource)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(*created)
+}
+
+func (s *Server) GetResource(ctx context.Context, request *resourcepb.GetResourceRequest) (*resourcepb.Resource, error) {
+	resource, err := s.read(ctx, request.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toProto(*resource)
+}
+
+func (s *Server) ListResources(ctx context.Context, request *resourcepb.ListResourcesRequest) (*resourcepb.ListResourcesResponse, error) {
+	selector, err := entities.ParseLabelSelector(request.GetLabelSelector())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	all, err := s.Repository.ReadAll(ctx, entities.ListOptions{
+		IncludeDeleted: request.GetIncludeDeleted(),
+		LabelSelector:  selector,
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &resourcepb.ListResourcesResponse{Resources: make([]*resourcepb.Resource, 0, len(all))}
+	for _, resource := range all {
+		message, err := toProto(resource)
+		if err != nil {
+			return nil, err
+		}
+		response.Resources = append(response.Resources, message)
+	}
+	return response, nil
+}
+
+// UpdateResource replaces the name, attributes and labels of the resource and returns it as stored.
+func (s *Server) UpdateResource(ctx context.Context, request *resourcepb.UpdateResourceRequest) (*resourcepb.Resource, error) {
+	resource, err := fromProto(request.GetResource())
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.read(ctx, request.GetResource().GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Repository.Update(ctx, current.ID, resource); err != nil {
+		return nil, statusError(err)
+	}
+	updated, err := s.Repository.Read(ctx, current.ID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProto(*updated)
+}
+
+// DeleteResource soft-deletes the resource, the children policy decides what happens to its children.
+func (s *Server) DeleteResource(ctx context.Context, request *resourcepb.DeleteResourceRequest) (*emptypb.Empty, error) {
+	policy, err := entities.ParseDeletePolicy(request.GetChildren())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	current, err := s.read(ctx, request.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Repository.DeleteTree(ctx, current.ID, policy); err != nil {
+		return nil, statusError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WatchResources sends the committed changes of resources until the client goes away. Created and updated
+// resources are read from the primary as they are when the change arrives, so a burst of changes to one
+// resource may be sent as the same state more than once.
+func (s *Server) WatchResources(_ *resourcepb.WatchResourcesRequest, stream resourcepb.ResourceService_WatchResourcesServer) error {
+	ctx := stream.Context()
+	events, unsubscribe := s.Changes.Subscribe(watchBuffer)
+	defer unsubscribe()
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "resource changes are no longer watched")
+			}
+			if !event.InScope(ctx) {
+				continue
+			}
+			message, err := s.eventToProto(ctx, event)
+			if err != nil {
+				return err
+			}
+			if message == nil {
+				continue
+			}
+			if err = stream.Send(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// eventToProto returns nil for changes to resources that are gone by the time they are read,
+// their deletion follows.
+func (s *Server) eventToProto(ctx context.Context, event database.ChangeEvent) (*resourcepb.ResourceEvent, error) {
+	message := &resourcepb.ResourceEvent{Id: event.PublicID}
+	switch event.Op {
+	case database.ChangeResync:
+		message.Type = resourcepb.ResourceEvent_TYPE_RESYNC
+		return message, nil
+	case database.ChangeDelete:
+		message.Type = resourcepb.ResourceEvent_TYPE_DELETED
+		return message, nil
+	case database.ChangeInsert:
+		message.Type = resourcepb.ResourceEvent_TYPE_CREATED
+	case database.ChangeUpdate:
+		message.Type = resourcepb.ResourceEvent_TYPE_UPDATED
+	default:
+		return nil, nil
+	}
+	resource, err := s.Repository.Read(database.WithPrimary(ctx), event.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if message.Resource, err = toProto(*resource); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// read returns the live resource with publicID.
+func (s *Server) read(ctx context.Context, publicID string) (*entities.Resource, error) {
+	id, err := s.resolve(ctx, publicID)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := s.Repository.Read(ctx, id)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return resource, nil
+}
+
+// resolve returns the internal ID of the resource with publicID, deleted or not.
+func (s *Server) resolve(ctx context.Context, publicID string) (int, error) {
+	if !entities.ValidPublicID(publicID) {
+		return 0, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid resource ID %q", publicID))
+	}
+	id, err := s.Repository.Resolve(ctx, publicID)
+	if err != nil {
+		return 0, statusError(err)
+	}
+	return id, nil
+}
@@ -0,0 +1,323 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/rpc"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// changes stands in for the database.Listener.
+type changes chan database.ChangeEvent
+
+func (c changes) Subscribe(int) (<-chan database.ChangeEvent, func()) {
+	return c, func() {}
+}
+
+var _ = Describe("Server", func() {
+	var (
+		ctx      context.Context
+		mockCtrl *gomock.Controller
+		mockRepo *mocks.MockResourceRepository
+		events   changes
+		client   resourcepb.ResourceServiceClient
+	)
+	parentID := 7
+	resource := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Some Name",
+		Attributes: map[string]interface{}{"size": 3.0}, Labels: map[string]string{"env": "prod"}, Status: "active",
+		ParentID: &parentID, ParentPublicID: publicID(7)}
+	message := &resourcepb.Resource{Id: publicID(123), Name: "Some Name",
+		Attributes: &structpb.Struct{Fields: map[string]*structpb.Value{"size": structpb.NewNumberValue(3)}},
+		Labels:     map[string]string{"env": "prod"}, Status: "active", ParentId: publicID(7)}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		events = make(changes, 8)
+		grpcServer := grpc.NewServer()
+		rpc.Register(grpcServer, rpc.NewServer(mockRepo, events))
+		client = resourcepb.NewResourceServiceClient(serve(grpcServer))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Context("CreateResource", func() {
+		It("creates the resource in the initial status below its parent", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(7)).Times(1).Return(7, nil)
+			mockRepo.EXPECT().Create(gomock.Any(), entities.Resource{Name: "Some Name",
+				Attributes: map[string]interface{}{"size": 3.0}, Labels: map[string]string{"env": "prod"},
+				Status: entities.DefaultLifecycle.Initial, ParentID: &parentID}).Times(1).Return(resource, nil)
+
+			By("acting")
+			created, err := client.CreateResource(ctx, &resourcepb.CreateResourceRequest{Resource: &resourcepb.Resource{
+				Name: "Some Name", Attributes: message.Attributes, Labels: message.Labels, ParentId: publicID(7)}})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(proto.Equal(created, message)).To(BeTrue())
+		})
+
+		It("rejects invalid resources", func() {
+			mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+			_, err := client.CreateResource(ctx, &resourcepb.CreateResourceRequest{Resource: &resourcepb.Resource{
+				Name: "Some Name", Labels: map[string]string{"-invalid": "prod"}}})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+
+		It("rejects missing parents", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(7)).Times(1).Return(0, pgx.ErrNoRows)
+			mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+			By("acting")
+			_, err := client.CreateResource(ctx, &resourcepb.CreateResourceRequest{Resource: &resourcepb.Resource{
+				Name: "Some Name", ParentId: publicID(7)}})
+
+			By("asserting")
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			Expect(status.Convert(err).Message()).To(Equal(entities.ErrParentMissing.Error()))
+		})
+
+		It("reports exceeded quotas", func() {
+			By("arranging")
+			mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(nil, entities.ErrQuotaExceeded)
+
+			By("acting")
+			_, err := client.CreateResource(ctx, &resourcepb.CreateResourceRequest{Resource: &resourcepb.Resource{
+				Name: "Some Name"}})
+
+			By("asserting")
+			Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+		})
+	})
+
+	Context("GetResource", func() {
+		It("returns the resource", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(resource, nil)
+
+			By("acting")
+			got, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(proto.Equal(got, message)).To(BeTrue())
+		})
+
+		It("rejects invalid IDs", func() {
+			mockRepo.EXPECT().Resolve(gomock.Any(), gomock.Any()).Times(0)
+
+			_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: "123"})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+
+		It("is not found when the resource is deleted", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(nil, pgx.ErrNoRows)
+
+			By("acting")
+			_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+			By("asserting")
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("is unavailable with a retry delay while the database breaker is open", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).
+				Return(0, &database.CircuitOpenError{RetryAfter: 3 * time.Second})
+
+			By("acting")
+			_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+			By("asserting")
+			Expect(status.Code(err)).To(Equal(codes.Unavailable))
+			details := status.Convert(err).Details()
+			Expect(details).To(HaveLen(1))
+			Expect(details[0].(*errdetails.RetryInfo).GetRetryDelay().AsDuration()).To(Equal(3 * time.Second))
+		})
+
+		It("is internal on other errors", func() {
+			By("arranging")
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(0, errors.New("some err"))
+
+			By("acting")
+			_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+			By("asserting")
+			Expect(status.Code(err)).To(Equal(codes.Internal))
+		})
+	})
+
+	Context("ListResources", func() {
+		It("lists the resources matching the selector", func() {
+			By("arranging")
+			mockRepo.EXPECT().ReadAll(gomock.Any(), entities.ListOptions{IncludeDeleted: true,
+				LabelSelector: []entities.LabelRequirement{{Key: "env", Operator: entities.SelectorEquals, Values: []string{"prod"}}}}).
+				Times(1).Return([]entities.Resource{*resource}, nil)
+
+			By("acting")
+			list, err := client.ListResources(ctx, &resourcepb.ListResourcesRequest{LabelSelector: "env=prod", IncludeDeleted: true})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.GetResources()).To(HaveLen(1))
+			Expect(proto.Equal(list.GetResources()[0], message)).To(BeTrue())
+		})
+
+		It("rejects invalid selectors", func() {
+			mockRepo.EXPECT().ReadAll(gomock.Any(), gomock.Any()).Times(0)
+
+			_, err := client.ListResources(ctx, &resourcepb.ListResourcesRequest{LabelSelector: "env=prod,"})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+	})
+
+	Context("UpdateResource", func() {
+		It("updates the resource and returns it as stored", func() {
+			By("arranging")
+			updated := *resource
+			updated.Name = "New Name"
+			gomock.InOrder(
+				mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil),
+				mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(resource, nil),
+				mockRepo.EXPECT().Update(gomock.Any(), 123, entities.Resource{PublicID: publicID(123), Name: "New Name"}).
+					Times(1).Return(nil),
+				mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).Return(&updated, nil),
+			)
+
+			By("acting")
+			got, err := client.UpdateResource(ctx, &resourcepb.UpdateResourceRequest{Resource: &resourcepb.Resource{
+				Id: publicID(123), Name: "New Name"}})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.GetName()).To(Equal("New Name"))
+		})
+	})
+
+	Context("DeleteResource", func() {
+		BeforeEach(func() {
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).AnyTimes().Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).AnyTimes().Return(resource, nil)
+		})
+
+		It("deletes the resource with its children", func() {
+			mockRepo.EXPECT().DeleteTree(gomock.Any(), 123, entities.DeleteCascade).Times(1).Return(nil)
+
+			_, err := client.DeleteResource(ctx, &resourcepb.DeleteResourceRequest{Id: publicID(123), Children: "cascade"})
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fails the precondition when the resource has children", func() {
+			mockRepo.EXPECT().DeleteTree(gomock.Any(), 123, entities.DeleteRestrict).Times(1).Return(entities.ErrHasChildren)
+
+			_, err := client.DeleteResource(ctx, &resourcepb.DeleteResourceRequest{Id: publicID(123)})
+
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		})
+
+		It("rejects invalid children policies", func() {
+			mockRepo.EXPECT().DeleteTree(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			_, err := client.DeleteResource(ctx, &resourcepb.DeleteResourceRequest{Id: publicID(123), Children: "adopt"})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+	})
+
+	Context("WatchResources", func() {
+		It("streams the changes of resources", func() {
+			By("arranging")
+			var readsPrimary bool
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).
+				DoAndReturn(func(ctx context.Context, id int) (*entities.Resource, error) {
+					readsPrimary = database.ReadsPrimary(ctx)
+					return resource, nil
+				})
+			mockRepo.EXPECT().Read(gomock.Any(), 124).Times(1).Return(nil, pgx.ErrNoRows)
+			watchCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stream, err := client.WatchResources(watchCtx, &resourcepb.WatchResourcesRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("acting")
+			events <- database.ChangeEvent{Op: database.ChangeInsert, ID: 123, PublicID: publicID(123)}
+			events <- database.ChangeEvent{Op: database.ChangeUpdate, ID: 124, PublicID: publicID(124)}
+			events <- database.ChangeEvent{Op: database.ChangeDelete, ID: 124, PublicID: publicID(124)}
+			events <- database.ChangeEvent{Op: database.ChangeResync}
+
+			By("asserting")
+			received := make([]*resourcepb.ResourceEvent, 0, 3)
+			for len(received) < 3 {
+				event, err := stream.Recv()
+				Expect(err).NotTo(HaveOccurred())
+				received = append(received, event)
+			}
+			Expect(received[0].GetType()).To(Equal(resourcepb.ResourceEvent_TYPE_CREATED))
+			Expect(proto.Equal(received[0].GetResource(), message)).To(BeTrue())
+			Expect(readsPrimary).To(BeTrue())
+			// the update of 124 is skipped, it was deleted before it could be read
+			Expect(received[1].GetType()).To(Equal(resourcepb.ResourceEvent_TYPE_DELETED))
+			Expect(received[1].GetId()).To(Equal(publicID(124)))
+			Expect(received[1].GetResource()).To(BeNil())
+			Expect(received[2].GetType()).To(Equal(resourcepb.ResourceEvent_TYPE_RESYNC))
+		})
+
+		It("leaves out the changes made in the schemas of tenants", func() {
+			By("arranging")
+			watchCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			stream, err := client.WatchResources(watchCtx, &resourcepb.WatchResourcesRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("acting")
+			events <- database.ChangeEvent{Op: database.ChangeDelete, ID: 123, PublicID: publicID(123), Schema: "tenant_acme"}
+			events <- database.ChangeEvent{Op: database.ChangeDelete, ID: 124, PublicID: publicID(124)}
+
+			By("asserting")
+			event, err := stream.Recv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(event.GetId()).To(Equal(publicID(124)))
+		})
+
+		It("ends when the changes are no longer watched", func() {
+			By("arranging")
+			stream, err := client.WatchResources(ctx, &resourcepb.WatchResourcesRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("acting")
+			close(events)
+
+			By("asserting")
+			_, err = stream.Recv()
+			Expect(status.Code(err)).To(Equal(codes.Unavailable))
+		})
+	})
+})
@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/addme96/simple-go-service/simple-service/database"
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// statusError maps the errors of the repository to status codes, like the handlers map them to HTTP statuses.
+// An open database breaker is Unavailable with a RetryInfo detail, the counterpart of Retry-After.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var circuitOpen *database.CircuitOpenError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return status.Error(codes.NotFound, "resource not found")
+	case errors.Is(err, entities.ErrParentMissing):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, entities.ErrHasChildren), errors.Is(err, entities.ErrHierarchyLoop):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, entities.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.As(err, &circuitOpen):
+		unavailable := status.New(codes.Unavailable, err.Error())
+		if detailed, detailErr := unavailable.WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(circuitOpen.RetryAfter),
+		}); detailErr == nil {
+			unavailable = detailed
+		}
+		return unavailable.Err()
+	case database.IsTransient(err):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/auth"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ResolveTenant is the counterpart of handlers.ResolveTenant: every call has to name a registered tenant, found
+// by the resolvers in its metadata and authority as they would in the headers and host of a request, and is then
+// scoped to it. It has to run before Transactional.
+func ResolveTenant(tenants handlers.TenantRepository, resolvers ...handlers.TenantResolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := tenantContext(ctx, tenants, resolvers)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// ResolveStreamTenant scopes streaming calls like ResolveTenant does unary ones.
+func ResolveStreamTenant(tenants handlers.TenantRepository,
+	resolvers ...handlers.TenantResolver) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, err := tenantContext(stream.Context(), tenants, resolvers)
+		if err != nil {
+			return err
+		}
+		return handler(server, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func tenantContext(ctx context.Context, tenants handlers.TenantRepository,
+	resolvers []handlers.TenantResolver) (context.Context, error) {
+	tenant, err := handlers.TenantOf(callRequest(ctx), tenants, resolvers...)
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, handlers.ErrTenantNotReady):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, handlers.ErrNoTenant), errors.Is(err, handlers.ErrTenantsDiffer),
+		errors.Is(err, handlers.ErrUnknownTenant), errors.Is(err, tenancy.ErrInvalidTenant):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, statusError(err)
+	}
+	return tenancy.WithTenant(ctx, tenant), nil
+}
+
+// callRequest stands in for the call to the tenant resolvers, its metadata become headers and its authority the
+// host, pseudo-headers other than the authority are left out.
+func callRequest(ctx context.Context) *http.Request {
+	md, _ := metadata.FromIncomingContext(ctx)
+	request := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/"}, Header: http.Header{}}
+	for key, values := range md {
+		if key == ":authority" && len(values) > 0 {
+			request.Host = values[0]
+			continue
+		}
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		for _, value := range values {
+			request.Header.Add(key, value)
+		}
+	}
+	return request.WithContext(ctx)
+}
+
+// contextStream serves a stream with the context the interceptors derived from its own.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
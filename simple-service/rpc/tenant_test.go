@@ -0,0 +1,132 @@
+package rpc_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/handlers"
+	"github.com/addme96/simple-go-service/simple-service/handlers/mocks"
+	"github.com/addme96/simple-go-service/simple-service/rpc"
+	"github.com/addme96/simple-go-service/simple-service/rpc/resourcepb"
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("ResolveTenant", func() {
+	var (
+		ctx         context.Context
+		mockCtrl    *gomock.Controller
+		mockRepo    *mocks.MockResourceRepository
+		mockTenants *mocks.MockTenantRepository
+		client      resourcepb.ResourceServiceClient
+	)
+	resource := &entities.Resource{ID: 123, PublicID: publicID(123), Name: "Some Name", Status: "active"}
+	key := []byte("secret")
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockResourceRepository(mockCtrl)
+		mockTenants = mocks.NewMockTenantRepository(mockCtrl)
+		resolvers, err := handlers.ParseTenantResolvers("header:X-Tenant-ID,claim:tenant", key)
+		Expect(err).NotTo(HaveOccurred())
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(rpc.ResolveTenant(mockTenants, resolvers...)),
+			grpc.ChainStreamInterceptor(rpc.ResolveStreamTenant(mockTenants, resolvers...)))
+		rpc.Register(grpcServer, rpc.NewServer(mockRepo, make(changes)))
+		client = resourcepb.NewResourceServiceClient(serve(grpcServer))
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	DescribeTable("scopes calls to the tenant they name",
+		func(pairs ...string) {
+			By("arranging")
+			var tenant string
+			mockTenants.EXPECT().Read(gomock.Any(), "acme").Return(&entities.Tenant{ID: "acme", Ready: true}, nil)
+			mockRepo.EXPECT().Resolve(gomock.Any(), publicID(123)).Times(1).Return(123, nil)
+			mockRepo.EXPECT().Read(gomock.Any(), 123).Times(1).
+				DoAndReturn(func(ctx context.Context, id int) (*entities.Resource, error) {
+					tenant, _ = tenancy.TenantFromContext(ctx)
+					return resource, nil
+				})
+
+			By("acting")
+			_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx, pairs...),
+				&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+			By("asserting")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tenant).To(Equal("acme"))
+		},
+		Entry("in their metadata", "x-tenant-id", "acme"),
+		Entry("in a claim", "authorization", "Bearer "+signJWT(key, `{"tenant":"acme","exp":4102444800}`)),
+	)
+
+	It("rejects calls naming no tenant", func() {
+		_, err := client.GetResource(ctx, &resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("rejects calls naming different tenants", func() {
+		_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "other",
+			"authorization", "Bearer "+signJWT(key, `{"tenant":"acme","exp":4102444800}`)),
+			&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("rejects calls naming their tenant in tokens it cannot verify", func() {
+		_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx,
+			"authorization", "Bearer "+signJWT([]byte("other"), `{"tenant":"acme"}`)),
+			&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+
+	It("holds back calls to tenants whose schema is not migrated yet", func() {
+		By("arranging")
+		mockTenants.EXPECT().Read(gomock.Any(), "acme").Return(&entities.Tenant{ID: "acme"}, nil)
+
+		By("acting")
+		_, err := client.GetResource(metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "acme"),
+			&resourcepb.GetResourceRequest{Id: publicID(123)})
+
+		By("asserting")
+		Expect(status.Code(err)).To(Equal(codes.Unavailable))
+	})
+
+	It("rejects streams naming unknown tenants", func() {
+		By("arranging")
+		mockTenants.EXPECT().Read(gomock.Any(), "acme").Return(nil, pgx.ErrNoRows)
+
+		By("acting")
+		stream, err := client.WatchResources(metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "acme"),
+			&resourcepb.WatchResourcesRequest{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = stream.Recv()
+
+		By("asserting")
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+})
+
+func signJWT(key []byte, claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(claims))
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + encode(mac.Sum(nil))
+}
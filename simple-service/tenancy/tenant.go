@@ -0,0 +1,48 @@
+// Package tenancy carries the tenant a request is made for. Every tenant has a Postgres schema of its own,
+// connections made for it find the tables of that schema only.
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// schemaPrefix keeps the schemas of tenants apart from the default schema and from those of Postgres.
+const schemaPrefix = "tenant_"
+
+var ErrInvalidTenant = errors.New("tenant IDs are lowercase letters, digits, - and _, starting with a letter or digit")
+
+// validID keeps schema names below the 63 bytes of Postgres identifiers and lets tenants be named by subdomains.
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant the request is made for, requests without one use the default schema.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+func Validate(tenant string) error {
+	if !validID.MatchString(tenant) {
+		return ErrInvalidTenant
+	}
+	return nil
+}
+
+// Schema returns the name of the schema of tenant, it needs quoting as IDs may contain -.
+func Schema(tenant string) string {
+	return schemaPrefix + tenant
+}
+
+// FromSchema returns the tenant of schema, it reports false for the default schema.
+func FromSchema(schema string) (string, bool) {
+	tenant := strings.TrimPrefix(schema, schemaPrefix)
+	return tenant, tenant != schema && Validate(tenant) == nil
+}
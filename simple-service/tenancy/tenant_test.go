@@ -0,0 +1,46 @@
+package tenancy_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/addme96/simple-go-service/simple-service/tenancy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tenant", func() {
+	It("is carried by the context", func() {
+		ctx := context.Background()
+
+		_, ok := tenancy.TenantFromContext(ctx)
+		tenant, scoped := tenancy.TenantFromContext(tenancy.WithTenant(ctx, "acme"))
+
+		Expect(ok).To(BeFalse())
+		Expect(scoped).To(BeTrue())
+		Expect(tenant).To(Equal("acme"))
+	})
+
+	DescribeTable("validates IDs",
+		func(tenant string, valid bool) {
+			Expect(tenancy.Validate(tenant) == nil).To(Equal(valid))
+		},
+		Entry("letters", "acme", true),
+		Entry("subdomains", "acme-eu1", true),
+		Entry("underscores", "acme_eu", true),
+		Entry("empty", "", false),
+		Entry("uppercase", "Acme", false),
+		Entry("quotes", `acme"; DROP`, false),
+		Entry("leading dash", "-acme", false),
+		Entry("too long", strings.Repeat("a", 41), false),
+	)
+
+	It("maps schemas back to tenants", func() {
+		tenant, ok := tenancy.FromSchema(tenancy.Schema("acme"))
+		_, defaultOK := tenancy.FromSchema("public")
+
+		Expect(ok).To(BeTrue())
+		Expect(tenant).To(Equal("acme"))
+		Expect(defaultOK).To(BeFalse())
+	})
+})
@@ -0,0 +1,44 @@
+package webhooks_test
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/webhooks"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signature", func() {
+	now := time.Unix(1650000000, 0)
+	body := []byte(`{"id":1}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	It("signs timestamp and body with HMAC-SHA256", func() {
+		Expect(webhooks.Sign("secret", now, body)).
+			To(Equal("sha256=62c006dae562371070aeb1007af0008c8a7fe5b9b5d671181e5ed190f92a8119"))
+		Expect(webhooks.Sign("secret", now, body)).NotTo(Equal(webhooks.Sign("other", now, body)))
+		Expect(webhooks.Sign("secret", now, body)).NotTo(Equal(webhooks.Sign("secret", now.Add(time.Second), body)))
+	})
+
+	It("verifies its own signatures", func() {
+		signature := webhooks.Sign("secret", now, body)
+		Expect(webhooks.Verify("secret", timestamp, signature, body, time.Minute, now.Add(30*time.Second))).To(Succeed())
+	})
+
+	It("rejects tampered bodies and wrong secrets", func() {
+		signature := webhooks.Sign("secret", now, body)
+		Expect(webhooks.Verify("secret", timestamp, signature, []byte(`{"id":2}`), time.Minute, now)).
+			To(MatchError(webhooks.ErrInvalidSignature))
+		Expect(webhooks.Verify("other", timestamp, signature, body, time.Minute, now)).
+			To(MatchError(webhooks.ErrInvalidSignature))
+		Expect(webhooks.Verify("secret", "yesterday", signature, body, time.Minute, now)).
+			To(MatchError(webhooks.ErrInvalidSignature))
+	})
+
+	It("rejects replays outside of the tolerance", func() {
+		signature := webhooks.Sign("secret", now, body)
+		Expect(webhooks.Verify("secret", timestamp, signature, body, time.Minute, now.Add(2*time.Minute))).
+			To(MatchError(webhooks.ErrStaleTimestamp))
+	})
+})
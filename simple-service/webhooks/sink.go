@@ -0,0 +1,25 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/addme96/simple-go-service/simple-service/outbox"
+)
+
+type Enqueuer interface {
+	Enqueue(ctx context.Context, eventID int64, eventType string, payload json.RawMessage) error
+}
+
+// Sink fans outbox events out into deliveries for the subscribed webhooks.
+type Sink struct {
+	enqueuer Enqueuer
+}
+
+func NewSink(enqueuer Enqueuer) *Sink {
+	return &Sink{enqueuer: enqueuer}
+}
+
+func (s *Sink) Publish(ctx context.Context, event outbox.Event) error {
+	return s.enqueuer.Enqueue(ctx, event.ID, event.Type, event.Payload)
+}
@@ -0,0 +1,29 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/addme96/simple-go-service/simple-service/outbox"
+	"github.com/addme96/simple-go-service/simple-service/webhooks"
+	"github.com/addme96/simple-go-service/simple-service/webhooks/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sink", func() {
+	It("enqueues deliveries for outbox events", func() {
+		By("arranging")
+		ctx := context.Background()
+		mockEnqueuer := mocks.NewMockEnqueuer(gomock.NewController(GinkgoT()))
+		event := outbox.Event{ID: 42, AggregateID: 7, Type: "resource.deleted", Payload: json.RawMessage(`{"id":7}`)}
+		mockEnqueuer.EXPECT().Enqueue(ctx, int64(42), "resource.deleted", event.Payload).Return(nil)
+
+		By("acting")
+		err := webhooks.NewSink(mockEnqueuer).Publish(ctx, event)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,149 @@
+//go:generate mockgen -destination=mocks/webhooks.go -package mocks . Repository,Enqueuer
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+)
+
+type Repository interface {
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]entities.WebhookDispatch, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+	MarkFailed(ctx context.Context, id int64, statusCode int, lastError string, nextAttemptAt time.Time) error
+	MarkDead(ctx context.Context, id int64, statusCode int, lastError string) error
+}
+
+// Payload is the body POSTed to subscribers.
+type Payload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type Worker struct {
+	repository   Repository
+	client       *http.Client
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	Now          func() time.Time
+}
+
+func NewWorker(repository Repository, client *http.Client) *Worker {
+	return &Worker{
+		repository:   repository,
+		client:       client,
+		BatchSize:    20,
+		PollInterval: time.Second,
+		MaxAttempts:  10,
+		MinBackoff:   10 * time.Second,
+		MaxBackoff:   time.Hour,
+		Now:          time.Now,
+	}
+}
+
+// Run delivers webhooks until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) error {
+	for {
+		n, err := w.DeliverBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Webhook delivery failed: %v\n", err)
+		}
+		if n > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// DeliverBatch attempts every due delivery once and returns how many were attempted.
+func (w *Worker) DeliverBatch(ctx context.Context) (int, error) {
+	dispatches, err := w.repository.ClaimDeliveries(ctx, w.BatchSize, w.lease())
+	if err != nil {
+		return 0, err
+	}
+	for _, dispatch := range dispatches {
+		statusCode, err := w.deliver(ctx, dispatch)
+		delivery := dispatch.Delivery
+		switch {
+		case err == nil:
+			err = w.repository.MarkDelivered(ctx, delivery.ID, statusCode)
+		case delivery.Attempts+1 >= w.MaxAttempts:
+			err = w.repository.MarkDead(ctx, delivery.ID, statusCode, err.Error())
+		default:
+			err = w.repository.MarkFailed(ctx, delivery.ID, statusCode, err.Error(),
+				w.Now().Add(w.backoff(delivery.Attempts)))
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(dispatches), nil
+}
+
+func (w *Worker) deliver(ctx context.Context, dispatch entities.WebhookDispatch) (int, error) {
+	delivery := dispatch.Delivery
+	body, err := json.Marshal(Payload{
+		ID: delivery.EventID, Type: delivery.EventType, CreatedAt: delivery.CreatedAt, Data: delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := w.Now()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, delivery.EventType)
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	request.Header.Set(HeaderSignature, Sign(dispatch.Webhook.Secret, timestamp, body))
+	response, err := w.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("subscriber responded with %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// lease covers a whole batch of requests timing out, so that a claimed delivery
+// is not picked up by another worker while it is still being attempted.
+func (w *Worker) lease() time.Duration {
+	timeout := w.client.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	return time.Duration(w.BatchSize+1) * timeout
+}
+
+func (w *Worker) backoff(attempts int) time.Duration {
+	backoff := w.MinBackoff
+	for i := 0; i < attempts && backoff < w.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > w.MaxBackoff {
+		return w.MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,160 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/addme96/simple-go-service/simple-service/entities"
+	"github.com/addme96/simple-go-service/simple-service/webhooks"
+	"github.com/addme96/simple-go-service/simple-service/webhooks/mocks"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Worker", func() {
+	var (
+		ctrl     *gomock.Controller
+		mockRepo *mocks.MockRepository
+		ctx      context.Context
+		receiver *httptest.Server
+		status   int
+		received chan *http.Request
+		bodies   chan []byte
+		worker   *webhooks.Worker
+	)
+	now := time.Unix(1650000000, 0)
+	createdAt := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		mockRepo = mocks.NewMockRepository(ctrl)
+		ctx = context.Background()
+		status = http.StatusNoContent
+		received = make(chan *http.Request, 1)
+		bodies = make(chan []byte, 1)
+		receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- r
+			bodies <- body
+			w.WriteHeader(status)
+		}))
+		worker = webhooks.NewWorker(mockRepo, receiver.Client())
+		worker.Now = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		receiver.Close()
+	})
+
+	dispatch := func(attempts int) entities.WebhookDispatch {
+		return entities.WebhookDispatch{
+			Delivery: entities.WebhookDelivery{
+				ID: 9, WebhookID: 5, EventID: 42, EventType: "resource.created",
+				Payload: json.RawMessage(`{"id":7,"name":"Resource Name"}`), Attempts: attempts, CreatedAt: createdAt,
+			},
+			Webhook: entities.Webhook{ID: 5, URL: receiver.URL, Secret: "s3cr3t"},
+		}
+	}
+
+	It("delivers signed payloads", func() {
+		By("arranging")
+		mockRepo.EXPECT().ClaimDeliveries(ctx, 20, gomock.Any()).Return([]entities.WebhookDispatch{dispatch(0)}, nil)
+		mockRepo.EXPECT().MarkDelivered(ctx, int64(9), http.StatusNoContent).Return(nil)
+
+		By("acting")
+		n, err := worker.DeliverBatch(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+		var request *http.Request
+		Eventually(received).Should(Receive(&request))
+		body := <-bodies
+		Expect(request.Header.Get(webhooks.HeaderEvent)).To(Equal("resource.created"))
+		Expect(request.Header.Get(webhooks.HeaderDelivery)).To(Equal("9"))
+		Expect(request.Header.Get(webhooks.HeaderTimestamp)).To(Equal("1650000000"))
+		Expect(webhooks.Verify("s3cr3t", request.Header.Get(webhooks.HeaderTimestamp),
+			request.Header.Get(webhooks.HeaderSignature), body, time.Minute, now)).To(Succeed())
+		var payload webhooks.Payload
+		Expect(json.Unmarshal(body, &payload)).To(Succeed())
+		Expect(payload).To(Equal(webhooks.Payload{
+			ID: 42, Type: "resource.created", CreatedAt: createdAt, Data: json.RawMessage(`{"id":7,"name":"Resource Name"}`),
+		}))
+	})
+
+	It("reschedules failed deliveries with exponential backoff", func() {
+		By("arranging")
+		status = http.StatusServiceUnavailable
+		mockRepo.EXPECT().ClaimDeliveries(ctx, 20, gomock.Any()).Return([]entities.WebhookDispatch{dispatch(2)}, nil)
+		mockRepo.EXPECT().MarkFailed(ctx, int64(9), http.StatusServiceUnavailable,
+			"subscriber responded with 503 Service Unavailable", now.Add(40*time.Second)).Return(nil)
+
+		By("acting")
+		n, err := worker.DeliverBatch(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1))
+	})
+
+	It("caps the backoff", func() {
+		By("arranging")
+		status = http.StatusInternalServerError
+		worker.MaxAttempts = 100
+		mockRepo.EXPECT().ClaimDeliveries(ctx, 20, gomock.Any()).Return([]entities.WebhookDispatch{dispatch(50)}, nil)
+		mockRepo.EXPECT().MarkFailed(ctx, int64(9), http.StatusInternalServerError, gomock.Any(), now.Add(time.Hour)).
+			Return(nil)
+
+		By("acting")
+		_, err := worker.DeliverBatch(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("dead-letters deliveries that ran out of attempts", func() {
+		By("arranging")
+		status = http.StatusGone
+		mockRepo.EXPECT().ClaimDeliveries(ctx, 20, gomock.Any()).Return([]entities.WebhookDispatch{dispatch(9)}, nil)
+		mockRepo.EXPECT().MarkDead(ctx, int64(9), http.StatusGone, "subscriber responded with 410 Gone").Return(nil)
+
+		By("acting")
+		_, err := worker.DeliverBatch(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("records unreachable subscribers without a status code", func() {
+		By("arranging")
+		unreachable := dispatch(0)
+		unreachable.Webhook.URL = "http://127.0.0.1:1"
+		mockRepo.EXPECT().ClaimDeliveries(ctx, 20, gomock.Any()).Return([]entities.WebhookDispatch{unreachable}, nil)
+		mockRepo.EXPECT().MarkFailed(ctx, int64(9), 0, gomock.Any(), now.Add(10*time.Second)).Return(nil)
+
+		By("acting")
+		_, err := worker.DeliverBatch(ctx)
+
+		By("asserting")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns repository errors", func() {
+		By("arranging")
+		expectedErr := errors.New("some error")
+		mockRepo.EXPECT().ClaimDeliveries(ctx, 20, gomock.Any()).Return(nil, expectedErr)
+
+		By("acting")
+		n, err := worker.DeliverBatch(ctx)
+
+		By("asserting")
+		Expect(err).To(Equal(expectedErr))
+		Expect(n).To(Equal(0))
+	})
+})